@@ -14,22 +14,81 @@ import (
 func Load() *model.Config {
 	godotenv.Load()
 
+	allowedDomains := strings.Split(getEnvStr("ALLOWED_DOMAINS", "youtube.com,youtu.be,vimeo.com,facebook.com,m.facebook.com,fb.watch,tiktok.com,instagram.com,twitter.com,x.com"), ",")
+
 	return &model.Config{
 		Server: model.ServerConfig{
 			Port:    getEnvInt("SERVER_PORT", 8080),
 			Host:    getEnvStr("SERVER_HOST", "0.0.0.0"),
 			Timeout: getEnvInt("SERVER_TIMEOUT", 300),
+
+			ServeFrontend: getEnvBool("SERVE_FRONTEND", true),
+
+			PublicBaseURL:  strings.TrimRight(getEnvStr("PUBLIC_BASE_URL", ""), "/"),
+			TrustedProxies: parseList(getEnvStr("TRUSTED_PROXIES", "")),
+
+			DownloadsDisabled: getEnvBool("DOWNLOADS_DISABLED", false),
+
+			RouteTimeouts: parseRouteTimeouts(getEnvStr("ROUTE_TIMEOUTS", "/api/video/info=60,/api/video/manifest=60,/api/validate=10")),
 		},
 		Storage: model.StorageConfig{
-			DownloadDir:     getEnvStr("DOWNLOAD_DIR", "./downloads"),
-			MaxVideoSizeMB:  getEnvInt("MAX_VIDEO_SIZE_MB", 300),
-			CleanupInterval: getEnvInt("STORAGE_CLEANUP_INTERVAL", 3600),
-			FileTTLSeconds:  getEnvInt("FILE_TTL_SECONDS", 86400),
+			DownloadDir:         getEnvStr("DOWNLOAD_DIR", "./downloads"),
+			MaxVideoSizeMB:      getEnvInt("MAX_VIDEO_SIZE_MB", 300),
+			MaxFilenameLength:   getEnvInt("MAX_FILENAME_LENGTH", 200),
+			CleanupInterval:     getEnvInt("STORAGE_CLEANUP_INTERVAL", 3600),
+			CleanupMaxRetries:   getEnvInt("CLEANUP_MAX_RETRIES", 5),
+			FileTTLSeconds:      getEnvInt("FILE_TTL_SECONDS", 86400),
+			ExpiredGraceSeconds: getEnvInt("EXPIRED_LINK_GRACE_SECONDS", 3600),
+			TrackingFile:        getEnvStr("STORAGE_TRACKING_FILE", "./data/files.json"),
+
+			FeedWindowSeconds: getEnvInt("FEED_WINDOW_SECONDS", 86400),
+			ShardDirs:         getEnvBool("STORAGE_SHARD_DIRS", false),
+			DateDirs:          getEnvBool("STORAGE_DATE_DIRS", false),
+			DeterministicIDs:  getEnvBool("DETERMINISTIC_DOWNLOAD_IDS", false),
+
+			MaxTotalStoredMB: getEnvInt("MAX_TOTAL_STORED_MB", 0),
+			AllowedMIMETypes: getEnvList("ALLOWED_DOWNLOAD_MIME_TYPES", []string{"video/*", "audio/*", "application/ogg", "text/vtt", "application/octet-stream"}),
+
+			EvictOldestStored: getEnvBool("STORAGE_EVICT_OLDEST", false),
+
+			MinFreeDiskPercent:  getEnvInt("MIN_FREE_DISK_PERCENT", 0),
+			WarnFreeDiskPercent: getEnvInt("WARN_FREE_DISK_PERCENT", 0),
+
+			HashWorkers: getEnvInt("HASH_WORKERS", 0),
+
+			InlineMIMETypes:    getEnvList("INLINE_MIME_TYPES", []string{"text/vtt", "image/*"}),
+			ExtensionMIMETypes: parseExtensionMIMETypes(getEnvStr("EXTENSION_MIME_TYPES", ".webm:video/webm,.mkv:video/x-matroska,.opus:audio/ogg,.m4a:audio/mp4,.ts:video/mp2t")),
+
+			FileNoStore: getEnvBool("FILE_NO_STORE", false),
 		},
 		Python: model.PythonConfig{
 			Port:    getEnvInt("PYTHON_WORKER_PORT", 5000),
 			Host:    getEnvStr("PYTHON_WORKER_HOST", "localhost"),
 			Timeout: getEnvInt("PYTHON_WORKER_TIMEOUT", 60),
+
+			MaxDownloadDuration: getEnvInt("MAX_DOWNLOAD_DURATION", 0),
+			InfoFallbackURLs:    parseList(getEnvStr("INFO_FALLBACK_PROVIDERS", "")),
+
+			MaxRetries:        getEnvInt("WORKER_MAX_RETRIES", 0),
+			RetryBackoffMs:    getEnvInt("WORKER_RETRY_BACKOFF_MS", 500),
+			RetryBudget:       getEnvInt("RETRY_BUDGET_PER_CLIENT", 10),
+			RetryBudgetWindow: getEnvInt("RETRY_BUDGET_WINDOW", 60),
+
+			StripURLTimestamps: getEnvBool("STRIP_URL_TIMESTAMPS", false),
+
+			MaxPlaylistEntries: getEnvInt("MAX_PLAYLIST_ENTRIES", 100),
+
+			MetadataCacheTTL:        getEnvInt("METADATA_CACHE_TTL", 300),
+			MetadataCacheMaxEntries: getEnvInt("METADATA_CACHE_MAX_ENTRIES", 1000),
+
+			KnownInfoTTL:        getEnvInt("KNOWN_INFO_TTL", 600),
+			KnownInfoMaxEntries: getEnvInt("KNOWN_INFO_MAX_ENTRIES", 1000),
+
+			VerifyFormatsWorkers: getEnvInt("VERIFY_FORMATS_WORKERS", 4),
+			VerifyFormatsTimeout: getEnvInt("VERIFY_FORMATS_TIMEOUT", 3),
+
+			InfoConcurrency:     getEnvInt("WORKER_INFO_CONCURRENCY", 0),
+			DownloadConcurrency: getEnvInt("WORKER_DOWNLOAD_CONCURRENCY", 0),
 		},
 		Logging: model.LoggingConfig{
 			Level:        getEnvStr("LOG_LEVEL", "info"),
@@ -37,32 +96,139 @@ func Load() *model.Config {
 			RotationSize: getEnvInt64("LOG_ROTATION_SIZE", 104857600),
 			MaxBackups:   getEnvInt("LOG_MAX_BACKUPS", 3),
 			MaxAge:       getEnvInt("LOG_MAX_AGE", 7),
+
+			AccessLogExcludePaths: strings.Split(getEnvStr("ACCESS_LOG_EXCLUDE_PATHS", "/api/health,/api/health/live"), ","),
 		},
 		Security: model.SecurityConfig{
-			AllowedDomains: strings.Split(getEnvStr("ALLOWED_DOMAINS", "youtube.com,youtu.be,vimeo.com,facebook.com,m.facebook.com,fb.watch,tiktok.com,instagram.com,twitter.com,x.com"), ","),
-			RequestTimeout: getEnvInt("REQUEST_TIMEOUT", 60),
-			RateLimitPerIP: getEnvInt("RATE_LIMIT_PER_IP", 30),
+			AllowedDomains:         allowedDomains,
+			InfoAllowedDomains:     getEnvList("INFO_ALLOWED_DOMAINS", allowedDomains),
+			DownloadAllowedDomains: getEnvList("DOWNLOAD_ALLOWED_DOMAINS", allowedDomains),
+			RequestTimeout:         getEnvInt("REQUEST_TIMEOUT", 60),
+			RateLimitPerIP:         getEnvInt("RATE_LIMIT_PER_IP", 30),
+
+			FileSizeHintMaxAge:       getEnvInt("FILESIZE_HINT_MAX_AGE", 600),
+			FileSizeTolerancePercent: getEnvInt("FILESIZE_TOLERANCE_PERCENT", 10),
+
+			ErrorJitterMinMs: getEnvInt("ERROR_JITTER_MIN_MS", 0),
+			ErrorJitterMaxMs: getEnvInt("ERROR_JITTER_MAX_MS", 0),
+
+			ResolveRedirects:     getEnvBool("RESOLVE_REDIRECTS", false),
+			RedirectResolveHosts: getEnvList("REDIRECT_RESOLVE_HOSTS", []string{"fb.watch", "vm.tiktok.com", "vt.tiktok.com", "t.co"}),
+			RedirectMaxDepth:     getEnvInt("REDIRECT_MAX_DEPTH", 5),
+			RedirectTimeout:      getEnvInt("REDIRECT_TIMEOUT", 5),
+			RequireHTTPSTarget:   getEnvBool("REQUIRE_HTTPS_TARGET", false),
+
+			VideoTokenSecret: getEnvStr("VIDEO_TOKEN_SECRET", ""),
 		},
 		Quota: model.QuotaConfig{
 			Enabled:      getEnvBool("QUOTA_ENABLED", false),
 			DailyLimitMB: getEnvInt64("QUOTA_DAILY_LIMIT_MB", 1000),
 			ResetHour:    getEnvInt("QUOTA_RESET_HOUR", 0),
 			ResetMinute:  getEnvInt("QUOTA_RESET_MINUTE", 0),
+
+			ResetJitterSeconds: getEnvInt("QUOTA_RESET_JITTER_SECONDS", 0),
+			IdleTTLSeconds:     getEnvInt("QUOTA_IDLE_TTL_SECONDS", 86400),
+			MaxEntries:         getEnvInt("QUOTA_MAX_ENTRIES", 100000),
+
+			StorePath:       getEnvStr("QUOTA_STORE_PATH", ""),
+			PersistInterval: getEnvInt("QUOTA_PERSIST_INTERVAL", 60),
 		},
 		RateLimit: model.RateLimitConfig{
-			Enabled:           getEnvBool("RATELIMIT_ENABLED", true),
-			RequestsPerMinute: getEnvInt("RATELIMIT_REQUESTS_PER_MINUTE", 60),
-			BurstSize:         getEnvInt("RATELIMIT_BURST_SIZE", 10),
-			CleanupInterval:   getEnvInt("RATELIMIT_CLEANUP_INTERVAL", 1800),
+			Enabled:             getEnvBool("RATELIMIT_ENABLED", true),
+			RequestsPerMinute:   getEnvInt("RATELIMIT_REQUESTS_PER_MINUTE", 60),
+			BurstSize:           getEnvInt("RATELIMIT_BURST_SIZE", 10),
+			CleanupInterval:     getEnvInt("RATELIMIT_CLEANUP_INTERVAL", 1800),
+			MaxEntries:          getEnvInt("RATELIMIT_MAX_ENTRIES", 100000),
+			MinDownloadInterval: getEnvInt("MIN_DOWNLOAD_INTERVAL", 0),
+			ExemptPaths:         getEnvList("RATELIMIT_EXEMPT_PATHS", []string{"/api/health", "/api/health/live", "/static/", "/"}),
 		},
 		QualityCategories: model.QualityCategoriesConfig{
 			Enabled: parseEnabledQualityCategories(
 				getEnvStr("ENABLED_QUALITY_CATEGORIES", "Audio,FD,SD,HD,FHD"),
 			),
+			MinQuality: parseQualityBound(getEnvStr("MIN_QUALITY", "")),
+			MaxQuality: parseQualityBound(getEnvStr("MAX_QUALITY", "")),
+
+			RejectVideoOnly:   getEnvBool("REJECT_VIDEO_ONLY", false),
+			RejectAudioOnly:   getEnvBool("REJECT_AUDIO_ONLY", false),
+			DefaultQuality:    parseDefaultQuality(getEnvStr("DEFAULT_QUALITY", "")),
+			PreferFreeFormats: getEnvBool("PREFER_FREE_FORMATS", false),
+			FormatFallback:    getEnvBool("FORMAT_FALLBACK", false),
+			Labels:            parseQualityLabels(getEnvStr("QUALITY_LABELS", "")),
+		},
+		ClientLimits: model.ClientLimitsConfig{
+			APIKeyHeader:  getEnvStr("API_KEY_HEADER", "X-API-Key"),
+			ProfilesFile:  getEnvStr("LIMIT_PROFILES_FILE", ""),
+			MaxConcurrent: getEnvInt("MAX_CONCURRENT_DOWNLOADS", 0),
+
+			MaxConcurrentInfo: getEnvInt("MAX_CONCURRENT_INFO", 0),
+
+			MaxProgressStreams:          getEnvInt("MAX_PROGRESS_STREAMS", 1000),
+			MaxProgressStreamsPerClient: getEnvInt("MAX_PROGRESS_STREAMS_PER_CLIENT", 5),
+
+			DuplicateDebounceSeconds: getEnvInt("DUPLICATE_DEBOUNCE_SECONDS", 0),
+		},
+		Batch: model.BatchConfig{
+			MaxItems:        getEnvInt("BATCH_MAX_ITEMS", 20),
+			DeadlineSeconds: getEnvInt("BATCH_DEADLINE_SECONDS", 30),
+			PrefetchWorkers: getEnvInt("BATCH_PREFETCH_WORKERS", 4),
+
+			MaxScheduleAheadSeconds: getEnvInt("SCHEDULE_MAX_AHEAD_SECONDS", 86400),
+			JobTTLSeconds:           getEnvInt("JOB_TTL_SECONDS", 3600),
+			MaxQueuedJobs:           getEnvInt("MAX_QUEUED_JOBS", 10),
+		},
+		Segmented: model.SegmentedDownloadConfig{
+			Enabled:     getEnvBool("SEGMENTED_DOWNLOAD_ENABLED", false),
+			ThresholdMB: getEnvInt("SEGMENTED_DOWNLOAD_THRESHOLD_MB", 50),
+			Segments:    getEnvInt("SEGMENTED_DOWNLOAD_SEGMENTS", 4),
+			Concurrency: getEnvInt("SEGMENTED_DOWNLOAD_CONCURRENCY", 4),
+		},
+		Admin: model.AdminConfig{
+			APIKey:     getEnvStr("ADMIN_API_KEY", ""),
+			CookiesDir: getEnvStr("COOKIES_DIR", "./cookies"),
+
+			DebugRawInfo: getEnvBool("DEBUG_RAW_INFO", false),
+		},
+		Callback: model.CallbackConfig{
+			AllowedDomains: parseList(getEnvStr("CALLBACK_ALLOWED_DOMAINS", "")),
+			Secret:         getEnvStr("CALLBACK_SECRET", ""),
+			Timeout:        getEnvInt("CALLBACK_TIMEOUT", 10),
+		},
+		Streaming: model.StreamingConfig{
+			ManifestPassthrough:  getEnvBool("MANIFEST_PASSTHROUGH_ENABLED", false),
+			ManifestAllowedHosts: strings.Split(getEnvStr("MANIFEST_ALLOWED_HOSTS", "googlevideo.com,vimeocdn.com,akamaized.net,fbcdn.net,tiktokcdn.com,cdninstagram.com,twimg.com"), ","),
+		},
+		Transcode: model.TranscodeConfig{
+			Enabled:         getEnvBool("TRANSCODE_ENABLED", false),
+			Codecs:          getEnvList("TRANSCODE_CODECS", []string{"h264"}),
+			MaxHeight:       getEnvInt("TRANSCODE_MAX_HEIGHT", 1080),
+			MaxBitrateKbps:  getEnvInt("TRANSCODE_MAX_BITRATE_KBPS", 8000),
+			MaxDuration:     getEnvInt("TRANSCODE_MAX_DURATION", 600),
+			MaxSourceSizeMB: getEnvInt("TRANSCODE_MAX_SOURCE_MB", 200),
+		},
+		Tracing: model.TracingConfig{
+			Endpoint:    getEnvStr("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+			ServiceName: getEnvStr("OTEL_SERVICE_NAME", "vidhub-backend"),
+		},
+		Stats: model.StatsConfig{
+			File:            getEnvStr("STATS_FILE", "./data/stats.json"),
+			PersistInterval: getEnvInt("STATS_PERSIST_INTERVAL", 60),
+		},
+		RPC: model.RPCConfig{
+			Enabled: getEnvBool("RPC_ENABLED", false),
+			Host:    getEnvStr("RPC_HOST", "127.0.0.1"),
+			Port:    getEnvInt("RPC_PORT", 8081),
 		},
 	}
 }
 
+// Reload re-reads configuration, letting values in .env override the current environment
+// Used on SIGHUP to pick up runtime switches without a restart
+func Reload() *model.Config {
+	godotenv.Overload()
+	return Load()
+}
+
 // parseEnabledQualityCategories parses comma-separated quality categories from env
 func parseEnabledQualityCategories(categoriesStr string) []string {
 	if categoriesStr == "" {
@@ -96,6 +262,106 @@ func parseEnabledQualityCategories(categoriesStr string) []string {
 	return validCategories
 }
 
+// parseQualityBound validates a MIN_QUALITY/MAX_QUALITY value
+// Only video categories (FD, SD, HD, FHD) can be used as bounds
+func parseQualityBound(quality string) string {
+	quality = strings.ToUpper(strings.TrimSpace(quality))
+	switch quality {
+	case "FD", "SD", "HD", "FHD":
+		return quality
+	default:
+		return ""
+	}
+}
+
+// parseDefaultQuality validates a DEFAULT_QUALITY value
+// Any quality category (Audio, FD, SD, HD, FHD) may be used
+func parseDefaultQuality(quality string) string {
+	if strings.EqualFold(strings.TrimSpace(quality), "Audio") {
+		return "Audio"
+	}
+	return parseQualityBound(quality)
+}
+
+// parseQualityLabels parses "category:label" pairs separated by commas
+// Unknown categories and empty labels are skipped
+func parseQualityLabels(value string) map[string]string {
+	labels := make(map[string]string)
+	for _, item := range parseList(value) {
+		category, label, ok := strings.Cut(item, ":")
+		if !ok {
+			continue
+		}
+		category = parseDefaultQuality(category)
+		label = strings.TrimSpace(label)
+		if category == "" || label == "" {
+			continue
+		}
+		labels[category] = label
+	}
+	return labels
+}
+
+// parseExtensionMIMETypes parses ".ext:type/subtype" pairs separated by commas
+// Extensions are lowercased and get a leading dot if it is missing; malformed pairs are skipped
+func parseExtensionMIMETypes(value string) map[string]string {
+	types := make(map[string]string)
+	for _, item := range parseList(value) {
+		ext, mediaType, ok := strings.Cut(item, ":")
+		if !ok {
+			continue
+		}
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		mediaType = strings.TrimSpace(mediaType)
+		if ext == "" || ext == "." || !strings.Contains(mediaType, "/") {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		types[ext] = mediaType
+	}
+	return types
+}
+
+// parseRouteTimeouts parses "route=seconds" pairs separated by commas
+// Malformed pairs and non-positive timeouts are skipped
+func parseRouteTimeouts(value string) map[string]int {
+	timeouts := make(map[string]int)
+	for _, item := range parseList(value) {
+		route, secondsStr, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		seconds, err := strconv.Atoi(strings.TrimSpace(secondsStr))
+		if err != nil || seconds <= 0 {
+			continue
+		}
+		timeouts[strings.TrimSpace(route)] = seconds
+	}
+	return timeouts
+}
+
+// parseList splits a comma-separated value, dropping empty items
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getEnvList reads a comma-separated list, falling back to defaultVal when unset or empty
+func getEnvList(key string, defaultVal []string) []string {
+	if items := parseList(getEnvStr(key, "")); len(items) > 0 {
+		return items
+	}
+	return defaultVal
+}
+
 func getEnvStr(key, defaultVal string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAllowedDomainLists(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		wantInfo     []string
+		wantDownload []string
+	}{
+		{
+			"both fall back to ALLOWED_DOMAINS",
+			map[string]string{"ALLOWED_DOMAINS": "youtube.com,vimeo.com"},
+			[]string{"youtube.com", "vimeo.com"},
+			[]string{"youtube.com", "vimeo.com"},
+		},
+		{
+			"preview anywhere, download selectively",
+			map[string]string{
+				"ALLOWED_DOMAINS":          "youtube.com",
+				"INFO_ALLOWED_DOMAINS":     "youtube.com, vimeo.com, tiktok.com",
+				"DOWNLOAD_ALLOWED_DOMAINS": "youtube.com",
+			},
+			[]string{"youtube.com", "vimeo.com", "tiktok.com"},
+			[]string{"youtube.com"},
+		},
+		{
+			"only the download list is split",
+			map[string]string{"ALLOWED_DOMAINS": "youtube.com,vimeo.com", "DOWNLOAD_ALLOWED_DOMAINS": "vimeo.com"},
+			[]string{"youtube.com", "vimeo.com"},
+			[]string{"vimeo.com"},
+		},
+		{
+			"empty specific list falls back",
+			map[string]string{"ALLOWED_DOMAINS": "youtube.com", "INFO_ALLOWED_DOMAINS": ""},
+			[]string{"youtube.com"},
+			[]string{"youtube.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("INFO_ALLOWED_DOMAINS", "")
+			t.Setenv("DOWNLOAD_ALLOWED_DOMAINS", "")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			cfg := Load()
+
+			if !reflect.DeepEqual(cfg.Security.InfoAllowedDomains, tt.wantInfo) {
+				t.Errorf("info domains = %q, want %q", cfg.Security.InfoAllowedDomains, tt.wantInfo)
+			}
+			if !reflect.DeepEqual(cfg.Security.DownloadAllowedDomains, tt.wantDownload) {
+				t.Errorf("download domains = %q, want %q", cfg.Security.DownloadAllowedDomains, tt.wantDownload)
+			}
+		})
+	}
+}
+
+func TestParseExtensionMIMETypes(t *testing.T) {
+	got := parseExtensionMIMETypes(" .MKV:video/x-matroska, vid:video/x-custom, broken, .bad:nosubtype, .:video/none")
+	want := map[string]string{".mkv": "video/x-matroska", ".vid": "video/x-custom"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseExtensionMIMETypes = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"videodownload/internal/model"
+	"videodownload/internal/service"
+	"videodownload/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AdminHandler handles admin-only operations
+type AdminHandler struct {
+	cookieService   *service.CookieService
+	downloadSwitch  *service.DownloadSwitch
+	downloadService *service.DownloadService
+	batchService    *service.BatchService
+	jobManager      *service.JobManager
+	lifetimeStats   *service.LifetimeStats
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(cs *service.CookieService, dsw *service.DownloadSwitch, ds *service.DownloadService, bs *service.BatchService, jm *service.JobManager, ls *service.LifetimeStats) *AdminHandler {
+	return &AdminHandler{
+		cookieService:   cs,
+		downloadSwitch:  dsw,
+		downloadService: ds,
+		batchService:    bs,
+		jobManager:      jm,
+		lifetimeStats:   ls,
+	}
+}
+
+// UploadCookies handles POST /api/admin/cookies/:profile
+// Accepts a Netscape cookie jar in the "file" form field; the contents are never echoed back
+func (h *AdminHandler) UploadCookies(c *gin.Context) {
+	profile := c.Param("profile")
+	if !service.ValidProfile(profile) {
+		respondError(c, http.StatusBadRequest, "invalid_profile", "Invalid cookie profile name")
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_request", "Invalid request format")
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_request", "Invalid request format")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_request", "Invalid request format")
+		return
+	}
+
+	count, err := h.cookieService.SaveCookieJar(profile, data)
+	if err != nil {
+		logger.FromContext(c).Warn("Rejected cookie jar upload", zap.String("profile", profile), zap.Error(err))
+		respondError(c, http.StatusBadRequest, "invalid_cookie_jar", "Invalid cookie jar: "+err.Error())
+		return
+	}
+
+	logger.FromContext(c).Info("Audit: cookie jar updated",
+		zap.String("profile", profile),
+		zap.String("ip", c.ClientIP()),
+		zap.Int("cookies", count),
+		zap.Int("size_bytes", len(data)))
+
+	c.JSON(http.StatusOK, model.CookieUploadResponse{
+		Profile:   profile,
+		Cookies:   count,
+		UpdatedAt: time.Now().Unix(),
+	})
+}
+
+// GetDownloadSwitch handles GET /api/admin/downloads
+func (h *AdminHandler) GetDownloadSwitch(c *gin.Context) {
+	c.JSON(http.StatusOK, model.DownloadSwitchResponse{
+		DownloadsDisabled: h.downloadSwitch.IsDisabled(),
+	})
+}
+
+// SetDownloadSwitch handles PUT /api/admin/downloads
+// Body: {"disabled": true} stops new downloads, {"disabled": false} resumes them
+func (h *AdminHandler) SetDownloadSwitch(c *gin.Context) {
+	var req model.DownloadSwitchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, "invalid_request", "Invalid request format")
+		return
+	}
+
+	h.downloadSwitch.SetDisabled(*req.Disabled, "admin")
+	logger.FromContext(c).Info("Audit: download kill switch set",
+		zap.Bool("downloads_disabled", *req.Disabled),
+		zap.String("ip", c.ClientIP()))
+
+	c.JSON(http.StatusOK, model.DownloadSwitchResponse{
+		DownloadsDisabled: h.downloadSwitch.IsDisabled(),
+	})
+}
+
+// CancelAllForIP handles POST /api/admin/downloads/cancel-all?ip=
+// Cancels every queued, running or scheduled download of a client identified by IP
+func (h *AdminHandler) CancelAllForIP(c *gin.Context) {
+	ip := net.ParseIP(c.Query("ip"))
+	if ip == nil {
+		respondError(c, http.StatusBadRequest, "invalid_request", "Invalid request format")
+		return
+	}
+
+	// Clients without an API key are keyed by IP
+	clientKey := ip.String()
+	cancelled := h.downloadService.CancelAll(clientKey) + h.batchService.CancelScheduled(clientKey) + h.jobManager.CancelScheduled(clientKey)
+	logger.FromContext(c).Info("Audit: downloads cancelled for client",
+		zap.String("target_ip", clientKey),
+		zap.Int("cancelled", cancelled),
+		zap.String("ip", c.ClientIP()))
+
+	c.JSON(http.StatusOK, model.CancelAllResponse{Cancelled: cancelled})
+}
+
+// GetStats handles GET /api/admin/stats
+// Reports the lifetime download counters
+func (h *AdminHandler) GetStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.lifetimeStats.Snapshot())
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"videodownload/internal/model"
+	"videodownload/pkg/middleware"
+)
+
+func TestDownloadKillSwitch(t *testing.T) {
+	t.Setenv("ADMIN_API_KEY", "admin-secret")
+	s := newTestServer(t, serveTestWorker)
+	admin := http.Header{middleware.AdminKeyHeader: {"admin-secret"}}
+
+	// A file downloaded before the switch is flipped stays servable
+	job := s.waitForJob(t, s.startDownload(t, testDownload, nil).JobID, nil)
+	if job.Status != model.JobDone {
+		t.Fatalf("job status = %s (%+v), want done", job.Status, job.Error)
+	}
+
+	if w := s.do(http.MethodPut, "/api/admin/downloads", map[string]bool{"disabled": true}, nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("PUT without the admin key = %d, want 401", w.Code)
+	}
+	w := s.do(http.MethodPut, "/api/admin/downloads", map[string]bool{"disabled": true}, admin)
+	var state model.DownloadSwitchResponse
+	json.Unmarshal(w.Body.Bytes(), &state)
+	if w.Code != http.StatusOK || !state.DownloadsDisabled {
+		t.Fatalf("PUT disabled = %d %s, want 200 with downloads_disabled", w.Code, w.Body)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       any
+		wantStatus int
+	}{
+		{"download is refused", http.MethodPost, "/api/download", testDownload, http.StatusServiceUnavailable},
+		{"batch is refused", http.MethodPost, "/api/download/batch", model.BatchDownloadRequest{Items: []model.DownloadRequest{testDownload}}, http.StatusServiceUnavailable},
+		{"info still works", http.MethodGet, "/api/video/info?url=" + url.QueryEscape(testDownload.URL), nil, http.StatusOK},
+		{"stored file is still served", http.MethodGet, "/api/download/" + job.Download.ID, nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(tt.method, tt.path, tt.body, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("%s %s = %d %s, want %d", tt.method, tt.path, w.Code, w.Body, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusServiceUnavailable {
+				var body model.ErrorResponse
+				json.Unmarshal(w.Body.Bytes(), &body)
+				if body.Error != "downloads_disabled" {
+					t.Errorf("error = %q, want downloads_disabled", body.Error)
+				}
+			}
+		})
+	}
+
+	// Switching back resumes downloads
+	s.do(http.MethodPut, "/api/admin/downloads", map[string]bool{"disabled": false}, admin)
+	s.waitForJob(t, s.startDownload(t, testDownload, nil).JobID, nil)
+}
+
+func TestDownloadsDisabledAtStartup(t *testing.T) {
+	t.Setenv("DOWNLOADS_DISABLED", "true")
+	s := newTestServer(t, serveTestWorker)
+
+	if w := s.do(http.MethodPost, "/api/download", testDownload, nil); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("POST /api/download = %d, want 503", w.Code)
+	}
+	if w := s.do(http.MethodGet, "/api/video/info?url="+url.QueryEscape(testDownload.URL), nil, nil); w.Code != http.StatusOK {
+		t.Errorf("GET /api/video/info = %d %s, want 200", w.Code, w.Body)
+	}
+}
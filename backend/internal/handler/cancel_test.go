@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"videodownload/internal/model"
+	"videodownload/pkg/middleware"
+)
+
+func TestCancelAllDownloads(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		header http.Header
+	}{
+		{"caller", "/api/download/cancel-all", nil},
+		{"admin by IP", "/api/admin/downloads/cancel-all?ip=192.0.2.1", http.Header{middleware.AdminKeyHeader: {"admin-secret"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ADMIN_API_KEY", "admin-secret")
+			t.Setenv("TRUSTED_PROXIES", "192.0.2.1")
+
+			// Downloads send half of the file, then hang until released or cancelled
+			var inFlight atomic.Int32
+			release := make(chan struct{})
+			s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/api/info" {
+					serveTestWorker(w, r)
+					return
+				}
+				inFlight.Add(1)
+				defer inFlight.Add(-1)
+				w.Header().Set("Content-Type", "video/mp4")
+				w.Write(testMedia[:len(testMedia)/2])
+				w.(http.Flusher).Flush()
+				select {
+				case <-release:
+					w.Write(testMedia[len(testMedia)/2:])
+				case <-r.Context().Done():
+				}
+			})
+			t.Cleanup(func() { close(release) })
+
+			// The caller has two running jobs and a scheduled one; another client has one running job
+			other := http.Header{"X-Forwarded-For": {"203.0.113.9"}}
+			running := []*model.DownloadJob{s.startDownload(t, testDownload, nil), s.startDownload(t, testDownload, nil)}
+			scheduled := s.startDownload(t, scheduledDownload(time.Hour), nil)
+			otherJob := s.startDownload(t, testDownload, other)
+			deadline := time.Now().Add(5 * time.Second)
+			for inFlight.Load() < 3 && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			w := s.do(http.MethodPost, tt.path, nil, tt.header)
+			var result model.CancelAllResponse
+			json.Unmarshal(w.Body.Bytes(), &result)
+			if w.Code != http.StatusOK || result.Cancelled != 3 {
+				t.Fatalf("cancel-all = %d %s, want 200 with 3 cancelled", w.Code, w.Body)
+			}
+
+			for _, job := range append(running, scheduled) {
+				done := s.waitForJob(t, job.JobID, nil)
+				if done.Status != model.JobFailed || done.Error == nil || done.Error.Error != "download_cancelled" {
+					t.Errorf("job %s = %s %+v, want failed with download_cancelled", job.JobID, done.Status, done.Error)
+				}
+			}
+
+			// Only the other client's download is left running, and it still completes. The
+			// cancelled worker calls must be gone first, or one of them could take the release
+			deadline = time.Now().Add(5 * time.Second)
+			for inFlight.Load() > 1 && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if n := inFlight.Load(); n != 1 {
+				t.Fatalf("%d worker downloads in flight after cancelling, want only the other client's", n)
+			}
+			release <- struct{}{}
+			if done := s.waitForJob(t, otherJob.JobID, other); done.Status != model.JobDone {
+				t.Errorf("other client's job = %s %+v, want done", done.Status, done.Error)
+			}
+
+			// Cancelled downloads leave no partial files behind
+			entries, _ := os.ReadDir(s.cfg.Storage.DownloadDir)
+			for _, entry := range entries {
+				if strings.HasPrefix(entry.Name(), ".partial-") {
+					t.Errorf("partial file %s left behind", entry.Name())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"videodownload/internal/model"
+)
+
+// testProfiles gives two API keys different concurrent download caps
+const testProfiles = `{
+	"profiles": {
+		"free": {"daily_limit_mb": 1000, "requests_per_minute": 100, "max_concurrent": 1},
+		"paid": {"daily_limit_mb": 5000, "requests_per_minute": 100, "max_concurrent": 2}
+	},
+	"keys": {"free-key": "free", "paid-key": "paid"}
+}`
+
+func TestConcurrencyPerTier(t *testing.T) {
+	profilesFile := filepath.Join(t.TempDir(), "profiles.json")
+	if err := os.WriteFile(profilesFile, []byte(testProfiles), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LIMIT_PROFILES_FILE", profilesFile)
+
+	// Downloads hang until released, so every accepted job stays running
+	var inFlight atomic.Int32
+	release := make(chan struct{})
+	s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/info" {
+			inFlight.Add(1)
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			defer inFlight.Add(-1)
+		}
+		serveTestWorker(w, r)
+	})
+	released := false
+	t.Cleanup(func() {
+		if !released {
+			close(release)
+		}
+	})
+
+	free := http.Header{"X-Api-Key": {"free-key"}}
+	paid := http.Header{"X-Api-Key": {"paid-key"}}
+	var jobs []*model.DownloadJob
+	jobs = append(jobs, s.startDownload(t, testDownload, free))
+	jobs = append(jobs, s.startDownload(t, testDownload, paid))
+	jobs = append(jobs, s.startDownload(t, testDownload, paid))
+
+	tests := []struct {
+		name        string
+		header      http.Header
+		wantMessage string
+	}{
+		{"free tier past 1", free, "the free tier allows 1"},
+		{"paid tier past 2", paid, "the paid tier allows 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(http.MethodPost, "/api/download", testDownload, tt.header)
+			var body model.ErrorResponse
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if w.Code != http.StatusTooManyRequests || body.Error != "concurrency_limit" {
+				t.Fatalf("POST = %d %s, want 429 concurrency_limit", w.Code, w.Body)
+			}
+			if !strings.Contains(body.Message, tt.wantMessage) {
+				t.Errorf("message %q does not mention %q", body.Message, tt.wantMessage)
+			}
+		})
+	}
+
+	// Both tiers' accepted downloads run at the same time
+	deadline := time.Now().Add(5 * time.Second)
+	for inFlight.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := inFlight.Load(); got != 3 {
+		t.Fatalf("%d downloads in flight, want 3", got)
+	}
+
+	released = true
+	close(release)
+	headers := []http.Header{free, paid, paid}
+	for i, job := range jobs {
+		if done := s.waitForJob(t, job.JobID, headers[i]); done.Status != model.JobDone {
+			t.Errorf("job %d = %s (%+v), want done", i, done.Status, done.Error)
+		}
+	}
+
+	// Finished downloads free their slots
+	s.waitForJob(t, s.startDownload(t, testDownload, free).JobID, free)
+}
+
+func TestInfoProceedsWhileDownloadPoolIsFull(t *testing.T) {
+	t.Setenv("WORKER_DOWNLOAD_CONCURRENCY", "1")
+	t.Setenv("WORKER_INFO_CONCURRENCY", "1")
+
+	// Downloads hang until released, holding the only download slot
+	var inFlight, infoCalls atomic.Int32
+	release := make(chan struct{})
+	s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/info" {
+			infoCalls.Add(1)
+		} else {
+			inFlight.Add(1)
+			defer inFlight.Add(-1)
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}
+		serveTestWorker(w, r)
+	})
+	released := false
+	t.Cleanup(func() {
+		if !released {
+			close(release)
+		}
+	})
+
+	jobs := []*model.DownloadJob{s.startDownload(t, testDownload, nil), s.startDownload(t, testDownload, nil)}
+	deadline := time.Now().Add(5 * time.Second)
+	for inFlight.Load() < 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := inFlight.Load(); n != 1 {
+		t.Fatalf("%d downloads reached the worker, want the pool's 1", n)
+	}
+
+	// A video not fetched yet, so the info call has to reach the worker
+	before := infoCalls.Load()
+	done := make(chan int, 1)
+	go func() {
+		w := s.do(http.MethodGet, "/api/video/info?url="+url.QueryEscape("https://www.youtube.com/watch?v=other"), nil, nil)
+		done <- w.Code
+	}()
+	select {
+	case code := <-done:
+		if code != http.StatusOK {
+			t.Errorf("GET /api/video/info = %d, want 200", code)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("info request blocked behind the saturated download pool")
+	}
+	if infoCalls.Load() == before {
+		t.Error("info was not fetched from the worker")
+	}
+
+	close(release)
+	released = true
+	for i, job := range jobs {
+		if done := s.waitForJob(t, job.JobID, nil); done.Status != model.JobDone {
+			t.Errorf("job %d = %s %+v, want done", i, done.Status, done.Error)
+		}
+	}
+}
@@ -1,15 +1,22 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"videodownload/internal/model"
 	"videodownload/internal/service"
+	"videodownload/internal/storage"
+	"videodownload/pkg/i18n"
 	"videodownload/pkg/logger"
+	"videodownload/pkg/middleware"
+	"videodownload/pkg/tracing"
 	"videodownload/pkg/validator"
 
 	"github.com/gin-gonic/gin"
@@ -18,146 +25,492 @@ import (
 
 // DownloadHandler handles download-related requests
 type DownloadHandler struct {
-	downloadService  *service.DownloadService
-	quotaService     *service.QuotaService
-	rateLimitService *service.RateLimitService
-	cfg              *model.Config
+	downloadService   *service.DownloadService
+	videoService      *service.VideoService
+	batchService      *service.BatchService
+	jobManager        *service.JobManager
+	quotaService      *service.QuotaService
+	rateLimitService  *service.RateLimitService
+	downloadSwitch    *service.DownloadSwitch
+	downloadValidator *service.DownloadValidator
+	progressTracker   *service.ProgressTracker
+	cfg               *model.Config
 }
 
 // NewDownloadHandler creates a new download handler
-func NewDownloadHandler(ds *service.DownloadService, cfg *model.Config, qs *service.QuotaService, rls *service.RateLimitService) *DownloadHandler {
+func NewDownloadHandler(ds *service.DownloadService, vs *service.VideoService, bs *service.BatchService, jm *service.JobManager, cfg *model.Config, qs *service.QuotaService, rls *service.RateLimitService, dsw *service.DownloadSwitch, dv *service.DownloadValidator) *DownloadHandler {
 	return &DownloadHandler{
-		downloadService:  ds,
-		quotaService:     qs,
-		rateLimitService: rls,
-		cfg:              cfg,
+		downloadService:   ds,
+		videoService:      vs,
+		batchService:      bs,
+		jobManager:        jm,
+		quotaService:      qs,
+		rateLimitService:  rls,
+		downloadSwitch:    dsw,
+		downloadValidator: dv,
+		progressTracker:   service.NewProgressTracker(cfg.ClientLimits.MaxProgressStreams, cfg.ClientLimits.MaxProgressStreamsPerClient),
+		cfg:               cfg,
 	}
 }
 
 // StartDownload handles POST /api/download
 func (h *DownloadHandler) StartDownload(c *gin.Context) {
+	if !h.checkDownloadsEnabled(c) || !h.checkDiskSpace(c) {
+		return
+	}
+
 	var req model.DownloadRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		logger.Logger.Warn("Invalid download request", zap.Error(err))
-		c.JSON(http.StatusBadRequest, model.ErrorResponse{
-			Error:   "invalid_request",
-			Message: "Invalid request format",
-			Code:    http.StatusBadRequest,
-		})
-		return
-	}
-
-	// Validate URL
-	if !validator.ValidateURL(req.URL, h.cfg.Security.AllowedDomains) {
-		logger.Logger.Warn("Invalid URL domain", zap.String("url", req.URL))
-		c.JSON(http.StatusBadRequest, model.ErrorResponse{
-			Error:   "invalid_domain",
-			Message: "URL domain is not allowed",
-			Code:    http.StatusBadRequest,
-		})
-		return
-	}
-
-	// Validate format ID
-	if !validator.ValidateFormatID(req.FormatID) {
-		logger.Logger.Warn("Invalid format ID", zap.String("format_id", req.FormatID))
-		c.JSON(http.StatusBadRequest, model.ErrorResponse{
-			Error:   "invalid_format",
-			Message: "Invalid format ID",
-			Code:    http.StatusBadRequest,
-		})
-		return
-	}
-
-	// ✅ Check server configuration: QUOTA_DAILY_LIMIT_MB must be >= MAX_VIDEO_SIZE_MB
-	// If daily quota is less than max file size, users can't download files successfully
-	if h.cfg.Quota.Enabled && h.cfg.Quota.DailyLimitMB < int64(h.cfg.Storage.MaxVideoSizeMB) {
-		logger.Logger.Error("Server configuration error: daily quota limit is less than max video size",
-			zap.Int64("daily_limit_mb", h.cfg.Quota.DailyLimitMB),
-			zap.Int64("max_video_size_mb", int64(h.cfg.Storage.MaxVideoSizeMB)))
-		c.JSON(http.StatusServiceUnavailable, model.ErrorResponse{
-			Error:   "quota_limit",
-			Message: "Server is currently under maintenance. Please try again later.",
-			Code:    http.StatusServiceUnavailable,
-		})
-		return
-	}
-
-	// ✅ Validate file size BEFORE starting download
-	// This prevents worker from processing oversized files
-	if req.FileSize > 0 {
-		maxSizeBytes := int64(h.cfg.Storage.MaxVideoSizeMB) * 1024 * 1024
-		if req.FileSize > maxSizeBytes {
-			maxSizeMB := h.cfg.Storage.MaxVideoSizeMB
-			fileSizeMB := req.FileSize / (1024 * 1024)
-			logger.Logger.Warn("File size exceeds limit",
-				zap.Int64("file_size", req.FileSize),
-				zap.Int64("max_size", maxSizeBytes),
-				zap.String("ip", c.ClientIP()))
-			c.JSON(http.StatusRequestEntityTooLarge, model.ErrorResponse{
-				Error:   "file_too_large",
-				Message: fmt.Sprintf("File size exceeds maximum limit of %dMB. Requested size: %dMB.", maxSizeMB, fileSizeMB),
-				Code:    http.StatusRequestEntityTooLarge,
-			})
+		logger.FromContext(c).Warn("Invalid download request", zap.Error(err))
+		respondError(c, http.StatusBadRequest, "invalid_request", "Invalid request format")
+		return
+	}
+
+	// Quota availability was already enforced by QuotaCheckMiddleware
+	profile := h.limitProfile(c)
+	if rejection := h.downloadValidator.Validate(c.Request.Context(), logger.FromContext(c), &req, profile); rejection != nil {
+		respondError(c, rejection.Code, rejection.Error, rejection.Message)
+		return
+	}
+
+	clientIP := middleware.GetClientKey(c)
+
+	// Downloads run in the background, or from start_at on; the job is polled at /api/download/status/:jobid
+	job, rejection := h.jobManager.Start(req, clientIP, profile)
+	if rejection != nil {
+		respondError(c, rejection.Code, rejection.Error, rejection.Message)
+		return
+	}
+	middleware.SetQuotaHeaders(c, h.quotaService.GetQuotaInfoWithLimit(clientIP, profile.DailyLimitMB))
+	c.JSON(http.StatusAccepted, publicJob(c, &h.cfg.Server, job))
+}
+
+// StartBatchDownload handles POST /api/download/batch
+// Returns finished items within the batch deadline and reports the rest as pending
+func (h *DownloadHandler) StartBatchDownload(c *gin.Context) {
+	if !h.checkDownloadsEnabled(c) || !h.checkDiskSpace(c) {
+		return
+	}
+
+	var req model.BatchDownloadRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Items) == 0 {
+		logger.FromContext(c).Warn("Invalid batch download request", zap.Error(err))
+		respondError(c, http.StatusBadRequest, "invalid_request", "Invalid request format")
+		return
+	}
+
+	if len(req.Items) > h.cfg.Batch.MaxItems {
+		respondError(c, http.StatusBadRequest, "batch_too_large", fmt.Sprintf("A batch may contain at most %d items", h.cfg.Batch.MaxItems))
+		return
+	}
+
+	profile := h.limitProfile(c)
+	clientIP := middleware.GetClientKey(c)
+	deadline := time.Now().Add(time.Duration(h.cfg.Batch.DeadlineSeconds) * time.Second)
+
+	// Prefetched info feeds the known-format checks of the download validator
+	prefetched := h.prefetchBatchInfo(c.Request.Context(), req.Items, deadline)
+
+	rejected := make(map[int]*model.ErrorResponse)
+	h.validateBatchItems(c, req.Items, prefetched, profile, rejected)
+
+	c.JSON(http.StatusOK, publicBatch(c, &h.cfg.Server, h.batchService.RunBatch(req.Items, rejected, clientIP, profile, deadline)))
+}
+
+// validateBatchItems records the rejection of every batch item that fails validation in rejected
+// Items already listed in rejected are skipped
+func (h *DownloadHandler) validateBatchItems(c *gin.Context, items []model.DownloadRequest, prefetched map[string]*service.PrefetchResult, profile *model.LimitProfile, rejected map[int]*model.ErrorResponse) {
+	for i := range items {
+		if _, ok := rejected[i]; ok {
+			continue
+		}
+		if rejection := h.downloadValidator.Validate(c.Request.Context(), logger.FromContext(c), &items[i], profile); rejection != nil {
+			rejected[i] = rejection
+		} else if rejection := h.checkPrefetchedItem(c, &items[i], prefetched[items[i].URL], profile); rejection != nil {
+			rejected[i] = rejection
+		}
+	}
+}
+
+// StartPlaylistDownload handles POST /api/download/playlist
+// Every video of the playlist, up to BATCH_MAX_ITEMS, is downloaded as a batch item in the
+// requested quality. Answers 200 while at least one item is done or still running, and
+// 422 playlist_failed with the same per-item results when every item failed
+func (h *DownloadHandler) StartPlaylistDownload(c *gin.Context) {
+	if !h.checkDownloadsEnabled(c) || !h.checkDiskSpace(c) {
+		return
+	}
+
+	var req model.PlaylistDownloadRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil || req.URL == "" {
+		logger.FromContext(c).Warn("Invalid playlist download request", zap.Error(err))
+		respondError(c, http.StatusBadRequest, "invalid_request", "Invalid request format")
+		return
+	}
+
+	quality := service.QualityCategory(req.Quality, h.cfg.QualityCategories.Labels)
+	if quality == "" {
+		quality = h.cfg.QualityCategories.DefaultQuality
+	}
+	if quality == "" {
+		respondError(c, http.StatusBadRequest, "quality_required", "A quality is required to download a playlist")
+		return
+	}
+
+	playlistURL, err := h.videoService.ResolveShortURL(c.Request.Context(), req.URL)
+	if err != nil {
+		logger.FromContext(c).Warn("Failed to resolve short URL", zap.String("url", req.URL), zap.Error(err))
+		respondError(c, http.StatusBadRequest, "unresolvable_url", "URL redirects could not be followed")
+		return
+	}
+	if _, reason := validator.CheckURL(playlistURL, h.cfg.Security.DownloadAllowedDomains, h.cfg.Security.RequireHTTPSTarget); reason == validator.URLInsecure {
+		respondError(c, http.StatusBadRequest, "insecure_url", "Only https URLs are allowed")
+		return
+	} else if reason != "" {
+		logger.FromContext(c).Warn("Invalid URL domain", zap.String("url", playlistURL))
+		respondError(c, http.StatusBadRequest, "invalid_domain", "URL domain is not allowed")
+		return
+	}
+
+	profile := h.limitProfile(c)
+	clientIP := middleware.GetClientKey(c)
+	deadline := time.Now().Add(time.Duration(h.cfg.Batch.DeadlineSeconds) * time.Second)
+
+	playlist, err := h.videoService.GetPlaylistInfo(c.Request.Context(), playlistURL, false)
+	if errors.Is(err, service.ErrNotPlaylist) {
+		respondError(c, http.StatusBadRequest, "not_playlist", "URL is not a playlist")
+		return
+	}
+	if err != nil {
+		logger.FromContext(c).Error("Failed to get playlist info", zap.Error(err), zap.String("url", playlistURL))
+		if respondIfTimedOut(c) {
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "fetch_failed", "Failed to fetch video information")
+		return
+	}
+
+	entries := playlist.Entries
+	if len(entries) > h.cfg.Batch.MaxItems {
+		entries = entries[:h.cfg.Batch.MaxItems]
+	}
+
+	// The playlist info already lists each video's formats, so it stands in for the batch prefetch
+	items := make([]model.DownloadRequest, len(entries))
+	prefetched := make(map[string]*service.PrefetchResult, len(entries))
+	rejected := make(map[int]*model.ErrorResponse)
+	for i := range entries {
+		items[i] = model.DownloadRequest{URL: entries[i].URL, Quality: quality}
+		if entries[i].URL == "" {
+			continue
+		}
+		prefetched[entries[i].URL] = &service.PrefetchResult{Info: &entries[i]}
+
+		format, err := h.videoService.BestFormatForQuality(&entries[i], quality)
+		if err != nil {
+			rejected[i] = rejection(http.StatusBadRequest, "quality_unavailable", fmt.Sprintf("No %s format is available for this video", quality))
+			continue
+		}
+		items[i].FormatID = format.FormatID
+		items[i].FileSize = format.FileSize
+	}
+	h.validateBatchItems(c, items, prefetched, profile, rejected)
+
+	resp := &model.PlaylistDownloadResponse{
+		BatchResponse: publicBatch(c, &h.cfg.Server, h.batchService.RunBatch(items, rejected, clientIP, profile, deadline)),
+		Title:         playlist.Title,
+		EntryCount:    playlist.EntryCount,
+		Truncated:     playlist.Truncated || len(entries) < len(playlist.Entries),
+	}
+	if noItemSucceeded(resp.BatchResponse) {
+		logger.FromContext(c).Warn("Every playlist item failed", zap.String("url", playlistURL), zap.Int("items", len(items)))
+		resp.ErrorResponse = &model.ErrorResponse{
+			Error:   "playlist_failed",
+			Message: i18n.Localize(c.GetHeader("Accept-Language"), "playlist_failed", "No video of the playlist could be downloaded"),
+			Code:    http.StatusUnprocessableEntity,
+		}
+		c.JSON(http.StatusUnprocessableEntity, resp)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// noItemSucceeded reports whether every item of a batch failed or was cancelled
+// Items still pending or scheduled may yet succeed, so they keep a batch from counting as failed
+func noItemSucceeded(batch *model.BatchResponse) bool {
+	for _, item := range batch.Items {
+		if item.Status != model.BatchItemFailed && item.Status != model.BatchItemCancelled {
+			return false
+		}
+	}
+	return true
+}
+
+// prefetchBatchInfo fetches the info of every allowed URL in a batch concurrently
+// Returns nil when prefetching is disabled
+func (h *DownloadHandler) prefetchBatchInfo(ctx context.Context, items []model.DownloadRequest, deadline time.Time) map[string]*service.PrefetchResult {
+	if h.cfg.Batch.PrefetchWorkers <= 0 {
+		return nil
+	}
+
+	var urls []string
+	for _, item := range items {
+		if _, reason := validator.CheckURL(item.URL, h.cfg.Security.DownloadAllowedDomains, h.cfg.Security.RequireHTTPSTarget); reason == "" {
+			urls = append(urls, item.URL)
+		}
+	}
+	if len(urls) == 0 {
+		return nil
+	}
+	return h.videoService.PrefetchInfo(ctx, urls, h.cfg.Batch.PrefetchWorkers, deadline)
+}
+
+// checkPrefetchedItem rejects a batch item whose prefetched info shows it can't be downloaded
+// Items without a prefetch result (disabled or past the deadline) are let through
+func (h *DownloadHandler) checkPrefetchedItem(c *gin.Context, req *model.DownloadRequest, prefetched *service.PrefetchResult, profile *model.LimitProfile) *model.ErrorResponse {
+	if prefetched == nil {
+		return nil
+	}
+	if prefetched.Err != nil {
+		logger.FromContext(c).Warn("Batch item info prefetch failed", zap.String("url", req.URL), zap.Error(prefetched.Err))
+		return rejection(http.StatusBadGateway, "fetch_failed", "Failed to fetch video information")
+	}
+
+	var format *model.FormatOption
+	for i := range prefetched.Info.Formats {
+		if prefetched.Info.Formats[i].FormatID == req.FormatID {
+			format = &prefetched.Info.Formats[i]
+			break
+		}
+	}
+	// Formats of disabled quality categories are filtered out of the info as well
+	if format == nil {
+		return rejection(http.StatusBadRequest, "format_unavailable", "The selected format is not available for this video")
+	}
+
+	maxSizeBytes := int64(profile.MaxVideoSizeMB) * 1024 * 1024
+	if format.FileSize > maxSizeBytes {
+		return rejection(http.StatusRequestEntityTooLarge, "file_too_large", fmt.Sprintf("File size exceeds maximum limit of %dMB. Requested size: %dMB.", profile.MaxVideoSizeMB, format.FileSize/(1024*1024)))
+	}
+	return nil
+}
+
+// GetBatchStatus handles GET /api/download/batch/:batchid
+func (h *DownloadHandler) GetBatchStatus(c *gin.Context) {
+	batchID := c.Param("batchid")
+
+	batch, ok := h.batchService.GetBatch(batchID, middleware.GetClientKey(c))
+	if !ok {
+		respondError(c, http.StatusNotFound, "not_found", "Batch not found or has expired")
+		return
+	}
+
+	c.JSON(http.StatusOK, publicBatch(c, &h.cfg.Server, batch))
+}
+
+// GetJobStatus handles GET /api/download/status/:jobid
+// Reports whether an async download is scheduled, queued, running, done or failed
+func (h *DownloadHandler) GetJobStatus(c *gin.Context) {
+	job, ok := h.jobManager.GetJob(c.Param("jobid"), middleware.GetClientKey(c))
+	if !ok {
+		respondError(c, http.StatusNotFound, "not_found", "Job not found or has expired")
+		return
+	}
+
+	c.JSON(http.StatusOK, publicJob(c, &h.cfg.Server, job))
+}
+
+// CancelJob handles DELETE /api/download/status/:jobid
+// Cancels a scheduled job; jobs that already started are cancelled with POST /api/download/cancel-all
+func (h *DownloadHandler) CancelJob(c *gin.Context) {
+	job, found, cancelled := h.jobManager.CancelJob(c.Param("jobid"), middleware.GetClientKey(c))
+	if !found {
+		respondError(c, http.StatusNotFound, "not_found", "Job not found or has expired")
+		return
+	}
+	if !cancelled {
+		respondError(c, http.StatusConflict, "job_started", "Only scheduled jobs can be cancelled")
+		return
+	}
+
+	c.JSON(http.StatusOK, publicJob(c, &h.cfg.Server, job))
+}
+
+// progressEventInterval is the minimum time between two progress events of one stream
+const progressEventInterval = 500 * time.Millisecond
+
+// StreamJobProgress handles GET /api/download/progress/:id
+// Streams an async job's progress as Server-Sent Events: "progress" while it is scheduled,
+// queued or running, then a final "complete" with the download link or "failed" with the error
+// The stream ends when the client disconnects; the download itself keeps running
+// Open streams are capped by MAX_PROGRESS_STREAMS and MAX_PROGRESS_STREAMS_PER_CLIENT
+func (h *DownloadHandler) StreamJobProgress(c *gin.Context) {
+	jobID := c.Param("id")
+	clientKey := middleware.GetClientKey(c)
+
+	if _, _, ok := h.jobManager.WatchJob(jobID, clientKey); !ok {
+		respondError(c, http.StatusNotFound, "not_found", "Job not found or has expired")
+		return
+	}
+
+	release, ok := h.progressTracker.Subscribe(clientKey)
+	if !ok {
+		logger.FromContext(c).Warn("Progress stream limit reached",
+			zap.Int("max_progress_streams", h.cfg.ClientLimits.MaxProgressStreams),
+			zap.Int("max_progress_streams_per_client", h.cfg.ClientLimits.MaxProgressStreamsPerClient))
+		respondError(c, http.StatusTooManyRequests, "progress_stream_limit", "Too many open progress streams; close one and retry")
+		return
+	}
+	defer release()
+
+	// The stream outlives SERVER_TIMEOUT for long downloads
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		logger.FromContext(c).Warn("Failed to lift write deadline for progress stream", zap.Error(err))
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	ctx := c.Request.Context()
+	for {
+		job, changed, ok := h.jobManager.WatchJob(jobID, clientKey)
+		if !ok {
 			return
 		}
-		logger.Logger.Debug("File size validation passed",
-			zap.Int64("file_size", req.FileSize),
-			zap.Int64("max_size", maxSizeBytes))
-	}
-
-	// Check quota before starting download
-	clientIP := c.ClientIP()
-	if h.cfg.Quota.Enabled {
-		// We need to check quota - but we don't know file size yet
-		// So we check if quota is even available (not completely exhausted)
-		allowed, remainingMB := h.quotaService.CheckQuota(clientIP, 0)
-		if !allowed && remainingMB == 0 {
-			logger.Logger.Warn("Quota exhausted", zap.String("ip", clientIP))
-			quotaInfo := h.quotaService.GetQuotaInfo(clientIP)
-			c.JSON(http.StatusPaymentRequired, model.ErrorResponse{
-				Error:   "quota_exhausted",
-				Message: "Daily download quota exhausted. Please try again after quota reset.",
-				Code:    http.StatusPaymentRequired,
-			})
-			c.Set("quota_info", quotaInfo)
+		job = publicJob(c, &h.cfg.Server, job)
+
+		switch job.Status {
+		case model.JobDone:
+			c.SSEvent("complete", job)
+			c.Writer.Flush()
+			return
+		case model.JobFailed:
+			c.SSEvent("failed", job)
+			c.Writer.Flush()
+			return
+		}
+		c.SSEvent("progress", job)
+		c.Writer.Flush()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			logger.FromContext(c).Debug("Progress stream closed by client", zap.String("job_id", jobID))
+			return
+		}
+		// Coalesce the many updates of a fast download into one event per interval
+		select {
+		case <-time.After(progressEventInterval):
+		case <-ctx.Done():
 			return
 		}
-		logger.Logger.Debug("Quota check passed", zap.String("ip", clientIP), zap.Int64("remaining_mb", remainingMB))
 	}
+}
 
-	// Start download
-	downloadResp, err := h.downloadService.Download(&req)
-	if err != nil {
-		logger.Logger.Error("Download failed", zap.Error(err), zap.String("url", req.URL))
-		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
-			Error:   "download_failed",
-			Message: err.Error(),
-			Code:    http.StatusInternalServerError,
-		})
-		return
-	}
-
-	// Add quota usage if enabled
-	if h.cfg.Quota.Enabled {
-		// Get file size and add to quota
-		fileSizeBytes, err := h.downloadService.GetFileSize(downloadResp.ID)
-		if err == nil && fileSizeBytes > 0 {
-			fileSizeMB := fileSizeBytes / (1024 * 1024)
-			if fileSizeBytes%1024*1024 > 0 {
-				fileSizeMB++ // Round up
-			}
-			h.quotaService.AddUsage(clientIP, fileSizeMB)
-			logger.Logger.Debug("Quota usage added", zap.String("ip", clientIP), zap.Int64("size_mb", fileSizeMB))
+// GetBatchZip handles GET /api/download/batch/:batchid/zip
+// Streams the batch's finished files as a single zip archive
+func (h *DownloadHandler) GetBatchZip(c *gin.Context) {
+	batchID := c.Param("batchid")
+
+	batch, ok := h.batchService.GetBatch(batchID, middleware.GetClientKey(c))
+	if !ok {
+		respondError(c, http.StatusNotFound, "not_found", "Batch not found or has expired")
+		return
+	}
 
-			// Set quota info in response header
-			quotaInfo := h.quotaService.GetQuotaInfo(clientIP)
-			c.Set("quota_info", quotaInfo)
+	var files []*model.DownloadedFile
+	for _, item := range batch.Items {
+		if item.Status != model.BatchItemDone || item.Download == nil {
+			continue
+		}
+		if file, err := h.downloadService.GetDownloadFile(item.Download.ID); err == nil {
+			files = append(files, file)
 		}
 	}
+	if len(files) == 0 {
+		respondError(c, http.StatusNotFound, "not_found", "Batch has no downloaded files")
+		return
+	}
 
-	c.JSON(http.StatusOK, downloadResp)
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", buildContentDispositionHeader("attachment", fmt.Sprintf("batch-%s.zip", batchID)))
+	c.Status(http.StatusOK)
+
+	// Headers are already sent, so a failure can only be logged
+	err := storage.WriteZip(c.Writer, files)
+	h.downloadService.RecordServed(int64(c.Writer.Size()))
+	if err != nil {
+		logger.FromContext(c).Error("Failed to stream batch zip", zap.String("batch_id", batchID), zap.Error(err))
+		return
+	}
+
+	logger.FromContext(c).Info("Batch zip downloaded by user", zap.String("batch_id", batchID), zap.Int("files", len(files)))
+}
+
+// CancelBatch handles DELETE /api/download/batch/:batchid
+// Cancels the batch items that are still scheduled
+func (h *DownloadHandler) CancelBatch(c *gin.Context) {
+	batch, ok := h.batchService.CancelBatch(c.Param("batchid"), middleware.GetClientKey(c))
+	if !ok {
+		respondError(c, http.StatusNotFound, "not_found", "Batch not found or has expired")
+		return
+	}
+
+	c.JSON(http.StatusOK, publicBatch(c, &h.cfg.Server, batch))
+}
+
+// CancelAllDownloads handles POST /api/download/cancel-all
+// Cancels every queued, running or scheduled download of the caller
+func (h *DownloadHandler) CancelAllDownloads(c *gin.Context) {
+	clientKey := middleware.GetClientKey(c)
+	cancelled := h.downloadService.CancelAll(clientKey) + h.batchService.CancelScheduled(clientKey) + h.jobManager.CancelScheduled(clientKey)
+	logger.FromContext(c).Info("Client cancelled all downloads", zap.Int("cancelled", cancelled))
+
+	c.JSON(http.StatusOK, model.CancelAllResponse{Cancelled: cancelled})
+}
+
+// checkDownloadsEnabled rejects new downloads while the kill switch is on
+func (h *DownloadHandler) checkDownloadsEnabled(c *gin.Context) bool {
+	if h.downloadSwitch.IsDisabled() {
+		respondError(c, http.StatusServiceUnavailable, "downloads_disabled", "Downloads are temporarily disabled. Please try again later.")
+		return false
+	}
+	return true
+}
+
+// checkDiskSpace rejects new downloads while free disk space is below MIN_FREE_DISK_PERCENT
+// Below WARN_FREE_DISK_PERCENT downloads are still accepted, with a warning logged
+func (h *DownloadHandler) checkDiskSpace(c *gin.Context) bool {
+	level, freePercent := h.downloadService.CheckDisk()
+	switch level {
+	case storage.DiskCritical:
+		logger.FromContext(c).Error("Free disk space below minimum, rejecting download",
+			zap.Float64("free_percent", freePercent),
+			zap.Int("min_free_percent", h.cfg.Storage.MinFreeDiskPercent))
+		respondError(c, http.StatusInsufficientStorage, "storage_full", "Server storage is full. Please try again later.")
+		return false
+	case storage.DiskLow:
+		logger.FromContext(c).Warn("Free disk space is low",
+			zap.Float64("free_percent", freePercent),
+			zap.Int("warn_free_percent", h.cfg.Storage.WarnFreeDiskPercent))
+	}
+	return true
+}
+
+// limitProfile returns the caller's limit profile or the global defaults
+func (h *DownloadHandler) limitProfile(c *gin.Context) *model.LimitProfile {
+	if profile := middleware.GetLimitProfile(c); profile != nil {
+		return profile
+	}
+	return &model.LimitProfile{
+		Name:              "default",
+		DailyLimitMB:      h.cfg.Quota.DailyLimitMB,
+		RequestsPerMinute: h.cfg.RateLimit.RequestsPerMinute,
+		MaxVideoSizeMB:    h.cfg.Storage.MaxVideoSizeMB,
+	}
 }
 
 // GetFile handles GET /api/download/:id
@@ -165,52 +518,209 @@ func (h *DownloadHandler) GetFile(c *gin.Context) {
 	fileID := c.Param("id")
 
 	if fileID == "" {
-		logger.Logger.Warn("Empty file ID")
-		c.JSON(http.StatusBadRequest, model.ErrorResponse{
-			Error:   "invalid_id",
-			Message: "File ID is required",
-			Code:    http.StatusBadRequest,
-		})
+		logger.FromContext(c).Warn("Empty file ID")
+		respondError(c, http.StatusBadRequest, "invalid_id", "File ID is required")
 		return
 	}
 
+	// A job's ID serves its file to the client that started it once the job is done
+	if job, ok := h.jobManager.GetJob(fileID, middleware.GetClientKey(c)); ok {
+		switch job.Status {
+		case model.JobDone:
+			fileID = job.Download.ID
+		case model.JobFailed:
+			respondError(c, job.Error.Code, job.Error.Error, job.Error.Message)
+			return
+		default:
+			respondError(c, http.StatusConflict, "download_pending", "Download is not finished yet")
+			return
+		}
+	}
+
 	file, err := h.downloadService.GetDownloadFile(fileID)
 	if err != nil {
-		logger.Logger.Warn("File not found", zap.String("file_id", fileID))
-		c.JSON(http.StatusNotFound, model.ErrorResponse{
-			Error:   "not_found",
-			Message: "File not found or has expired",
-			Code:    http.StatusNotFound,
-		})
+		if expiredFile := h.downloadService.GetExpiredDownload(fileID); expiredFile != nil {
+			logger.FromContext(c).Info("Expired file requested", zap.String("file_id", fileID))
+			h.respondFileGone(c, expiredFile)
+			return
+		}
+		logger.FromContext(c).Warn("File not found", zap.String("file_id", fileID))
+		h.respondFileNotFound(c)
 		return
 	}
 
-	// Check if file still exists
+	// A tracked file missing on disk is reported exactly like an unknown ID
 	if _, err := os.Stat(file.FilePath); err != nil {
-		logger.Logger.Warn("File does not exist", zap.String("path", file.FilePath))
-		c.JSON(http.StatusNotFound, model.ErrorResponse{
-			Error:   "not_found",
-			Message: "File no longer available",
-			Code:    http.StatusNotFound,
-		})
+		logger.FromContext(c).Warn("File does not exist", zap.String("path", file.FilePath))
+		h.respondFileNotFound(c)
 		return
 	}
 
 	// Set proper Content-Disposition header with filename encoding
 	// Use RFC 5987 for proper handling of unicode and special characters
-	contentDisposition := buildContentDispositionHeader(file.Filename)
+	contentType := service.ServeContentType(file, h.cfg.Storage.ExtensionMIMETypes)
+	contentDisposition := buildContentDispositionHeader(service.ServeDisposition(contentType, c.Query("disposition"), h.cfg.Storage.InlineMIMETypes), file.Filename)
 	c.Header("Content-Disposition", contentDisposition)
-	c.Header("Content-Type", "application/octet-stream")
-	c.File(file.FilePath)
+	c.Header("Content-Type", contentType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	// The checksum always describes the uncompressed content
+	if file.SHA256 != "" {
+		c.Header("X-Content-SHA256", file.SHA256)
+	}
+	setFileCacheHeaders(c, file, h.cfg.Storage.FileNoStore || c.Query("no_cache") == "true")
 
-	logger.Logger.Info("File downloaded by user",
+	servePath := file.FilePath
+	if storage.IsCompressible(file.Filename) {
+		c.Header("Vary", "Accept-Encoding")
+
+		// Ranges are served from the uncompressed file so offsets match the checksummed content
+		if c.GetHeader("Range") == "" && acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			if sidecarPath, err := storage.GzipSidecar(file.FilePath); err == nil {
+				servePath = sidecarPath
+				c.Header("Content-Encoding", "gzip")
+			} else {
+				logger.FromContext(c).Warn("Failed to prepare gzip sidecar", zap.String("path", file.FilePath), zap.Error(err))
+			}
+		}
+	}
+	_, span := tracing.Start(c.Request.Context(), "file.serve")
+	span.SetAttribute("file.id", fileID)
+	c.File(servePath)
+	span.SetAttribute("http.response_size", strconv.Itoa(c.Writer.Size()))
+	span.End(nil)
+	h.downloadService.RecordServed(int64(c.Writer.Size()))
+
+	logger.FromContext(c).Info("File downloaded by user",
 		zap.String("file_id", fileID),
-		zap.String("filename", file.Filename))
+		zap.String("filename", file.Filename),
+		zap.String("platform", file.Platform))
+}
+
+// respondFileNotFound answers an unknown or expired download ID after the configured jitter
+func (h *DownloadHandler) respondFileNotFound(c *gin.Context) {
+	middleware.ErrorJitter(&h.cfg.Security)
+	respondError(c, http.StatusNotFound, "not_found", "File not found or has expired")
+}
+
+// respondFileGone reports a download that expired within EXPIRED_LINK_GRACE_SECONDS
+func (h *DownloadHandler) respondFileGone(c *gin.Context, expiredFile *model.ExpiredFile) {
+	c.JSON(http.StatusGone, model.GoneResponse{
+		ErrorResponse: model.ErrorResponse{
+			Error:   "expired",
+			Message: i18n.Localize(c.GetHeader("Accept-Language"), "expired", "File has expired; download the video again"),
+			Code:    http.StatusGone,
+		},
+		ExpiredAt:   expiredFile.ExpiredAt.Unix(),
+		Refreshable: expiredFile.URL != "",
+	})
+}
+
+// setFileCacheHeaders sets the caching headers of a served file
+// By default only the browser may cache it, and not past its expiry; noStore forbids any caching
+func setFileCacheHeaders(c *gin.Context, file *model.DownloadedFile, noStore bool) {
+	if noStore {
+		c.Header("Cache-Control", "no-store, max-age=0")
+		c.Header("Pragma", "no-cache")
+		c.Header("Expires", "0")
+		return
+	}
+
+	maxAge := int(time.Until(file.ExpiresAt).Seconds())
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", maxAge))
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		// An explicit q=0 refuses the coding
+		q := strings.ReplaceAll(strings.ToLower(params), " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
+
+// GetFileInfo handles GET /api/download/:id/info
+// Only the client that requested the download sees it; other callers get 404 like an unknown ID
+func (h *DownloadHandler) GetFileInfo(c *gin.Context) {
+	fileID := c.Param("id")
+	clientKey := middleware.GetClientKey(c)
+
+	file, err := h.downloadService.GetDownloadFile(fileID)
+	if err != nil || file.ClientKey != clientKey {
+		if expiredFile := h.downloadService.GetExpiredDownload(fileID); expiredFile != nil && expiredFile.ClientKey == clientKey {
+			h.respondFileGone(c, expiredFile)
+			return
+		}
+		logger.FromContext(c).Warn("File not found for info", zap.String("file_id", fileID))
+		h.respondFileNotFound(c)
+		return
+	}
+
+	if _, err := os.Stat(file.FilePath); err != nil {
+		logger.FromContext(c).Warn("File does not exist", zap.String("path", file.FilePath))
+		h.respondFileNotFound(c)
+		return
+	}
+
+	contentType := file.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	c.JSON(http.StatusOK, model.FileInfoResponse{
+		ID:           file.ID,
+		Filename:     file.Filename,
+		Size:         file.Size,
+		ContentType:  contentType,
+		SHA256:       file.SHA256,
+		URL:          file.URL,
+		Platform:     file.Platform,
+		DownloadLink: publicLink(c, &h.cfg.Server, fmt.Sprintf("/api/download/%s", file.ID)),
+		CreatedAt:    file.CreatedAt,
+		ExpiresAt:    file.ExpiresAt,
+	})
+}
+
+// GetChecksum handles GET /api/download/:id/checksum
+func (h *DownloadHandler) GetChecksum(c *gin.Context) {
+	fileID := c.Param("id")
+
+	file, err := h.downloadService.GetDownloadFile(fileID)
+	if err != nil {
+		logger.FromContext(c).Warn("File not found for checksum", zap.String("file_id", fileID))
+		h.respondFileNotFound(c)
+		return
+	}
+
+	// With HASH_WORKERS the checksum is filled in shortly after the download completes
+	status := http.StatusOK
+	if file.SHA256 == "" {
+		status = http.StatusAccepted
+	}
+	c.JSON(status, model.ChecksumResponse{
+		ID:       fileID,
+		Filename: file.Filename,
+		Size:     file.Size,
+		SHA256:   file.SHA256,
+		Pending:  file.SHA256 == "",
+	})
 }
 
 // buildContentDispositionHeader builds a proper Content-Disposition header
-// with RFC 5987 encoding for unicode and special characters
-func buildContentDispositionHeader(filename string) string {
+// with RFC 5987 encoding for unicode and special characters; disposition is "inline" or "attachment"
+func buildContentDispositionHeader(disposition string, filename string) string {
+	// Control characters (including CR/LF) are never valid in a filename
+	// and could be used for header injection, so drop them entirely
+	filename = stripControlChars(filename)
+
 	// Check if filename needs encoding (has non-ASCII or special characters)
 	needsEncoding := false
 	for _, r := range filename {
@@ -220,19 +730,56 @@ func buildContentDispositionHeader(filename string) string {
 		}
 	}
 
-	// Also check for spaces - they should be quoted at minimum
-	if strings.ContainsAny(filename, " \t\n\r") {
+	// Also check for spaces - they should be encoded as %20
+	if strings.Contains(filename, " ") {
 		needsEncoding = true
 	}
 
 	if !needsEncoding {
 		// Simple ASCII filename without special characters
 		// Just quote it for safety
-		return fmt.Sprintf(`attachment; filename="%s"`, filename)
+		return fmt.Sprintf(`%s; filename="%s"`, disposition, filename)
 	}
 
 	// Use RFC 5987 encoding for unicode and special characters
 	// Format: filename*=UTF-8''<percent-encoded-filename>
-	encodedFilename := url.QueryEscape(filename)
-	return fmt.Sprintf(`attachment; filename*=UTF-8''%s`, encodedFilename)
+	return fmt.Sprintf(`%s; filename*=UTF-8''%s`, disposition, encodeRFC5987(filename))
+}
+
+// stripControlChars removes ASCII control characters and DEL from s
+func stripControlChars(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// encodeRFC5987 percent-encodes s per RFC 5987 attr-char rules
+// Unlike url.QueryEscape, spaces become %20 rather than +
+func encodeRFC5987(s string) string {
+	const hexDigits = "0123456789ABCDEF"
+
+	var result strings.Builder
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		if isRFC5987AttrChar(b) {
+			result.WriteByte(b)
+			continue
+		}
+		result.WriteByte('%')
+		result.WriteByte(hexDigits[b>>4])
+		result.WriteByte(hexDigits[b&0x0f])
+	}
+	return result.String()
+}
+
+// isRFC5987AttrChar reports whether b may appear unencoded in an RFC 5987 value
+func isRFC5987AttrChar(b byte) bool {
+	switch {
+	case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b >= '0' && b <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
 }
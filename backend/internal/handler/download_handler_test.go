@@ -0,0 +1,1078 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"videodownload/internal/model"
+)
+
+func TestChecksumMatchesServedBytes(t *testing.T) {
+	tests := []struct {
+		name        string
+		hashWorkers string
+	}{
+		{"hashed while writing", "0"},
+		{"hashed in the background", "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HASH_WORKERS", tt.hashWorkers)
+			s := newTestServer(t, serveTestMedia)
+
+			job := s.waitForJob(t, s.startDownload(t, testDownload, nil).JobID, nil)
+			if job.Status != model.JobDone {
+				t.Fatalf("job status = %s (%+v), want done", job.Status, job.Error)
+			}
+			fileID := job.Download.ID
+
+			// The background hash lands shortly after the job is done
+			var checksum model.ChecksumResponse
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				w := s.do(http.MethodGet, "/api/download/"+fileID+"/checksum", nil, nil)
+				json.Unmarshal(w.Body.Bytes(), &checksum)
+				if w.Code == http.StatusOK || time.Now().After(deadline) {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if checksum.Pending || checksum.SHA256 == "" {
+				t.Fatalf("checksum still pending: %+v", checksum)
+			}
+
+			w := s.do(http.MethodGet, "/api/download/"+fileID, nil, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET file = %d, want 200", w.Code)
+			}
+			sum := sha256.Sum256(w.Body.Bytes())
+			served := hex.EncodeToString(sum[:])
+			if served != checksum.SHA256 {
+				t.Errorf("checksum endpoint = %s, served bytes hash to %s", checksum.SHA256, served)
+			}
+			if header := w.Header().Get("X-Content-SHA256"); header != served {
+				t.Errorf("X-Content-SHA256 = %q, served bytes hash to %s", header, served)
+			}
+		})
+	}
+}
+
+func TestBuildContentDispositionHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		want     string // Filename a client decodes from the header
+	}{
+		{"plain", "video.mp4", "video.mp4"},
+		{"spaces", "my video.mp4", "my video.mp4"},
+		{"plus sign", "a+b.mp4", "a+b.mp4"},
+		{"plus and spaces", "c++ talk.mp4", "c++ talk.mp4"},
+		{"unicode", "vidéo 日本.mp4", "vidéo 日本.mp4"},
+		{"quotes", `say "hi".mp4`, `say "hi".mp4`},
+		{"header injection", "evil.mp4\r\nSet-Cookie: a=b", "evil.mp4Set-Cookie: a=b"},
+		{"control characters", "a\tb\x00c\x7f.mp4", "abc.mp4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := buildContentDispositionHeader("attachment", tt.filename)
+			if strings.ContainsAny(header, "\r\n\x00") {
+				t.Fatalf("header contains control characters: %q", header)
+			}
+			if _, encoded, ok := strings.Cut(header, "filename*="); ok && strings.Contains(encoded, " ") {
+				t.Errorf("encoded filename contains a raw space: %q", header)
+			}
+
+			disposition, params, err := mime.ParseMediaType(header)
+			if err != nil {
+				t.Fatalf("ParseMediaType(%q): %v", header, err)
+			}
+			if disposition != "attachment" {
+				t.Errorf("disposition = %q, want attachment", disposition)
+			}
+			if params["filename"] != tt.want {
+				t.Errorf("decoded filename = %q from %q, want %q", params["filename"], header, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeRFC5987(t *testing.T) {
+	if got, want := encodeRFC5987("a b+c"), "a%20b+c"; got != want {
+		t.Errorf("encodeRFC5987 = %q, want %q", got, want)
+	}
+}
+
+func TestDownloadDurationBudget(t *testing.T) {
+	t.Setenv("MAX_DOWNLOAD_DURATION", "1")
+	// The stub sends part of the file, then stalls past the budget
+	s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Header().Set("Content-Length", strconv.Itoa(len(testMedia)))
+		w.Write(testMedia[:len(testMedia)/2])
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+
+	started := time.Now()
+	job := s.waitForJob(t, s.startDownload(t, testDownload, nil).JobID, nil)
+	if job.Status != model.JobFailed || job.Error == nil || job.Error.Error != "download_timeout" {
+		t.Fatalf("job = %s %+v, want failed with download_timeout", job.Status, job.Error)
+	}
+	if elapsed := time.Since(started); elapsed > 4*time.Second {
+		t.Errorf("job failed after %v, want it cancelled at the 1s budget", elapsed)
+	}
+
+	// The partial file is cleaned up
+	entries, _ := os.ReadDir(s.cfg.Storage.DownloadDir)
+	for _, entry := range entries {
+		t.Errorf("file %s left behind", entry.Name())
+	}
+}
+
+func TestSingleQuotaDecision(t *testing.T) {
+	t.Setenv("QUOTA_ENABLED", "true")
+	t.Setenv("QUOTA_DAILY_LIMIT_MB", "1000")
+	const client = "192.0.2.1" // httptest's remote address
+
+	tests := []struct {
+		name         string
+		usedMB       int64
+		wantStatus   int
+		wantError    string
+		wantUsedMB   int64 // Usage once the request is settled
+		wantDownload bool
+	}{
+		{"quota left", 0, http.StatusAccepted, "", 1, true},
+		{"quota exhausted", 1000, http.StatusPaymentRequired, "quota_exhausted", 1000, false},
+		{"quota too small for the reservation", 900, http.StatusPaymentRequired, "quota_insufficient", 900, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var workerCalls atomic.Int32
+			s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				workerCalls.Add(1)
+				serveTestMedia(w, r)
+			})
+			s.quotaService.AddUsage(client, tt.usedMB)
+
+			w := s.do(http.MethodPost, "/api/download", testDownload, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("POST /api/download = %d %s, want %d", w.Code, w.Body, tt.wantStatus)
+			}
+			if got := w.Header().Values("X-Quota-Remaining-MB"); len(got) != 1 {
+				t.Errorf("X-Quota-Remaining-MB = %q, want exactly one value", got)
+			}
+
+			if tt.wantDownload {
+				var job model.DownloadJob
+				json.Unmarshal(w.Body.Bytes(), &job)
+				if done := s.waitForJob(t, job.JobID, nil); done.Status != model.JobDone {
+					t.Fatalf("job = %s %+v, want done", done.Status, done.Error)
+				}
+			} else {
+				var resp model.ErrorResponse
+				json.Unmarshal(w.Body.Bytes(), &resp)
+				if resp.Error != tt.wantError {
+					t.Errorf("error = %q, want %q", resp.Error, tt.wantError)
+				}
+				if n := workerCalls.Load(); n != 0 {
+					t.Errorf("rejected request made %d worker calls", n)
+				}
+			}
+
+			// The reservation is given back and the file is charged once
+			if used := s.quotaService.GetQuotaInfo(client)["used_mb"]; used != tt.wantUsedMB {
+				t.Errorf("used = %vMB, want %dMB", used, tt.wantUsedMB)
+			}
+		})
+	}
+}
+
+func TestDuplicateDebounce(t *testing.T) {
+	t.Setenv("QUOTA_ENABLED", "true")
+	t.Setenv("DUPLICATE_DEBOUNCE_SECONDS", "5")
+	const client = "192.0.2.1" // httptest's remote address
+
+	var downloads atomic.Int32
+	release := make(chan struct{})
+	s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/info" {
+			serveTestWorker(w, r)
+			return
+		}
+		downloads.Add(1)
+		<-release
+		serveTestMedia(w, r)
+	})
+
+	// Both requests are in flight before the worker answers the first
+	first := s.startDownload(t, testDownload, nil)
+	second := s.startDownload(t, testDownload, nil)
+	close(release)
+
+	jobs := []*model.DownloadJob{s.waitForJob(t, first.JobID, nil), s.waitForJob(t, second.JobID, nil)}
+	// One made just after the first completed gets the same result
+	jobs = append(jobs, s.waitForJob(t, s.startDownload(t, testDownload, nil).JobID, nil))
+
+	duplicates := 0
+	for i, job := range jobs {
+		if job.Status != model.JobDone {
+			t.Fatalf("job %d = %s %+v, want done", i, job.Status, job.Error)
+		}
+		if job.Download.ID != jobs[0].Download.ID {
+			t.Errorf("job %d downloaded %s, want the shared %s", i, job.Download.ID, jobs[0].Download.ID)
+		}
+		if job.Download.Duplicate {
+			duplicates++
+		}
+	}
+	if duplicates != 2 {
+		t.Errorf("%d results marked duplicate, want 2", duplicates)
+	}
+	if n := downloads.Load(); n != 1 {
+		t.Errorf("worker got %d download calls, want 1", n)
+	}
+	if used := s.quotaService.GetQuotaInfo(client)["used_mb"]; used != int64(1) {
+		t.Errorf("used = %vMB, want the file charged once (1MB)", used)
+	}
+}
+
+func TestDiskThresholds(t *testing.T) {
+	// Any real filesystem has less than 100% free, so a threshold of 100 is always crossed
+	tests := []struct {
+		name         string
+		minPercent   string
+		warnPercent  string
+		wantStatus   int
+		wantDisk     string
+		wantDegraded bool
+	}{
+		{"thresholds off", "0", "0", http.StatusAccepted, "", false},
+		{"below the warning threshold", "0", "100", http.StatusAccepted, "low", true},
+		{"below the minimum", "100", "100", http.StatusInsufficientStorage, "critical", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MIN_FREE_DISK_PERCENT", tt.minPercent)
+			t.Setenv("WARN_FREE_DISK_PERCENT", tt.warnPercent)
+			s := newTestServer(t, serveTestWorker)
+			// The directory is created at startup; statfs can't tell anything before that
+			if err := s.storageManager.EnsureDownloadDir(); err != nil {
+				t.Fatal(err)
+			}
+
+			w := s.do(http.MethodPost, "/api/download", testDownload, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("POST /api/download = %d %s, want %d", w.Code, w.Body, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusAccepted {
+				var job model.DownloadJob
+				json.Unmarshal(w.Body.Bytes(), &job)
+				s.waitForJob(t, job.JobID, nil)
+			} else {
+				var resp model.ErrorResponse
+				if json.Unmarshal(w.Body.Bytes(), &resp); resp.Error != "storage_full" {
+					t.Errorf("error = %q, want storage_full", resp.Error)
+				}
+			}
+
+			var health struct {
+				Status          string  `json:"status"`
+				Degraded        bool    `json:"degraded"`
+				Disk            string  `json:"disk"`
+				DiskFreePercent float64 `json:"disk_free_percent"`
+			}
+			w = s.do(http.MethodGet, "/api/health", nil, nil)
+			if err := json.Unmarshal(w.Body.Bytes(), &health); w.Code != http.StatusOK || err != nil {
+				t.Fatalf("GET /api/health = %d %s, want 200", w.Code, w.Body)
+			}
+			if health.Degraded != tt.wantDegraded || health.Disk != tt.wantDisk {
+				t.Errorf("health = %+v, want degraded %v with disk %q", health, tt.wantDegraded, tt.wantDisk)
+			}
+			if tt.wantDegraded && (health.DiskFreePercent < 0 || health.DiskFreePercent >= 100) {
+				t.Errorf("disk_free_percent = %v, want the measured share", health.DiskFreePercent)
+			}
+		})
+	}
+}
+
+// testSubtitle is a WebVTT file long enough to be worth compressing
+var testSubtitle = []byte("WEBVTT\n\n" + strings.Repeat("00:00:01.000 --> 00:00:02.000\nHello subtitles\n\n", 200))
+
+func TestSubtitleServedGzip(t *testing.T) {
+	t.Setenv("HASH_WORKERS", "0")
+	s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/vtt")
+		w.Header().Set("Content-Disposition", `attachment; filename="Test video.en.vtt"`)
+		w.Write(testSubtitle)
+	})
+	job := s.waitForJob(t, s.startDownload(t, testDownload, nil).JobID, nil)
+	if job.Status != model.JobDone {
+		t.Fatalf("job status = %s (%+v), want done", job.Status, job.Error)
+	}
+	path := "/api/download/" + job.Download.ID
+	sum := sha256.Sum256(testSubtitle)
+	wantSum := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		wantGzip bool
+		wantBody []byte
+	}{
+		{"gzip accepted", http.Header{"Accept-Encoding": {"gzip, deflate"}}, true, testSubtitle},
+		{"cached sidecar is reused", http.Header{"Accept-Encoding": {"gzip"}}, true, testSubtitle},
+		{"gzip refused", http.Header{"Accept-Encoding": {"gzip;q=0"}}, false, testSubtitle},
+		{"no Accept-Encoding", nil, false, testSubtitle},
+		{"range is served uncompressed", http.Header{"Accept-Encoding": {"gzip"}, "Range": {"bytes=0-5"}}, false, testSubtitle[:6]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(http.MethodGet, path, nil, tt.header)
+			if w.Code != http.StatusOK && w.Code != http.StatusPartialContent {
+				t.Fatalf("GET = %d %s", w.Code, w.Body)
+			}
+			if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", got)
+			}
+			if got := w.Header().Get("X-Content-SHA256"); got != wantSum {
+				t.Errorf("X-Content-SHA256 = %q, want the hash of the uncompressed file %q", got, wantSum)
+			}
+
+			body := w.Body.Bytes()
+			gzipped := w.Header().Get("Content-Encoding") == "gzip"
+			if gzipped != tt.wantGzip {
+				t.Fatalf("Content-Encoding = %q, want gzip: %v", w.Header().Get("Content-Encoding"), tt.wantGzip)
+			}
+			if gzipped {
+				if len(body) >= len(testSubtitle) {
+					t.Errorf("gzip body is %d bytes, not smaller than %d", len(body), len(testSubtitle))
+				}
+				reader, err := gzip.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if body, err = io.ReadAll(reader); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !bytes.Equal(body, tt.wantBody) {
+				t.Errorf("served %d bytes, want %d", len(body), len(tt.wantBody))
+			}
+		})
+	}
+}
+
+func TestMediaNotServedGzip(t *testing.T) {
+	s := newTestServer(t, serveTestMedia)
+	job := s.waitForJob(t, s.startDownload(t, testDownload, nil).JobID, nil)
+	if job.Status != model.JobDone {
+		t.Fatalf("job status = %s (%+v), want done", job.Status, job.Error)
+	}
+
+	w := s.do(http.MethodGet, "/api/download/"+job.Download.ID, nil, http.Header{"Accept-Encoding": {"gzip"}})
+	if w.Header().Get("Content-Encoding") != "" || !bytes.Equal(w.Body.Bytes(), testMedia) {
+		t.Errorf("mp4 served with Content-Encoding %q and %d bytes, want as-is", w.Header().Get("Content-Encoding"), w.Body.Len())
+	}
+}
+
+func TestNotFoundJitter(t *testing.T) {
+	t.Setenv("ERROR_JITTER_MIN_MS", "150")
+	t.Setenv("ERROR_JITTER_MAX_MS", "200")
+	t.Setenv("ADMIN_API_KEY", "admin-secret")
+	s := newTestServer(t, serveTestMedia)
+	job := s.waitForJob(t, s.startDownload(t, testDownload, nil).JobID, nil)
+	if job.Status != model.JobDone {
+		t.Fatalf("job status = %s (%+v), want done", job.Status, job.Error)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		header     http.Header
+		wantStatus int
+		wantDelay  bool
+	}{
+		{"unknown ID", "/api/download/does-not-exist", nil, http.StatusNotFound, true},
+		{"admin auth failure", "/api/admin/downloads", http.Header{"X-Admin-Key": {"wrong"}}, http.StatusUnauthorized, true},
+		{"existing file", "/api/download/" + job.Download.ID, nil, http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			w := s.do(http.MethodGet, tt.path, nil, tt.header)
+			elapsed := time.Since(start)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("GET %s = %d, want %d", tt.path, w.Code, tt.wantStatus)
+			}
+			if tt.wantDelay && (elapsed < 150*time.Millisecond || elapsed > 400*time.Millisecond) {
+				t.Errorf("error response took %v, want a 150-200ms jitter", elapsed)
+			}
+			if !tt.wantDelay && elapsed >= 150*time.Millisecond {
+				t.Errorf("response took %v, want no jitter", elapsed)
+			}
+		})
+	}
+}
+
+func TestExpiredLinkGrace(t *testing.T) {
+	t.Setenv("EXPIRED_LINK_GRACE_SECONDS", "3600")
+	s := newTestServer(t, serveTestWorker)
+
+	// Both downloads expired; one within the grace window and one past it
+	expired := []struct {
+		name     string
+		formatID string
+		ago      time.Duration
+	}{
+		{"in grace", "18", time.Minute},
+		{"past grace", "140", 2 * time.Hour},
+	}
+	ids := map[string]string{"unknown": "1712345678000000001"}
+	for _, e := range expired {
+		req := model.DownloadRequest{URL: testDownload.URL, FormatID: e.formatID}
+		job := s.waitForJob(t, s.startDownload(t, req, nil).JobID, nil)
+		if job.Status != model.JobDone {
+			t.Fatalf("job = %s %+v, want done", job.Status, job.Error)
+		}
+		s.storageManager.GetFile(job.Download.ID).ExpiresAt = time.Now().Add(-e.ago)
+		ids[e.name] = job.Download.ID
+	}
+	s.storageManager.ManualCleanup()
+
+	tests := []struct {
+		name       string
+		wantStatus int
+		wantError  string
+	}{
+		{"in grace", http.StatusGone, "expired"},
+		{"past grace", http.StatusNotFound, "not_found"},
+		{"unknown", http.StatusNotFound, "not_found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(http.MethodGet, "/api/download/"+ids[tt.name], nil, nil)
+			var body model.GoneResponse
+			json.Unmarshal(w.Body.Bytes(), &body)
+			if w.Code != tt.wantStatus || body.Error != tt.wantError {
+				t.Fatalf("GET = %d %s, want %d %s", w.Code, w.Body, tt.wantStatus, tt.wantError)
+			}
+			if tt.wantStatus == http.StatusGone {
+				if !body.Refreshable || time.Since(time.Unix(body.ExpiredAt, 0)) > 2*time.Minute {
+					t.Errorf("gone response = %s, want refreshable with expired_at about a minute ago", w.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestBatchPrefetchRejectsInvalidItems(t *testing.T) {
+	t.Setenv("MAX_VIDEO_SIZE_MB", "1")
+	t.Setenv("BATCH_PREFETCH_WORKERS", "2")
+	var downloads atomic.Int32
+	s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			URL string `json:"url"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path != "/api/info" {
+			downloads.Add(1)
+			serveTestMedia(w, r)
+			return
+		}
+		switch {
+		case strings.HasSuffix(body.URL, "v=broken"):
+			w.WriteHeader(http.StatusInternalServerError)
+		case strings.HasSuffix(body.URL, "v=huge"):
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id": "huge", "title": "Huge video", "formats": [
+				{"format_id": "18", "ext": "mp4", "resolution": "640x360", "height": 360, "vcodec": "avc1", "acodec": "mp4a", "filesize": 5242880}
+			]}`))
+		default:
+			serveTestWorker(w, r)
+		}
+	})
+
+	items := []model.DownloadRequest{
+		{URL: "https://www.youtube.com/watch?v=abc123", FormatID: "18"},
+		{URL: "https://www.youtube.com/watch?v=broken", FormatID: "18"},
+		{URL: "https://www.youtube.com/watch?v=huge", FormatID: "18"},
+		{URL: "https://www.youtube.com/watch?v=abc123", FormatID: "999"},
+		{URL: "https://example.com/video.mp4", FormatID: "18"},
+	}
+	w := s.do(http.MethodPost, "/api/download/batch", model.BatchDownloadRequest{Items: items}, nil)
+	var batch model.BatchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &batch); w.Code != http.StatusOK || err != nil {
+		t.Fatalf("POST /api/download/batch = %d %s, want 200", w.Code, w.Body)
+	}
+
+	wantErrors := []string{"", "fetch_failed", "file_too_large", "format_unavailable", "invalid_domain"}
+	for i, item := range batch.Items {
+		if wantErrors[i] == "" {
+			if item.Status == model.BatchItemFailed {
+				t.Errorf("item %d failed with %+v, want it downloaded", i, item.Error)
+			}
+			continue
+		}
+		if item.Status != model.BatchItemFailed || item.Error == nil || item.Error.Error != wantErrors[i] {
+			t.Errorf("item %d = %s %+v, want failed with %s", i, item.Status, item.Error, wantErrors[i])
+		}
+		if item.JobID != "" {
+			t.Errorf("rejected item %d was started as job %s", i, item.JobID)
+		}
+	}
+	if job := s.waitForJob(t, batch.Items[0].JobID, nil); job.Status != model.JobDone {
+		t.Errorf("valid item = %s %+v, want done", job.Status, job.Error)
+	}
+	if got := downloads.Load(); got != 1 {
+		t.Errorf("worker got %d download calls, want 1 for the only valid item", got)
+	}
+}
+
+func TestBatchItemResults(t *testing.T) {
+	s := newTestServer(t, serveTestWorker)
+
+	items := []model.DownloadRequest{
+		{URL: "https://www.youtube.com/watch?v=abc123", FormatID: "18"},
+		{URL: "https://example.com/video.mp4", FormatID: "18"},
+		{URL: "https://www.youtube.com/watch?v=abc123", FormatID: "140"},
+	}
+	w := s.do(http.MethodPost, "/api/download/batch", model.BatchDownloadRequest{Items: items}, nil)
+	var batch model.BatchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &batch); w.Code != http.StatusOK || err != nil {
+		t.Fatalf("POST /api/download/batch = %d %s, want 200", w.Code, w.Body)
+	}
+	if len(batch.Items) != len(items) {
+		t.Fatalf("got %d items, want %d", len(batch.Items), len(items))
+	}
+
+	for i, item := range batch.Items {
+		if item.Index != i || item.URL != items[i].URL {
+			t.Errorf("item %d = index %d url %q, want the request's order", i, item.Index, item.URL)
+		}
+	}
+	for _, i := range []int{0, 2} {
+		item := batch.Items[i]
+		if !item.Success || item.Status != model.BatchItemDone || item.Error != nil {
+			t.Errorf("item %d = %s success %v %+v, want done", i, item.Status, item.Success, item.Error)
+			continue
+		}
+		if item.Download == nil || item.DownloadID == "" || item.DownloadID != item.Download.ID {
+			t.Errorf("item %d download_id = %q, want the download's ID", i, item.DownloadID)
+		}
+	}
+	if batch.Items[0].DownloadID == batch.Items[2].DownloadID {
+		t.Errorf("items 0 and 2 share download %s, want one per format", batch.Items[0].DownloadID)
+	}
+
+	failed := batch.Items[1]
+	if failed.Success || failed.Status != model.BatchItemFailed || failed.DownloadID != "" || failed.JobID != "" {
+		t.Errorf("item 1 = %+v, want failed without a job or download", failed)
+	}
+	if failed.Error == nil || failed.Error.Error != "invalid_domain" || failed.Error.Message == "" {
+		t.Errorf("item 1 error = %+v, want invalid_domain with a message", failed.Error)
+	}
+}
+
+// testPlaylistEntry is a playlist entry of the fake worker with an FD (360p) format
+func testPlaylistEntry(id string) string {
+	return `{"id": "` + id + `", "title": "Video ` + id + `", "url": "https://www.youtube.com/watch?v=` + id + `", "formats": [
+		{"format_id": "18", "ext": "mp4", "resolution": "640x360", "height": 360, "vcodec": "avc1", "acodec": "mp4a", "filesize": 65548}
+	]}`
+}
+
+func TestPlaylistDownload(t *testing.T) {
+	// An audio-only entry has no FD format, so it is rejected before downloading
+	audioOnly := `{"id": "audio", "title": "Audio", "url": "https://www.youtube.com/watch?v=audio", "formats": [
+		{"format_id": "140", "ext": "m4a", "resolution": "audio only", "vcodec": "none", "acodec": "mp4a", "filesize": 32000}
+	]}`
+
+	tests := []struct {
+		name       string
+		entries    []string
+		wantStatus int
+		wantItems  []string // Status per item
+	}{
+		{"partial success", []string{testPlaylistEntry("good"), audioOnly, testPlaylistEntry("gone")}, http.StatusOK,
+			[]string{model.BatchItemDone, model.BatchItemFailed, model.BatchItemFailed}},
+		{"every item fails", []string{audioOnly, testPlaylistEntry("gone")}, http.StatusUnprocessableEntity,
+			[]string{model.BatchItemFailed, model.BatchItemFailed}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				var body struct {
+					URL string `json:"url"`
+				}
+				json.NewDecoder(r.Body).Decode(&body)
+				switch {
+				case r.URL.Path == "/api/info":
+					w.Header().Set("Content-Type", "application/json")
+					w.Write([]byte(`{"id": "list", "title": "Test playlist", "playlist_count": ` + strconv.Itoa(len(tt.entries)) +
+						`, "entries": [` + strings.Join(tt.entries, ",") + `]}`))
+				case strings.HasSuffix(body.URL, "v=gone"):
+					w.WriteHeader(http.StatusBadRequest)
+				default:
+					serveTestMedia(w, r)
+				}
+			})
+
+			w := s.do(http.MethodPost, "/api/download/playlist", model.PlaylistDownloadRequest{URL: "https://www.youtube.com/playlist?list=abc", Quality: "FD"}, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("POST /api/download/playlist = %d %s, want %d", w.Code, w.Body, tt.wantStatus)
+			}
+			var resp struct {
+				model.ErrorResponse
+				model.BatchResponse
+				Title      string `json:"title"`
+				EntryCount int    `json:"entry_count"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Title != "Test playlist" || resp.EntryCount != len(tt.entries) {
+				t.Errorf("playlist = %q with %d entries, want %q with %d", resp.Title, resp.EntryCount, "Test playlist", len(tt.entries))
+			}
+
+			if tt.wantStatus == http.StatusOK {
+				if resp.Error != "" {
+					t.Errorf("error = %+v, want none with an item done", resp.ErrorResponse)
+				}
+			} else if resp.Error != "playlist_failed" || resp.Code != tt.wantStatus || resp.Message == "" {
+				t.Errorf("error = %+v, want playlist_failed", resp.ErrorResponse)
+			}
+
+			if len(resp.Items) != len(tt.wantItems) {
+				t.Fatalf("got %d items, want %d", len(resp.Items), len(tt.wantItems))
+			}
+			for i, item := range resp.Items {
+				if item.Status != tt.wantItems[i] {
+					t.Errorf("item %d = %s %+v, want %s", i, item.Status, item.Error, tt.wantItems[i])
+				}
+				// Failed items carry their own error either way
+				if item.Status == model.BatchItemFailed && (item.Error == nil || item.Error.Error == "") {
+					t.Errorf("failed item %d has no error", i)
+				}
+			}
+		})
+	}
+}
+
+func TestPlaylistDownloadRejectsSingleVideo(t *testing.T) {
+	s := newTestServer(t, serveTestWorker)
+
+	w := s.do(http.MethodPost, "/api/download/playlist", model.PlaylistDownloadRequest{URL: testDownload.URL, Quality: "FD"}, nil)
+	var resp model.ErrorResponse
+	if json.Unmarshal(w.Body.Bytes(), &resp); w.Code != http.StatusBadRequest || resp.Error != "not_playlist" {
+		t.Errorf("POST /api/download/playlist = %d %s, want 400 not_playlist", w.Code, w.Body)
+	}
+}
+
+func TestVideoTokenDownload(t *testing.T) {
+	t.Setenv("VIDEO_TOKEN_SECRET", "token-secret")
+	t.Setenv("INFO_ALLOWED_DOMAINS", "youtube.com,vimeo.com")
+	t.Setenv("DOWNLOAD_ALLOWED_DOMAINS", "youtube.com")
+	var workerURL atomic.Value
+	s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/info" {
+			var body model.PythonWorkerDownloadRequest
+			json.NewDecoder(r.Body).Decode(&body)
+			workerURL.Store(body.URL)
+		}
+		serveTestWorker(w, r)
+	})
+
+	// token fetches the info of videoURL and returns its video token
+	token := func(videoURL string) string {
+		t.Helper()
+		w := s.do(http.MethodGet, "/api/video/info?url="+url.QueryEscape(videoURL), nil, nil)
+		var info model.VideoInfo
+		if json.Unmarshal(w.Body.Bytes(), &info); w.Code != http.StatusOK || info.VideoToken == "" {
+			t.Fatalf("GET /api/video/info = %d %s, want 200 with a video_token", w.Code, w.Body)
+		}
+		return info.VideoToken
+	}
+
+	job := s.startDownload(t, model.DownloadRequest{VideoToken: token(testDownload.URL), FormatID: "18"}, nil)
+	if done := s.waitForJob(t, job.JobID, nil); done.Status != model.JobDone {
+		t.Fatalf("job = %s %+v, want done", done.Status, done.Error)
+	}
+	if got := workerURL.Load(); got != testDownload.URL {
+		t.Errorf("worker got URL %v, want %s", got, testDownload.URL)
+	}
+
+	valid := token(testDownload.URL)
+	tests := []struct {
+		name      string
+		token     string
+		wantError string
+	}{
+		{"tampered token", valid[:len(valid)-2] + "AA", "invalid_video_token"},
+		{"not a token", testDownload.URL, "invalid_video_token"},
+		// The allowlist is checked again for the URL behind the token
+		{"token for an info-only domain", token("https://vimeo.com/123456"), "invalid_domain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(http.MethodPost, "/api/download", model.DownloadRequest{VideoToken: tt.token, FormatID: "18"}, nil)
+			var resp model.ErrorResponse
+			if json.Unmarshal(w.Body.Bytes(), &resp); w.Code != http.StatusBadRequest || resp.Error != tt.wantError {
+				t.Errorf("POST /api/download = %d %s, want 400 %s", w.Code, w.Body, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestFormatFallback(t *testing.T) {
+	const listed = `{"id": "abc123", "title": "Test video", "formats": [
+		{"format_id": "22", "ext": "mp4", "resolution": "1280x720", "height": 720, "vcodec": "avc1", "acodec": "mp4a"},
+		{"format_id": "18", "ext": "mp4", "resolution": "640x360", "height": 360, "vcodec": "avc1", "acodec": "mp4a"}
+	]}`
+	const withHD = `{"id": "abc123", "title": "Test video", "formats": [
+		{"format_id": "45", "ext": "mp4", "resolution": "1280x720", "height": 720, "vcodec": "avc1", "acodec": "mp4a"},
+		{"format_id": "18", "ext": "mp4", "resolution": "640x360", "height": 360, "vcodec": "avc1", "acodec": "mp4a"}
+	]}`
+	const withoutHD = `{"id": "abc123", "title": "Test video", "formats": [
+		{"format_id": "18", "ext": "mp4", "resolution": "640x360", "height": 360, "vcodec": "avc1", "acodec": "mp4a"}
+	]}`
+
+	tests := []struct {
+		name         string
+		fallback     string
+		updated      string // Info after format 22 was pruned
+		wantFallback *model.FormatFallback
+		wantFetched  []string
+	}{
+		{"falls back within the category", "true", withHD, &model.FormatFallback{RequestedFormatID: "22", FormatID: "45", Quality: "HD"}, []string{"22", "45"}},
+		{"fallback off", "false", withHD, nil, []string{"22"}},
+		{"no format left in the category", "true", withoutHD, nil, []string{"22"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FORMAT_FALLBACK", tt.fallback)
+			var mu sync.Mutex
+			var fetched []string
+			pruned := false
+			s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				if r.URL.Path == "/api/info" {
+					w.Header().Set("Content-Type", "application/json")
+					if pruned {
+						w.Write([]byte(tt.updated))
+					} else {
+						w.Write([]byte(listed))
+					}
+					return
+				}
+				var body model.PythonWorkerDownloadRequest
+				json.NewDecoder(r.Body).Decode(&body)
+				fetched = append(fetched, body.FormatID)
+				if body.FormatID == "22" {
+					pruned = true
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusGone)
+					w.Write([]byte(`{"error": "format_unavailable", "message": "The selected format is not available for this video", "code": 410}`))
+					return
+				}
+				serveTestMedia(w, r)
+			})
+
+			// The client picked format 22 from the info, which is how its category is known
+			if w := s.do(http.MethodGet, "/api/video/info?url="+url.QueryEscape(testDownload.URL), nil, nil); w.Code != http.StatusOK {
+				t.Fatalf("GET /api/video/info = %d %s, want 200", w.Code, w.Body)
+			}
+			job := s.waitForJob(t, s.startDownload(t, model.DownloadRequest{URL: testDownload.URL, FormatID: "22"}, nil).JobID, nil)
+			if tt.wantFallback != nil {
+				if job.Status != model.JobDone {
+					t.Fatalf("job = %s %+v, want done", job.Status, job.Error)
+				}
+				if got := job.Download.FormatFallback; got == nil || *got != *tt.wantFallback {
+					t.Errorf("format_fallback = %+v, want %+v", got, tt.wantFallback)
+				}
+			} else if job.Status != model.JobFailed || job.Error.Code != http.StatusGone || job.Error.Error != "format_unavailable" {
+				t.Errorf("job = %s %+v, want failed with 410 format_unavailable", job.Status, job.Error)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if strings.Join(fetched, ",") != strings.Join(tt.wantFetched, ",") {
+				t.Errorf("worker downloads = %v, want %v", fetched, tt.wantFetched)
+			}
+		})
+	}
+}
+
+func TestDefaultQuality(t *testing.T) {
+	tests := []struct {
+		name           string
+		defaultQuality string
+		wantStatus     int
+		wantFormatID   string
+	}{
+		{"format_id required without a default", "", http.StatusBadRequest, ""},
+		{"video default", "FD", http.StatusAccepted, "18"},
+		{"audio default", "Audio", http.StatusAccepted, "140"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DEFAULT_QUALITY", tt.defaultQuality)
+			var formatID atomic.Value
+			s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/info" {
+					var body model.PythonWorkerDownloadRequest
+					json.NewDecoder(r.Body).Decode(&body)
+					formatID.Store(body.FormatID)
+				}
+				serveTestWorker(w, r)
+			})
+
+			w := s.do(http.MethodPost, "/api/download", model.DownloadRequest{URL: testDownload.URL}, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("URL-only download = %d %s, want %d", w.Code, w.Body, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusAccepted {
+				return
+			}
+			var job model.DownloadJob
+			json.Unmarshal(w.Body.Bytes(), &job)
+			if done := s.waitForJob(t, job.JobID, nil); done.Status != model.JobDone {
+				t.Fatalf("job = %s %+v, want done", done.Status, done.Error)
+			}
+			if got, _ := formatID.Load().(string); got != tt.wantFormatID {
+				t.Errorf("worker downloaded format %q, want %q", got, tt.wantFormatID)
+			}
+		})
+	}
+}
+
+func TestBatchIDsAreRandom(t *testing.T) {
+	s := newTestServer(t, serveTestWorker)
+
+	ids := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		w := s.do(http.MethodPost, "/api/download/batch", model.BatchDownloadRequest{Items: []model.DownloadRequest{testDownload}}, nil)
+		var batch model.BatchResponse
+		json.Unmarshal(w.Body.Bytes(), &batch)
+		if w.Code != http.StatusOK {
+			t.Fatalf("POST /api/download/batch = %d %s, want 200", w.Code, w.Body)
+		}
+		if len(batch.BatchID) != 32 || strings.Trim(batch.BatchID, "0123456789abcdef") != "" {
+			t.Errorf("batch ID %q is not 32 hex characters", batch.BatchID)
+		}
+		ids[batch.BatchID] = true
+	}
+	if len(ids) != 3 {
+		t.Errorf("3 batches got %d distinct IDs", len(ids))
+	}
+}
+
+func TestStorageCap(t *testing.T) {
+	tests := []struct {
+		name        string
+		evict       string
+		wantStatus  string
+		wantEvicted bool
+	}{
+		{"rejected at the cap", "false", model.JobFailed, false},
+		{"oldest evicted at the cap", "true", model.JobDone, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MAX_TOTAL_STORED_MB", "1")
+			t.Setenv("STORAGE_EVICT_OLDEST", tt.evict)
+			s := newTestServer(t, serveTestWorker)
+
+			// A stored file leaves less room than the 64KB test media needs
+			if err := s.storageManager.EnsureDownloadDir(); err != nil {
+				t.Fatal(err)
+			}
+			oldPath, err := s.storageManager.GetDownloadPathForID("old", "old.mp4")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(oldPath, []byte("old"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			s.storageManager.SaveFile("old", &model.DownloadedFile{Filename: "old.mp4", FilePath: oldPath, Size: 1024*1024 - 1024})
+
+			job := s.startDownload(t, testDownload, nil)
+			done := s.waitForJob(t, job.JobID, nil)
+			if done.Status != tt.wantStatus {
+				t.Fatalf("job = %s %+v, want %s", done.Status, done.Error, tt.wantStatus)
+			}
+			if tt.wantStatus == model.JobFailed && (done.Error.Code != http.StatusInsufficientStorage || done.Error.Error != "storage_full") {
+				t.Errorf("error = %d %s, want 507 storage_full", done.Error.Code, done.Error.Error)
+			}
+
+			stillTracked := s.storageManager.GetFile("old") != nil
+			if stillTracked == tt.wantEvicted {
+				t.Errorf("old file tracked = %v, want %v", stillTracked, !tt.wantEvicted)
+			}
+			if total := s.storageManager.GetTotalBytes(); total > 1024*1024 {
+				t.Errorf("stored %d bytes, over the 1MB cap", total)
+			}
+		})
+	}
+}
+
+func TestFileInfoMatchesStoredFile(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "192.0.2.1")
+	t.Setenv("EXPIRED_LINK_GRACE_SECONDS", "3600")
+	s := newTestServer(t, serveTestWorker)
+
+	job := s.waitForJob(t, s.startDownload(t, testDownload, nil).JobID, nil)
+	if job.Status != model.JobDone {
+		t.Fatalf("job = %s %+v, want done", job.Status, job.Error)
+	}
+	id := job.Download.ID
+	stored := s.storageManager.GetFile(id)
+
+	w := s.do(http.MethodGet, "/api/download/"+id+"/info", nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET info = %d %s, want 200", w.Code, w.Body)
+	}
+	var info model.FileInfoResponse
+	json.Unmarshal(w.Body.Bytes(), &info)
+	if info.ID != id || info.Filename != stored.Filename || info.Size != int64(len(testMedia)) || info.Size != stored.Size ||
+		info.ContentType != "video/mp4" || info.URL != testDownload.URL || !info.CreatedAt.Equal(stored.CreatedAt) ||
+		!info.ExpiresAt.Equal(stored.ExpiresAt) || !strings.HasSuffix(info.DownloadLink, "/api/download/"+id) {
+		t.Errorf("info = %+v, want the stored file %+v", info, stored)
+	}
+	if sum := sha256.Sum256(testMedia); info.SHA256 != "" && info.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("sha256 = %s, want the checksum of the stored bytes", info.SHA256)
+	}
+
+	// Other clients can't see the file, and once expired it answers like GetFile
+	other := http.Header{"X-Forwarded-For": {"203.0.113.9"}}
+	if w := s.do(http.MethodGet, "/api/download/"+id+"/info", nil, other); w.Code != http.StatusNotFound {
+		t.Errorf("other client's GET info = %d, want 404", w.Code)
+	}
+	s.storageManager.GetFile(id).ExpiresAt = time.Now().Add(-time.Minute)
+	s.storageManager.ManualCleanup()
+	if w := s.do(http.MethodGet, "/api/download/"+id+"/info", nil, nil); w.Code != http.StatusGone {
+		t.Errorf("GET info after expiry = %d %s, want 410", w.Code, w.Body)
+	}
+	if w := s.do(http.MethodGet, "/api/download/1712345678000000001/info", nil, nil); w.Code != http.StatusNotFound {
+		t.Errorf("GET info of an unknown ID = %d, want 404", w.Code)
+	}
+}
+
+func TestServeCustomMappedExtension(t *testing.T) {
+	t.Setenv("EXTENSION_MIME_TYPES", ".vid:video/x-custom")
+	s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="Test video.vid"`)
+		w.Write(testMedia)
+	})
+
+	job := s.waitForJob(t, s.startDownload(t, testDownload, nil).JobID, nil)
+	if job.Status != model.JobDone {
+		t.Fatalf("job = %s %+v, want done", job.Status, job.Error)
+	}
+	w := s.do(http.MethodGet, "/api/download/"+job.Download.ID, nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET file = %d %s, want 200", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); got != "video/x-custom" {
+		t.Errorf("Content-Type = %q, want video/x-custom", got)
+	}
+}
+
+func TestServeDisposition(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		filename    string
+		body        []byte
+		query       string
+		want        string
+	}{
+		{"vtt is inline", "text/vtt", "Vidéo.vtt", []byte("WEBVTT\n\n00:00.000 --> 00:01.000\nHello\n"), "", `inline; filename*=UTF-8''Vid%C3%A9o.vtt`},
+		{"mp4 is an attachment", "video/mp4", "Vidéo.mp4", testMedia, "", `attachment; filename*=UTF-8''Vid%C3%A9o.mp4`},
+		{"query asks for inline mp4", "video/mp4", "Test_video.mp4", testMedia, "?disposition=inline", `inline; filename="Test_video.mp4"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Header().Set("Content-Disposition", buildContentDispositionHeader("attachment", tt.filename))
+				w.Write(tt.body)
+			})
+
+			job := s.waitForJob(t, s.startDownload(t, testDownload, nil).JobID, nil)
+			if job.Status != model.JobDone {
+				t.Fatalf("job = %s %+v, want done", job.Status, job.Error)
+			}
+			w := s.do(http.MethodGet, "/api/download/"+job.Download.ID+tt.query, nil, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET file = %d %s, want 200", w.Code, w.Body)
+			}
+			if got := w.Header().Get("Content-Disposition"); got != tt.want {
+				t.Errorf("Content-Disposition = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileCacheHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		noStore     string
+		query       string
+		wantNoStore bool
+	}{
+		{"private within the TTL by default", "false", "", false},
+		{"no_cache forbids caching", "false", "?no_cache=true", true},
+		{"FILE_NO_STORE forbids caching for every file", "true", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FILE_TTL_SECONDS", "600")
+			t.Setenv("FILE_NO_STORE", tt.noStore)
+			s := newTestServer(t, serveTestWorker)
+
+			job := s.waitForJob(t, s.startDownload(t, testDownload, nil).JobID, nil)
+			if job.Status != model.JobDone {
+				t.Fatalf("job = %s %+v, want done", job.Status, job.Error)
+			}
+			w := s.do(http.MethodGet, "/api/download/"+job.Download.ID+tt.query, nil, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET file = %d %s, want 200", w.Code, w.Body)
+			}
+
+			cacheControl := w.Header().Get("Cache-Control")
+			if tt.wantNoStore {
+				if cacheControl != "no-store, max-age=0" || w.Header().Get("Pragma") != "no-cache" || w.Header().Get("Expires") != "0" {
+					t.Errorf("headers = %v, want no-store with Pragma and Expires", w.Header())
+				}
+				return
+			}
+			var maxAge int
+			if _, err := fmt.Sscanf(cacheControl, "private, max-age=%d", &maxAge); err != nil || maxAge <= 0 || maxAge > 600 {
+				t.Errorf("Cache-Control = %q, want private with a max-age within the 600s TTL", cacheControl)
+			}
+			if pragma := w.Header().Get("Pragma"); pragma != "" {
+				t.Errorf("Pragma = %q, want none", pragma)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"videodownload/internal/model"
+	"videodownload/internal/service"
+	"videodownload/pkg/logger"
+	"videodownload/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// FeedHandler serves the caller's recent downloads as a pollable feed
+type FeedHandler struct {
+	downloadService *service.DownloadService
+	cfg             *model.Config
+}
+
+// NewFeedHandler creates a new feed handler
+func NewFeedHandler(ds *service.DownloadService, cfg *model.Config) *FeedHandler {
+	return &FeedHandler{
+		downloadService: ds,
+		cfg:             cfg,
+	}
+}
+
+// maxExportEntries caps a single history export page
+const maxExportEntries = 1000
+
+// atomFeed is the Atom (RFC 4287) representation of the downloads feed
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// atomEntry is a single download in the Atom feed
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Summary string   `xml:"summary"`
+}
+
+// atomLink is an Atom link element
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+// GetFeed handles GET /api/downloads/feed
+// Query: since (RFC 3339 cursor), format (json|atom)
+func (h *FeedHandler) GetFeed(c *gin.Context) {
+	window := time.Duration(h.cfg.Storage.FeedWindowSeconds) * time.Second
+	since := time.Now().Add(-window)
+
+	if sinceStr := c.Query("since"); sinceStr != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, sinceStr)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid_request", "Parameter since must be an RFC 3339 timestamp")
+			return
+		}
+		if parsed.After(since) {
+			since = parsed
+		}
+	}
+
+	clientKey := middleware.GetClientKey(c)
+	files := h.downloadService.ListDownloads(clientKey, since)
+
+	entries := make([]model.FeedEntry, 0, len(files))
+	for _, file := range files {
+		entries = append(entries, model.FeedEntry{
+			ID:           file.ID,
+			Filename:     file.Filename,
+			Size:         file.Size,
+			URL:          file.URL,
+			DownloadLink: publicLink(c, &h.cfg.Server, fmt.Sprintf("/api/download/%s", file.ID)),
+			CreatedAt:    file.CreatedAt,
+			ExpiresAt:    file.ExpiresAt,
+		})
+	}
+
+	// Feeds are per-client and change with every download
+	c.Header("Cache-Control", "private, no-cache")
+	c.Header("Vary", "Accept, "+h.cfg.ClientLimits.APIKeyHeader)
+	updated := since
+	if len(entries) > 0 {
+		updated = entries[len(entries)-1].CreatedAt
+		c.Header("Last-Modified", updated.UTC().Format(http.TimeFormat))
+	}
+
+	logger.FromContext(c).Debug("Downloads feed served", zap.String("client", clientKey), zap.Int("entries", len(entries)))
+
+	if c.Query("format") == "atom" {
+		h.writeAtom(c, entries, updated)
+		return
+	}
+
+	resp := model.FeedResponse{Entries: entries}
+	if len(entries) > 0 {
+		resp.NextSince = updated.Format(time.RFC3339Nano)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// writeAtom renders the feed entries as an Atom document
+func (h *FeedHandler) writeAtom(c *gin.Context, entries []model.FeedEntry, updated time.Time) {
+	feed := atomFeed{
+		ID:      "urn:vidhub:downloads",
+		Title:   "VidHub downloads",
+		Updated: updated.UTC().Format(time.RFC3339),
+	}
+
+	for _, entry := range entries {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      "urn:vidhub:download:" + entry.ID,
+			Title:   entry.Filename,
+			Updated: entry.CreatedAt.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: entry.DownloadLink, Rel: "enclosure"},
+			Summary: fmt.Sprintf("%s (%d bytes), expires %s", entry.URL, entry.Size, entry.ExpiresAt.UTC().Format(time.RFC3339)),
+		})
+	}
+
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		logger.FromContext(c).Error("Failed to render Atom feed", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, "feed_failed", "Failed to render feed")
+		return
+	}
+
+	c.Data(http.StatusOK, "application/atom+xml; charset=utf-8", append([]byte(xml.Header), body...))
+}
+
+// ExportDownloads handles GET /api/downloads/export
+// Query: format (json|csv), offset, limit (default and max 1000)
+// Exports the caller's tracked downloads, oldest first
+func (h *FeedHandler) ExportDownloads(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		respondError(c, http.StatusBadRequest, "invalid_request", "Parameter format must be json or csv")
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		respondError(c, http.StatusBadRequest, "invalid_request", "Parameter offset must be a non-negative integer")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(maxExportEntries)))
+	if err != nil || limit <= 0 {
+		respondError(c, http.StatusBadRequest, "invalid_request", "Parameter limit must be a positive integer")
+		return
+	}
+	if limit > maxExportEntries {
+		limit = maxExportEntries
+	}
+
+	clientKey := middleware.GetClientKey(c)
+	files := h.downloadService.ListDownloads(clientKey, time.Time{})
+	total := len(files)
+	if offset > total {
+		offset = total
+	}
+	if offset+limit < total {
+		files = files[offset : offset+limit]
+	} else {
+		files = files[offset:]
+	}
+
+	now := time.Now()
+	entries := make([]model.ExportEntry, 0, len(files))
+	for _, file := range files {
+		status := model.DownloadAvailable
+		if now.After(file.ExpiresAt) {
+			status = model.DownloadExpired
+		}
+		entries = append(entries, model.ExportEntry{
+			ID:        file.ID,
+			Filename:  file.Filename,
+			URL:       file.URL,
+			Size:      file.Size,
+			SHA256:    file.SHA256,
+			Platform:  file.Platform,
+			Status:    status,
+			CreatedAt: file.CreatedAt,
+			ExpiresAt: file.ExpiresAt,
+		})
+	}
+
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	logger.FromContext(c).Debug("Download history exported", zap.String("format", format), zap.Int("entries", len(entries)))
+
+	if format == "csv" {
+		h.writeCSV(c, entries)
+		return
+	}
+	c.JSON(http.StatusOK, model.ExportResponse{Entries: entries, Total: total})
+}
+
+// writeCSV streams export entries as CSV, one flushed row at a time
+func (h *FeedHandler) writeCSV(c *gin.Context, entries []model.ExportEntry) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="downloads.csv"`)
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"id", "filename", "url", "size", "sha256", "platform", "status", "created_at", "expires_at"})
+	for _, entry := range entries {
+		writer.Write([]string{
+			entry.ID,
+			csvSafe(entry.Filename),
+			csvSafe(entry.URL),
+			strconv.FormatInt(entry.Size, 10),
+			entry.SHA256,
+			entry.Platform,
+			entry.Status,
+			entry.CreatedAt.UTC().Format(time.RFC3339),
+			entry.ExpiresAt.UTC().Format(time.RFC3339),
+		})
+		writer.Flush()
+	}
+
+	if err := writer.Error(); err != nil {
+		logger.FromContext(c).Warn("Failed to stream history export", zap.Error(err))
+	}
+}
+
+// csvSafe neutralizes cells that spreadsheets would evaluate as formulas
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
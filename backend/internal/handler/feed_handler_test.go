@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"videodownload/internal/model"
+)
+
+func TestExportDownloads(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "192.0.2.1")
+	s := newTestServer(t, serveTestWorker)
+
+	// The caller has two finished downloads, another client has one
+	other := http.Header{"X-Forwarded-For": {"203.0.113.9"}}
+	var mine []string
+	for _, formatID := range []string{"18", "140"} {
+		req := model.DownloadRequest{URL: testDownload.URL, FormatID: formatID}
+		job := s.waitForJob(t, s.startDownload(t, req, nil).JobID, nil)
+		if job.Status != model.JobDone {
+			t.Fatalf("job = %s %+v, want done", job.Status, job.Error)
+		}
+		mine = append(mine, job.Download.ID)
+	}
+	if job := s.waitForJob(t, s.startDownload(t, testDownload, other).JobID, other); job.Status != model.JobDone {
+		t.Fatalf("other client's job = %s %+v, want done", job.Status, job.Error)
+	}
+
+	t.Run("json", func(t *testing.T) {
+		w := s.do(http.MethodGet, "/api/downloads/export?format=json", nil, nil)
+		var export model.ExportResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &export); w.Code != http.StatusOK || err != nil {
+			t.Fatalf("export = %d %s, want 200 JSON", w.Code, w.Body)
+		}
+		if export.Total != 2 || len(export.Entries) != 2 {
+			t.Fatalf("export has %d of %d entries, want 2 of 2", len(export.Entries), export.Total)
+		}
+		for i, entry := range export.Entries {
+			if entry.ID != mine[i] || entry.URL != testDownload.URL || entry.Size != int64(len(testMedia)) ||
+				entry.Status != model.DownloadAvailable || entry.CreatedAt.IsZero() {
+				t.Errorf("entry %d = %+v, want download %s", i, entry, mine[i])
+			}
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		w := s.do(http.MethodGet, "/api/downloads/export?format=csv", nil, nil)
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv") {
+			t.Fatalf("export = %d %s, want 200 text/csv", w.Code, w.Header().Get("Content-Type"))
+		}
+		rows, err := csv.NewReader(w.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("parse CSV: %v", err)
+		}
+		if len(rows) != 3 || rows[0][0] != "id" || rows[0][3] != "size" {
+			t.Fatalf("CSV = %v, want a header and 2 rows", rows)
+		}
+		for i, row := range rows[1:] {
+			if row[0] != mine[i] || row[2] != testDownload.URL || row[6] != model.DownloadAvailable {
+				t.Errorf("row %d = %v, want download %s", i+1, row, mine[i])
+			}
+		}
+	})
+
+	t.Run("scoped to the caller", func(t *testing.T) {
+		w := s.do(http.MethodGet, "/api/downloads/export", nil, other)
+		var export model.ExportResponse
+		json.Unmarshal(w.Body.Bytes(), &export)
+		if w.Code != http.StatusOK || export.Total != 1 || export.Entries[0].ID == mine[0] || export.Entries[0].ID == mine[1] {
+			t.Errorf("other client's export = %d %s, want only its own download", w.Code, w.Body)
+		}
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		w := s.do(http.MethodGet, "/api/downloads/export?offset=1&limit=1", nil, nil)
+		var export model.ExportResponse
+		json.Unmarshal(w.Body.Bytes(), &export)
+		if export.Total != 2 || len(export.Entries) != 1 || export.Entries[0].ID != mine[1] {
+			t.Errorf("export page = %s, want the second download of 2", w.Body)
+		}
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		if w := s.do(http.MethodGet, "/api/downloads/export?format=xml", nil, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("format=xml = %d, want 400", w.Code)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"net"
+	"strings"
+
+	"videodownload/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// publicBaseURLAuto derives the base URL from each request instead of a fixed value
+const publicBaseURLAuto = "auto"
+
+// publicLink turns a server-relative path into the link returned to clients
+// Links stay relative unless PUBLIC_BASE_URL is set
+func publicLink(c *gin.Context, cfg *model.ServerConfig, path string) string {
+	switch cfg.PublicBaseURL {
+	case "":
+		return path
+	case publicBaseURLAuto:
+		return requestBaseURL(c, cfg) + path
+	default:
+		return cfg.PublicBaseURL + path
+	}
+}
+
+// publicDownload returns a copy of resp whose download link is public
+func publicDownload(c *gin.Context, cfg *model.ServerConfig, resp *model.DownloadResponse) *model.DownloadResponse {
+	if resp == nil || cfg.PublicBaseURL == "" {
+		return resp
+	}
+	public := *resp
+	public.DownloadLink = publicLink(c, cfg, resp.DownloadLink)
+	return &public
+}
+
+// publicBatch rewrites the download links of a batch response in place
+func publicBatch(c *gin.Context, cfg *model.ServerConfig, batch *model.BatchResponse) *model.BatchResponse {
+	for i := range batch.Items {
+		batch.Items[i].Download = publicDownload(c, cfg, batch.Items[i].Download)
+	}
+	return batch
+}
+
+// publicJob fills in the status link of an async job and makes its download link public
+func publicJob(c *gin.Context, cfg *model.ServerConfig, job *model.DownloadJob) *model.DownloadJob {
+	job.StatusLink = publicLink(c, cfg, "/api/download/status/"+job.JobID)
+	job.Download = publicDownload(c, cfg, job.Download)
+	return job
+}
+
+// requestBaseURL derives scheme://host of the request
+// X-Forwarded-Proto and X-Forwarded-Host are only trusted from TRUSTED_PROXIES
+func requestBaseURL(c *gin.Context, cfg *model.ServerConfig) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	host := c.Request.Host
+
+	if isTrustedProxy(c.RemoteIP(), cfg.TrustedProxies) {
+		if proto := firstForwardedValue(c.GetHeader("X-Forwarded-Proto")); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwardedHost := firstForwardedValue(c.GetHeader("X-Forwarded-Host")); forwardedHost != "" {
+			host = forwardedHost
+		}
+	}
+
+	return scheme + "://" + host
+}
+
+// firstForwardedValue returns the client-most value of a comma-separated forwarded header
+func firstForwardedValue(header string) string {
+	value, _, _ := strings.Cut(header, ",")
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// isTrustedProxy reports whether ip matches one of the trusted proxy IPs or CIDRs
+func isTrustedProxy(ip string, trustedProxies []string) bool {
+	remote := net.ParseIP(ip)
+	if remote == nil {
+		return false
+	}
+
+	for _, proxy := range trustedProxies {
+		if strings.Contains(proxy, "/") {
+			if _, network, err := net.ParseCIDR(proxy); err == nil && network.Contains(remote) {
+				return true
+			}
+			continue
+		}
+		if trusted := net.ParseIP(proxy); trusted != nil && trusted.Equal(remote) {
+			return true
+		}
+	}
+	return false
+}
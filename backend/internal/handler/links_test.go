@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPublicLinks(t *testing.T) {
+	tests := []struct {
+		name           string
+		publicBaseURL  string
+		trustedProxies string
+		header         http.Header
+		wantBase       string
+	}{
+		{"relative by default", "", "", nil, ""},
+		{"fixed base URL", "https://vidhub.example.com", "", nil, "https://vidhub.example.com"},
+		{"auto uses the request host", "auto", "", nil, "http://example.com"},
+		{
+			"auto ignores forwarded headers from untrusted peers", "auto", "10.0.0.1",
+			http.Header{"X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"evil.example.com"}},
+			"http://example.com",
+		},
+		{
+			"auto honors forwarded headers from a trusted proxy", "auto", "192.0.2.1",
+			http.Header{"X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"vidhub.example.com"}},
+			"https://vidhub.example.com",
+		},
+		{
+			"auto takes the client-most value from a trusted CIDR", "auto", "192.0.2.0/24",
+			http.Header{"X-Forwarded-Proto": {"HTTPS, http"}, "X-Forwarded-Host": {"Vidhub.example.com, proxy.internal"}},
+			"https://vidhub.example.com",
+		},
+		{
+			"auto ignores an unknown forwarded scheme", "auto", "192.0.2.1",
+			http.Header{"X-Forwarded-Proto": {"ftp"}},
+			"http://example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PUBLIC_BASE_URL", tt.publicBaseURL)
+			t.Setenv("TRUSTED_PROXIES", tt.trustedProxies)
+			s := newTestServer(t, serveTestWorker)
+
+			// httptest requests come from 192.0.2.1 with Host example.com
+			job := s.startDownload(t, testDownload, tt.header)
+			if want := tt.wantBase + "/api/download/status/" + job.JobID; job.StatusLink != want {
+				t.Errorf("status_link = %q, want %q", job.StatusLink, want)
+			}
+
+			job = s.waitForJob(t, job.JobID, tt.header)
+			if job.Download == nil {
+				t.Fatalf("job finished without a download: %+v", job)
+			}
+			if want := tt.wantBase + "/api/download/"; !strings.HasPrefix(job.Download.DownloadLink, want) {
+				t.Errorf("download_link = %q, want prefix %q", job.Download.DownloadLink, want)
+			}
+		})
+	}
+}
+
+func TestForwardedClientIP(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies string
+		wantSeparate   bool
+	}{
+		{"untrusted peer cannot pick its client IP", "", false},
+		{"trusted proxy forwards the client IP", "192.0.2.1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRUSTED_PROXIES", tt.trustedProxies)
+			s := newTestServer(t, serveTestWorker)
+
+			// Jobs are scoped to the client, so a job started for a forwarded IP
+			// is only visible to that IP when the proxy is trusted
+			forwarded := http.Header{"X-Forwarded-For": {"203.0.113.9"}}
+			job := s.startDownload(t, testDownload, forwarded)
+			s.waitForJob(t, job.JobID, forwarded)
+
+			w := s.do(http.MethodGet, "/api/download/status/"+job.JobID, nil, nil)
+			if separate := w.Code == http.StatusNotFound; separate != tt.wantSeparate {
+				t.Errorf("status without X-Forwarded-For = %d, want a separate client: %v", w.Code, tt.wantSeparate)
+			}
+		})
+	}
+}
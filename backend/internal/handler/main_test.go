@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"videodownload/config"
+	"videodownload/internal/model"
+	"videodownload/internal/service"
+	"videodownload/internal/storage"
+	"videodownload/pkg/logger"
+	"videodownload/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// TestMain silences logging once, since background jobs of earlier tests may still log
+func TestMain(m *testing.M) {
+	logger.Logger = zap.NewNop()
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// testMedia is a minimal MP4 body served by the fake worker
+var testMedia = append([]byte{0, 0, 0, 0x18, 'f', 't', 'y', 'p', 'm', 'p', '4', '2'}, make([]byte, 64*1024)...)
+
+// serveTestMedia answers worker downloads with testMedia
+func serveTestMedia(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "video/mp4")
+	w.Header().Set("Content-Disposition", `attachment; filename="Test video.mp4"`)
+	w.Write(testMedia)
+}
+
+// testMetadata is the worker info returned for every URL
+const testMetadata = `{
+	"id": "abc123",
+	"title": "Test video",
+	"url": "https://www.youtube.com/watch?v=abc123",
+	"duration": 60,
+	"formats": [
+		{"format_id": "18", "ext": "mp4", "resolution": "640x360", "height": 360, "vcodec": "avc1", "acodec": "mp4a", "filesize": 65548},
+		{"format_id": "140", "ext": "m4a", "resolution": "audio only", "vcodec": "none", "acodec": "mp4a", "filesize": 32000}
+	]
+}`
+
+// serveTestWorker answers worker info requests with testMetadata and downloads with testMedia
+func serveTestWorker(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/info" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(testMetadata))
+		return
+	}
+	serveTestMedia(w, r)
+}
+
+// testServer is the API wired like main.go against a fake worker
+type testServer struct {
+	router          *gin.Engine
+	cfg             *model.Config
+	storageManager  *storage.Manager
+	downloadService *service.DownloadService
+	jobManager      *service.JobManager
+	quotaService    *service.QuotaService
+}
+
+// newTestServer wires the API routes against a fake worker answered by worker
+// Set environment overrides with t.Setenv before calling it
+func newTestServer(t *testing.T, worker http.HandlerFunc) *testServer {
+	t.Helper()
+	// Jobs a test doesn't wait for may still be writing when it ends, so removal is retried
+	// after the worker has answered them
+	dir, err := os.MkdirTemp("", "handler-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { removeAllEventually(t, dir) })
+	workerServer := httptest.NewServer(worker)
+	t.Cleanup(workerServer.Close)
+	workerURL, _ := url.Parse(workerServer.URL)
+
+	t.Setenv("PYTHON_WORKER_HOST", workerURL.Hostname())
+	t.Setenv("PYTHON_WORKER_PORT", workerURL.Port())
+	t.Setenv("DOWNLOAD_DIR", filepath.Join(dir, "downloads"))
+	t.Setenv("STORAGE_TRACKING_FILE", filepath.Join(dir, "files.json"))
+	t.Setenv("STATS_FILE", "")
+	t.Setenv("SEGMENTED_DOWNLOAD_ENABLED", "false")
+	cfg := config.Load()
+
+	storageManager := storage.NewManager(&cfg.Storage)
+	videoService := service.NewVideoService(cfg.Python.Host, cfg.Python.Port, cfg.Python.Timeout, cfg)
+	lifetimeStats := service.NewLifetimeStats(&cfg.Stats)
+	downloadService := service.NewDownloadService(cfg.Python.Host, cfg.Python.Port, cfg.Python.Timeout,
+		storageManager, videoService, lifetimeStats, cfg)
+	quotaService := service.NewQuotaService(&cfg.Quota, nil)
+	t.Cleanup(quotaService.Stop)
+	rateLimitService := service.NewRateLimitService(&cfg.RateLimit)
+	t.Cleanup(rateLimitService.Stop)
+	jobManager := service.NewJobManager(downloadService, quotaService, cfg)
+	batchService := service.NewBatchService(downloadService, jobManager, cfg)
+	profileService, err := service.NewProfileService(cfg)
+	if err != nil {
+		t.Fatalf("NewProfileService: %v", err)
+	}
+	downloadSwitch := service.NewDownloadSwitch(cfg.Server.DownloadsDisabled)
+
+	router := gin.New()
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		t.Fatalf("SetTrustedProxies: %v", err)
+	}
+	router.Use(middleware.ClientProfileMiddleware(profileService, cfg.ClientLimits.APIKeyHeader))
+
+	videoHandler := NewVideoHandler(videoService, storageManager, cfg)
+	feedHandler := NewFeedHandler(downloadService, cfg)
+	adminHandler := NewAdminHandler(service.NewCookieService(&cfg.Admin), downloadSwitch, downloadService, batchService, jobManager, lifetimeStats)
+	downloadHandler := NewDownloadHandler(downloadService, videoService, batchService, jobManager, cfg, quotaService,
+		rateLimitService, downloadSwitch, service.NewDownloadValidator(videoService, cfg))
+
+	quotaCheck := func(c *gin.Context) { c.Next() }
+	if cfg.Quota.Enabled {
+		quotaCheck = middleware.QuotaCheckMiddleware(quotaService, cfg)
+	}
+
+	api := router.Group("/api")
+	api.GET("/video/info", videoHandler.GetVideoInfo)
+	api.GET("/validate", videoHandler.ValidateURL)
+	api.POST("/download", quotaCheck, downloadHandler.StartDownload)
+	api.POST("/download/batch", quotaCheck, downloadHandler.StartBatchDownload)
+	api.POST("/download/playlist", quotaCheck, downloadHandler.StartPlaylistDownload)
+	api.GET("/download/batch/:batchid", downloadHandler.GetBatchStatus)
+	api.DELETE("/download/batch/:batchid", downloadHandler.CancelBatch)
+	api.GET("/download/status/:jobid", downloadHandler.GetJobStatus)
+	api.DELETE("/download/status/:jobid", downloadHandler.CancelJob)
+	api.POST("/download/cancel-all", downloadHandler.CancelAllDownloads)
+	api.GET("/download/progress/:id", downloadHandler.StreamJobProgress)
+	api.GET("/download/:id", downloadHandler.GetFile)
+	api.GET("/download/:id/checksum", downloadHandler.GetChecksum)
+	api.GET("/download/:id/info", downloadHandler.GetFileInfo)
+	api.GET("/downloads/feed", feedHandler.GetFeed)
+	api.GET("/downloads/export", feedHandler.ExportDownloads)
+	api.GET("/health", videoHandler.HealthCheck)
+	admin := api.Group("/admin", middleware.AdminAuthMiddleware(cfg.Admin.APIKey, &cfg.Security))
+	admin.GET("/downloads", adminHandler.GetDownloadSwitch)
+	admin.PUT("/downloads", adminHandler.SetDownloadSwitch)
+	admin.POST("/downloads/cancel-all", adminHandler.CancelAllForIP)
+
+	return &testServer{
+		router:          router,
+		cfg:             cfg,
+		storageManager:  storageManager,
+		downloadService: downloadService,
+		jobManager:      jobManager,
+		quotaService:    quotaService,
+	}
+}
+
+// removeAllEventually removes dir, retrying while background jobs finish writing into it
+func removeAllEventually(t *testing.T, dir string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := os.RemoveAll(dir)
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Errorf("removing %s: %v", dir, err)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// do sends a request through the router; a non-nil body is sent as JSON
+func (s *testServer) do(method, path string, body any, header http.Header) *httptest.ResponseRecorder {
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// startDownload posts a download and returns the queued job
+func (s *testServer) startDownload(t *testing.T, req model.DownloadRequest, header http.Header) *model.DownloadJob {
+	t.Helper()
+	w := s.do(http.MethodPost, "/api/download", req, header)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("POST /api/download = %d %s, want 202", w.Code, w.Body)
+	}
+	var job model.DownloadJob
+	if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+	return &job
+}
+
+// waitForJob polls a job until it is finished
+func (s *testServer) waitForJob(t *testing.T, jobID string, header http.Header) *model.DownloadJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		w := s.do(http.MethodGet, "/api/download/status/"+jobID, nil, header)
+		var job model.DownloadJob
+		if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+			t.Fatalf("status %d %s: %v", w.Code, w.Body, err)
+		}
+		if job.Status == model.JobDone || job.Status == model.JobFailed {
+			return &job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", jobID)
+	return nil
+}
+
+// testDownload is a download request that passes validation
+var testDownload = model.DownloadRequest{URL: "https://www.youtube.com/watch?v=abc123", FormatID: "18"}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"videodownload/internal/service"
+	"videodownload/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsHandler exposes internal gauges in Prometheus text format
+type MetricsHandler struct {
+	quotaService     *service.QuotaService
+	rateLimitService *service.RateLimitService
+	storageManager   *storage.Manager
+	lifetimeStats    *service.LifetimeStats
+}
+
+// NewMetricsHandler creates a new metrics handler
+func NewMetricsHandler(qs *service.QuotaService, rls *service.RateLimitService, sm *storage.Manager, ls *service.LifetimeStats) *MetricsHandler {
+	return &MetricsHandler{
+		quotaService:     qs,
+		rateLimitService: rls,
+		storageManager:   sm,
+		lifetimeStats:    ls,
+	}
+}
+
+// GetMetrics handles GET /api/metrics
+func (h *MetricsHandler) GetMetrics(c *gin.Context) {
+	var b strings.Builder
+
+	writeGauge(&b, "vidhub_quota_entries", "Number of clients tracked by the quota service", float64(h.quotaService.GetEntryCount()))
+	writeGauge(&b, "vidhub_ratelimit_entries", "Number of clients tracked by the rate limiter", float64(h.rateLimitService.GetEntryCount()))
+
+	// Storage gauges are collected on scrape; each getter only holds the storage read lock briefly
+	writeGauge(&b, "vidhub_storage_tracked_files", "Number of downloaded files currently tracked", float64(h.storageManager.GetTrackedFilesCount()))
+	writeGauge(&b, "vidhub_storage_expired_files", "Number of expired files not yet deleted", float64(h.storageManager.GetExpiredFilesCount()))
+	writeGauge(&b, "vidhub_storage_bytes", "Total size of tracked files in bytes", float64(h.storageManager.GetTotalBytes()))
+	writeGauge(&b, "vidhub_storage_oldest_file_age_seconds", "Age of the oldest tracked file in seconds", h.storageManager.GetOldestFileAge().Seconds())
+
+	stats := h.lifetimeStats.Snapshot()
+	writeCounter(&b, "vidhub_downloads_total", "Completed downloads since stats began", float64(stats.Downloads))
+	writeCounter(&b, "vidhub_downloaded_bytes_total", "Bytes stored from completed downloads since stats began", float64(stats.DownloadedBytes))
+	writeCounter(&b, "vidhub_served_bytes_total", "Bytes sent to clients since stats began", float64(stats.ServedBytes))
+
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+}
+
+// writeGauge appends a single gauge in Prometheus text exposition format
+func writeGauge(b *strings.Builder, name string, help string, value float64) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s gauge\n", name)
+	fmt.Fprintf(b, "%s %g\n", name, value)
+}
+
+// writeCounter appends a single counter in Prometheus text exposition format
+func writeCounter(b *strings.Builder, name string, help string, value float64) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s counter\n", name)
+	fmt.Fprintf(b, "%s %g\n", name, value)
+}
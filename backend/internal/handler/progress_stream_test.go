@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"videodownload/internal/model"
+)
+
+func TestProgressStreamLimit(t *testing.T) {
+	t.Setenv("MAX_PROGRESS_STREAMS_PER_CLIENT", "2")
+
+	// Downloads hang until the test ends so the job stays running
+	unblock := make(chan struct{})
+	s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/info" {
+			select {
+			case <-unblock:
+			case <-r.Context().Done():
+			}
+		}
+		serveTestWorker(w, r)
+	})
+	server := httptest.NewServer(s.router)
+	t.Cleanup(server.Close)
+
+	// The job is started through the server so its client key matches the streams
+	data, _ := json.Marshal(testDownload)
+	resp, err := http.Post(server.URL+"/api/download", "application/json", bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var job model.DownloadJob
+	json.NewDecoder(resp.Body).Decode(&job)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("POST /api/download = %d, want 202", resp.StatusCode)
+	}
+	// The job must finish before the download directory is removed
+	t.Cleanup(func() {
+		close(unblock)
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			resp, err := http.Get(server.URL + "/api/download/status/" + job.JobID)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			var status model.DownloadJob
+			json.NewDecoder(resp.Body).Decode(&status)
+			resp.Body.Close()
+			if status.Status == model.JobDone || status.Status == model.JobFailed {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Errorf("job %s did not finish", job.JobID)
+	})
+
+	// open returns the stream response; the caller closes its body to end the stream
+	open := func() *http.Response {
+		t.Helper()
+		resp, err := http.Get(server.URL + "/api/download/progress/" + job.JobID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	first, second := open(), open()
+	for _, resp := range []*http.Response{first, second} {
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("stream within the cap = %d, want 200", resp.StatusCode)
+		}
+		line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+		if !strings.HasPrefix(line, "event:progress") {
+			t.Fatalf("first stream line = %q, want a progress event", line)
+		}
+	}
+	defer second.Body.Close()
+
+	rejected := open()
+	var body model.ErrorResponse
+	json.NewDecoder(rejected.Body).Decode(&body)
+	rejected.Body.Close()
+	if rejected.StatusCode != http.StatusTooManyRequests || body.Error != "progress_stream_limit" {
+		t.Fatalf("stream past the cap = %d %q, want 429 progress_stream_limit", rejected.StatusCode, body.Error)
+	}
+
+	// Closing a stream frees its slot once the server notices the disconnect
+	first.Body.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		resp := open()
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("slot not freed after closing a stream, last status %d", resp.StatusCode)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
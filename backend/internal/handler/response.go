@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"videodownload/internal/model"
+	"videodownload/pkg/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondError writes an ErrorResponse with the message localized from Accept-Language
+// The error code stays stable across languages; only the message changes
+func respondError(c *gin.Context, status int, code string, message string) {
+	c.JSON(status, model.ErrorResponse{
+		Error:   code,
+		Message: i18n.Localize(c.GetHeader("Accept-Language"), code, message),
+		Code:    status,
+	})
+}
+
+// respondIfTimedOut answers 504 when the request's route timeout has passed
+// Returns true when a response was written
+func respondIfTimedOut(c *gin.Context) bool {
+	if !errors.Is(c.Request.Context().Err(), context.DeadlineExceeded) {
+		return false
+	}
+	respondError(c, http.StatusGatewayTimeout, "request_timeout", "Request took too long and was cancelled")
+	return true
+}
+
+// rejection builds an ErrorResponse for a refused request
+func rejection(status int, code string, message string) *model.ErrorResponse {
+	return &model.ErrorResponse{
+		Error:   code,
+		Message: message,
+		Code:    status,
+	}
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"videodownload/internal/model"
+)
+
+// scheduledDownload returns testDownload set to start after delay
+func scheduledDownload(delay time.Duration) model.DownloadRequest {
+	req := testDownload
+	startAt := time.Now().Add(delay).UTC().Truncate(time.Second).Add(time.Second)
+	req.StartAt = &startAt
+	return req
+}
+
+// countingWorker serves serveTestWorker and counts the download calls
+func countingWorker(downloads *atomic.Int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/info" {
+			downloads.Add(1)
+		}
+		serveTestWorker(w, r)
+	}
+}
+
+func TestScheduledDownload(t *testing.T) {
+	var downloads atomic.Int32
+	s := newTestServer(t, countingWorker(&downloads))
+
+	req := scheduledDownload(0)
+	job := s.startDownload(t, req, nil)
+	if job.Status != model.JobScheduled || job.ScheduledAt == nil || !job.ScheduledAt.Equal(*req.StartAt) {
+		t.Fatalf("job = %s scheduled at %v, want scheduled at %v", job.Status, job.ScheduledAt, req.StartAt)
+	}
+	if job.StatusLink != "/api/download/status/"+job.JobID {
+		t.Errorf("status_link = %q, want the job's status link", job.StatusLink)
+	}
+
+	w := s.do(http.MethodGet, "/api/download/status/"+job.JobID, nil, nil)
+	var polled model.DownloadJob
+	json.Unmarshal(w.Body.Bytes(), &polled)
+	if polled.Status != model.JobScheduled || downloads.Load() != 0 {
+		t.Fatalf("before start_at: status %s with %d worker downloads, want scheduled with none", polled.Status, downloads.Load())
+	}
+
+	done := s.waitForJob(t, job.JobID, nil)
+	if done.Status != model.JobDone || downloads.Load() != 1 {
+		t.Fatalf("after start_at: status %s (%+v) with %d worker downloads, want done with one", done.Status, done.Error, downloads.Load())
+	}
+	if done.FinishedAt.Before(*req.StartAt) {
+		t.Errorf("finished at %v, before start_at %v", done.FinishedAt, req.StartAt)
+	}
+}
+
+func TestCancelScheduledDownload(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "192.0.2.1")
+	var downloads atomic.Int32
+	s := newTestServer(t, countingWorker(&downloads))
+	job := s.startDownload(t, scheduledDownload(time.Hour), nil)
+
+	tests := []struct {
+		name       string
+		jobID      string
+		header     http.Header
+		wantStatus int
+		wantError  string
+	}{
+		{"unknown job", "job-unknown", nil, http.StatusNotFound, "not_found"},
+		{"other client's job", job.JobID, http.Header{"X-Forwarded-For": {"203.0.113.9"}}, http.StatusNotFound, "not_found"},
+		{"scheduled job", job.JobID, nil, http.StatusOK, ""},
+		{"already cancelled", job.JobID, nil, http.StatusConflict, "job_started"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(http.MethodDelete, "/api/download/status/"+tt.jobID, nil, tt.header)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("DELETE = %d %s, want %d", w.Code, w.Body, tt.wantStatus)
+			}
+			if tt.wantError != "" {
+				var body model.ErrorResponse
+				json.Unmarshal(w.Body.Bytes(), &body)
+				if body.Error != tt.wantError {
+					t.Errorf("error = %q, want %q", body.Error, tt.wantError)
+				}
+			}
+		})
+	}
+
+	cancelled := s.waitForJob(t, job.JobID, nil)
+	if cancelled.Status != model.JobFailed || cancelled.Error == nil || cancelled.Error.Error != "download_cancelled" {
+		t.Errorf("cancelled job = %s %+v, want failed with download_cancelled", cancelled.Status, cancelled.Error)
+	}
+	if downloads.Load() != 0 {
+		t.Errorf("worker downloads = %d, want none", downloads.Load())
+	}
+}
+
+func TestScheduledDownloadsCountAsQueued(t *testing.T) {
+	t.Setenv("MAX_QUEUED_JOBS", "2")
+	s := newTestServer(t, serveTestWorker)
+
+	s.startDownload(t, scheduledDownload(time.Hour), nil)
+	s.startDownload(t, scheduledDownload(time.Hour), nil)
+	w := s.do(http.MethodPost, "/api/download", scheduledDownload(time.Hour), nil)
+	var body model.ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusTooManyRequests || body.Error != "queue_full" {
+		t.Fatalf("third scheduled download = %d %s, want 429 queue_full", w.Code, w.Body)
+	}
+
+	// cancel-all includes scheduled jobs and frees the queue
+	w = s.do(http.MethodPost, "/api/download/cancel-all", nil, nil)
+	var result model.CancelAllResponse
+	json.Unmarshal(w.Body.Bytes(), &result)
+	if result.Cancelled != 2 {
+		t.Errorf("cancel-all cancelled %d, want 2", result.Cancelled)
+	}
+	s.startDownload(t, scheduledDownload(time.Hour), nil)
+}
+
+func TestScheduleBounds(t *testing.T) {
+	t.Setenv("SCHEDULE_MAX_AHEAD_SECONDS", "3600")
+	s := newTestServer(t, serveTestWorker)
+
+	for _, delay := range []time.Duration{-time.Minute, 2 * time.Hour} {
+		req := testDownload
+		startAt := time.Now().Add(delay)
+		req.StartAt = &startAt
+		w := s.do(http.MethodPost, "/api/download", req, nil)
+		var body model.ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &body)
+		if w.Code != http.StatusBadRequest || body.Error != "invalid_schedule" {
+			t.Errorf("start_at %v from now = %d %s, want 400 invalid_schedule", delay, w.Code, w.Body)
+		}
+	}
+}
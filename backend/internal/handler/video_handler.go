@@ -1,11 +1,16 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"videodownload/internal/model"
 	"videodownload/internal/service"
+	"videodownload/internal/storage"
+	"videodownload/pkg/i18n"
 	"videodownload/pkg/logger"
+	"videodownload/pkg/middleware"
 	"videodownload/pkg/validator"
 
 	"github.com/gin-gonic/gin"
@@ -14,15 +19,17 @@ import (
 
 // VideoHandler handles video-related requests
 type VideoHandler struct {
-	videoService *service.VideoService
-	cfg          *model.Config
+	videoService   *service.VideoService
+	storageManager *storage.Manager
+	cfg            *model.Config
 }
 
 // NewVideoHandler creates a new video handler
-func NewVideoHandler(vs *service.VideoService, cfg *model.Config) *VideoHandler {
+func NewVideoHandler(vs *service.VideoService, sm *storage.Manager, cfg *model.Config) *VideoHandler {
 	return &VideoHandler{
-		videoService: vs,
-		cfg:          cfg,
+		videoService:   vs,
+		storageManager: sm,
+		cfg:            cfg,
 	}
 }
 
@@ -31,47 +38,230 @@ func (h *VideoHandler) GetVideoInfo(c *gin.Context) {
 	videoURL := c.Query("url")
 
 	if videoURL == "" {
-		logger.Logger.Warn("Empty URL provided")
-		c.JSON(http.StatusBadRequest, model.ErrorResponse{
-			Error:   "invalid_url",
-			Message: "Video URL is required",
-			Code:    http.StatusBadRequest,
-		})
+		logger.FromContext(c).Warn("Empty URL provided")
+		respondError(c, http.StatusBadRequest, "invalid_url", "Video URL is required")
+		return
+	}
+
+	// Short share links are validated (and fetched) by their final URL
+	videoURL, err := h.videoService.ResolveShortURL(c.Request.Context(), videoURL)
+	if err != nil {
+		logger.FromContext(c).Warn("Failed to resolve short URL", zap.String("url", c.Query("url")), zap.Error(err))
+		respondError(c, http.StatusBadRequest, "unresolvable_url", "URL redirects could not be followed")
 		return
 	}
 
 	// Validate URL
-	if !validator.ValidateURL(videoURL, h.cfg.Security.AllowedDomains) {
-		logger.Logger.Warn("Invalid URL domain",
+	if !h.checkTargetScheme(c, videoURL) {
+		return
+	}
+	if !validator.ValidateURL(videoURL, h.cfg.Security.InfoAllowedDomains) {
+		logger.FromContext(c).Warn("Invalid URL domain",
 			zap.String("url", videoURL),
-			zap.Strings("allowed_domains", h.cfg.Security.AllowedDomains))
-		c.JSON(http.StatusBadRequest, model.ErrorResponse{
-			Error:   "invalid_domain",
-			Message: "URL domain is not allowed",
-			Code:    http.StatusBadRequest,
-		})
+			zap.Strings("allowed_domains", h.cfg.Security.InfoAllowedDomains))
+		respondError(c, http.StatusBadRequest, "invalid_domain", "URL domain is not allowed")
+		return
+	}
+
+	// Verbose mode adds description, tags and engagement counters
+	verbose := c.Query("verbose") == "true"
+
+	// debug_raw echoes the worker's metadata to admins; without DEBUG_RAW_INFO it is ignored
+	debugRaw := h.cfg.Admin.DebugRawInfo && c.Query("debug_raw") == "true"
+	if debugRaw && !middleware.IsAdminRequest(c, h.cfg.Admin.APIKey) {
+		logger.FromContext(c).Warn("Rejected raw info request", zap.String("ip", c.ClientIP()))
+		middleware.ErrorJitter(&h.cfg.Security)
+		respondError(c, http.StatusUnauthorized, "unauthorized", "Admin credentials required")
 		return
 	}
 
+	// per_group_limit caps the formats returned per quality category
+	perGroupLimit := 0
+	if limitStr := c.Query("per_group_limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 {
+			respondError(c, http.StatusBadRequest, "invalid_request", "Parameter per_group_limit must be a positive integer")
+			return
+		}
+		perGroupLimit = limit
+	}
+
 	// Get video info from service
-	videoInfo, err := h.videoService.GetVideoInfo(videoURL)
+	videoInfo, rawMetadata, err := h.videoService.GetVideoInfoWithRaw(c.Request.Context(), videoURL, verbose)
+	if errors.Is(err, service.ErrPlaylist) {
+		// Playlists answer with their entries; verify_formats and debug_raw only apply to single videos
+		playlist := h.videoService.PlaylistFromMetadata(videoURL, rawMetadata, verbose)
+		for i := range playlist.Entries {
+			playlist.Entries[i].Formats = h.shapeFormats(c, playlist.Entries[i].Formats, perGroupLimit)
+			playlist.Entries[i].VideoToken = h.videoService.VideoToken(playlist.Entries[i].URL)
+		}
+		c.JSON(http.StatusOK, playlist)
+		return
+	}
 	if err != nil {
-		logger.Logger.Error("Failed to get video info", zap.Error(err), zap.String("url", videoURL))
-		c.JSON(http.StatusInternalServerError, model.ErrorResponse{
-			Error:   "fetch_failed",
-			Message: "Failed to fetch video information",
-			Code:    http.StatusInternalServerError,
+		logger.FromContext(c).Error("Failed to get video info", zap.Error(err), zap.String("url", videoURL))
+		if respondIfTimedOut(c) {
+			return
+		}
+		respondError(c, http.StatusInternalServerError, "fetch_failed", "Failed to fetch video information")
+		return
+	}
+
+	// verify_formats probes each format's media URL and flags the dead ones
+	if c.Query("verify_formats") == "true" {
+		verified := *videoInfo
+		verified.Formats = h.videoService.VerifyFormats(c.Request.Context(), videoInfo.Formats, rawMetadata)
+		videoInfo = &verified
+	}
+
+	labeled := *videoInfo
+	labeled.Formats = h.shapeFormats(c, videoInfo.Formats, perGroupLimit)
+	labeled.VideoToken = h.videoService.VideoToken(videoURL)
+
+	if debugRaw {
+		logger.FromContext(c).Info("Raw video info served", zap.String("url", videoURL))
+		c.JSON(http.StatusOK, &model.VideoInfoWithRaw{VideoInfo: &labeled, Raw: rawMetadata})
+		return
+	}
+	c.JSON(http.StatusOK, &labeled)
+}
+
+// shapeFormats applies the downloadable_only, per_group_limit and label options to a video's formats
+func (h *VideoHandler) shapeFormats(c *gin.Context, formats []model.FormatOption, perGroupLimit int) []model.FormatOption {
+	// downloadable_only drops formats StartDownload would reject for this caller; quality
+	// evaluates them as requested under that category, e.g. dropping audio-only ones for HD
+	if c.Query("downloadable_only") == "true" {
+		maxVideoSizeMB := h.cfg.Storage.MaxVideoSizeMB
+		if profile := middleware.GetLimitProfile(c); profile != nil {
+			maxVideoSizeMB = profile.MaxVideoSizeMB
+		}
+		quality := service.QualityCategory(c.Query("quality"), h.cfg.QualityCategories.Labels)
+
+		downloadable := []model.FormatOption{}
+		for _, format := range formats {
+			unreachable := format.Reachable != nil && !*format.Reachable
+			if !unreachable && service.DownloadRejection(format, quality, &h.cfg.QualityCategories, maxVideoSizeMB) == "" {
+				downloadable = append(downloadable, format)
+			}
+		}
+		formats = downloadable
+	}
+
+	formats = service.LimitFormatsPerQuality(formats, perGroupLimit)
+
+	// Display labels are applied last; the filters above work on quality categories
+	return service.LabelFormats(formats, h.cfg.QualityCategories.Labels)
+}
+
+// checkTargetScheme rejects plain http target URLs when REQUIRE_HTTPS_TARGET is on
+func (h *VideoHandler) checkTargetScheme(c *gin.Context, videoURL string) bool {
+	if _, reason := validator.CheckURL(videoURL, nil, h.cfg.Security.RequireHTTPSTarget); reason == validator.URLInsecure {
+		logger.FromContext(c).Warn("Insecure target URL rejected", zap.String("url", videoURL))
+		respondError(c, http.StatusBadRequest, "insecure_url", "Only https URLs are allowed")
+		return false
+	}
+	return true
+}
+
+// urlRejectionMessages are the default messages of the validator rejection reasons
+var urlRejectionMessages = map[string]string{
+	validator.URLMalformed:         "URL is malformed",
+	validator.URLUnsupportedScheme: "Only http and https URLs are supported",
+	validator.URLDomainNotAllowed:  "URL domain is not allowed",
+	validator.URLInsecure:          "Only https URLs are allowed",
+}
+
+// ValidateURL handles GET /api/validate
+// Runs only the local URL checks so clients can cheaply validate input as the user types
+// purpose=download checks against the download allowlist instead of the info allowlist
+func (h *VideoHandler) ValidateURL(c *gin.Context) {
+	videoURL := c.Query("url")
+	if videoURL == "" {
+		respondError(c, http.StatusBadRequest, "invalid_url", "Video URL is required")
+		return
+	}
+
+	allowedDomains := h.cfg.Security.InfoAllowedDomains
+	if c.Query("purpose") == "download" {
+		allowedDomains = h.cfg.Security.DownloadAllowedDomains
+	}
+
+	domain, reason := validator.CheckURL(videoURL, allowedDomains, h.cfg.Security.RequireHTTPSTarget)
+	if reason != "" {
+		c.JSON(http.StatusOK, model.URLValidationResponse{
+			Valid:   false,
+			Domain:  domain,
+			Error:   reason,
+			Message: i18n.Localize(c.GetHeader("Accept-Language"), reason, urlRejectionMessages[reason]),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, videoInfo)
+	c.JSON(http.StatusOK, model.URLValidationResponse{
+		Valid:  true,
+		Domain: domain,
+	})
+}
+
+// GetManifest handles GET /api/video/manifest
+// Returns the HLS/DASH manifest URL for adaptive-streaming players instead of downloading
+func (h *VideoHandler) GetManifest(c *gin.Context) {
+	if !h.cfg.Streaming.ManifestPassthrough {
+		respondError(c, http.StatusNotFound, "manifest_disabled", "Manifest passthrough is not enabled on this server")
+		return
+	}
+
+	videoURL := c.Query("url")
+	formatID := c.Query("format_id")
+
+	if videoURL == "" || !validator.ValidateFormatID(formatID) {
+		respondError(c, http.StatusBadRequest, "invalid_request", "Video URL and format ID are required")
+		return
+	}
+
+	// Manifests deliver media, so they follow the download allowlist
+	if !h.checkTargetScheme(c, videoURL) {
+		return
+	}
+	if !validator.ValidateURL(videoURL, h.cfg.Security.DownloadAllowedDomains) {
+		logger.FromContext(c).Warn("Invalid URL domain", zap.String("url", videoURL))
+		respondError(c, http.StatusBadRequest, "invalid_domain", "URL domain is not allowed")
+		return
+	}
+
+	manifest, err := h.videoService.GetManifest(c.Request.Context(), videoURL, formatID)
+	if err != nil {
+		logger.FromContext(c).Warn("Manifest not available", zap.Error(err), zap.String("url", videoURL), zap.String("format_id", formatID))
+		if respondIfTimedOut(c) {
+			return
+		}
+		respondError(c, http.StatusUnprocessableEntity, "manifest_unavailable", "No streaming manifest is available for this format")
+		return
+	}
+
+	// Only hand out manifests that point to known CDN hosts, matched exactly or by subdomain
+	if !validator.ValidateHostURL(manifest.ManifestURL, h.cfg.Streaming.ManifestAllowedHosts) {
+		logger.FromContext(c).Warn("Manifest host not allowed", zap.String("manifest_url", manifest.ManifestURL))
+		respondError(c, http.StatusBadGateway, "manifest_host_not_allowed", "Manifest host is not allowed")
+		return
+	}
+
+	c.JSON(http.StatusOK, manifest)
 }
 
 // HealthCheck handles GET /health
+// degraded is set while free disk space is below WARN_FREE_DISK_PERCENT; the service still
+// answers 200 since it keeps working until MIN_FREE_DISK_PERCENT is reached
 func (h *VideoHandler) HealthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "healthy",
-		"service": "video-downloader",
-	})
+	level, freePercent := h.storageManager.CheckDisk()
+	response := gin.H{
+		"status":   "healthy",
+		"service":  "video-downloader",
+		"degraded": level != storage.DiskOK,
+	}
+	if level != storage.DiskOK {
+		response["disk"] = level
+		response["disk_free_percent"] = freePercent
+	}
+	c.JSON(http.StatusOK, response)
 }
@@ -0,0 +1,302 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"videodownload/internal/model"
+	"videodownload/pkg/middleware"
+)
+
+func TestSplitAllowedDomains(t *testing.T) {
+	t.Setenv("ALLOWED_DOMAINS", "youtube.com")
+	t.Setenv("INFO_ALLOWED_DOMAINS", "youtube.com,vimeo.com")
+	t.Setenv("DOWNLOAD_ALLOWED_DOMAINS", "youtube.com")
+	s := newTestServer(t, serveTestWorker)
+
+	tests := []struct {
+		name         string
+		url          string
+		wantInfo     int
+		wantDownload int
+	}{
+		{"allowed for both", "https://www.youtube.com/watch?v=abc123", http.StatusOK, http.StatusAccepted},
+		{"info only", "https://vimeo.com/123456", http.StatusOK, http.StatusBadRequest},
+		{"neither", "https://example.com/video", http.StatusBadRequest, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(http.MethodGet, "/api/video/info?url="+url.QueryEscape(tt.url), nil, nil)
+			if w.Code != tt.wantInfo {
+				t.Errorf("GET /api/video/info = %d %s, want %d", w.Code, w.Body, tt.wantInfo)
+			}
+
+			w = s.do(http.MethodPost, "/api/download", model.DownloadRequest{URL: tt.url, FormatID: "18"}, nil)
+			if w.Code != tt.wantDownload {
+				t.Fatalf("POST /api/download = %d %s, want %d", w.Code, w.Body, tt.wantDownload)
+			}
+			if w.Code == http.StatusAccepted {
+				var job model.DownloadJob
+				json.Unmarshal(w.Body.Bytes(), &job)
+				s.waitForJob(t, job.JobID, nil)
+			}
+			if w.Code == http.StatusBadRequest {
+				var resp model.ErrorResponse
+				json.Unmarshal(w.Body.Bytes(), &resp)
+				if resp.Error != "invalid_domain" {
+					t.Errorf("download error = %q, want invalid_domain", resp.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	t.Setenv("INFO_ALLOWED_DOMAINS", "youtube.com,vimeo.com")
+	t.Setenv("DOWNLOAD_ALLOWED_DOMAINS", "youtube.com")
+	var workerCalls atomic.Int32
+	s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		workerCalls.Add(1)
+		serveTestWorker(w, r)
+	})
+
+	tests := []struct {
+		name  string
+		query string
+		want  model.URLValidationResponse
+	}{
+		{"allowed", "url=https://www.youtube.com/watch?v=abc123", model.URLValidationResponse{Valid: true, Domain: "youtube.com"}},
+		{"allowed subdomain", "url=https://m.youtube.com/watch?v=abc123", model.URLValidationResponse{Valid: true, Domain: "m.youtube.com"}},
+		{"blocked domain", "url=https://example.com/video", model.URLValidationResponse{Domain: "example.com", Error: "invalid_domain"}},
+		{"blocked for downloads only", "purpose=download&url=https://vimeo.com/123", model.URLValidationResponse{Domain: "vimeo.com", Error: "invalid_domain"}},
+		{"malformed", "url=" + url.QueryEscape("https://%zz"), model.URLValidationResponse{Error: "malformed_url"}},
+		{"no host", "url=youtube.com/watch", model.URLValidationResponse{Error: "malformed_url"}},
+		{"unsupported scheme", "url=ftp://youtube.com/video", model.URLValidationResponse{Error: "unsupported_scheme"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(http.MethodGet, "/api/validate?"+tt.query, nil, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("GET /api/validate = %d %s, want 200", w.Code, w.Body)
+			}
+			var got model.URLValidationResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Valid != tt.want.Valid || got.Domain != tt.want.Domain || got.Error != tt.want.Error {
+				t.Errorf("response = %+v, want %+v", got, tt.want)
+			}
+			if !got.Valid && got.Message == "" {
+				t.Error("rejection has no message")
+			}
+		})
+	}
+
+	if w := s.do(http.MethodGet, "/api/validate", nil, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("GET /api/validate without url = %d, want 400", w.Code)
+	}
+	if n := workerCalls.Load(); n != 0 {
+		t.Errorf("validation made %d worker calls, want none", n)
+	}
+}
+
+func TestPerGroupLimitParameter(t *testing.T) {
+	s := newTestServer(t, serveTestWorker)
+	infoPath := "/api/video/info?url=" + url.QueryEscape(testDownload.URL)
+
+	tests := []struct {
+		name       string
+		limit      string
+		wantStatus int
+	}{
+		{"positive", "1", http.StatusOK},
+		{"zero", "0", http.StatusBadRequest},
+		{"negative", "-2", http.StatusBadRequest},
+		{"not a number", "all", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := s.do(http.MethodGet, infoPath+"&per_group_limit="+tt.limit, nil, nil)
+			if w.Code != tt.wantStatus {
+				t.Errorf("per_group_limit=%s = %d %s, want %d", tt.limit, w.Code, w.Body, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRequireHTTPSTarget(t *testing.T) {
+	tests := []struct {
+		name         string
+		requireHTTPS string
+		url          string
+		wantStatus   int
+	}{
+		{"http allowed by default", "false", "http://www.youtube.com/watch?v=abc123", http.StatusOK},
+		{"https allowed when required", "true", "https://www.youtube.com/watch?v=abc123", http.StatusOK},
+		{"http rejected when required", "true", "http://www.youtube.com/watch?v=abc123", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("REQUIRE_HTTPS_TARGET", tt.requireHTTPS)
+			s := newTestServer(t, serveTestWorker)
+
+			info := s.do(http.MethodGet, "/api/video/info?url="+url.QueryEscape(tt.url), nil, nil)
+			download := s.do(http.MethodPost, "/api/download", model.DownloadRequest{URL: tt.url, FormatID: "18"}, nil)
+			if tt.wantStatus == http.StatusOK {
+				if info.Code != http.StatusOK || download.Code != http.StatusAccepted {
+					t.Fatalf("info = %d, download = %d; want 200 and 202", info.Code, download.Code)
+				}
+				return
+			}
+			for _, w := range []*httptest.ResponseRecorder{info, download} {
+				var body model.ErrorResponse
+				json.Unmarshal(w.Body.Bytes(), &body)
+				if w.Code != tt.wantStatus || body.Error != "insecure_url" {
+					t.Errorf("response = %d %s, want %d insecure_url", w.Code, w.Body, tt.wantStatus)
+				}
+			}
+		})
+	}
+}
+
+func TestQualityLabels(t *testing.T) {
+	t.Setenv("QUALITY_LABELS", "FD:360p,Audio:Sound")
+	t.Setenv("REJECT_AUDIO_ONLY", "true")
+	s := newTestServer(t, serveTestWorker)
+	infoPath := "/api/video/info?url=" + url.QueryEscape(testDownload.URL)
+
+	formatsOf := func(query string) map[string]model.FormatOption {
+		t.Helper()
+		w := s.do(http.MethodGet, infoPath+query, nil, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d %s", query, w.Code, w.Body)
+		}
+		var info model.VideoInfo
+		json.Unmarshal(w.Body.Bytes(), &info)
+		formats := make(map[string]model.FormatOption)
+		for _, format := range info.Formats {
+			formats[format.FormatID] = format
+		}
+		return formats
+	}
+
+	formats := formatsOf("")
+	for id, want := range map[string]string{"18": "360p", "140": "Sound"} {
+		if got := formats[id].Quality; got != want {
+			t.Errorf("quality of format %s = %q, want %q", id, got, want)
+		}
+		if got := formats[id].OfficialName; !strings.HasPrefix(got, want+" ") {
+			t.Errorf("official name of format %s = %q, want it to start with %q", id, got, want)
+		}
+	}
+
+	// Filtering works on categories, and accepts the label in their place; the audio-only format
+	// is dropped under the FD category either way
+	for _, quality := range []string{"FD", "360p"} {
+		filtered := formatsOf("&downloadable_only=true&quality=" + quality)
+		if _, ok := filtered["18"]; !ok || len(filtered) != 1 {
+			t.Errorf("downloadable formats for quality=%s = %v, want only 18", quality, filtered)
+		}
+	}
+
+	job := s.startDownload(t, model.DownloadRequest{URL: testDownload.URL, FormatID: "18", Quality: "360p"}, nil)
+	if done := s.waitForJob(t, job.JobID, nil); done.Status != model.JobDone {
+		t.Errorf("download with a labeled quality = %s %+v, want done", done.Status, done.Error)
+	}
+}
+
+func TestPartialVideoInfo(t *testing.T) {
+	tests := []struct {
+		name       string
+		metadata   string
+		wantStatus int
+	}{
+		{"title without formats", `{"id": "abc123", "title": "Test video"}`, http.StatusOK},
+		{"nothing extracted", `{"id": "abc123"}`, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tt.metadata))
+			})
+
+			w := s.do(http.MethodGet, "/api/video/info?url="+url.QueryEscape(testDownload.URL), nil, nil)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("GET /api/video/info = %d %s, want %d", w.Code, w.Body, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				var errResp model.ErrorResponse
+				if json.Unmarshal(w.Body.Bytes(), &errResp); errResp.Error != "fetch_failed" {
+					t.Errorf("error = %+v, want fetch_failed", errResp)
+				}
+				return
+			}
+
+			var info model.VideoInfo
+			if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+				t.Fatal(err)
+			}
+			if info.Title != "Test video" || !info.Partial || len(info.Warnings) == 0 || len(info.Formats) != 0 {
+				t.Errorf("info = %+v, want the title, partial=true, warnings and no formats", info)
+			}
+		})
+	}
+}
+
+func TestDebugRawInfo(t *testing.T) {
+	const adminKey = "test-admin-key"
+	admin := http.Header{middleware.AdminKeyHeader: {adminKey}}
+
+	tests := []struct {
+		name       string
+		enabled    string
+		query      string
+		header     http.Header
+		wantStatus int
+		wantRaw    bool
+	}{
+		{"enabled for an admin", "true", "&debug_raw=true", admin, http.StatusOK, true},
+		{"enabled but not asked for", "true", "", admin, http.StatusOK, false},
+		{"disabled by config", "false", "&debug_raw=true", admin, http.StatusOK, false},
+		{"enabled without admin credentials", "true", "&debug_raw=true", nil, http.StatusUnauthorized, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ADMIN_API_KEY", adminKey)
+			t.Setenv("DEBUG_RAW_INFO", tt.enabled)
+			s := newTestServer(t, serveTestWorker)
+
+			w := s.do(http.MethodGet, "/api/video/info?url="+url.QueryEscape(testDownload.URL)+tt.query, nil, tt.header)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("GET /api/video/info = %d %s, want %d", w.Code, w.Body, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var body map[string]json.RawMessage
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			raw, hasRaw := body["_raw"]
+			if hasRaw != tt.wantRaw {
+				t.Fatalf("_raw present = %v, want %v", hasRaw, tt.wantRaw)
+			}
+			if _, ok := body["formats"]; !ok {
+				t.Error("parsed formats missing from the response")
+			}
+			if hasRaw {
+				var metadata model.VideoMetadata
+				if err := json.Unmarshal(raw, &metadata); err != nil || metadata.Title != "Test video" || len(metadata.Formats) != 2 {
+					t.Errorf("_raw = %s, want the worker's metadata", raw)
+				}
+			}
+		})
+	}
+}
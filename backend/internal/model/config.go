@@ -10,6 +10,16 @@ type Config struct {
 	Quota             QuotaConfig
 	RateLimit         RateLimitConfig
 	QualityCategories QualityCategoriesConfig
+	Streaming         StreamingConfig
+	ClientLimits      ClientLimitsConfig
+	Batch             BatchConfig
+	Segmented         SegmentedDownloadConfig
+	Admin             AdminConfig
+	Callback          CallbackConfig
+	RPC               RPCConfig
+	Stats             StatsConfig
+	Transcode         TranscodeConfig
+	Tracing           TracingConfig
 }
 
 // ServerConfig holds server configuration
@@ -17,14 +27,47 @@ type ServerConfig struct {
 	Port    int
 	Host    string
 	Timeout int // seconds
+
+	ServeFrontend bool // Serve the static frontend from this server
+
+	PublicBaseURL  string   // Prefix for absolute download links ("" = relative, "auto" = derive from the request)
+	TrustedProxies []string // Proxy IPs/CIDRs whose X-Forwarded-Proto/Host headers are honored
+
+	DownloadsDisabled bool // Kill switch refusing new downloads; reloadable with SIGHUP
+
+	RouteTimeouts map[string]int // Route pattern -> request timeout in seconds; unlisted routes have none
 }
 
 // StorageConfig holds storage configuration
 type StorageConfig struct {
-	DownloadDir     string
-	MaxVideoSizeMB  int
-	CleanupInterval int // seconds
-	FileTTLSeconds  int // Time to live for downloaded files
+	DownloadDir         string
+	MaxVideoSizeMB      int
+	MaxFilenameLength   int    // Max filename length in characters (also capped to 255 bytes)
+	CleanupInterval     int    // seconds
+	CleanupMaxRetries   int    // Sweeps that retry a failed deletion before the file is untracked
+	FileTTLSeconds      int    // Time to live for downloaded files
+	ExpiredGraceSeconds int    // How long expired IDs answer 410 Gone instead of 404 (0 = disabled)
+	TrackingFile        string // JSON file tracked downloads are persisted to, so they survive restarts ("" = in memory only)
+
+	FeedWindowSeconds int  // How far back the downloads feed reaches
+	ShardDirs         bool // Store files in subdirectories keyed by a hash prefix of the download ID
+	DateDirs          bool // Store files under YYYY/MM/DD/<id>_<filename>; takes precedence over ShardDirs
+	DeterministicIDs  bool // Derive download IDs from the request so identical requests share one file
+
+	AllowedMIMETypes []string // Media types (or type/* wildcards) accepted from the worker; empty allows all
+	MaxTotalStoredMB int      // Cap on the combined size of tracked files (0 = unlimited)
+
+	EvictOldestStored bool // Evict the oldest tracked files to make room instead of rejecting at MaxTotalStoredMB
+
+	MinFreeDiskPercent  int // New downloads are rejected while the download dir's filesystem has less free (0 = off)
+	WarnFreeDiskPercent int // Below this free share health reports degraded and a warning is logged (0 = off)
+
+	HashWorkers int // Files hashed concurrently in the background after saving (0 = hash while writing)
+
+	ExtensionMIMETypes map[string]string // Extension (".mkv") to Content-Type for served files, over Go's mime table
+	InlineMIMETypes    []string          // Content types (or type/* wildcards) served with an inline disposition
+
+	FileNoStore bool // Serve every file with Cache-Control: no-store, as ?no_cache=true does per request
 }
 
 // PythonConfig holds Python worker configuration
@@ -32,6 +75,30 @@ type PythonConfig struct {
 	Port    int
 	Host    string
 	Timeout int // seconds
+
+	MaxDownloadDuration int      // seconds a whole download job may take (0 = unlimited)
+	InfoFallbackURLs    []string // Base URLs of fallback info providers, tried in order after the primary worker
+
+	MaxRetries        int // Retries of a failed worker download call (0 = no retries)
+	RetryBackoffMs    int // Initial backoff between retries, doubled after each attempt
+	RetryBudget       int // Retries a single client may use per budget window
+	RetryBudgetWindow int // seconds over which a client's retry budget fully refills
+
+	StripURLTimestamps bool // Drop ?t=/&start=/#t= offsets when caching and deduplicating URLs
+
+	MaxPlaylistEntries int // Videos of a playlist URL extracted and returned at most
+
+	MetadataCacheTTL        int // seconds fetched video metadata is served from memory (0 = no cache)
+	MetadataCacheMaxEntries int // URLs kept in the metadata cache; the least recently used is evicted
+
+	KnownInfoTTL        int // seconds a fetched VideoInfo is kept for format, platform and size lookups (0 = not kept)
+	KnownInfoMaxEntries int // Videos whose info is kept; the oldest is evicted
+
+	VerifyFormatsWorkers int // Concurrent probes of format URLs for ?verify_formats=true (0 = verification disabled)
+	VerifyFormatsTimeout int // seconds a single format probe may take
+
+	InfoConcurrency     int // Info calls to the worker running at once, shared by all clients (0 = unlimited)
+	DownloadConcurrency int // Download calls to the worker running at once, separate from info calls (0 = unlimited)
 }
 
 // LoggingConfig holds logging configuration
@@ -41,13 +108,31 @@ type LoggingConfig struct {
 	RotationSize int64 // bytes
 	MaxBackups   int
 	MaxAge       int // days
+
+	AccessLogExcludePaths []string // Paths logged at DEBUG instead of INFO by the access logger
 }
 
 // SecurityConfig holds security configuration
 type SecurityConfig struct {
-	AllowedDomains []string
-	RequestTimeout int // seconds
-	RateLimitPerIP int
+	AllowedDomains         []string
+	InfoAllowedDomains     []string // Domains allowed for info extraction (defaults to AllowedDomains)
+	DownloadAllowedDomains []string // Domains allowed for downloads (defaults to AllowedDomains)
+	RequestTimeout         int      // seconds
+	RateLimitPerIP         int
+	RequireHTTPSTarget     bool // Reject plain http video URLs with insecure_url
+
+	FileSizeHintMaxAge       int // seconds a fetched VideoInfo is used to cross-check client FileSize hints
+	FileSizeTolerancePercent int // Allowed deviation between client FileSize and the known format size
+
+	ErrorJitterMinMs int // Lower bound of the random delay added to not-found/auth failures
+	ErrorJitterMaxMs int // Upper bound of that delay (0 = disabled)
+
+	ResolveRedirects     bool     // Follow redirects of short URLs before the allowlist check
+	RedirectResolveHosts []string // Short URL hosts whose redirects are followed (e.g. fb.watch)
+	RedirectMaxDepth     int      // Maximum redirect hops followed
+	RedirectTimeout      int      // seconds per hop
+
+	VideoTokenSecret string // Key of the video tokens returned by /api/video/info (empty = random per process)
 }
 
 // QuotaConfig holds user download quota configuration
@@ -56,21 +141,137 @@ type QuotaConfig struct {
 	DailyLimitMB int64 // Daily quota limit in MB per IP
 	ResetHour    int   // Hour (0-23) to reset quota (midnight = 0)
 	ResetMinute  int   // Minute (0-59) to reset quota
+
+	ResetJitterSeconds int // Spread per-entry resets over this window around the reset time (0 = exact)
+	IdleTTLSeconds     int // Entries with no usage for this long are evicted
+	MaxEntries         int // Hard cap on tracked clients, enforced on insert by evicting the oldest idle entry
+
+	StorePath       string // JSON file quota usage is persisted to, so it survives restarts ("" = in memory only)
+	PersistInterval int    // seconds between saves of the quota file (0 = only on shutdown)
 }
 
 // RateLimitConfig holds rate limiting configuration for DDoS protection
 type RateLimitConfig struct {
-	Enabled           bool // Enable rate limiting
-	RequestsPerMinute int  // Max requests per minute per IP
-	BurstSize         int  // Max burst size
-	CleanupInterval   int  // Interval in seconds to clean up old entries
+	Enabled           bool     // Enable rate limiting
+	RequestsPerMinute int      // Max requests per minute per IP
+	BurstSize         int      // Max burst size
+	CleanupInterval   int      // Interval in seconds to clean up old entries
+	MaxEntries        int      // Hard cap on tracked clients; oldest entries are evicted beyond it
+	ExemptPaths       []string // Paths never rate limited; entries ending in "/" match as prefixes
+
+	MinDownloadInterval int // Minimum seconds between download starts of the same client (0 = disabled)
 }
 
 // QualityCategoriesConfig holds quality category filtering configuration
 type QualityCategoriesConfig struct {
-	Enabled []string // List of enabled quality categories (Audio, FD, SD, HD, FHD)
+	MinQuality string   // Lowest video category accepted for download (empty = no lower bound)
+	MaxQuality string   // Highest video category accepted for download (empty = no upper bound)
+	Enabled    []string // List of enabled quality categories (Audio, FD, SD, HD, FHD)
+
 	// Examples:
 	// - []string{"Audio", "FD", "SD", "HD", "FHD"} = All categories enabled (default)
 	// - []string{"SD", "HD", "FHD"} = Only SD, HD, FHD (FD disabled)
 	// - []string{"HD", "FHD"} = Only high quality (HD and FHD)
+
+	RejectVideoOnly bool // Reject formats without an audio track unless raw_track is set
+	RejectAudioOnly bool // Reject audio-only formats requested under a video quality unless raw_track is set
+
+	DefaultQuality    string // Category resolved server-side when a request has no format_id (empty = format_id required)
+	PreferFreeFormats bool   // Prefer VP9/AV1/Opus/Vorbis (WebM/Ogg) formats when resolving a category
+	FormatFallback    bool   // Retry with the best format of the same category when the requested one is gone
+
+	Labels map[string]string // Display label per category, e.g. FHD -> 1080p; categories stay the internal names
+}
+
+// StreamingConfig holds adaptive-streaming (HLS/DASH) manifest passthrough configuration
+type StreamingConfig struct {
+	ManifestPassthrough  bool     // Allow returning manifest URLs instead of downloading
+	ManifestAllowedHosts []string // Hosts a returned manifest URL may point to
+}
+
+// ClientLimitsConfig holds per-API-key limit profile configuration
+type ClientLimitsConfig struct {
+	APIKeyHeader  string // Header carrying the client API key
+	ProfilesFile  string // JSON file mapping API keys to limit profiles (empty = defaults only)
+	MaxConcurrent int    // Default concurrent downloads per client (0 = unlimited)
+
+	MaxConcurrentInfo int // Info/manifest extractions a client may have in flight (0 = unlimited)
+
+	MaxProgressStreams          int // Progress streams open at once across all clients (0 = unlimited)
+	MaxProgressStreamsPerClient int // Progress streams a client may have open at once (0 = unlimited)
+
+	DuplicateDebounceSeconds int // Identical download requests of a client within this window share one result (0 = disabled)
+}
+
+// LimitProfile holds the limits applied to a client
+// Zero values fall back to the global defaults
+type LimitProfile struct {
+	Name              string `json:"name"`
+	DailyLimitMB      int64  `json:"daily_limit_mb"`
+	RequestsPerMinute int    `json:"requests_per_minute"`
+	MaxConcurrent     int    `json:"max_concurrent"` // 0 = unlimited
+	MaxVideoSizeMB    int    `json:"max_video_size_mb"`
+}
+
+// BatchConfig holds batch download configuration
+type BatchConfig struct {
+	MaxItems        int // Max items accepted in one batch request
+	DeadlineSeconds int // How long a batch request waits before returning pending items
+	PrefetchWorkers int // Concurrent info fetches used to check items before a batch starts (0 = no prefetch)
+
+	MaxScheduleAheadSeconds int // How far in the future start_at may be
+	JobTTLSeconds           int // How long finished async download jobs stay pollable
+	MaxQueuedJobs           int // Max async download jobs a client may have queued or running (0 = unlimited)
+}
+
+// SegmentedDownloadConfig holds parallel byte-range download configuration
+type SegmentedDownloadConfig struct {
+	Enabled     bool
+	ThresholdMB int // Only files at least this large are fetched in segments
+	Segments    int // Number of byte ranges a file is split into
+	Concurrency int // Max ranges fetched at the same time
+}
+
+// AdminConfig holds configuration of the admin-only endpoints
+type AdminConfig struct {
+	APIKey     string // Key expected in the X-Admin-Key header (empty = admin endpoints disabled)
+	CookiesDir string // Directory of per-profile Netscape cookie jars shared with the worker
+
+	DebugRawInfo bool // Allow admins to request the worker's raw metadata with ?debug_raw=true; keep off in production
+}
+
+// CallbackConfig holds per-request download callback configuration
+type CallbackConfig struct {
+	AllowedDomains []string // Hosts a callback_url may point to (empty = callbacks disabled)
+	Secret         string   // HMAC-SHA256 key used to sign callback bodies
+	Timeout        int      // seconds
+}
+
+// RPCConfig holds the optional gRPC interface configuration
+type RPCConfig struct {
+	Enabled bool
+	Host    string
+	Port    int
+}
+
+// StatsConfig holds lifetime statistics persistence configuration
+type StatsConfig struct {
+	File            string // JSON file the lifetime counters are persisted to ("" = in memory only)
+	PersistInterval int    // seconds between saves
+}
+
+// TranscodeConfig holds the limits of post-download transcoding
+type TranscodeConfig struct {
+	Enabled         bool
+	Codecs          []string // Video codecs clients may request (h264, h265, vp9)
+	MaxHeight       int      // Largest max_height a client may request
+	MaxBitrateKbps  int      // Largest video_bitrate_kbps a client may request
+	MaxDuration     int      // seconds; longer videos are not transcoded
+	MaxSourceSizeMB int      // Largest source format that may be transcoded
+}
+
+// TracingConfig holds the OTLP span exporter settings
+type TracingConfig struct {
+	Endpoint    string // OTLP/HTTP base URL; empty disables tracing
+	ServiceName string
 }
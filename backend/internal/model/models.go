@@ -8,30 +8,110 @@ type VideoInfo struct {
 	Title        string         `json:"title"`
 	Duration     int            `json:"duration"`
 	ThumbnailURL string         `json:"thumbnail_url"`
+	Thumbnails   []Thumbnail    `json:"thumbnails"` // Sorted by resolution, smallest first
 	Uploader     string         `json:"uploader"`
+	Extractor    string         `json:"extractor,omitempty"` // yt-dlp extractor key, e.g. Youtube
+	Platform     string         `json:"platform"`            // Normalized platform, e.g. youtube, tiktok
 	Formats      []FormatOption `json:"formats"`
+	StartTime    int            `json:"start_time,omitempty"` // Start offset in seconds parsed from the URL (?t=, #t=)
+	IsLive       bool           `json:"is_live,omitempty"`    // Video is a live stream that can't be downloaded yet
+	Partial      bool           `json:"partial,omitempty"`    // Only basic metadata could be extracted
+	Warnings     []string       `json:"warnings,omitempty"`   // What failed when Partial is set
+
+	// Verbose fields, only populated when requested with ?verbose=true
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	ViewCount   *int64   `json:"view_count,omitempty"`
+	LikeCount   *int64   `json:"like_count,omitempty"`
+	UploadDate  string   `json:"upload_date,omitempty"` // YYYYMMDD as reported by yt-dlp
+
+	VideoToken string `json:"video_token,omitempty"` // Opaque stand-in for the URL in download requests
+}
+
+// PlaylistInfo represents the videos of a playlist URL
+// Entries holds at most MAX_PLAYLIST_ENTRIES videos; Truncated is set when the playlist has more
+type PlaylistInfo struct {
+	Type       string      `json:"type"` // Always "playlist", to tell the shape apart from VideoInfo
+	URL        string      `json:"url"`
+	Title      string      `json:"title"`
+	Uploader   string      `json:"uploader"`
+	Extractor  string      `json:"extractor,omitempty"`
+	Platform   string      `json:"platform"`
+	EntryCount int         `json:"entry_count"` // Videos in the playlist, including those left out
+	Truncated  bool        `json:"truncated,omitempty"`
+	Entries    []VideoInfo `json:"entries"`
+}
+
+// VideoInfoWithRaw is a video info response with the worker's unparsed metadata attached
+// Only returned to admins with DEBUG_RAW_INFO on, to compare parsing against the source
+type VideoInfoWithRaw struct {
+	*VideoInfo
+	Raw *VideoMetadata `json:"_raw"`
+}
+
+// Thumbnail is one of the available thumbnail images of a video
+// Width and height are 0 when the extractor does not report them
+type Thumbnail struct {
+	URL        string `json:"url"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	Preference int    `json:"preference"` // yt-dlp preference, higher is better
 }
 
 // FormatOption represents a downloadable format
 type FormatOption struct {
-	FormatID     string `json:"format_id"`
-	Format       string `json:"format"`
-	Extension    string `json:"ext"`
-	Resolution   string `json:"resolution"`
-	VideoCodec   string `json:"video_codec"`
-	AudioCodec   string `json:"audio_codec"`
-	FileSize     int64  `json:"file_size"`
-	Fps          int    `json:"fps"`
-	Quality      string `json:"quality"` // FHD, HD, SD, Audio
-	OfficialName string `json:"official_name"`
+	FormatID      string  `json:"format_id"`
+	Format        string  `json:"format"`
+	Extension     string  `json:"ext"`
+	Resolution    string  `json:"resolution"`
+	VideoCodec    string  `json:"video_codec"`
+	AudioCodec    string  `json:"audio_codec"`
+	FileSize      int64   `json:"file_size"`
+	Fps           int     `json:"fps"`
+	Quality       string  `json:"quality"` // FHD, HD, SD, Audio
+	OfficialName  string  `json:"official_name"`
+	Protocol      string  `json:"protocol,omitempty"`     // e.g. https, m3u8_native, http_dash_segments
+	GeoRestricted bool    `json:"geo_restricted"`         // Format is not available from the server's region
+	HasDRM        bool    `json:"has_drm,omitempty"`      // Format is DRM protected and can't be downloaded
+	IsLive        bool    `json:"is_live,omitempty"`      // Format belongs to a live stream
+	Bitrate       float64 `json:"bitrate,omitempty"`      // Total bitrate in kbit/s when known
+	AspectRatio   string  `json:"aspect_ratio,omitempty"` // e.g. 16:9, omitted when dimensions are unknown
+	Orientation   string  `json:"orientation,omitempty"`  // landscape, portrait or square
+	Reachable     *bool   `json:"reachable,omitempty"`    // Whether the media URL answered, only set with ?verify_formats=true
+}
+
+// ManifestResponse represents an adaptive-streaming manifest for direct playback
+type ManifestResponse struct {
+	FormatID    string `json:"format_id"`
+	Protocol    string `json:"protocol"`
+	ManifestURL string `json:"manifest_url"`
 }
 
 // DownloadRequest represents a user's download request
 type DownloadRequest struct {
-	URL      string `json:"url" binding:"required"`
-	FormatID string `json:"format_id" binding:"required"`
-	Quality  string `json:"quality"`   // FHD, HD, Audio, etc.
-	FileSize int64  `json:"file_size"` // File size in bytes for backend validation
+	URL        string `json:"url"`         // Required unless video_token is given
+	VideoToken string `json:"video_token"` // Token from /api/video/info, used in place of url
+	FormatID   string `json:"format_id"`   // Optional when DEFAULT_QUALITY is set
+	Quality    string `json:"quality"`     // FHD, HD, Audio, etc.
+	FileSize   int64  `json:"file_size"`   // File size in bytes for backend validation
+
+	EmbedMetadata  bool `json:"embed_metadata"`  // Embed title, artist and date tags in the file
+	EmbedThumbnail bool `json:"embed_thumbnail"` // Also embed the thumbnail as cover art (requires embed_metadata)
+
+	CallbackURL string `json:"callback_url"` // Notified with the result when the download finishes
+	RawTrack    bool   `json:"raw_track"`    // Explicitly accept a video-only or audio-only track
+	Filename    string `json:"filename"`     // Output filename; the extension always follows the downloaded format
+
+	StartAt *time.Time `json:"start_at,omitempty"` // RFC 3339 time to start the download; runs as a background job
+
+	Transcode *TranscodeSpec `json:"transcode,omitempty"` // Re-encode the file after download
+}
+
+// TranscodeSpec describes a post-download re-encode done by the worker
+type TranscodeSpec struct {
+	VideoCodec       string `json:"video_codec"`                  // One of TRANSCODE_CODECS, e.g. h264
+	MaxHeight        int    `json:"max_height"`                   // Downscale to at most this height, keeping aspect ratio
+	VideoBitrateKbps int    `json:"video_bitrate_kbps,omitempty"` // Target video bitrate (0 = encoder default)
 }
 
 // DownloadResponse represents the response to a download request
@@ -40,17 +120,121 @@ type DownloadResponse struct {
 	Title        string `json:"title"`
 	DownloadLink string `json:"download_link"`
 	ExpiresAt    int64  `json:"expires_at"`
+
+	MetadataEmbedded  bool `json:"metadata_embedded,omitempty"`
+	ThumbnailEmbedded bool `json:"thumbnail_embedded,omitempty"`
+	Transcoded        bool `json:"transcoded,omitempty"`
+
+	Duplicate bool `json:"duplicate,omitempty"` // Result of an identical earlier request, shared by the debounce window
+
+	FormatFallback *FormatFallback `json:"format_fallback,omitempty"` // Set when the requested format was gone and another was used
+}
+
+// FormatFallback reports the format downloaded in place of one that no longer exists
+type FormatFallback struct {
+	RequestedFormatID string `json:"requested_format_id"`
+	FormatID          string `json:"format_id"`
+	Quality           string `json:"quality"`
 }
 
 // DownloadedFile tracks downloaded files for cleanup
 type DownloadedFile struct {
+	ID          string
+	Filename    string
+	FilePath    string
+	Size        int64
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+	URL         string
+	SHA256      string // Hex-encoded SHA-256 of the stored file; empty until the background hash is done
+	ClientKey   string // Client (API key or IP) that requested the download
+	Platform    string // Normalized platform of the source video
+	ContentType string // Media type detected when the file was stored
+}
+
+// ExpiredFile is the metadata kept for a cleaned-up download during the grace window
+type ExpiredFile struct {
 	ID        string
-	Filename  string
-	FilePath  string
-	Size      int64
-	CreatedAt time.Time
-	ExpiresAt time.Time
 	URL       string
+	ClientKey string
+	ExpiredAt time.Time
+}
+
+// GoneResponse is returned for downloads that expired recently
+// Refreshable means the source URL is known, so the video can be downloaded again
+type GoneResponse struct {
+	ErrorResponse
+	ExpiredAt   int64 `json:"expired_at"`
+	Refreshable bool  `json:"refreshable"`
+}
+
+// FileInfoResponse describes a tracked download without its contents
+type FileInfoResponse struct {
+	ID           string    `json:"id"`
+	Filename     string    `json:"filename"`
+	Size         int64     `json:"size"`
+	ContentType  string    `json:"content_type"`
+	SHA256       string    `json:"sha256,omitempty"`
+	URL          string    `json:"url"`
+	Platform     string    `json:"platform"`
+	DownloadLink string    `json:"download_link"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// ChecksumResponse represents the integrity info of a downloaded file
+type ChecksumResponse struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+	Pending  bool   `json:"pending,omitempty"` // The checksum is still being computed in the background
+}
+
+// PythonWorkerRequestVersion is the version of the request bodies sent to the Python worker
+// Bump it when a field changes meaning; adding optional fields does not need a bump
+const PythonWorkerRequestVersion = 1
+
+// PythonWorkerInfoRequest is the body of the worker's /api/info endpoint
+type PythonWorkerInfoRequest struct {
+	Version    int    `json:"version"`
+	URL        string `json:"url"`
+	MaxEntries int    `json:"max_entries,omitempty"` // Playlist entries to extract at most
+}
+
+// PythonWorkerDownloadRequest is the body of the worker's /api/download endpoint
+type PythonWorkerDownloadRequest struct {
+	Version        int    `json:"version"`
+	URL            string `json:"url"`
+	FormatID       string `json:"format_id"`
+	Quality        string `json:"quality"`
+	EmbedMetadata  bool   `json:"embed_metadata"`
+	EmbedThumbnail bool   `json:"embed_thumbnail"`
+
+	Transcode *PythonWorkerTranscode `json:"transcode,omitempty"`
+}
+
+// PythonWorkerTranscode is the transcode step of a worker download
+// MaxDuration lets the worker refuse sources whose duration was unknown to the backend
+type PythonWorkerTranscode struct {
+	VideoCodec       string `json:"video_codec"`
+	MaxHeight        int    `json:"max_height"`
+	VideoBitrateKbps int    `json:"video_bitrate_kbps"`
+	MaxDuration      int    `json:"max_duration"`
+	TaskID           string `json:"task_id,omitempty"` // Lets the backend poll /api/task/<task_id> for the transcoding phase
+}
+
+// PythonWorkerTaskPhase is the body of the worker's /api/task/<task_id> endpoint
+type PythonWorkerTaskPhase struct {
+	TaskID string `json:"task_id"`
+	Phase  string `json:"phase"` // downloading or transcoding
+}
+
+// PythonWorkerResolveRequest is the body of the worker's /api/resolve endpoint
+type PythonWorkerResolveRequest struct {
+	Version  int    `json:"version"`
+	URL      string `json:"url"`
+	FormatID string `json:"format_id"`
 }
 
 // PythonWorkerDownloadResponse represents response from Python worker download endpoint
@@ -61,6 +245,195 @@ type PythonWorkerDownloadResponse struct {
 	Status   string `json:"status"`   // Optional status
 }
 
+// PythonWorkerResolveResponse describes the direct media source of a format
+// Segmentable is false when the format needs worker-side processing (merging, HLS/DASH)
+type PythonWorkerResolveResponse struct {
+	URL         string            `json:"url"`
+	HTTPHeaders map[string]string `json:"http_headers"`
+	FileSize    int64             `json:"filesize"`
+	Ext         string            `json:"ext"`
+	Title       string            `json:"title"`
+	Segmentable bool              `json:"segmentable"`
+}
+
+// BatchDownloadRequest represents a request to download several items at once
+type BatchDownloadRequest struct {
+	Items []DownloadRequest `json:"items" binding:"required"`
+}
+
+// Batch item statuses
+const (
+	BatchItemPending = "pending"
+	BatchItemDone    = "done"
+	BatchItemFailed  = "failed"
+
+	BatchItemScheduled = "scheduled" // Waiting for its start_at time
+	BatchItemCancelled = "cancelled" // Cancelled before it started
+)
+
+// BatchItemResult represents the outcome of a single batch item
+// Items are returned in request order; Index is the item's position in the request
+type BatchItemResult struct {
+	Index      int               `json:"index"`
+	URL        string            `json:"url"`
+	JobID      string            `json:"job_id,omitempty"` // Pollable at /api/download/status/:jobid; unset for rejected items
+	Status     string            `json:"status"`           // scheduled, pending, done, failed, cancelled
+	Success    bool              `json:"success"`          // True once the item is done
+	DownloadID string            `json:"download_id,omitempty"`
+	Download   *DownloadResponse `json:"download,omitempty"`
+	Error      *ErrorResponse    `json:"error,omitempty"`
+
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
+}
+
+// BatchResponse represents the progress of a batch download
+type BatchResponse struct {
+	BatchID string            `json:"batch_id"`
+	Status  string            `json:"status"` // pending until every item is done or failed
+	Items   []BatchItemResult `json:"items"`
+}
+
+// PlaylistDownloadRequest represents a request to download every video of a playlist
+type PlaylistDownloadRequest struct {
+	URL     string `json:"url"`
+	Quality string `json:"quality"` // Quality category for every video; DEFAULT_QUALITY when empty
+}
+
+// PlaylistDownloadResponse represents a playlist download, run as a batch of its videos
+// The embedded error is only set when no item succeeded, and is answered with its code
+type PlaylistDownloadResponse struct {
+	*ErrorResponse
+	*BatchResponse
+	Title      string `json:"title"`
+	EntryCount int    `json:"entry_count"` // Videos in the playlist, including those not downloaded
+	Truncated  bool   `json:"truncated,omitempty"`
+}
+
+// Download job statuses
+const (
+	JobScheduled   = "scheduled"   // Waiting for its start_at time
+	JobQueued      = "queued"      // Waiting for one of the client's download slots
+	JobRunning     = "running"     // Being fetched from the worker
+	JobTranscoding = "transcoding" // Downloaded; the worker is re-encoding it as requested
+	JobDone        = "done"
+	JobFailed      = "failed"
+)
+
+// DownloadJob represents the state of an async download
+type DownloadJob struct {
+	JobID          string            `json:"job_id"`
+	Status         string            `json:"status"`                    // scheduled, queued, running, transcoding, done, failed
+	ScheduledAt    *time.Time        `json:"scheduled_at,omitempty"`    // Start time of a job created with start_at
+	EstimatedStart *time.Time        `json:"estimated_start,omitempty"` // Rough start of a queued job, once recent downloads give an average
+	Progress       *float64          `json:"progress,omitempty"`        // Percent complete, only while running and the file size is known
+	Indeterminate  bool              `json:"indeterminate,omitempty"`   // Running with an unknown file size; only bytes_received advances
+	Received       int64             `json:"bytes_received,omitempty"`
+	TotalBytes     int64             `json:"total_bytes,omitempty"` // 0 when the size is unknown
+	SpeedBps       int64             `json:"speed_bps,omitempty"`   // Smoothed transfer rate while running
+	ETASeconds     *int64            `json:"eta_seconds,omitempty"` // Seconds left, only when the size and speed are known
+	StatusLink     string            `json:"status_link"`
+	Download       *DownloadResponse `json:"download,omitempty"`
+	Error          *ErrorResponse    `json:"error,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	FinishedAt     *time.Time        `json:"finished_at,omitempty"`
+}
+
+// FeedEntry represents a completed download in the downloads feed
+type FeedEntry struct {
+	ID           string    `json:"id"`
+	Filename     string    `json:"filename"`
+	Size         int64     `json:"size"`
+	URL          string    `json:"url"`
+	DownloadLink string    `json:"download_link"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// FeedResponse represents the JSON downloads feed
+type FeedResponse struct {
+	Entries   []FeedEntry `json:"entries"`
+	NextSince string      `json:"next_since,omitempty"` // Cursor for the next poll (RFC 3339)
+}
+
+// Download history statuses
+const (
+	DownloadAvailable = "available" // File can still be fetched
+	DownloadExpired   = "expired"   // Past its TTL, waiting for cleanup
+)
+
+// ExportEntry is one download in a client's exported history
+type ExportEntry struct {
+	ID        string    `json:"id"`
+	Filename  string    `json:"filename"`
+	URL       string    `json:"url"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	Platform  string    `json:"platform"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ExportResponse is the JSON download history export
+type ExportResponse struct {
+	Entries []ExportEntry `json:"entries"`
+	Total   int           `json:"total"` // Entries available before offset/limit were applied
+}
+
+// URLValidationResponse reports whether a URL passes the cheap pre-extraction checks
+type URLValidationResponse struct {
+	Valid   bool   `json:"valid"`
+	Domain  string `json:"domain,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Callback events
+const (
+	CallbackDownloadCompleted = "download.completed"
+	CallbackDownloadFailed    = "download.failed"
+)
+
+// CallbackPayload is POSTed to a request's callback_url when its download finishes
+type CallbackPayload struct {
+	Event     string            `json:"event"`
+	URL       string            `json:"url"`
+	FormatID  string            `json:"format_id"`
+	Download  *DownloadResponse `json:"download,omitempty"`
+	Error     *ErrorResponse    `json:"error,omitempty"`
+	Timestamp int64             `json:"timestamp"`
+}
+
+// CookieUploadResponse confirms a cookie jar update without echoing its contents
+type CookieUploadResponse struct {
+	Profile   string `json:"profile"`
+	Cookies   int    `json:"cookies"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+// DownloadSwitchRequest sets the download kill switch
+type DownloadSwitchRequest struct {
+	Disabled *bool `json:"disabled" binding:"required"`
+}
+
+// DownloadSwitchResponse reports the download kill switch state
+type DownloadSwitchResponse struct {
+	DownloadsDisabled bool `json:"downloads_disabled"`
+}
+
+// CancelAllResponse reports how many downloads and scheduled batch items were cancelled
+type CancelAllResponse struct {
+	Cancelled int `json:"cancelled"`
+}
+
+// LifetimeStats are the download counters kept across restarts
+type LifetimeStats struct {
+	Downloads       int64     `json:"downloads"`
+	DownloadedBytes int64     `json:"downloaded_bytes"` // Bytes stored from completed downloads
+	ServedBytes     int64     `json:"served_bytes"`     // Bytes sent to clients, including zips
+	Since           time.Time `json:"since"`            // When counting started
+}
+
 // ErrorResponse represents an API error
 type ErrorResponse struct {
 	Error   string `json:"error"`
@@ -70,11 +443,26 @@ type ErrorResponse struct {
 
 // VideoMetadata contains parsed video metadata from yt-dlp
 type VideoMetadata struct {
-	ID        string                   `json:"id"`
-	Title     string                   `json:"title"`
-	Duration  float64                  `json:"duration"`
-	Thumbnail string                   `json:"thumbnail"`
-	Uploader  string                   `json:"uploader"`
-	URL       string                   `json:"url"`
-	Formats   []map[string]interface{} `json:"formats"`
+	ID           string                   `json:"id"`
+	Title        string                   `json:"title"`
+	Duration     float64                  `json:"duration"`
+	Thumbnail    string                   `json:"thumbnail"`
+	Thumbnails   []Thumbnail              `json:"thumbnails"`
+	Extractor    string                   `json:"extractor"`
+	ExtractorKey string                   `json:"extractor_key"`
+	Uploader     string                   `json:"uploader"`
+	URL          string                   `json:"url"`
+	Formats      []map[string]interface{} `json:"formats"`
+	IsLive       bool                     `json:"is_live"`
+
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	ViewCount   *float64 `json:"view_count"`
+	LikeCount   *float64 `json:"like_count"`
+	UploadDate  string   `json:"upload_date"`
+
+	Warnings []string `json:"warnings"` // Extraction problems that still left basic metadata usable
+
+	Entries       []VideoMetadata `json:"entries,omitempty"` // Videos of a playlist URL
+	PlaylistCount int             `json:"playlist_count,omitempty"`
 }
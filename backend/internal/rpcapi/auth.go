@@ -0,0 +1,92 @@
+package rpcapi
+
+import (
+	"context"
+	"net/http"
+
+	"videodownload/internal/model"
+	"videodownload/pkg/logger"
+	"videodownload/pkg/middleware"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// caller is the authenticated client of an RPC
+type caller struct {
+	clientKey string // Same key the REST API uses for this API key, so limits are shared
+	profile   *model.LimitProfile
+}
+
+type callerContextKey struct{}
+
+// authenticate resolves the API key in the call's metadata to a caller and charges its rate limit
+// Unlike REST, anonymous callers are refused: there is no client IP to key limits on
+func (s *Server) authenticate(ctx context.Context) (context.Context, error) {
+	var apiKey string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(s.cfg.ClientLimits.APIKeyHeader); len(values) > 0 {
+			apiKey = values[0]
+		}
+	}
+	if !s.profileService.IsKnownKey(apiKey) {
+		logger.Logger.Warn("Rejected RPC call without a known API key")
+		middleware.ErrorJitter(&s.cfg.Security)
+		return nil, rpcError(http.StatusUnauthorized, "unauthorized", "A known API key is required")
+	}
+
+	c := &caller{
+		clientKey: "key:" + apiKey,
+		profile:   s.profileService.GetProfile(apiKey),
+	}
+	if s.cfg.RateLimit.Enabled && !s.rateLimitService.IsAllowedWithLimit(c.clientKey, c.profile.RequestsPerMinute) {
+		return nil, rpcError(http.StatusTooManyRequests, "rate_limit_exceeded", "Too many requests. Please try again later.")
+	}
+	return context.WithValue(ctx, callerContextKey{}, c), nil
+}
+
+// authUnary authenticates unary calls
+func (s *Server) authUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authStream authenticates streaming calls
+func (s *Server) authStream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &callerStream{ServerStream: stream, ctx: ctx})
+}
+
+// callerStream is a server stream whose context carries the caller
+type callerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the stream's context with the caller attached
+func (cs *callerStream) Context() context.Context {
+	return cs.ctx
+}
+
+// callerFromContext returns the caller attached by the auth interceptors
+func callerFromContext(ctx context.Context) *caller {
+	c, _ := ctx.Value(callerContextKey{}).(*caller)
+	return c
+}
+
+// callerLogger returns a logger tagged with the RPC method and the caller's profile
+// The client key embeds the API key, so it is not logged
+func callerLogger(ctx context.Context, method string) *zap.Logger {
+	log := logger.Logger.With(zap.String("rpc", method))
+	if c := callerFromContext(ctx); c != nil {
+		log = log.With(zap.String("profile", c.profile.Name))
+	}
+	return log
+}
@@ -0,0 +1,153 @@
+package rpcapi
+
+import (
+	"net/http"
+
+	"videodownload/internal/model"
+	"videodownload/internal/rpcapi/videodownloadpb"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// errorDomain is the ErrorInfo domain of RPC errors; the reason is the REST error code
+const errorDomain = "videodownload"
+
+// rpcError builds a gRPC status error carrying the REST error code as ErrorInfo reason
+func rpcError(httpStatus int, code string, message string) error {
+	return rejectionError(&model.ErrorResponse{Error: code, Message: message, Code: httpStatus})
+}
+
+// rejectionError converts a REST error response into a gRPC status error
+func rejectionError(rejection *model.ErrorResponse) error {
+	st := status.New(grpcCode(rejection.Code), rejection.Message)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: rejection.Error, Domain: errorDomain}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// grpcCode maps the HTTP status of a REST error to the closest gRPC code
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusGone, http.StatusUnavailableForLegalReasons:
+		return codes.FailedPrecondition
+	case http.StatusPaymentRequired, http.StatusTooManyRequests, http.StatusInsufficientStorage:
+		return codes.ResourceExhausted
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	return codes.Internal
+}
+
+// downloadRequestFromProto converts a StartDownload request into the REST request model
+func downloadRequestFromProto(in *videodownloadpb.StartDownloadRequest) model.DownloadRequest {
+	req := model.DownloadRequest{
+		URL:            in.Url,
+		VideoToken:     in.VideoToken,
+		FormatID:       in.FormatId,
+		Quality:        in.Quality,
+		FileSize:       in.FileSize,
+		EmbedMetadata:  in.EmbedMetadata,
+		EmbedThumbnail: in.EmbedThumbnail,
+		CallbackURL:    in.CallbackUrl,
+		RawTrack:       in.RawTrack,
+		Filename:       in.Filename,
+	}
+	if in.Transcode != nil {
+		req.Transcode = &model.TranscodeSpec{
+			VideoCodec:       in.Transcode.VideoCodec,
+			MaxHeight:        int(in.Transcode.MaxHeight),
+			VideoBitrateKbps: int(in.Transcode.VideoBitrateKbps),
+		}
+	}
+	return req
+}
+
+// videoInfoToProto converts video info into its RPC message
+func videoInfoToProto(info *model.VideoInfo) *videodownloadpb.VideoInfo {
+	out := &videodownloadpb.VideoInfo{
+		Url:          info.URL,
+		Title:        info.Title,
+		Duration:     int32(info.Duration),
+		ThumbnailUrl: info.ThumbnailURL,
+		Uploader:     info.Uploader,
+		Extractor:    info.Extractor,
+		Platform:     info.Platform,
+		StartTime:    int32(info.StartTime),
+		Partial:      info.Partial,
+		Warnings:     info.Warnings,
+		Description:  info.Description,
+		Tags:         info.Tags,
+		UploadDate:   info.UploadDate,
+		VideoToken:   info.VideoToken,
+	}
+	for _, format := range info.Formats {
+		out.Formats = append(out.Formats, &videodownloadpb.Format{
+			FormatId:      format.FormatID,
+			Format:        format.Format,
+			Ext:           format.Extension,
+			Resolution:    format.Resolution,
+			VideoCodec:    format.VideoCodec,
+			AudioCodec:    format.AudioCodec,
+			FileSize:      format.FileSize,
+			Fps:           int32(format.Fps),
+			Quality:       format.Quality,
+			OfficialName:  format.OfficialName,
+			Protocol:      format.Protocol,
+			GeoRestricted: format.GeoRestricted,
+			Bitrate:       format.Bitrate,
+		})
+	}
+	return out
+}
+
+// jobToProto converts an async job into its RPC message
+// Download links are made absolute with PUBLIC_BASE_URL when it is set
+func (s *Server) jobToProto(job *model.DownloadJob) *videodownloadpb.DownloadJob {
+	out := &videodownloadpb.DownloadJob{
+		JobId:         job.JobID,
+		Status:        job.Status,
+		Progress:      job.Progress,
+		Indeterminate: job.Indeterminate,
+		BytesReceived: job.Received,
+		TotalBytes:    job.TotalBytes,
+		CreatedAt:     timestamppb.New(job.CreatedAt),
+	}
+	if job.FinishedAt != nil {
+		out.FinishedAt = timestamppb.New(*job.FinishedAt)
+	}
+	if job.Download != nil {
+		out.Download = &videodownloadpb.Download{
+			Id:                job.Download.ID,
+			Title:             job.Download.Title,
+			DownloadLink:      s.cfg.Server.PublicBaseURL + job.Download.DownloadLink,
+			ExpiresAt:         job.Download.ExpiresAt,
+			MetadataEmbedded:  job.Download.MetadataEmbedded,
+			ThumbnailEmbedded: job.Download.ThumbnailEmbedded,
+			Transcoded:        job.Download.Transcoded,
+		}
+	}
+	if job.Error != nil {
+		out.Error = &videodownloadpb.Error{
+			Code:       job.Error.Error,
+			Message:    job.Error.Message,
+			HttpStatus: int32(job.Error.Code),
+		}
+	}
+	return out
+}
@@ -0,0 +1,220 @@
+package rpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"videodownload/internal/model"
+	"videodownload/internal/rpcapi/videodownloadpb"
+	"videodownload/internal/service"
+	"videodownload/internal/storage"
+	"videodownload/pkg/logger"
+	"videodownload/pkg/validator"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// progressEventInterval is the minimum time between two job updates of one WatchDownload stream
+const progressEventInterval = 500 * time.Millisecond
+
+// Server serves the VideoDownload gRPC service backed by the same services as the REST API
+type Server struct {
+	videodownloadpb.UnimplementedVideoDownloadServer
+
+	cfg               *model.Config
+	videoService      *service.VideoService
+	downloadService   *service.DownloadService
+	jobManager        *service.JobManager
+	quotaService      *service.QuotaService
+	rateLimitService  *service.RateLimitService
+	profileService    *service.ProfileService
+	downloadSwitch    *service.DownloadSwitch
+	downloadValidator *service.DownloadValidator
+
+	addr     string
+	grpc     *grpc.Server
+	listener net.Listener
+}
+
+// NewServer creates a gRPC server; callers authenticate with an API key from LIMIT_PROFILES_FILE
+func NewServer(cfg *model.Config, vs *service.VideoService, ds *service.DownloadService, jm *service.JobManager, qs *service.QuotaService, rls *service.RateLimitService, ps *service.ProfileService, dsw *service.DownloadSwitch, dv *service.DownloadValidator) *Server {
+	s := &Server{
+		cfg:               cfg,
+		videoService:      vs,
+		downloadService:   ds,
+		jobManager:        jm,
+		quotaService:      qs,
+		rateLimitService:  rls,
+		profileService:    ps,
+		downloadSwitch:    dsw,
+		downloadValidator: dv,
+		addr:              fmt.Sprintf("%s:%d", cfg.RPC.Host, cfg.RPC.Port),
+	}
+
+	s.grpc = grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.authUnary),
+		grpc.ChainStreamInterceptor(s.authStream),
+	)
+	videodownloadpb.RegisterVideoDownloadServer(s.grpc, s)
+	return s
+}
+
+// Start listens on the configured address and serves in the background
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+
+	go func() {
+		if err := s.grpc.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			logger.Logger.Error("RPC server stopped", zap.Error(err))
+		}
+	}()
+
+	logger.Logger.Info("RPC server listening", zap.String("address", listener.Addr().String()))
+	return nil
+}
+
+// Stop closes the listener and every open connection, ending running WatchDownload streams
+// Queued and running downloads keep going in the job manager
+func (s *Server) Stop() {
+	s.grpc.Stop()
+}
+
+// GetVideoInfo mirrors GET /api/video/info
+func (s *Server) GetVideoInfo(ctx context.Context, in *videodownloadpb.GetVideoInfoRequest) (*videodownloadpb.VideoInfo, error) {
+	log := callerLogger(ctx, "GetVideoInfo")
+
+	if in.Url == "" {
+		return nil, rpcError(http.StatusBadRequest, "invalid_url", "Video URL is required")
+	}
+	videoURL, err := s.videoService.ResolveShortURL(ctx, in.Url)
+	if err != nil {
+		log.Warn("Failed to resolve short URL", zap.String("url", in.Url), zap.Error(err))
+		return nil, rpcError(http.StatusBadRequest, "unresolvable_url", "URL redirects could not be followed")
+	}
+	_, reason := validator.CheckURL(videoURL, s.cfg.Security.InfoAllowedDomains, s.cfg.Security.RequireHTTPSTarget)
+	if reason == validator.URLInsecure {
+		return nil, rpcError(http.StatusBadRequest, "insecure_url", "Only https URLs are allowed")
+	}
+	if reason != "" {
+		log.Warn("Invalid URL domain", zap.String("url", videoURL))
+		return nil, rpcError(http.StatusBadRequest, "invalid_domain", "URL domain is not allowed")
+	}
+
+	info, err := s.videoService.GetVideoInfo(ctx, videoURL, in.Verbose)
+	if errors.Is(err, service.ErrPlaylist) {
+		return nil, rpcError(http.StatusBadRequest, "playlist_unsupported", "Playlist URLs are not supported over RPC; request each video instead")
+	}
+	if err != nil {
+		log.Error("Failed to get video info", zap.Error(err), zap.String("url", videoURL))
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, rpcError(http.StatusGatewayTimeout, "request_timeout", "Request took too long and was cancelled")
+		}
+		return nil, rpcError(http.StatusBadGateway, "fetch_failed", "Failed to fetch video information")
+	}
+
+	labeled := *info
+	labeled.Formats = service.LabelFormats(info.Formats, s.cfg.QualityCategories.Labels)
+	labeled.VideoToken = s.videoService.VideoToken(videoURL)
+	return videoInfoToProto(&labeled), nil
+}
+
+// StartDownload mirrors POST /api/download
+// The request passes the same validation, quota and concurrency limits as over REST
+func (s *Server) StartDownload(ctx context.Context, in *videodownloadpb.StartDownloadRequest) (*videodownloadpb.DownloadJob, error) {
+	caller := callerFromContext(ctx)
+	log := callerLogger(ctx, "StartDownload")
+
+	if s.downloadSwitch.IsDisabled() {
+		return nil, rpcError(http.StatusServiceUnavailable, "downloads_disabled", "Downloads are temporarily disabled. Please try again later.")
+	}
+	if level, _ := s.downloadService.CheckDisk(); level == storage.DiskCritical {
+		return nil, rpcError(http.StatusInsufficientStorage, "storage_full", "Server storage is full. Please try again later.")
+	}
+	if err := s.checkQuota(log, caller); err != nil {
+		return nil, err
+	}
+
+	req := downloadRequestFromProto(in)
+	if rejection := s.downloadValidator.Validate(ctx, log, &req, caller.profile); rejection != nil {
+		return nil, rejectionError(rejection)
+	}
+
+	job, rejection := s.jobManager.Start(req, caller.clientKey, caller.profile)
+	if rejection != nil {
+		return nil, rejectionError(rejection)
+	}
+	return s.jobToProto(job), nil
+}
+
+// GetDownloadStatus mirrors GET /api/download/status/:jobid
+func (s *Server) GetDownloadStatus(ctx context.Context, in *videodownloadpb.GetDownloadStatusRequest) (*videodownloadpb.DownloadJob, error) {
+	job, ok := s.jobManager.GetJob(in.JobId, callerFromContext(ctx).clientKey)
+	if !ok {
+		return nil, rpcError(http.StatusNotFound, "not_found", "Job not found or has expired")
+	}
+	return s.jobToProto(job), nil
+}
+
+// WatchDownload mirrors GET /api/download/progress/:id
+// Sends the job's state on every change, at most once per progressEventInterval, and ends
+// after the done or failed state; the download keeps running when the caller goes away
+func (s *Server) WatchDownload(in *videodownloadpb.GetDownloadStatusRequest, stream videodownloadpb.VideoDownload_WatchDownloadServer) error {
+	ctx := stream.Context()
+	clientKey := callerFromContext(ctx).clientKey
+
+	for {
+		job, changed, ok := s.jobManager.WatchJob(in.JobId, clientKey)
+		if !ok {
+			return rpcError(http.StatusNotFound, "not_found", "Job not found or has expired")
+		}
+		if err := stream.Send(s.jobToProto(job)); err != nil {
+			return err
+		}
+		if job.Status == model.JobDone || job.Status == model.JobFailed {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		// Coalesce the many updates of a fast download into one message per interval
+		select {
+		case <-time.After(progressEventInterval):
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+// checkQuota mirrors QuotaCheckMiddleware: only callers whose quota is used up are refused,
+// since the file size is not known yet
+func (s *Server) checkQuota(log *zap.Logger, caller *caller) error {
+	if !s.cfg.Quota.Enabled {
+		return nil
+	}
+	if s.cfg.Quota.DailyLimitMB < int64(s.cfg.Storage.MaxVideoSizeMB) {
+		log.Error("Server configuration error: daily quota limit is less than max video size",
+			zap.Int64("daily_limit_mb", s.cfg.Quota.DailyLimitMB),
+			zap.Int64("max_video_size_mb", int64(s.cfg.Storage.MaxVideoSizeMB)))
+		return rpcError(http.StatusServiceUnavailable, "quota_limit", "Server is currently under maintenance. Please try again later.")
+	}
+
+	allowed, remainingMB := s.quotaService.CheckQuotaWithLimit(caller.clientKey, 0, caller.profile.DailyLimitMB)
+	if !allowed && remainingMB == 0 {
+		log.Warn("Quota exhausted")
+		return rpcError(http.StatusPaymentRequired, "quota_exhausted", "Daily download quota exhausted. Please try again after quota reset.")
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package rpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"videodownload/config"
+	"videodownload/internal/rpcapi/videodownloadpb"
+	"videodownload/internal/service"
+	"videodownload/internal/storage"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testAPIKey   = "partner-key"
+	otherAPIKey  = "other-key"
+	testVideoURL = "https://www.youtube.com/watch?v=abc123"
+)
+
+// testVideo is a minimal MP4 body the fake worker serves for downloads
+var testVideo = append([]byte{0, 0, 0, 0x18, 'f', 't', 'y', 'p', 'm', 'p', '4', '2'}, make([]byte, 4096)...)
+
+// newFakeWorker serves the worker's /api/info and /api/download endpoints for testVideoURL
+func newFakeWorker(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/info", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":            "abc123",
+			"title":         "Test video",
+			"duration":      42,
+			"extractor_key": "Youtube",
+			"url":           testVideoURL,
+			"formats": []map[string]interface{}{
+				{"format_id": "18", "ext": "mp4", "resolution": "640x360", "vcodec": "avc1", "acodec": "mp4a", "filesize": len(testVideo), "protocol": "https"},
+			},
+		})
+	})
+	mux.HandleFunc("/api/download", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Header().Set("Content-Disposition", `attachment; filename="Test video.mp4"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(testVideo)))
+		w.Write(testVideo)
+	})
+	mux.HandleFunc("/api/resolve", http.NotFound)
+
+	worker := httptest.NewServer(mux)
+	t.Cleanup(worker.Close)
+	return worker
+}
+
+// startTestServer starts an RPC server wired like main.go against a fake worker and
+// returns a client for it
+func startTestServer(t *testing.T) videodownloadpb.VideoDownloadClient {
+	t.Helper()
+
+	worker, _ := url.Parse(newFakeWorker(t).URL)
+	dir := t.TempDir()
+	profilesFile := filepath.Join(dir, "profiles.json")
+	profiles := `{"profiles": {"partner": {"max_concurrent": 2}}, "keys": {"` + testAPIKey + `": "partner", "` + otherAPIKey + `": "partner"}}`
+	if err := os.WriteFile(profilesFile, []byte(profiles), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("PYTHON_WORKER_HOST", worker.Hostname())
+	t.Setenv("PYTHON_WORKER_PORT", worker.Port())
+	t.Setenv("DOWNLOAD_DIR", filepath.Join(dir, "downloads"))
+	t.Setenv("STORAGE_TRACKING_FILE", filepath.Join(dir, "files.json"))
+	t.Setenv("STATS_FILE", "")
+	t.Setenv("LIMIT_PROFILES_FILE", profilesFile)
+	t.Setenv("CALLBACK_ALLOWED_DOMAINS", "hooks.example.com")
+	t.Setenv("CALLBACK_SECRET", "test-secret")
+	t.Setenv("RPC_HOST", "127.0.0.1")
+	t.Setenv("RPC_PORT", "0")
+	cfg := config.Load()
+
+	storageManager := storage.NewManager(&cfg.Storage)
+	if err := storageManager.EnsureDownloadDir(); err != nil {
+		t.Fatal(err)
+	}
+	videoService := service.NewVideoService(cfg.Python.Host, cfg.Python.Port, cfg.Python.Timeout, cfg)
+	downloadService := service.NewDownloadService(cfg.Python.Host, cfg.Python.Port, cfg.Python.Timeout,
+		storageManager, videoService, service.NewLifetimeStats(&cfg.Stats), cfg)
+	quotaService := service.NewQuotaService(&cfg.Quota, nil)
+	rateLimitService := service.NewRateLimitService(&cfg.RateLimit)
+	t.Cleanup(rateLimitService.Stop)
+	profileService, err := service.NewProfileService(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := NewServer(cfg, videoService, downloadService,
+		service.NewJobManager(downloadService, quotaService, cfg),
+		quotaService, rateLimitService, profileService,
+		service.NewDownloadSwitch(false),
+		service.NewDownloadValidator(videoService, cfg))
+	if err := server.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(server.listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return videodownloadpb.NewVideoDownloadClient(conn)
+}
+
+// withAPIKey returns a context carrying an API key
+func withAPIKey(t *testing.T, apiKey string) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return metadata.AppendToOutgoingContext(ctx, "x-api-key", apiKey)
+}
+
+// errorReason returns the REST error code carried by an RPC error
+func errorReason(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+	return ""
+}
+
+func TestGetVideoInfo(t *testing.T) {
+	client := startTestServer(t)
+
+	info, err := client.GetVideoInfo(withAPIKey(t, testAPIKey), &videodownloadpb.GetVideoInfoRequest{Url: testVideoURL})
+	if err != nil {
+		t.Fatalf("GetVideoInfo: %v", err)
+	}
+	if info.Title != "Test video" {
+		t.Errorf("title = %q, want %q", info.Title, "Test video")
+	}
+	if len(info.Formats) != 1 || info.Formats[0].FormatId != "18" {
+		t.Errorf("formats = %v, want the single format 18", info.Formats)
+	}
+	if info.VideoToken == "" {
+		t.Error("video token is empty")
+	}
+}
+
+func TestCallsWithoutKnownAPIKeyAreRefused(t *testing.T) {
+	client := startTestServer(t)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{"no key", context.Background()},
+		{"unknown key", metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "guess")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.GetVideoInfo(tt.ctx, &videodownloadpb.GetVideoInfoRequest{Url: testVideoURL})
+			if status.Code(err) != codes.Unauthenticated {
+				t.Fatalf("code = %v, want %v", status.Code(err), codes.Unauthenticated)
+			}
+		})
+	}
+}
+
+func TestStartDownloadStreamsProgressUntilDone(t *testing.T) {
+	client := startTestServer(t)
+	ctx := withAPIKey(t, testAPIKey)
+
+	job, err := client.StartDownload(ctx, &videodownloadpb.StartDownloadRequest{Url: testVideoURL, FormatId: "18"})
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	if job.JobId == "" {
+		t.Fatal("job ID is empty")
+	}
+
+	stream, err := client.WatchDownload(ctx, &videodownloadpb.GetDownloadStatusRequest{JobId: job.JobId})
+	if err != nil {
+		t.Fatalf("WatchDownload: %v", err)
+	}
+	var last *videodownloadpb.DownloadJob
+	for {
+		update, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		last = update
+	}
+
+	if last == nil || last.Status != "done" {
+		t.Fatalf("final job = %v, want status done", last)
+	}
+	if last.Download == nil || last.Download.Id == "" {
+		t.Fatalf("done job has no download: %v", last)
+	}
+
+	current, err := client.GetDownloadStatus(ctx, &videodownloadpb.GetDownloadStatusRequest{JobId: job.JobId})
+	if err != nil {
+		t.Fatalf("GetDownloadStatus: %v", err)
+	}
+	if current.Download.GetId() != last.Download.Id {
+		t.Errorf("status download = %q, want %q", current.Download.GetId(), last.Download.Id)
+	}
+}
+
+func TestStartDownloadAppliesRESTValidation(t *testing.T) {
+	client := startTestServer(t)
+
+	tests := []struct {
+		name   string
+		req    *videodownloadpb.StartDownloadRequest
+		reason string
+	}{
+		{"domain not allowed", &videodownloadpb.StartDownloadRequest{Url: "https://example.com/video", FormatId: "18"}, "invalid_domain"},
+		{"callback outside allowlist", &videodownloadpb.StartDownloadRequest{Url: testVideoURL, FormatId: "18", CallbackUrl: "http://169.254.169.254/latest"}, "invalid_callback"},
+		{"path in filename", &videodownloadpb.StartDownloadRequest{Url: testVideoURL, FormatId: "18", Filename: "../../etc/passwd"}, "invalid_filename"},
+		{"bad video token", &videodownloadpb.StartDownloadRequest{VideoToken: "not-a-token", FormatId: "18"}, "invalid_video_token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.StartDownload(withAPIKey(t, testAPIKey), tt.req)
+			if status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("code = %v, want %v", status.Code(err), codes.InvalidArgument)
+			}
+			if reason := errorReason(err); reason != tt.reason {
+				t.Errorf("reason = %q, want %q", reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestJobsAreScopedToTheirAPIKey(t *testing.T) {
+	client := startTestServer(t)
+
+	job, err := client.StartDownload(withAPIKey(t, testAPIKey), &videodownloadpb.StartDownloadRequest{Url: testVideoURL, FormatId: "18"})
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+
+	_, err = client.GetDownloadStatus(withAPIKey(t, otherAPIKey), &videodownloadpb.GetDownloadStatusRequest{JobId: job.JobId})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("code = %v, want %v", status.Code(err), codes.NotFound)
+	}
+}
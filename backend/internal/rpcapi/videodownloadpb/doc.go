@@ -0,0 +1,4 @@
+// Package videodownloadpb holds the generated code of the VideoDownload gRPC service
+package videodownloadpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative videodownload.proto
@@ -0,0 +1,260 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: videodownload.proto
+
+package videodownloadpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	VideoDownload_GetVideoInfo_FullMethodName      = "/videodownload.v1.VideoDownload/GetVideoInfo"
+	VideoDownload_StartDownload_FullMethodName     = "/videodownload.v1.VideoDownload/StartDownload"
+	VideoDownload_GetDownloadStatus_FullMethodName = "/videodownload.v1.VideoDownload/GetDownloadStatus"
+	VideoDownload_WatchDownload_FullMethodName     = "/videodownload.v1.VideoDownload/WatchDownload"
+)
+
+// VideoDownloadClient is the client API for VideoDownload service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type VideoDownloadClient interface {
+	// GetVideoInfo mirrors GET /api/video/info
+	GetVideoInfo(ctx context.Context, in *GetVideoInfoRequest, opts ...grpc.CallOption) (*VideoInfo, error)
+	// StartDownload mirrors POST /api/download: the download is queued and
+	// its job returned right away
+	StartDownload(ctx context.Context, in *StartDownloadRequest, opts ...grpc.CallOption) (*DownloadJob, error)
+	// GetDownloadStatus mirrors GET /api/download/status/:jobid
+	GetDownloadStatus(ctx context.Context, in *GetDownloadStatusRequest, opts ...grpc.CallOption) (*DownloadJob, error)
+	// WatchDownload mirrors GET /api/download/progress/:id: it streams the job's state while it
+	// is queued or running and ends after sending the done or failed state
+	WatchDownload(ctx context.Context, in *GetDownloadStatusRequest, opts ...grpc.CallOption) (VideoDownload_WatchDownloadClient, error)
+}
+
+type videoDownloadClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewVideoDownloadClient(cc grpc.ClientConnInterface) VideoDownloadClient {
+	return &videoDownloadClient{cc}
+}
+
+func (c *videoDownloadClient) GetVideoInfo(ctx context.Context, in *GetVideoInfoRequest, opts ...grpc.CallOption) (*VideoInfo, error) {
+	out := new(VideoInfo)
+	err := c.cc.Invoke(ctx, VideoDownload_GetVideoInfo_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *videoDownloadClient) StartDownload(ctx context.Context, in *StartDownloadRequest, opts ...grpc.CallOption) (*DownloadJob, error) {
+	out := new(DownloadJob)
+	err := c.cc.Invoke(ctx, VideoDownload_StartDownload_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *videoDownloadClient) GetDownloadStatus(ctx context.Context, in *GetDownloadStatusRequest, opts ...grpc.CallOption) (*DownloadJob, error) {
+	out := new(DownloadJob)
+	err := c.cc.Invoke(ctx, VideoDownload_GetDownloadStatus_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *videoDownloadClient) WatchDownload(ctx context.Context, in *GetDownloadStatusRequest, opts ...grpc.CallOption) (VideoDownload_WatchDownloadClient, error) {
+	stream, err := c.cc.NewStream(ctx, &VideoDownload_ServiceDesc.Streams[0], VideoDownload_WatchDownload_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &videoDownloadWatchDownloadClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type VideoDownload_WatchDownloadClient interface {
+	Recv() (*DownloadJob, error)
+	grpc.ClientStream
+}
+
+type videoDownloadWatchDownloadClient struct {
+	grpc.ClientStream
+}
+
+func (x *videoDownloadWatchDownloadClient) Recv() (*DownloadJob, error) {
+	m := new(DownloadJob)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// VideoDownloadServer is the server API for VideoDownload service.
+// All implementations must embed UnimplementedVideoDownloadServer
+// for forward compatibility
+type VideoDownloadServer interface {
+	// GetVideoInfo mirrors GET /api/video/info
+	GetVideoInfo(context.Context, *GetVideoInfoRequest) (*VideoInfo, error)
+	// StartDownload mirrors POST /api/download: the download is queued and
+	// its job returned right away
+	StartDownload(context.Context, *StartDownloadRequest) (*DownloadJob, error)
+	// GetDownloadStatus mirrors GET /api/download/status/:jobid
+	GetDownloadStatus(context.Context, *GetDownloadStatusRequest) (*DownloadJob, error)
+	// WatchDownload mirrors GET /api/download/progress/:id: it streams the job's state while it
+	// is queued or running and ends after sending the done or failed state
+	WatchDownload(*GetDownloadStatusRequest, VideoDownload_WatchDownloadServer) error
+	mustEmbedUnimplementedVideoDownloadServer()
+}
+
+// UnimplementedVideoDownloadServer must be embedded to have forward compatible implementations.
+type UnimplementedVideoDownloadServer struct {
+}
+
+func (UnimplementedVideoDownloadServer) GetVideoInfo(context.Context, *GetVideoInfoRequest) (*VideoInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetVideoInfo not implemented")
+}
+func (UnimplementedVideoDownloadServer) StartDownload(context.Context, *StartDownloadRequest) (*DownloadJob, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartDownload not implemented")
+}
+func (UnimplementedVideoDownloadServer) GetDownloadStatus(context.Context, *GetDownloadStatusRequest) (*DownloadJob, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDownloadStatus not implemented")
+}
+func (UnimplementedVideoDownloadServer) WatchDownload(*GetDownloadStatusRequest, VideoDownload_WatchDownloadServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchDownload not implemented")
+}
+func (UnimplementedVideoDownloadServer) mustEmbedUnimplementedVideoDownloadServer() {}
+
+// UnsafeVideoDownloadServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to VideoDownloadServer will
+// result in compilation errors.
+type UnsafeVideoDownloadServer interface {
+	mustEmbedUnimplementedVideoDownloadServer()
+}
+
+func RegisterVideoDownloadServer(s grpc.ServiceRegistrar, srv VideoDownloadServer) {
+	s.RegisterService(&VideoDownload_ServiceDesc, srv)
+}
+
+func _VideoDownload_GetVideoInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetVideoInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoDownloadServer).GetVideoInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VideoDownload_GetVideoInfo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoDownloadServer).GetVideoInfo(ctx, req.(*GetVideoInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoDownload_StartDownload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartDownloadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoDownloadServer).StartDownload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VideoDownload_StartDownload_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoDownloadServer).StartDownload(ctx, req.(*StartDownloadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoDownload_GetDownloadStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDownloadStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(VideoDownloadServer).GetDownloadStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: VideoDownload_GetDownloadStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(VideoDownloadServer).GetDownloadStatus(ctx, req.(*GetDownloadStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _VideoDownload_WatchDownload_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetDownloadStatusRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(VideoDownloadServer).WatchDownload(m, &videoDownloadWatchDownloadServer{stream})
+}
+
+type VideoDownload_WatchDownloadServer interface {
+	Send(*DownloadJob) error
+	grpc.ServerStream
+}
+
+type videoDownloadWatchDownloadServer struct {
+	grpc.ServerStream
+}
+
+func (x *videoDownloadWatchDownloadServer) Send(m *DownloadJob) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// VideoDownload_ServiceDesc is the grpc.ServiceDesc for VideoDownload service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var VideoDownload_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "videodownload.v1.VideoDownload",
+	HandlerType: (*VideoDownloadServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetVideoInfo",
+			Handler:    _VideoDownload_GetVideoInfo_Handler,
+		},
+		{
+			MethodName: "StartDownload",
+			Handler:    _VideoDownload_StartDownload_Handler,
+		},
+		{
+			MethodName: "GetDownloadStatus",
+			Handler:    _VideoDownload_GetDownloadStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchDownload",
+			Handler:       _VideoDownload_WatchDownload_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "videodownload.proto",
+}
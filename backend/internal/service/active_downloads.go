@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"sync"
+)
+
+// activeDownloads indexes the cancel funcs of queued and running downloads by client key
+type activeDownloads struct {
+	byClient map[string]map[uint64]context.CancelFunc
+	nextID   uint64
+	mu       sync.Mutex
+}
+
+// newActiveDownloads creates an empty download index
+func newActiveDownloads() *activeDownloads {
+	return &activeDownloads{
+		byClient: make(map[string]map[uint64]context.CancelFunc),
+	}
+}
+
+// track registers a new download of clientKey and returns its context, derived from parent
+// The returned func must be called once the download is over
+func (a *activeDownloads) track(parent context.Context, clientKey string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+
+	a.mu.Lock()
+	a.nextID++
+	id := a.nextID
+	if a.byClient[clientKey] == nil {
+		a.byClient[clientKey] = make(map[uint64]context.CancelFunc)
+	}
+	a.byClient[clientKey][id] = cancel
+	a.mu.Unlock()
+
+	return ctx, func() {
+		a.mu.Lock()
+		delete(a.byClient[clientKey], id)
+		if len(a.byClient[clientKey]) == 0 {
+			delete(a.byClient, clientKey)
+		}
+		a.mu.Unlock()
+		cancel()
+	}
+}
+
+// cancelAll cancels every tracked download of clientKey and returns how many there were
+func (a *activeDownloads) cancelAll(clientKey string) int {
+	a.mu.Lock()
+	downloads := a.byClient[clientKey]
+	delete(a.byClient, clientKey)
+	a.mu.Unlock()
+
+	for _, cancel := range downloads {
+		cancel()
+	}
+	return len(downloads)
+}
@@ -0,0 +1,345 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"videodownload/internal/model"
+	"videodownload/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// batchJob tracks the items of a single batch download
+type batchJob struct {
+	id        string
+	clientKey string
+	maxConc   int // Client's concurrent download cap, items queue behind it
+	createdAt time.Time
+	items     []model.BatchItemResult
+	pending   int
+	done      chan struct{}
+	cancel    chan struct{} // Closed to cancel items that have not started yet
+	cancelled bool
+}
+
+// BatchService runs batch downloads and keeps their progress for polling
+// Every item runs as a job of the JobManager, so item job IDs can be polled like any other job
+type BatchService struct {
+	downloadService *DownloadService
+	jobManager      *JobManager
+	cfg             *model.Config
+	batches         map[string]*batchJob
+	mu              sync.RWMutex
+}
+
+// NewBatchService creates a new batch service
+func NewBatchService(ds *DownloadService, jm *JobManager, cfg *model.Config) *BatchService {
+	return &BatchService{
+		downloadService: ds,
+		jobManager:      jm,
+		cfg:             cfg,
+		batches:         make(map[string]*batchJob),
+	}
+}
+
+// RunBatch starts downloading every accepted item concurrently and waits up to the batch deadline
+// Items listed in rejected are reported as failed without being downloaded, as are items
+// whose quota reservation doesn't fit the client's remaining quota
+// Items still running at the deadline are reported as pending and keep running in the background
+// Items run at most the profile's MaxConcurrent at a time (0 = unlimited); the rest wait for a free slot
+// The zero deadline means BATCH_DEADLINE_SECONDS from now
+func (bs *BatchService) RunBatch(reqs []model.DownloadRequest, rejected map[int]*model.ErrorResponse, clientKey string, profile *model.LimitProfile, deadline time.Time) *model.BatchResponse {
+	bs.expireBatches()
+
+	job := &batchJob{
+		id:        randomID(),
+		clientKey: clientKey,
+		maxConc:   profile.MaxConcurrent,
+		createdAt: time.Now(),
+		items:     make([]model.BatchItemResult, len(reqs)),
+		done:      make(chan struct{}),
+		cancel:    make(chan struct{}),
+	}
+
+	now := time.Now()
+	startingNow := 0
+	tracked := make([]*downloadJob, len(reqs))
+
+	for i := range reqs {
+		job.items[i] = model.BatchItemResult{
+			Index:  i,
+			URL:    reqs[i].URL,
+			Status: model.BatchItemPending,
+		}
+		rejection, ok := rejected[i]
+		if !ok {
+			// Items are checked against the quota one by one, in order
+			tracked[i], rejection = bs.jobManager.enqueue(&reqs[i], clientKey, profile, false)
+		}
+		if rejection != nil {
+			job.items[i].Status = model.BatchItemFailed
+			job.items[i].Error = rejection
+			continue
+		}
+		job.items[i].JobID = tracked[i].state.JobID
+		if reqs[i].StartAt != nil && reqs[i].StartAt.After(now) {
+			job.items[i].Status = model.BatchItemScheduled
+			job.items[i].ScheduledAt = reqs[i].StartAt
+		} else {
+			startingNow++
+		}
+		job.pending++
+	}
+
+	bs.mu.Lock()
+	bs.batches[job.id] = job
+	bs.mu.Unlock()
+
+	if job.pending == 0 {
+		close(job.done)
+	}
+
+	rejectedItems := 0
+	for i := range reqs {
+		if tracked[i] == nil {
+			rejectedItems++
+			continue
+		}
+		go bs.runItem(job, i, tracked[i], reqs[i])
+	}
+
+	logger.Logger.Info("Batch download started",
+		zap.String("batch_id", job.id),
+		zap.Int("items", len(reqs)),
+		zap.Int("rejected", rejectedItems))
+
+	if deadline.IsZero() {
+		deadline = time.Now().Add(time.Duration(bs.cfg.Batch.DeadlineSeconds) * time.Second)
+	}
+
+	// Scheduled items won't finish within the deadline, so only wait for items starting now
+	if startingNow > 0 {
+		select {
+		case <-job.done:
+		case <-time.After(time.Until(deadline)):
+			logger.Logger.Info("Batch deadline reached, returning partial results", zap.String("batch_id", job.id))
+		}
+	}
+
+	return bs.snapshot(job)
+}
+
+// runItem downloads a single batch item and records its result in the batch and its job
+// Quota is settled per item by the job, so failures never undo other items' charges
+func (bs *BatchService) runItem(job *batchJob, index int, tracked *downloadJob, req model.DownloadRequest) {
+	if req.StartAt != nil && !bs.waitForStart(job, index, *req.StartAt) {
+		bs.jobManager.finish(tracked, nil, ErrDownloadCancelled)
+		return
+	}
+
+	resp, err := bs.downloadService.DownloadWhenFree(&req, job.clientKey, job.maxConc, job.cancel,
+		func() { bs.jobManager.markRunning(tracked) }, bs.jobManager.progressFunc(tracked), bs.jobManager.phaseFunc(tracked))
+	bs.jobManager.finish(tracked, resp, err)
+
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	item := &job.items[index]
+	if errors.Is(err, ErrDownloadCancelled) {
+		item.Status = model.BatchItemCancelled
+	} else if err != nil {
+		logger.Logger.Warn("Batch item failed", zap.String("job_id", item.JobID), zap.Error(err))
+		item.Status = model.BatchItemFailed
+		item.Error = downloadErrorResponse(err)
+	} else {
+		item.Status = model.BatchItemDone
+		item.Success = true
+		item.DownloadID = resp.ID
+		item.Download = resp
+	}
+
+	job.pending--
+	if job.pending == 0 {
+		close(job.done)
+	}
+}
+
+// waitForStart holds a scheduled item until its start time
+// Returns false when the item was cancelled first
+func (bs *BatchService) waitForStart(job *batchJob, index int, startAt time.Time) bool {
+	timer := time.NewTimer(time.Until(startAt))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		bs.mu.Lock()
+		defer bs.mu.Unlock()
+
+		// CancelBatch may have won the race against the timer
+		if job.items[index].Status == model.BatchItemCancelled {
+			return false
+		}
+		job.items[index].Status = model.BatchItemPending
+		return true
+	case <-job.cancel:
+		return false
+	}
+}
+
+// CancelBatch cancels the items of a batch that have not started yet
+// Items already downloading run to completion
+func (bs *BatchService) CancelBatch(batchID string, clientKey string) (*model.BatchResponse, bool) {
+	bs.mu.Lock()
+	job, exists := bs.batches[batchID]
+	if !exists || job.clientKey != clientKey {
+		bs.mu.Unlock()
+		return nil, false
+	}
+	cancelledItems := bs.cancelJobLocked(job)
+	bs.mu.Unlock()
+
+	logger.Logger.Info("Batch cancelled", zap.String("batch_id", batchID), zap.Int("cancelled_items", cancelledItems))
+	return bs.snapshot(job), true
+}
+
+// CancelScheduled cancels the scheduled items of every batch owned by clientKey
+// Returns the number of items cancelled; queued and running items are left to DownloadService.CancelAll
+func (bs *BatchService) CancelScheduled(clientKey string) int {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	cancelledItems := 0
+	for _, job := range bs.batches {
+		if job.clientKey == clientKey {
+			cancelledItems += bs.cancelJobLocked(job)
+		}
+	}
+	return cancelledItems
+}
+
+// cancelJobLocked cancels a batch's scheduled items and signals its queued items to stop
+// Returns the number of scheduled items cancelled; bs.mu must be held
+func (bs *BatchService) cancelJobLocked(job *batchJob) int {
+	cancelledItems := 0
+	for i := range job.items {
+		if job.items[i].Status == model.BatchItemScheduled {
+			job.items[i].Status = model.BatchItemCancelled
+			job.pending--
+			cancelledItems++
+		}
+	}
+	if cancelledItems > 0 && job.pending == 0 {
+		close(job.done)
+	}
+	if !job.cancelled {
+		job.cancelled = true
+		close(job.cancel)
+	}
+	return cancelledItems
+}
+
+// GetBatch returns the current progress of a batch owned by clientKey
+func (bs *BatchService) GetBatch(batchID string, clientKey string) (*model.BatchResponse, bool) {
+	bs.mu.RLock()
+	job, exists := bs.batches[batchID]
+	bs.mu.RUnlock()
+
+	if !exists || job.clientKey != clientKey {
+		return nil, false
+	}
+	return bs.snapshot(job), true
+}
+
+// snapshot copies a batch's current state into a response
+func (bs *BatchService) snapshot(job *batchJob) *model.BatchResponse {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
+	items := make([]model.BatchItemResult, len(job.items))
+	copy(items, job.items)
+
+	status := model.BatchItemDone
+	if job.pending > 0 {
+		status = model.BatchItemPending
+	}
+
+	return &model.BatchResponse{
+		BatchID: job.id,
+		Status:  status,
+		Items:   items,
+	}
+}
+
+// expireBatches drops finished batch records older than the file TTL
+func (bs *BatchService) expireBatches() {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	ttl := time.Duration(bs.cfg.Storage.FileTTLSeconds) * time.Second
+	for id, job := range bs.batches {
+		if job.pending == 0 && time.Since(job.createdAt) > ttl {
+			delete(bs.batches, id)
+		}
+	}
+}
+
+// downloadErrorResponse converts a download error into the API error shape
+func downloadErrorResponse(err error) *model.ErrorResponse {
+	if errors.Is(err, ErrDownloadCancelled) {
+		return &model.ErrorResponse{
+			Error:   "download_cancelled",
+			Message: "Download was cancelled",
+			Code:    http.StatusConflict,
+		}
+	}
+	if errors.Is(err, ErrDownloadTimeout) {
+		return &model.ErrorResponse{
+			Error:   "download_timeout",
+			Message: "Download took too long and was cancelled",
+			Code:    http.StatusGatewayTimeout,
+		}
+	}
+	if errors.Is(err, ErrWorkerBadResponse) {
+		return &model.ErrorResponse{
+			Error:   "worker_bad_response",
+			Message: "The worker returned an unexpected response instead of the video",
+			Code:    http.StatusBadGateway,
+		}
+	}
+	if errors.Is(err, ErrStorageFull) {
+		return &model.ErrorResponse{
+			Error:   "storage_full",
+			Message: "Server storage is full. Please try again later.",
+			Code:    http.StatusInsufficientStorage,
+		}
+	}
+	if errors.Is(err, ErrFormatUnavailable) {
+		return &model.ErrorResponse{
+			Error:   "format_unavailable",
+			Message: "The selected format is not available for this video",
+			Code:    http.StatusGone,
+		}
+	}
+	if errors.Is(err, ErrInvalidFilename) {
+		return &model.ErrorResponse{
+			Error:   "invalid_filename",
+			Message: "Filename must be a plain, non-empty file name",
+			Code:    http.StatusBadRequest,
+		}
+	}
+	if errors.Is(err, ErrDisallowedContentType) {
+		return &model.ErrorResponse{
+			Error:   "disallowed_content_type",
+			Message: err.Error(),
+			Code:    http.StatusUnprocessableEntity,
+		}
+	}
+	return &model.ErrorResponse{
+		Error:   "download_failed",
+		Message: err.Error(),
+		Code:    http.StatusInternalServerError,
+	}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"videodownload/internal/model"
+	"videodownload/pkg/logger"
+	"videodownload/pkg/validator"
+
+	"go.uber.org/zap"
+)
+
+// Callback request headers
+const (
+	CallbackSignatureHeader = "X-Vidhub-Signature"
+	CallbackTimestampHeader = "X-Vidhub-Timestamp"
+)
+
+// CallbackService notifies per-request callback URLs when downloads finish
+type CallbackService struct {
+	httpClient *http.Client
+	cfg        *model.CallbackConfig
+}
+
+// NewCallbackService creates a new callback service
+func NewCallbackService(cfg *model.CallbackConfig) *CallbackService {
+	return &CallbackService{
+		httpClient: &http.Client{
+			Timeout: time.Duration(cfg.Timeout) * time.Second,
+			// Redirects could lead outside the callback allowlist
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		cfg: cfg,
+	}
+}
+
+// Notify POSTs the payload to callbackURL in the background
+// The body is signed as "sha256=<hex HMAC-SHA256(secret, timestamp + "." + body)>"
+// The URL is checked against the allowlist again, since requests may have been queued under an
+// older configuration; nothing is sent without a secret, as the signature would be forgeable
+func (s *CallbackService) Notify(callbackURL string, payload *model.CallbackPayload) {
+	if s.cfg.Secret == "" {
+		logger.Logger.Warn("Callback skipped: CALLBACK_SECRET is not set", zap.String("callback_url", callbackURL))
+		return
+	}
+	if !validator.ValidateCallbackURL(callbackURL, s.cfg.AllowedDomains) {
+		logger.Logger.Warn("Callback skipped: URL not allowed", zap.String("callback_url", callbackURL))
+		return
+	}
+
+	payload.Timestamp = time.Now().Unix()
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logger.Logger.Error("Failed to encode callback payload", zap.Error(err))
+		return
+	}
+
+	go func() {
+		req, err := http.NewRequest("POST", callbackURL, bytes.NewReader(body))
+		if err != nil {
+			logger.Logger.Warn("Invalid callback URL", zap.String("callback_url", callbackURL), zap.Error(err))
+			return
+		}
+
+		timestamp := fmt.Sprintf("%d", payload.Timestamp)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(CallbackTimestampHeader, timestamp)
+		req.Header.Set(CallbackSignatureHeader, SignCallback(s.cfg.Secret, timestamp, body))
+
+		resp, err := s.httpClient.Do(req)
+		if err != nil {
+			logger.Logger.Warn("Callback delivery failed", zap.String("callback_url", callbackURL), zap.Error(err))
+			return
+		}
+		resp.Body.Close()
+
+		logger.Logger.Info("Callback delivered",
+			zap.String("callback_url", callbackURL),
+			zap.String("event", payload.Event),
+			zap.Int("status", resp.StatusCode))
+	}()
+}
+
+// SignCallback computes the signature header value of a callback body
+func SignCallback(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"videodownload/internal/model"
+)
+
+func TestSignCallback(t *testing.T) {
+	body := []byte(`{"event":"download.completed"}`)
+	signature := SignCallback("secret", "1700000000", body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      []byte
+		wantSame  bool
+	}{
+		{"same input", "secret", "1700000000", body, true},
+		{"other secret", "other", "1700000000", body, false},
+		{"other timestamp", "secret", "1700000001", body, false},
+		{"other body", "secret", "1700000000", []byte(`{"event":"download.failed"}`), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SignCallback(tt.secret, tt.timestamp, tt.body)
+			if (got == signature) != tt.wantSame {
+				t.Errorf("SignCallback = %s, matches %s: %v, want %v", got, signature, got == signature, tt.wantSame)
+			}
+		})
+	}
+
+	// HMAC-SHA256("secret", "1700000000." + body), as a receiver computes it
+	const want = "sha256=78b47e43cab6e32319447846fe3b4704213e3de2aa174be5fb3ad9de1b20065e"
+	if signature != want {
+		t.Errorf("signature = %s, want %s", signature, want)
+	}
+}
+
+func TestCallbackNotify(t *testing.T) {
+	type delivery struct {
+		header http.Header
+		body   []byte
+	}
+
+	tests := []struct {
+		name     string
+		secret   string
+		allowed  []string
+		wantSent bool
+	}{
+		{"signed and delivered", "secret", []string{"127.0.0.1"}, true},
+		{"skipped without a secret", "", []string{"127.0.0.1"}, false},
+		{"skipped for a host outside the allowlist", "secret", []string{"hooks.example.com"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deliveries := make(chan delivery, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				deliveries <- delivery{header: r.Header.Clone(), body: body}
+			}))
+			defer server.Close()
+
+			s := NewCallbackService(&model.CallbackConfig{AllowedDomains: tt.allowed, Secret: tt.secret, Timeout: 5})
+			s.Notify(server.URL+"/done", &model.CallbackPayload{Event: "download.completed"})
+
+			select {
+			case d := <-deliveries:
+				if !tt.wantSent {
+					t.Fatal("callback sent, want skipped")
+				}
+				timestamp := d.header.Get(CallbackTimestampHeader)
+				if want := SignCallback(tt.secret, timestamp, d.body); d.header.Get(CallbackSignatureHeader) != want {
+					t.Errorf("signature = %s, want %s", d.header.Get(CallbackSignatureHeader), want)
+				}
+			case <-time.After(500 * time.Millisecond):
+				if tt.wantSent {
+					t.Fatal("callback not delivered")
+				}
+			}
+		})
+	}
+}
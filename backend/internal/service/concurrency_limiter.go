@@ -0,0 +1,70 @@
+package service
+
+import (
+	"sync"
+)
+
+// ConcurrencyLimiter caps the number of operations (downloads, info fetches) running at once per client key
+type ConcurrencyLimiter struct {
+	active  map[string]int
+	changed chan struct{} // Closed and replaced whenever a slot is released
+	mu      sync.Mutex
+}
+
+// NewConcurrencyLimiter creates a new concurrency limiter
+func NewConcurrencyLimiter() *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		active:  make(map[string]int),
+		changed: make(chan struct{}),
+	}
+}
+
+// TryAcquire takes a slot for clientKey if fewer than limit are in use
+// A limit of 0 or less means unlimited
+func (l *ConcurrencyLimiter) TryAcquire(clientKey string, limit int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if limit > 0 && l.active[clientKey] >= limit {
+		return false
+	}
+	l.active[clientKey]++
+	return true
+}
+
+// Acquire blocks until a slot for clientKey is free and takes it
+// Returns false without taking a slot when done or cancel is closed first
+func (l *ConcurrencyLimiter) Acquire(clientKey string, limit int, done <-chan struct{}, cancel <-chan struct{}) bool {
+	for {
+		l.mu.Lock()
+		if limit <= 0 || l.active[clientKey] < limit {
+			l.active[clientKey]++
+			l.mu.Unlock()
+			return true
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-done:
+			return false
+		case <-cancel:
+			return false
+		}
+	}
+}
+
+// Release frees a slot taken by TryAcquire or Acquire
+func (l *ConcurrencyLimiter) Release(clientKey string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.active[clientKey]--
+	if l.active[clientKey] <= 0 {
+		delete(l.active, clientKey)
+	}
+
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
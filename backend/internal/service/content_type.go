@@ -0,0 +1,138 @@
+package service
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"videodownload/internal/model"
+)
+
+// executableSignatures are magic numbers of executables that http.DetectContentType does not know
+var executableSignatures = []struct {
+	magic     []byte
+	mediaType string
+}{
+	{[]byte("\x7fELF"), "application/x-executable"},
+	{[]byte("MZ"), "application/x-msdownload"},
+	{[]byte("\xcf\xfa\xed\xfe"), "application/x-mach-binary"},
+}
+
+// detectContentType returns the media type of a downloaded body, without parameters
+// Sniffing wins; when it only yields application/octet-stream the worker's declared
+// Content-Type is used instead, as long as it is more specific. Sniffed text/plain
+// likewise gives way to a declared text type such as text/vtt
+func detectContentType(declared string, data []byte) string {
+	for _, sig := range executableSignatures {
+		if bytes.HasPrefix(data, sig.magic) {
+			return sig.mediaType
+		}
+	}
+
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if sniffed != "application/octet-stream" && sniffed != "text/plain" {
+		return sniffed
+	}
+
+	declaredType, _, err := mime.ParseMediaType(declared)
+	if err != nil || declaredType == "" {
+		return sniffed
+	}
+	if sniffed == "text/plain" && !strings.HasPrefix(declaredType, "text/") {
+		return sniffed
+	}
+	return declaredType
+}
+
+// mediaTypeAllowed reports whether mediaType matches an allowlist entry
+// Entries are exact media types or "type/*" wildcards; an empty allowlist allows everything
+func mediaTypeAllowed(mediaType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	mediaType = strings.ToLower(mediaType)
+	for _, entry := range allowed {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if prefix, ok := strings.CutSuffix(entry, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+		} else if mediaType == entry {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeContentType picks the Content-Type for serving a stored file
+// The extension is looked up in overrides, then in Go's mime table; unknown extensions
+// fall back to the type sniffed at download time (or from the file head), then octet-stream
+func ServeContentType(file *model.DownloadedFile, overrides map[string]string) string {
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if ext != "" {
+		if mediaType, ok := overrides[ext]; ok {
+			return mediaType
+		}
+		if mediaType := mime.TypeByExtension(ext); mediaType != "" {
+			return mediaType
+		}
+	}
+
+	sniffed := file.ContentType
+	if sniffed == "" {
+		sniffed = sniffFile(file.FilePath)
+	}
+	if sniffed != "" {
+		return sniffed
+	}
+	return "application/octet-stream"
+}
+
+// sniffFile detects the media type of a file from its first 512 bytes
+func sniffFile(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if n == 0 && err != nil {
+		return ""
+	}
+	return detectContentType("", head[:n])
+}
+
+// activeContentTypes can run script when rendered by a browser and are never served inline
+var activeContentTypes = []string{"text/html", "application/xhtml+xml", "image/svg+xml", "text/xml", "application/xml", "text/javascript", "application/javascript"}
+
+// ServeDisposition returns "inline" or "attachment" for a served file
+// A ?disposition= query wins over INLINE_MIME_TYPES, except that active content is always an attachment
+func ServeDisposition(contentType string, requested string, inlineTypes []string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "attachment"
+	}
+	for _, active := range activeContentTypes {
+		if mediaType == active {
+			return "attachment"
+		}
+	}
+
+	switch strings.ToLower(requested) {
+	case "inline":
+		return "inline"
+	case "attachment":
+		return "attachment"
+	}
+	if len(inlineTypes) > 0 && mediaTypeAllowed(mediaType, inlineTypes) {
+		return "inline"
+	}
+	return "attachment"
+}
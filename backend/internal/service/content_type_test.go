@@ -0,0 +1,65 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"videodownload/internal/model"
+)
+
+func TestServeContentType(t *testing.T) {
+	dir := t.TempDir()
+	mp4Path := filepath.Join(dir, "sniffable")
+	if err := os.WriteFile(mp4Path, testMedia, 0644); err != nil {
+		t.Fatal(err)
+	}
+	overrides := map[string]string{".vid": "video/x-custom", ".mp4": "video/x-override"}
+
+	tests := []struct {
+		name     string
+		file     model.DownloadedFile
+		wantType string
+	}{
+		{"custom-mapped extension", model.DownloadedFile{Filename: "clip.vid", FilePath: mp4Path}, "video/x-custom"},
+		{"custom mapping matches any case", model.DownloadedFile{Filename: "clip.VID", FilePath: mp4Path}, "video/x-custom"},
+		{"custom mapping wins over Go's table", model.DownloadedFile{Filename: "clip.mp4", FilePath: mp4Path}, "video/x-override"},
+		{"Go's table", model.DownloadedFile{Filename: "clip.mp3", FilePath: mp4Path}, "audio/mpeg"},
+		{"unknown extension uses the type sniffed at download", model.DownloadedFile{Filename: "clip.zzz", FilePath: mp4Path, ContentType: "audio/ogg"}, "audio/ogg"},
+		{"unknown extension sniffs the stored file", model.DownloadedFile{Filename: "clip.zzz", FilePath: mp4Path}, "video/mp4"},
+		{"nothing known falls back to octet-stream", model.DownloadedFile{Filename: "clip", FilePath: filepath.Join(dir, "missing")}, "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ServeContentType(&tt.file, overrides); got != tt.wantType {
+				t.Errorf("ServeContentType(%s) = %q, want %q", tt.file.Filename, got, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestServeDisposition(t *testing.T) {
+	inlineTypes := []string{"text/vtt", "image/*"}
+
+	tests := []struct {
+		contentType string
+		requested   string
+		want        string
+	}{
+		{"text/vtt; charset=utf-8", "", "inline"},
+		{"image/jpeg", "", "inline"},
+		{"video/mp4", "", "attachment"},
+		{"video/mp4", "inline", "inline"},
+		{"text/vtt", "attachment", "attachment"},
+		{"image/svg+xml", "", "attachment"},
+		{"text/html", "inline", "attachment"},
+		{"not a type", "inline", "attachment"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType+"/"+tt.requested, func(t *testing.T) {
+			if got := ServeDisposition(tt.contentType, tt.requested, inlineTypes); got != tt.want {
+				t.Errorf("ServeDisposition(%q, %q) = %q, want %q", tt.contentType, tt.requested, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"videodownload/internal/model"
+)
+
+// maxCookieJarBytes bounds the size of an uploaded cookie jar
+const maxCookieJarBytes = 1 << 20
+
+// cookieProfilePattern restricts profile names to safe file names, e.g. "default" or "youtube.com"
+var cookieProfilePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// CookieService stores per-profile cookie jars used by the worker for gated content
+// The worker picks the jar named after the video's domain, falling back to "default"
+type CookieService struct {
+	cfg *model.AdminConfig
+}
+
+// NewCookieService creates a new cookie service
+func NewCookieService(cfg *model.AdminConfig) *CookieService {
+	return &CookieService{cfg: cfg}
+}
+
+// ValidProfile reports whether name can be used as a cookie profile
+func ValidProfile(name string) bool {
+	return cookieProfilePattern.MatchString(name) && !strings.Contains(name, "..")
+}
+
+// SaveCookieJar validates a Netscape cookie jar and atomically replaces the profile's jar
+// Returns the number of cookies stored
+func (s *CookieService) SaveCookieJar(profile string, data []byte) (int, error) {
+	if !ValidProfile(profile) {
+		return 0, fmt.Errorf("invalid cookie profile name")
+	}
+	if len(data) > maxCookieJarBytes {
+		return 0, fmt.Errorf("cookie jar exceeds %d bytes", maxCookieJarBytes)
+	}
+
+	count, err := validateCookieJar(data)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(s.cfg.CookiesDir, 0700); err != nil {
+		return 0, err
+	}
+
+	// Write to a temp file first so the worker never reads a half-written jar
+	tmp, err := os.CreateTemp(s.cfg.CookiesDir, ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(s.cfg.CookiesDir, profile+".txt")); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// validateCookieJar checks the Netscape cookie jar format and counts its cookies
+// Each non-comment line must have 7 tab-separated fields:
+// domain, include subdomains, path, secure, expiry, name, value
+func validateCookieJar(data []byte) (int, error) {
+	count := 0
+	lineNumber := 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		// curl marks HttpOnly cookies with this prefix instead of a comment
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return 0, fmt.Errorf("line %d: expected 7 tab-separated fields, got %d", lineNumber, len(fields))
+		}
+		if fields[0] == "" || fields[5] == "" {
+			return 0, fmt.Errorf("line %d: missing domain or cookie name", lineNumber)
+		}
+		if !isCookieFlag(fields[1]) || !isCookieFlag(fields[3]) {
+			return 0, fmt.Errorf("line %d: flags must be TRUE or FALSE", lineNumber)
+		}
+		if _, err := strconv.ParseInt(fields[4], 10, 64); err != nil {
+			return 0, fmt.Errorf("line %d: invalid expiry", lineNumber)
+		}
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	if count == 0 {
+		return 0, fmt.Errorf("cookie jar contains no cookies")
+	}
+	return count, nil
+}
+
+// isCookieFlag reports whether a Netscape boolean field is well-formed
+func isCookieFlag(value string) bool {
+	return value == "TRUE" || value == "FALSE"
+}
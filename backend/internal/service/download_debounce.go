@@ -0,0 +1,69 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"videodownload/internal/model"
+)
+
+// downloadDebouncer coalesces identical download requests of one client made within a short window
+// A duplicate arriving while the first request runs waits for it; one arriving shortly after
+// a successful download gets the same result instead of a second file
+type downloadDebouncer struct {
+	window time.Duration
+	mu     sync.Mutex
+	calls  map[string]*debouncedCall
+}
+
+// debouncedCall is a download whose result is shared with duplicates of it
+type debouncedCall struct {
+	done chan struct{} // Closed once resp and err are set
+	resp *model.DownloadResponse
+	err  error
+}
+
+func newDownloadDebouncer(window time.Duration) *downloadDebouncer {
+	return &downloadDebouncer{
+		window: window,
+		calls:  make(map[string]*debouncedCall),
+	}
+}
+
+// join returns the call registered under key, registering a new one when there is none
+// leader is true when the caller registered the call and must run the download and finish it
+func (d *downloadDebouncer) join(key string) (call *debouncedCall, leader bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if call, ok := d.calls[key]; ok {
+		return call, false
+	}
+	call = &debouncedCall{done: make(chan struct{})}
+	d.calls[key] = call
+	return call, true
+}
+
+// finish publishes the result of a call
+// Successful results are kept for the debounce window; failures are forgotten at once so
+// a retry runs a fresh download
+func (d *downloadDebouncer) finish(key string, call *debouncedCall, resp *model.DownloadResponse, err error) {
+	call.resp = resp
+	call.err = err
+	close(call.done)
+
+	if err != nil {
+		d.forget(key, call)
+		return
+	}
+	time.AfterFunc(d.window, func() { d.forget(key, call) })
+}
+
+func (d *downloadDebouncer) forget(key string, call *debouncedCall) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.calls[key] == call {
+		delete(d.calls, key)
+	}
+}
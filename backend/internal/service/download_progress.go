@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"io"
+	"math"
+	"time"
+)
+
+// ProgressFunc receives how many bytes of a file have arrived and the file's total size
+type ProgressFunc func(received, total int64)
+
+type progressKey struct{}
+
+// withProgress attaches a progress reporter to the context of a download
+func withProgress(ctx context.Context, report ProgressFunc) context.Context {
+	if report == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressKey{}, report)
+}
+
+// progressFromContext returns the download's progress reporter, nil when nobody is listening
+func progressFromContext(ctx context.Context) ProgressFunc {
+	report, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	return report
+}
+
+// PhaseFunc receives the stage a running download moved to, e.g. model.JobTranscoding
+type PhaseFunc func(phase string)
+
+type phaseKey struct{}
+
+// withPhase attaches a phase reporter to the context of a download
+func withPhase(ctx context.Context, report PhaseFunc) context.Context {
+	if report == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, phaseKey{}, report)
+}
+
+// phaseFromContext returns the download's phase reporter, nil when nobody is listening
+func phaseFromContext(ctx context.Context) PhaseFunc {
+	report, _ := ctx.Value(phaseKey{}).(PhaseFunc)
+	return report
+}
+
+// progressReader reports the bytes read through it
+// total is 0 for bodies of unknown length, whose progress is indeterminate
+type progressReader struct {
+	r        io.Reader
+	received int64
+	total    int64
+	report   ProgressFunc
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	if n > 0 {
+		p.received += int64(n)
+		p.report(p.received, p.total)
+	}
+	return n, err
+}
+
+// speedSampleInterval is the shortest span a speed sample covers, so bursts of small reads
+// don't produce wild readings
+const speedSampleInterval = 500 * time.Millisecond
+
+// speedSmoothing is the weight of the newest sample in the moving average
+const speedSmoothing = 0.3
+
+// speedMeter derives a download's transfer rate from its byte counts
+// Samples are smoothed with an exponential moving average
+type speedMeter struct {
+	lastAt    time.Time
+	lastBytes int64
+	bps       float64 // 0 until the first sample
+}
+
+// observe records that received bytes had arrived at now
+func (m *speedMeter) observe(received int64, now time.Time) {
+	if m.lastAt.IsZero() {
+		m.lastAt, m.lastBytes = now, received
+		return
+	}
+	elapsed := now.Sub(m.lastAt)
+	if elapsed < speedSampleInterval {
+		return
+	}
+
+	sample := float64(received-m.lastBytes) / elapsed.Seconds()
+	if m.bps == 0 {
+		m.bps = sample
+	} else {
+		m.bps = speedSmoothing*sample + (1-speedSmoothing)*m.bps
+	}
+	m.lastAt, m.lastBytes = now, received
+}
+
+// speed returns the smoothed rate in bytes per second, 0 before the first sample
+func (m *speedMeter) speed() int64 {
+	return int64(m.bps)
+}
+
+// eta returns the seconds left to receive total bytes
+// False when the total is unknown or no speed has been measured yet
+func (m *speedMeter) eta(received, total int64) (int64, bool) {
+	if total <= 0 || m.bps <= 0 {
+		return 0, false
+	}
+	remaining := total - received
+	if remaining < 0 {
+		remaining = 0
+	}
+	return int64(math.Ceil(float64(remaining) / m.bps)), true
+}
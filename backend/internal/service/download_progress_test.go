@@ -0,0 +1,67 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSpeedMeterSmoothing(t *testing.T) {
+	const mb = 1 << 20
+	start := time.Unix(1700000000, 0)
+	var m speedMeter
+
+	// One second per sample: 1MB/s, 1MB/s, then a 3MB/s burst and a stall
+	samples := []struct {
+		at       time.Duration
+		received int64
+		want     float64
+	}{
+		{0, 0, 0},
+		{100 * time.Millisecond, 200 * 1024, 0}, // Shorter than a sample interval
+		{time.Second, 1 * mb, 1 * mb},
+		{2 * time.Second, 2 * mb, 1 * mb},
+		{3 * time.Second, 5 * mb, 1.6 * mb},  // 0.3*3 + 0.7*1
+		{4 * time.Second, 5 * mb, 1.12 * mb}, // 0.3*0 + 0.7*1.6
+	}
+	for _, sample := range samples {
+		m.observe(sample.received, start.Add(sample.at))
+		// The average is float arithmetic, truncated to whole bytes
+		if got := m.speed(); math.Abs(float64(got)-sample.want) > 1 {
+			t.Errorf("speed after %v = %d, want %.0f", sample.at, got, sample.want)
+		}
+	}
+}
+
+func TestSpeedMeterETA(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	var m speedMeter
+
+	if _, ok := m.eta(0, 1000); ok {
+		t.Error("ETA before any speed was measured")
+	}
+
+	m.observe(0, start)
+	m.observe(1000, start.Add(time.Second))
+	tests := []struct {
+		name     string
+		received int64
+		total    int64
+		want     int64
+		wantOK   bool
+	}{
+		{"known size", 1000, 10000, 9, true},
+		{"partial second rounds up", 1000, 1500, 1, true},
+		{"finished", 1000, 1000, 0, true},
+		{"past the expected size", 1200, 1000, 0, true},
+		{"unknown size", 1000, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := m.eta(tt.received, tt.total)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("eta(%d, %d) = %d, %v; want %d, %v", tt.received, tt.total, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
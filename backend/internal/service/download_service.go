@@ -2,128 +2,453 @@ package service
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
-	"mime"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"videodownload/internal/model"
 	"videodownload/internal/storage"
 	"videodownload/pkg/logger"
+	"videodownload/pkg/tracing"
+	"videodownload/pkg/validator"
 
 	"go.uber.org/zap"
 )
 
+// Filesystem limits for stored files
+const (
+	maxFilenameBytes = 255  // Per path component
+	maxPathBytes     = 4095 // Full path, excluding the terminating NUL
+)
+
+// ErrDownloadTimeout is returned when a download exceeds MAX_DOWNLOAD_DURATION
+var ErrDownloadTimeout = errors.New("download exceeded maximum duration")
+
+// ErrDownloadCancelled is returned when a download is cancelled before it finishes
+var ErrDownloadCancelled = errors.New("download was cancelled")
+
+// ErrWorkerBadResponse is returned when the worker answers with an empty body or an
+// HTML/text page (e.g. from a proxy or captive portal) instead of media
+var ErrWorkerBadResponse = errors.New("worker returned a non-media response")
+
+// ErrDisallowedContentType is returned when a downloaded file's media type is not in ALLOWED_DOWNLOAD_MIME_TYPES
+var ErrDisallowedContentType = errors.New("downloaded content type is not allowed")
+
+// ErrStorageFull is returned when a download would push stored files over MAX_TOTAL_STORED_MB
+var ErrStorageFull = errors.New("storage capacity exceeded")
+
+// ErrFormatUnavailable is returned when the worker reports that the requested format no longer exists
+var ErrFormatUnavailable = errors.New("requested format is no longer available")
+
+// ErrInvalidFilename is returned when a request's filename override is not a plain file name
+var ErrInvalidFilename = errors.New("filename must be a plain, non-empty file name")
+
 // DownloadService handles video downloads
 type DownloadService struct {
 	pythonWorkerURL string
 	httpClient      *http.Client
 	storageManager  *storage.Manager
+	videoService    *VideoService
+	callbacks       *CallbackService
+	retryBudget     *RetryBudget
+	concurrency     *ConcurrencyLimiter
+	active          *activeDownloads
+	stats           *LifetimeStats
+	debounce        *downloadDebouncer // nil when DUPLICATE_DEBOUNCE_SECONDS is 0
+	hashPool        *HashPool          // nil when checksums are computed while writing
+	downloadPool    *workerPool        // Bounds download calls to the worker; nil when WORKER_DOWNLOAD_CONCURRENCY is 0
+	cfg             *model.Config
 }
 
 // NewDownloadService creates a new download service
-func NewDownloadService(host string, port int, timeout int, sm *storage.Manager) *DownloadService {
+func NewDownloadService(host string, port int, timeout int, sm *storage.Manager, vs *VideoService, stats *LifetimeStats, cfg *model.Config) *DownloadService {
+	var debounce *downloadDebouncer
+	if cfg.ClientLimits.DuplicateDebounceSeconds > 0 {
+		debounce = newDownloadDebouncer(time.Duration(cfg.ClientLimits.DuplicateDebounceSeconds) * time.Second)
+	}
+
+	var hashPool *HashPool
+	if cfg.Storage.HashWorkers > 0 {
+		hashPool = NewHashPool(sm, cfg.Storage.HashWorkers)
+	}
+
 	return &DownloadService{
 		pythonWorkerURL: fmt.Sprintf("http://%s:%d", host, port),
 		httpClient: &http.Client{
 			Timeout: time.Duration(timeout) * time.Second,
 		},
 		storageManager: sm,
+		videoService:   vs,
+		callbacks:      NewCallbackService(&cfg.Callback),
+		retryBudget:    NewRetryBudget(cfg.Python.RetryBudget, time.Duration(cfg.Python.RetryBudgetWindow)*time.Second),
+		concurrency:    NewConcurrencyLimiter(),
+		active:         newActiveDownloads(),
+		stats:          stats,
+		debounce:       debounce,
+		hashPool:       hashPool,
+		downloadPool:   newWorkerPool(cfg.Python.DownloadConcurrency),
+		cfg:            cfg,
 	}
 }
 
-// Download starts downloading a video
-func (s *DownloadService) Download(req *model.DownloadRequest) (*model.DownloadResponse, error) {
-	// Validate file size before downloading
-	endpoint := s.pythonWorkerURL + "/api/download"
+// DownloadWhenFree downloads a video on behalf of clientKey once one of its download slots is free
+// Used by batches, whose items are queued behind the client's concurrency cap
+// Closing cancel drops the download while it is still queued; once started it runs to completion
+// started is called once the download leaves the queue; progress receives the bytes fetched
+// from the worker while the response size is known, and phase the start of a transcode
+func (s *DownloadService) DownloadWhenFree(req *model.DownloadRequest, clientKey string, maxConcurrent int, cancel <-chan struct{}, started func(), progress ProgressFunc, phase PhaseFunc) (*model.DownloadResponse, error) {
+	return s.downloadQueued(req, clientKey, maxConcurrent, cancel, started, progress, phase)
+}
 
-	reqBody := map[string]string{
-		"url":       req.URL,
-		"format_id": req.FormatID,
-		"quality":   req.Quality,
+// DownloadTracked is like DownloadWhenFree for a single async job, which can't be cancelled
+// while queued
+// An identical request of the same client within DUPLICATE_DEBOUNCE_SECONDS shares the first
+// one's result, marked as Duplicate, instead of downloading again
+func (s *DownloadService) DownloadTracked(req *model.DownloadRequest, clientKey string, maxConcurrent int, started func(), progress ProgressFunc, phase PhaseFunc) (*model.DownloadResponse, error) {
+	if s.debounce == nil {
+		return s.downloadQueued(req, clientKey, maxConcurrent, nil, started, progress, phase)
 	}
-	bodyBytes, _ := json.Marshal(reqBody)
 
-	httpReq, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(bodyBytes))
-	if err != nil {
-		logger.Logger.Error("Failed to create download request", zap.Error(err))
-		return nil, err
+	key := clientKey + "\n" + deterministicDownloadID(req, s.cfg.Python.StripURLTimestamps)
+	call, leader := s.debounce.join(key)
+	if leader {
+		resp, err := s.downloadQueued(req, clientKey, maxConcurrent, nil, started, progress, phase)
+		s.debounce.finish(key, call, resp, err)
+		return resp, err
+	}
+
+	<-call.done
+	// The first request failed, e.g. because it was cancelled; this one runs on its own
+	if call.err != nil {
+		return s.downloadQueued(req, clientKey, maxConcurrent, nil, started, progress, phase)
+	}
+
+	logger.Logger.Info("Duplicate download request coalesced",
+		zap.String("client", clientKey),
+		zap.String("download_id", call.resp.ID))
+	duplicate := *call.resp
+	duplicate.Duplicate = true
+	return &duplicate, nil
+}
+
+func (s *DownloadService) downloadQueued(req *model.DownloadRequest, clientKey string, maxConcurrent int, cancel <-chan struct{}, started func(), progress ProgressFunc, phase PhaseFunc) (*model.DownloadResponse, error) {
+	// Queued downloads are tracked too, so CancelAll also drops them
+	ctx, untrack := s.active.track(context.Background(), clientKey)
+	defer untrack()
+
+	if !s.concurrency.Acquire(clientKey, maxConcurrent, ctx.Done(), cancel) {
+		if req.CallbackURL != "" {
+			s.notifyCallback(req, nil, ErrDownloadCancelled)
+		}
+		return nil, ErrDownloadCancelled
+	}
+	defer s.concurrency.Release(clientKey)
+
+	if started != nil {
+		started()
+	}
+	return s.runDownload(withPhase(withProgress(ctx, progress), phase), req, clientKey)
+}
+
+// CancelAll cancels every queued or running download of clientKey and returns how many were cancelled
+// Files are only written once fully fetched, so cancelled downloads leave nothing behind on disk
+func (s *DownloadService) CancelAll(clientKey string) int {
+	cancelled := s.active.cancelAll(clientKey)
+	if cancelled > 0 {
+		logger.Logger.Info("Downloads cancelled", zap.String("client", clientKey), zap.Int("count", cancelled))
+	}
+	return cancelled
+}
+
+// runDownload downloads a video and notifies the request's callback
+// The whole operation is bounded by MAX_DOWNLOAD_DURATION when configured
+func (s *DownloadService) runDownload(ctx context.Context, req *model.DownloadRequest, clientKey string) (*model.DownloadResponse, error) {
+	if s.cfg.Python.MaxDownloadDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(s.cfg.Python.MaxDownloadDuration)*time.Second)
+		defer cancel()
 	}
 
-	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := s.download(ctx, req, clientKey)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		logger.Logger.Warn("Download exceeded maximum duration",
+			zap.String("url", req.URL),
+			zap.Int("max_duration_seconds", s.cfg.Python.MaxDownloadDuration))
+		err = ErrDownloadTimeout
+	} else if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		logger.Logger.Info("Download cancelled", zap.String("url", req.URL))
+		err = ErrDownloadCancelled
+	}
+
+	if req.CallbackURL != "" {
+		s.notifyCallback(req, resp, err)
+	}
+	return resp, err
+}
 
-	resp, err := s.httpClient.Do(httpReq)
+// notifyCallback reports the outcome of a download to the request's callback_url
+func (s *DownloadService) notifyCallback(req *model.DownloadRequest, resp *model.DownloadResponse, err error) {
+	payload := &model.CallbackPayload{
+		Event:    model.CallbackDownloadCompleted,
+		URL:      req.URL,
+		FormatID: req.FormatID,
+		Download: resp,
+	}
 	if err != nil {
-		logger.Logger.Error("Download failed", zap.Error(err), zap.String("url", req.URL))
-		return nil, fmt.Errorf("download failed: %w", err)
+		payload.Event = model.CallbackDownloadFailed
+		payload.Download = nil
+		payload.Error = downloadErrorResponse(err)
 	}
-	defer resp.Body.Close()
+	s.callbacks.Notify(req.CallbackURL, payload)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		logger.Logger.Warn("Failed download response", zap.Int("status", resp.StatusCode))
-		return nil, fmt.Errorf("download failed with status %d", resp.StatusCode)
+// deterministicDownloadID derives a download ID from everything that affects the produced file
+// The URL's scheme and host are case-normalized so trivially different spellings share an ID,
+// and start offsets are ignored when stripTimestamps is set
+func deterministicDownloadID(req *model.DownloadRequest, stripTimestamps bool) string {
+	normalizedURL := strings.TrimSpace(req.URL)
+	if stripTimestamps {
+		normalizedURL, _ = StripTimestamp(normalizedURL)
+	}
+	if u, err := url.Parse(normalizedURL); err == nil {
+		u.Scheme = strings.ToLower(u.Scheme)
+		u.Host = strings.ToLower(u.Host)
+		u.Fragment = ""
+		normalizedURL = u.String()
+	}
+
+	key := fmt.Sprintf("%s\n%s\n%s\n%t\n%t", normalizedURL, req.FormatID, req.Quality, req.EmbedMetadata, req.EmbedThumbnail)
+	if t := req.Transcode; t != nil {
+		key += fmt.Sprintf("\n%s\n%d\n%d", t.VideoCodec, t.MaxHeight, t.VideoBitrateKbps)
+	}
+	if req.Filename != "" {
+		key += "\nfilename:" + req.Filename
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
+
+// randomID returns 32 hex characters from crypto/rand, so IDs can't be guessed from one another
+// crypto/rand only fails when the OS has no entropy source, which leaves no safe fallback
+func randomID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return hex.EncodeToString(id)
+}
+
+// reuseDownload returns the response for an already stored, unexpired file with the given ID
+func (s *DownloadService) reuseDownload(downloadID string) (*model.DownloadResponse, bool) {
+	file := s.storageManager.GetFile(downloadID)
+	if file == nil || time.Now().After(file.ExpiresAt) {
+		return nil, false
+	}
+	if _, err := os.Stat(file.FilePath); err != nil {
+		return nil, false
 	}
 
-	// Try to read Python worker response (may include filename in JSON)
-	var pythonResponse *model.PythonWorkerDownloadResponse
-	var filename string
-	var fileDataBytes []byte
+	logger.Logger.Info("Reusing stored download", zap.String("download_id", downloadID))
+	return &model.DownloadResponse{
+		ID:           downloadID,
+		Title:        file.Filename,
+		DownloadLink: fmt.Sprintf("/api/download/%s", downloadID),
+		ExpiresAt:    file.ExpiresAt.Unix(),
+	}, true
+}
+
+// fetchedFile is a downloaded file spooled to a temporary file before it is stored
+type fetchedFile struct {
+	filename          string
+	contentType       string // Declared Content-Type, empty when unknown
+	metadataEmbedded  bool
+	thumbnailEmbedded bool
+	transcoded        bool
+
+	*spooledFile
+}
+
+// sniffLength is how much of a file content type detection looks at
+const sniffLength = 512
+
+// spooledFile is a body written to a temporary file in the download directory
+type spooledFile struct {
+	path     string
+	size     int64
+	head     []byte // First sniffLength bytes, for content type detection
+	checksum string // Hex SHA-256, empty when the hash pool computes it later
+}
 
-	// First, try to read full body into buffer
-	respBodyBytes, err := io.ReadAll(resp.Body)
+// spool streams r into a temporary file in the download directory
+// Reading stops once the file is past MAX_VIDEO_SIZE_MB, which the caller then rejects.
+// Without a hash pool the checksum is computed in the same pass
+func (s *DownloadService) spool(r io.Reader) (_ *spooledFile, err error) {
+	if err := s.storageManager.EnsureDownloadDir(); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(s.cfg.Storage.DownloadDir, ".partial-*")
 	if err != nil {
-		logger.Logger.Error("Failed to read response body", zap.Error(err))
 		return nil, err
 	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	head := &headBuffer{}
+	writers := []io.Writer{f, head}
+	var hasher hash.Hash
+	if s.hashPool == nil {
+		hasher = sha256.New()
+		writers = append(writers, hasher)
+	}
+
+	maxBytes := int64(s.cfg.Storage.MaxVideoSizeMB) * 1024 * 1024
+	size, err := io.Copy(io.MultiWriter(writers...), io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	spooled := &spooledFile{path: f.Name(), size: size, head: head.data}
+	if hasher != nil {
+		spooled.checksum = hex.EncodeToString(hasher.Sum(nil))
+	}
+	return spooled, nil
+}
+
+// discard removes the temporary file unless it was moved into place
+func (f *spooledFile) discard() {
+	if f.path != "" {
+		os.Remove(f.path)
+	}
+}
+
+// headBuffer keeps the first sniffLength bytes written to it
+type headBuffer struct {
+	data []byte
+}
+
+func (b *headBuffer) Write(p []byte) (int, error) {
+	if room := sniffLength - len(b.data); room > 0 {
+		b.data = append(b.data, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
 
-	// Try to parse as JSON first (Python worker may return filename in JSON)
-	if jsonErr := json.Unmarshal(respBodyBytes, &pythonResponse); jsonErr == nil && pythonResponse != nil && pythonResponse.Filename != "" {
-		// Python worker returned JSON with filename
-		filename = pythonResponse.Filename
-		logger.Logger.Debug("Filename extracted from Python worker JSON response",
-			zap.String("filename", filename))
-	} else {
-		// Fallback: extract from Content-Disposition header or use default
-		if cd := resp.Header.Get("Content-Disposition"); cd != "" {
-			filename = s.extractFilenameFromHeader(cd)
+// download fetches the file, in segments when possible, and stores it
+func (s *DownloadService) download(ctx context.Context, req *model.DownloadRequest, clientKey string) (*model.DownloadResponse, error) {
+	// Checked here too, so every entry point rejects path-like names before the worker is called
+	if req.Filename != "" {
+		if _, ok := validator.ValidateFilenameOverride(req.Filename); !ok {
+			logger.Logger.Warn("Invalid filename override", zap.String("filename", req.Filename))
+			return nil, ErrInvalidFilename
 		}
+	}
 
-		if filename == "" {
-			filename = "video_download.mp4"
-			logger.Logger.Warn("Could not extract filename, using default",
-				zap.String("content_disposition", resp.Header.Get("Content-Disposition")),
-				zap.String("default_filename", filename))
-		} else {
-			logger.Logger.Debug("Filename extracted from Content-Disposition header",
-				zap.String("filename", filename))
+	downloadID := randomID()
+	if s.cfg.Storage.DeterministicIDs {
+		downloadID = deterministicDownloadID(req, s.cfg.Python.StripURLTimestamps)
+		if resp, ok := s.reuseDownload(downloadID); ok {
+			return resp, nil
 		}
+	}
+
+	// Skip the fetch when the reported size already can't fit
+	if req.FileSize > 0 && !s.storageManager.HasRoomFor(req.FileSize) {
+		logger.Logger.Warn("Storage cap reached before download", zap.Int64("file_size", req.FileSize))
+		return nil, ErrStorageFull
+	}
 
-		// If we parsed as JSON and got this far, respBodyBytes is the JSON, not the file data
-		// This shouldn't happen in normal flow, but safeguard against it
-		if string(respBodyBytes[:min(50, len(respBodyBytes))]) == "{" {
-			logger.Logger.Error("Response appears to be JSON error, not file data")
-			return nil, fmt.Errorf("invalid response from Python worker")
+	fetched, ok := s.fetchSegmented(ctx, req)
+	var fallback *model.FormatFallback
+	if !ok {
+		var err error
+		fetched, err = s.fetchFromWorker(ctx, req, clientKey)
+		if errors.Is(err, ErrFormatUnavailable) && s.cfg.QualityCategories.FormatFallback {
+			fetched, fallback, err = s.fetchFallbackFormat(ctx, req, clientKey)
+		}
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	fileDataBytes = respBodyBytes
+	defer fetched.discard()
+	filename := fetched.filename
+
+	if fetched.size == 0 || looksLikeErrorPage(fetched.contentType, fetched.head) {
+		logger.Logger.Error("Download returned a non-media body",
+			zap.String("url", req.URL),
+			zap.String("content_type", fetched.contentType),
+			zap.Int64("size_bytes", fetched.size))
+		return nil, ErrWorkerBadResponse
+	}
+
+	contentType := detectContentType(fetched.contentType, fetched.head)
+	if !mediaTypeAllowed(contentType, s.cfg.Storage.AllowedMIMETypes) {
+		logger.Logger.Warn("Download content type not allowed",
+			zap.String("url", req.URL),
+			zap.String("content_type", contentType))
+		return nil, fmt.Errorf("%w: %s", ErrDisallowedContentType, contentType)
+	}
+
+	// A client-chosen name replaces the worker's in Content-Disposition, keeping the extension of
+	// what was actually produced. On disk the file keeps the worker's name
+	displayName := filename
+	if req.Filename != "" {
+		override, _ := validator.ValidateFilenameOverride(req.Filename)
+		ext := filepath.Ext(filename)
+		if ext == "" {
+			if formatExt := s.formatExtension(req); formatExt != "" {
+				ext = "." + formatExt
+			}
+		}
+		displayName = validator.ForceExtension(override, ext)
+	}
 
-	// Note: Filename is already truncated by Python worker, don't truncate again
-	// Double truncation can cause issues with UTF-8 characters
+	// Python worker already truncates to MAX_FILENAME_LENGTH characters; truncation here is
+	// rune-safe and also enforces the byte limits of the filesystem, leaving room for the ID prefix
+	displayName = validator.TruncateFilename(displayName, s.cfg.Storage.MaxFilenameLength)
+	displayName = validator.TruncateFilenameBytes(displayName, maxFilenameBytes)
+	filename = validator.TruncateFilename(filename, s.cfg.Storage.MaxFilenameLength)
+	filename = validator.TruncateFilenameBytes(filename, maxFilenameBytes-len(downloadID)-1)
 	logger.Logger.Info("Download from Python worker completed",
 		zap.String("filename", filename),
-		zap.Int("size_bytes", len(fileDataBytes)))
+		zap.Int64("size_bytes", fetched.size))
+
+	// Validate file size; spooling stops right past the limit
+	if !s.storageManager.ValidateFileSize(fetched.size) {
+		logger.Logger.Warn("File size exceeds limit", zap.String("filename", filename), zap.Int64("size", fetched.size))
+		return nil, fmt.Errorf("file size exceeds maximum limit of %dMB", s.cfg.Storage.MaxVideoSizeMB)
+	}
 
-	// Validate file size
-	if !s.storageManager.ValidateFileSize(int64(len(fileDataBytes))) {
-		logger.Logger.Warn("File size exceeds limit", zap.String("filename", filename), zap.Int("size", len(fileDataBytes)))
-		return nil, fmt.Errorf("file size exceeds maximum limit of %dMB", 300)
+	releaseSpace, ok := s.storageManager.ReserveSpace(fetched.size)
+	if !ok {
+		logger.Logger.Warn("Storage cap reached",
+			zap.String("filename", filename),
+			zap.Int64("size", fetched.size),
+			zap.Int("max_total_stored_mb", s.cfg.Storage.MaxTotalStoredMB))
+		return nil, ErrStorageFull
 	}
+	defer releaseSpace()
 
 	// Save file
 	if err := s.storageManager.EnsureDownloadDir(); err != nil {
@@ -131,113 +456,295 @@ func (s *DownloadService) Download(req *model.DownloadRequest) (*model.DownloadR
 		return nil, err
 	}
 
-	downloadPath := s.storageManager.GetDownloadPath(filename)
-	if err := os.WriteFile(downloadPath, fileDataBytes, 0644); err != nil {
+	// Don't start writing if the job budget is already spent
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	downloadPath, err := s.storageManager.GetDownloadPathForID(downloadID, filename)
+	if err != nil {
+		logger.Logger.Error("Failed to prepare download path", zap.Error(err))
+		return nil, err
+	}
+
+	// Keep the full path under the OS limit by shortening the filename further if needed
+	if excess := len(downloadPath) - maxPathBytes; excess > 0 {
+		if excess >= len(filename) {
+			return nil, fmt.Errorf("download directory path is too long")
+		}
+		filename = validator.TruncateFilenameBytes(filename, len(filename)-excess)
+		if downloadPath, err = s.storageManager.GetDownloadPathForID(downloadID, filename); err != nil {
+			return nil, err
+		}
+	}
+
+	// The spooled file already holds the data; with a hash pool it is hashed later, off the request path
+	_, writeSpan := tracing.Start(ctx, "storage.write")
+	writeSpan.SetAttribute("file.size", strconv.FormatInt(fetched.size, 10))
+	err = os.Chmod(fetched.path, 0644)
+	if err == nil {
+		err = os.Rename(fetched.path, downloadPath)
+	}
+	writeSpan.End(err)
+	if err != nil {
 		logger.Logger.Error("Failed to write file", zap.Error(err), zap.String("filename", filename))
 		return nil, err
 	}
+	fetched.path = ""
+	checksum := fetched.checksum
 	logger.Logger.Info("File saved to disk",
 		zap.String("path", downloadPath),
 		zap.String("filename", filename),
-		zap.Int64("size_bytes", int64(len(fileDataBytes))))
+		zap.Int64("size_bytes", fetched.size),
+		zap.String("sha256", checksum))
 
 	// Generate download response
-	downloadID := fmt.Sprintf("%d", time.Now().UnixNano())
 	file := &model.DownloadedFile{
-		Filename: filename,
-		FilePath: downloadPath,
-		Size:     int64(len(fileDataBytes)),
-		URL:      req.URL,
+		Filename:    displayName,
+		FilePath:    downloadPath,
+		Size:        fetched.size,
+		URL:         req.URL,
+		SHA256:      checksum,
+		ClientKey:   clientKey,
+		Platform:    s.videoService.GetPlatform(req.URL),
+		ContentType: contentType,
 	}
 
 	if err := s.storageManager.SaveFile(downloadID, file); err != nil {
 		return nil, err
 	}
+	if s.hashPool != nil {
+		s.hashPool.Submit(downloadID, downloadPath)
+	}
+	s.stats.AddDownload(file.Size)
 
 	expiresAt := time.Now().Add(time.Duration(s.storageManager.GetFileTTL()) * time.Second).Unix()
 
 	logger.Logger.Info("Download completed and tracked",
 		zap.String("download_id", downloadID),
-		zap.String("filename", filename),
+		zap.String("filename", displayName),
+		zap.String("platform", file.Platform),
 		zap.Int64("expires_at", expiresAt))
 
 	return &model.DownloadResponse{
 		ID:           downloadID,
-		Title:        filename,
+		Title:        displayName,
 		DownloadLink: fmt.Sprintf("/api/download/%s", downloadID),
 		ExpiresAt:    expiresAt,
+
+		MetadataEmbedded:  fetched.metadataEmbedded,
+		ThumbnailEmbedded: fetched.thumbnailEmbedded,
+		Transcoded:        fetched.transcoded,
+
+		FormatFallback: fallback,
 	}, nil
 }
 
-// extractFilenameFromHeader extracts filename from Content-Disposition header
-func (s *DownloadService) extractFilenameFromHeader(cd string) string {
-	// Use mime.ParseMediaType for proper RFC 2183 parsing
-	_, params, err := mime.ParseMediaType(cd)
+// fetchFallbackFormat retries a download whose format is gone with the best format of the
+// same quality category, taken from freshly fetched info
+// The category comes from the format's known info, or the request's quality label
+func (s *DownloadService) fetchFallbackFormat(ctx context.Context, req *model.DownloadRequest, clientKey string) (*fetchedFile, *model.FormatFallback, error) {
+	quality := req.Quality
+	if format, ok := s.videoService.GetKnownFormat(req.URL, req.FormatID); ok {
+		quality = format.Quality
+	}
+	if quality == "" {
+		return nil, nil, ErrFormatUnavailable
+	}
 
-	if err == nil {
-		// Try to get filename* (RFC 5987 - UTF-8 encoded) first
-		if fn, ok := params["filename*"]; ok && fn != "" {
-			// RFC 5987 format: UTF-8''encodedfilename
-			if strings.HasPrefix(fn, "UTF-8''") {
-				encodedFn := fn[7:] // Remove "UTF-8''" prefix
-				// Decode the RFC 5987 encoded filename
-				decodedFn, decodeErr := queryUnescape(encodedFn)
-				if decodeErr == nil && decodedFn != "" {
-					return filepath.Base(decodedFn)
-				}
-			}
-		}
+	// The known formats are what pointed at the missing one
+	s.videoService.InvalidateInfo(req.URL)
+	replacement, err := s.videoService.ResolveFormatForQuality(ctx, req.URL, quality)
+	if err != nil {
+		logger.Logger.Warn("No fallback format found",
+			zap.String("url", req.URL),
+			zap.String("quality", quality),
+			zap.Error(err))
+		return nil, nil, ErrFormatUnavailable
+	}
+	if replacement.Quality != quality || replacement.FormatID == req.FormatID {
+		logger.Logger.Warn("Fallback format rejected",
+			zap.String("url", req.URL),
+			zap.String("format_id", replacement.FormatID),
+			zap.String("quality", replacement.Quality),
+			zap.String("requested_quality", quality))
+		return nil, nil, ErrFormatUnavailable
+	}
 
-		// Fallback to filename (RFC 2183) if filename* failed or not present
-		if fn, ok := params["filename"]; ok && fn != "" {
-			return filepath.Base(fn)
+	logger.Logger.Info("Requested format unavailable, falling back",
+		zap.String("url", req.URL),
+		zap.String("requested_format_id", req.FormatID),
+		zap.String("format_id", replacement.FormatID),
+		zap.String("quality", quality))
+
+	retry := *req
+	retry.FormatID = replacement.FormatID
+	retry.Quality = quality
+	fetched, err := s.fetchFromWorker(ctx, &retry, clientKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return fetched, &model.FormatFallback{
+		RequestedFormatID: req.FormatID,
+		FormatID:          replacement.FormatID,
+		Quality:           quality,
+	}, nil
+}
+
+// fetchFromWorker has the worker download the file and streams it back in a single response
+func (s *DownloadService) fetchFromWorker(ctx context.Context, req *model.DownloadRequest, clientKey string) (_ *fetchedFile, err error) {
+	endpoint := s.pythonWorkerURL + "/api/download"
+
+	ctx, span := tracing.StartClient(ctx, "worker.download")
+	span.SetAttribute("worker.endpoint", endpoint)
+	defer func() { span.End(err) }()
+
+	workerReq := model.PythonWorkerDownloadRequest{
+		Version:        model.PythonWorkerRequestVersion,
+		URL:            req.URL,
+		FormatID:       req.FormatID,
+		Quality:        req.Quality,
+		EmbedMetadata:  req.EmbedMetadata,
+		EmbedThumbnail: req.EmbedThumbnail,
+	}
+	reportPhase := phaseFromContext(ctx)
+	if req.Transcode != nil {
+		workerReq.Transcode = &model.PythonWorkerTranscode{
+			VideoCodec:       req.Transcode.VideoCodec,
+			MaxHeight:        req.Transcode.MaxHeight,
+			VideoBitrateKbps: req.Transcode.VideoBitrateKbps,
+			MaxDuration:      s.cfg.Transcode.MaxDuration,
 		}
-	} else {
-		// Parsing failed, try legacy simple split approach
-		parts := strings.Split(cd, "filename=")
-		if len(parts) > 1 {
-			filename := strings.Trim(parts[1], "\"")
-			return filepath.Base(filename)
+		if reportPhase != nil {
+			workerReq.Transcode.TaskID = randomID()
 		}
 	}
+	bodyBytes, _ := json.Marshal(workerReq)
 
-	return ""
-}
+	// The slot is held until the file is read, since the worker is busy until then
+	release, err := s.downloadPool.acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	defer release()
+
+	stopWatching := func() {}
+	if workerReq.Transcode != nil && workerReq.Transcode.TaskID != "" {
+		stopWatching = s.watchTranscodePhase(ctx, workerReq.Transcode.TaskID, reportPhase)
+	}
+	resp, err := s.postWithRetry(ctx, clientKey, endpoint, bodyBytes)
+	// The worker answers once the transcode is done, so there is nothing left to watch
+	stopWatching()
+	if err != nil {
+		logger.Logger.Error("Download failed", zap.Error(err), zap.String("url", req.URL))
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusGone {
+		var workerErr model.ErrorResponse
+		if json.NewDecoder(resp.Body).Decode(&workerErr) == nil && workerErr.Error == "format_unavailable" {
+			logger.Logger.Warn("Requested format no longer available",
+				zap.String("url", req.URL),
+				zap.String("format_id", req.FormatID))
+			return nil, ErrFormatUnavailable
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		logger.Logger.Warn("Failed download response", zap.Int("status", resp.StatusCode))
+		return nil, fmt.Errorf("download failed with status %d", resp.StatusCode)
+	}
 
-// min returns the minimum of two integers
-func min(a, b int) int {
-	if a < b {
-		return a
+	// A JSON body is a worker message, never file data
+	if isJSONResponse(resp.Header) {
+		var workerResponse model.PythonWorkerDownloadResponse
+		_ = json.NewDecoder(resp.Body).Decode(&workerResponse)
+		logger.Logger.Error("Python worker returned a JSON message instead of file data",
+			zap.String("status", workerResponse.Status),
+			zap.String("message", workerResponse.Message))
+		return nil, fmt.Errorf("invalid response from Python worker: %s", workerResponse.Message)
+	}
+
+	// Without a Content-Length the total stays 0, reported as indeterminate progress
+	var body io.Reader = resp.Body
+	if report := progressFromContext(ctx); report != nil {
+		body = &progressReader{r: resp.Body, total: max(resp.ContentLength, 0), report: report}
+	}
+	spooled, err := s.spool(body)
+	if err != nil {
+		logger.Logger.Error("Failed to read response body", zap.Error(err))
+		return nil, err
 	}
-	return b
+
+	filename, source := resolveFilename(resp.Header.Get("Content-Disposition"), s.formatExtension(req))
+	logger.Logger.Debug("Resolved download filename", zap.String("filename", filename), zap.String("source", source))
+
+	return &fetchedFile{
+		filename:    filename,
+		contentType: resp.Header.Get("Content-Type"),
+		spooledFile: spooled,
+
+		// The worker reports which tags it actually managed to embed
+		metadataEmbedded:  resp.Header.Get("X-Metadata-Embedded") == "true",
+		thumbnailEmbedded: resp.Header.Get("X-Thumbnail-Embedded") == "true",
+		transcoded:        resp.Header.Get("X-Transcoded") == "true",
+	}, nil
 }
 
-// queryUnescape decodes URL-encoded strings (used for RFC 5987 filenames)
-func queryUnescape(s string) (string, error) {
-	// Decode %XX sequences and handle + as space for application/x-www-form-urlencoded
-	// But RFC 5987 doesn't use + for space, so we use a different approach
-	var result strings.Builder
-	for i := 0; i < len(s); i++ {
-		switch s[i] {
-		case '%':
-			if i+2 < len(s) {
-				// Parse hex value
-				hex := s[i+1 : i+3]
-				var b byte
-				_, err := fmt.Sscanf(hex, "%x", &b)
-				if err != nil {
-					return "", fmt.Errorf("invalid hex in encoded filename: %v", err)
-				}
-				result.WriteByte(b)
-				i += 2
-			} else {
-				result.WriteByte(s[i])
-			}
-		default:
-			result.WriteByte(s[i])
+// formatExtension returns the container extension of the requested format when it is known
+func (s *DownloadService) formatExtension(req *model.DownloadRequest) string {
+	ext, _ := s.videoService.GetKnownFormatExtension(req.URL, req.FormatID)
+	return ext
+}
+
+// postWithRetry POSTs a JSON body to the worker, retrying transport errors and 5xx responses
+// Retries back off exponentially and draw from the client's retry budget; once the budget
+// is exhausted the last failure is returned immediately
+func (s *DownloadService) postWithRetry(ctx context.Context, clientKey string, endpoint string, bodyBytes []byte) (*http.Response, error) {
+	backoff := time.Duration(s.cfg.Python.RetryBackoffMs) * time.Millisecond
+
+	for attempt := 0; ; attempt++ {
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(bodyBytes))
+		if err != nil {
+			logger.Logger.Error("Failed to create download request", zap.Error(err))
+			return nil, err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		tracing.Inject(ctx, httpReq.Header)
+
+		resp, err := s.httpClient.Do(httpReq)
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			return resp, nil
+		}
+
+		if attempt >= s.cfg.Python.MaxRetries || ctx.Err() != nil {
+			return resp, err
+		}
+		if !s.retryBudget.Allow(clientKey) {
+			logger.Logger.Warn("Retry budget exhausted, not retrying", zap.String("client", clientKey))
+			return resp, err
+		}
+
+		if resp != nil {
+			resp.Body.Close()
+		}
+		logger.Logger.Warn("Worker call failed, retrying",
+			zap.Int("attempt", attempt+1),
+			zap.Duration("backoff", backoff),
+			zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
 		}
+		backoff *= 2
 	}
-	return result.String(), nil
+}
+
+// RecordServed adds bytes sent to a client to the lifetime counters
+func (s *DownloadService) RecordServed(size int64) {
+	s.stats.AddServed(size)
 }
 
 // GetDownloadFile retrieves a downloaded file for streaming
@@ -250,6 +757,11 @@ func (s *DownloadService) GetDownloadFile(fileID string) (*model.DownloadedFile,
 	return file, nil
 }
 
+// GetExpiredDownload returns a recently expired download, or nil when the ID is unknown
+func (s *DownloadService) GetExpiredDownload(fileID string) *model.ExpiredFile {
+	return s.storageManager.GetExpiredFile(fileID)
+}
+
 // GetFileSize returns the size of a downloaded file
 func (s *DownloadService) GetFileSize(fileID string) (int64, error) {
 	file := s.storageManager.GetFile(fileID)
@@ -259,3 +771,24 @@ func (s *DownloadService) GetFileSize(fileID string) (int64, error) {
 
 	return file.Size, nil
 }
+
+// CheckDisk returns the free disk level of the download directory and its free percentage
+func (s *DownloadService) CheckDisk() (string, float64) {
+	return s.storageManager.CheckDisk()
+}
+
+// ListDownloads returns a client's tracked downloads created after since, oldest first
+func (s *DownloadService) ListDownloads(clientKey string, since time.Time) []*model.DownloadedFile {
+	var files []*model.DownloadedFile
+	for _, file := range s.storageManager.GetTrackedFilesInfo() {
+		if file.ClientKey == clientKey && file.CreatedAt.After(since) {
+			files = append(files, file)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].CreatedAt.Before(files[j].CreatedAt)
+	})
+
+	return files
+}
@@ -0,0 +1,438 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"unicode/utf8"
+
+	"videodownload/config"
+	"videodownload/internal/model"
+	"videodownload/internal/storage"
+)
+
+// testMedia is a minimal MP4 body, large enough to arrive in several reads
+var testMedia = append([]byte{0, 0, 0, 0x18, 'f', 't', 'y', 'p', 'm', 'p', '4', '2'}, make([]byte, 256*1024)...)
+
+// newTestDownloadService returns a DownloadService whose worker downloads are answered by handler
+func newTestDownloadService(t *testing.T, handler http.HandlerFunc) (*DownloadService, string) {
+	t.Helper()
+	worker := httptest.NewServer(handler)
+	t.Cleanup(worker.Close)
+	workerURL, _ := url.Parse(worker.URL)
+
+	dir := t.TempDir()
+	t.Setenv("PYTHON_WORKER_HOST", workerURL.Hostname())
+	t.Setenv("PYTHON_WORKER_PORT", workerURL.Port())
+	t.Setenv("DOWNLOAD_DIR", filepath.Join(dir, "downloads"))
+	t.Setenv("STORAGE_TRACKING_FILE", filepath.Join(dir, "files.json"))
+	t.Setenv("STATS_FILE", "")
+	t.Setenv("SEGMENTED_DOWNLOAD_ENABLED", "false")
+	cfg := config.Load()
+
+	videoService := NewVideoService(cfg.Python.Host, cfg.Python.Port, cfg.Python.Timeout, cfg)
+	s := NewDownloadService(cfg.Python.Host, cfg.Python.Port, cfg.Python.Timeout,
+		storage.NewManager(&cfg.Storage), videoService, NewLifetimeStats(&cfg.Stats), cfg)
+	return s, cfg.Storage.DownloadDir
+}
+
+func TestDownloadStreamsBodyWithProgress(t *testing.T) {
+	tests := []struct {
+		name          string
+		contentLength bool
+		wantTotal     int64
+	}{
+		{"known length", true, int64(len(testMedia))},
+		{"unknown length is indeterminate", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, dir := newTestDownloadService(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "video/mp4")
+				w.Header().Set("Content-Disposition", `attachment; filename="Test video.mp4"`)
+				if tt.contentLength {
+					w.Header().Set("Content-Length", strconv.Itoa(len(testMedia)))
+					w.Write(testMedia)
+					return
+				}
+				// Flushing before the end sends a chunked body without Content-Length
+				half := len(testMedia) / 2
+				w.Write(testMedia[:half])
+				w.(http.Flusher).Flush()
+				w.Write(testMedia[half:])
+			})
+
+			var received, reports int64
+			progress := func(got, total int64) {
+				reports++
+				received = got
+				if total != tt.wantTotal {
+					t.Errorf("progress total = %d, want %d", total, tt.wantTotal)
+				}
+			}
+			req := &model.DownloadRequest{URL: "https://www.youtube.com/watch?v=abc123", FormatID: "18"}
+			resp, err := s.DownloadTracked(req, "client", 0, nil, progress, nil)
+			if err != nil {
+				t.Fatalf("DownloadTracked: %v", err)
+			}
+
+			if reports == 0 || received != int64(len(testMedia)) {
+				t.Errorf("progress reported %d bytes in %d calls, want %d", received, reports, len(testMedia))
+			}
+			file, err := s.GetDownloadFile(resp.ID)
+			if err != nil {
+				t.Fatalf("GetDownloadFile: %v", err)
+			}
+			if info, err := os.Stat(file.FilePath); err != nil || info.Size() != int64(len(testMedia)) {
+				t.Errorf("stored file = %v, %v; want %d bytes", info, err, len(testMedia))
+			}
+			entries, _ := os.ReadDir(dir)
+			for _, entry := range entries {
+				if strings.HasPrefix(entry.Name(), ".partial-") {
+					t.Errorf("temporary file %s left behind", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadLongTitle(t *testing.T) {
+	title := strings.Repeat("Very long title ", 63) + "end" // 1011 characters
+	s, _ := newTestDownloadService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+title+`.mp4"`)
+		w.Write(testMedia)
+	})
+
+	req := &model.DownloadRequest{URL: "https://www.youtube.com/watch?v=abc123", FormatID: "18"}
+	resp, err := s.DownloadTracked(req, "client", 0, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("DownloadTracked: %v", err)
+	}
+
+	maxLen := s.cfg.Storage.MaxFilenameLength
+	if n := utf8.RuneCountInString(resp.Title); n > maxLen || !strings.HasSuffix(resp.Title, ".mp4") {
+		t.Errorf("title has %d characters (%q), want at most %d ending in .mp4", n, resp.Title, maxLen)
+	}
+	file, err := s.GetDownloadFile(resp.ID)
+	if err != nil {
+		t.Fatalf("GetDownloadFile: %v", err)
+	}
+	stored := filepath.Base(file.FilePath)
+	if len(stored) > 255 || !strings.HasSuffix(stored, ".mp4") {
+		t.Errorf("stored name has %d bytes (%q), want at most 255 ending in .mp4", len(stored), stored)
+	}
+	if _, err := os.Stat(file.FilePath); err != nil {
+		t.Errorf("stored file: %v", err)
+	}
+}
+
+func TestDownloadFilenameOverride(t *testing.T) {
+	s, _ := newTestDownloadService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Header().Set("Content-Disposition", `attachment; filename="Test video.mp4"`)
+		w.Write(testMedia)
+	})
+
+	tests := []struct {
+		name     string
+		filename string
+		want     string
+	}{
+		{"kept as given", "lecture-03.mp4", "lecture-03.mp4"},
+		{"extension follows the format", "lecture-03.mkv", "lecture-03.mp4"},
+		{"extension added", "lecture-04", "lecture-04.mp4"},
+		{"sanitized", `week 1: "intro".mp4`, "week 1_ _intro_.mp4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &model.DownloadRequest{URL: "https://www.youtube.com/watch?v=abc123", FormatID: "18", Filename: tt.filename}
+			resp, err := s.DownloadTracked(req, "client", 0, nil, nil, nil)
+			if err != nil {
+				t.Fatalf("DownloadTracked: %v", err)
+			}
+			if resp.Title != tt.want {
+				t.Errorf("title = %q, want %q", resp.Title, tt.want)
+			}
+			file, err := s.GetDownloadFile(resp.ID)
+			if err != nil {
+				t.Fatalf("GetDownloadFile: %v", err)
+			}
+			if file.Filename != tt.want {
+				t.Errorf("served name = %q, want %q", file.Filename, tt.want)
+			}
+			// On disk the file keeps the worker's name so overrides can't collide or escape the directory
+			if stored := filepath.Base(file.FilePath); stored != resp.ID+"_Test video.mp4" {
+				t.Errorf("stored name = %q, want the worker's name after the ID", stored)
+			}
+		})
+	}
+
+	for _, filename := range []string{"../../etc/passwd", "..", "   "} {
+		req := &model.DownloadRequest{URL: "https://www.youtube.com/watch?v=abc123", FormatID: "18", Filename: filename}
+		if _, err := s.DownloadTracked(req, "client", 0, nil, nil, nil); !errors.Is(err, ErrInvalidFilename) {
+			t.Errorf("filename %q: err = %v, want ErrInvalidFilename", filename, err)
+		}
+	}
+}
+
+func TestRetryBudgetPerClient(t *testing.T) {
+	t.Setenv("WORKER_MAX_RETRIES", "3")
+	t.Setenv("WORKER_RETRY_BACKOFF_MS", "1")
+	t.Setenv("RETRY_BUDGET_PER_CLIENT", "4")
+	t.Setenv("RETRY_BUDGET_WINDOW", "3600")
+	var calls atomic.Int32
+	s, _ := newTestDownloadService(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	tests := []struct {
+		client    string
+		wantCalls int32
+	}{
+		{"client-a", 4}, // First try plus 3 retries, 1 token left
+		{"client-a", 2}, // The last token
+		{"client-a", 1}, // Budget exhausted: fails fast
+		{"client-b", 4}, // Other clients keep their own budget
+	}
+	for i, tt := range tests {
+		calls.Store(0)
+		req := &model.DownloadRequest{URL: "https://www.youtube.com/watch?v=abc123", FormatID: "18"}
+		if _, err := s.DownloadTracked(req, tt.client, 0, nil, nil, nil); err == nil {
+			t.Fatalf("download %d succeeded against a failing worker", i+1)
+		}
+		if got := calls.Load(); got != tt.wantCalls {
+			t.Errorf("download %d of %s made %d worker calls, want %d", i+1, tt.client, got, tt.wantCalls)
+		}
+	}
+}
+
+func TestEmbedMetadataPlumbing(t *testing.T) {
+	tests := []struct {
+		name           string
+		embedMetadata  bool
+		embedThumbnail bool
+	}{
+		{"not requested", false, false},
+		{"metadata", true, false},
+		{"metadata and cover art", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got model.PythonWorkerDownloadRequest
+			s, _ := newTestDownloadService(t, func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&got)
+				w.Header().Set("Content-Type", "video/mp4")
+				if got.EmbedMetadata {
+					w.Header().Set("X-Metadata-Embedded", "true")
+				}
+				w.Write(testMedia)
+			})
+
+			req := &model.DownloadRequest{
+				URL:            "https://www.youtube.com/watch?v=abc123",
+				FormatID:       "18",
+				EmbedMetadata:  tt.embedMetadata,
+				EmbedThumbnail: tt.embedThumbnail,
+			}
+			resp, err := s.DownloadTracked(req, "client", 0, nil, nil, nil)
+			if err != nil {
+				t.Fatalf("DownloadTracked: %v", err)
+			}
+
+			if got.EmbedMetadata != tt.embedMetadata || got.EmbedThumbnail != tt.embedThumbnail {
+				t.Errorf("worker got embed_metadata = %v, embed_thumbnail = %v; want %v, %v",
+					got.EmbedMetadata, got.EmbedThumbnail, tt.embedMetadata, tt.embedThumbnail)
+			}
+			if resp.MetadataEmbedded != tt.embedMetadata {
+				t.Errorf("metadata_embedded = %v, want %v", resp.MetadataEmbedded, tt.embedMetadata)
+			}
+		})
+	}
+}
+
+func TestWorkerRequestShape(t *testing.T) {
+	bodies := map[string]map[string]any{}
+	var mu sync.Mutex
+	s, _ := newTestDownloadService(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		bodies[r.URL.Path] = body
+		mu.Unlock()
+		if r.URL.Path == "/api/info" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id": "abc123", "title": "Test video", "formats": []}`))
+			return
+		}
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Write(testMedia)
+	})
+
+	if _, err := s.videoService.GetVideoInfo(context.Background(), "https://www.youtube.com/watch?v=abc123", false); err != nil {
+		t.Fatalf("GetVideoInfo: %v", err)
+	}
+	req := &model.DownloadRequest{URL: "https://www.youtube.com/watch?v=abc123", FormatID: "18", Quality: "360p", EmbedMetadata: true}
+	if _, err := s.DownloadTracked(req, "client", 0, nil, nil, nil); err != nil {
+		t.Fatalf("DownloadTracked: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want map[string]any
+	}{
+		{"/api/info", map[string]any{
+			"version":     float64(model.PythonWorkerRequestVersion),
+			"url":         "https://www.youtube.com/watch?v=abc123",
+			"max_entries": float64(s.cfg.Python.MaxPlaylistEntries),
+		}},
+		{"/api/download", map[string]any{
+			"version":         float64(model.PythonWorkerRequestVersion),
+			"url":             "https://www.youtube.com/watch?v=abc123",
+			"format_id":       "18",
+			"quality":         "360p",
+			"embed_metadata":  true,
+			"embed_thumbnail": false,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := bodies[tt.path]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("worker got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadIDs(t *testing.T) {
+	tests := []struct {
+		name          string
+		deterministic bool
+		wantSameID    bool
+		wantCalls     int32
+	}{
+		{"random by default", false, false, 2},
+		{"deterministic reuses the file", true, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DETERMINISTIC_DOWNLOAD_IDS", strconv.FormatBool(tt.deterministic))
+			var calls atomic.Int32
+			s, _ := newTestDownloadService(t, func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.Header().Set("Content-Type", "video/mp4")
+				w.Write(testMedia)
+			})
+
+			var ids []string
+			for i := 0; i < 2; i++ {
+				req := &model.DownloadRequest{URL: "https://www.youtube.com/watch?v=abc123", FormatID: "18"}
+				resp, err := s.DownloadTracked(req, "client", 0, nil, nil, nil)
+				if err != nil {
+					t.Fatalf("DownloadTracked: %v", err)
+				}
+				if len(resp.ID) != 32 || strings.Trim(resp.ID, "0123456789abcdef") != "" {
+					t.Errorf("ID %q is not 32 hex characters", resp.ID)
+				}
+				ids = append(ids, resp.ID)
+			}
+
+			if sameID := ids[0] == ids[1]; sameID != tt.wantSameID {
+				t.Errorf("IDs of identical requests = %v, want same: %v", ids, tt.wantSameID)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("worker got %d download calls, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+
+	// A different request never shares a deterministic ID
+	a := deterministicDownloadID(&model.DownloadRequest{URL: "https://www.youtube.com/watch?v=abc123", FormatID: "18"}, false)
+	b := deterministicDownloadID(&model.DownloadRequest{URL: "https://www.youtube.com/watch?v=abc123", FormatID: "140"}, false)
+	if a == b {
+		t.Errorf("formats 18 and 140 share deterministic ID %s", a)
+	}
+}
+
+func TestDownloadRejectsErrorPages(t *testing.T) {
+	const errorPage = "<!DOCTYPE html><html><head><title>502 Bad Gateway</title></head><body>Bad Gateway</body></html>"
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"declared HTML", "text/html; charset=utf-8", errorPage},
+		{"HTML declared as video", "video/mp4", errorPage},
+		{"plain text", "text/plain", "upstream connect error"},
+		{"empty body", "video/mp4", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, dir := newTestDownloadService(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Header().Set("Content-Disposition", `attachment; filename="Test video.mp4"`)
+				w.Write([]byte(tt.body))
+			})
+
+			req := &model.DownloadRequest{URL: "https://www.youtube.com/watch?v=abc123", FormatID: "18"}
+			if resp, err := s.DownloadTracked(req, "client", 0, nil, nil, nil); !errors.Is(err, ErrWorkerBadResponse) {
+				t.Fatalf("DownloadTracked = %+v, %v; want ErrWorkerBadResponse", resp, err)
+			}
+			entries, _ := os.ReadDir(dir)
+			for _, entry := range entries {
+				t.Errorf("file %s left behind", entry.Name())
+			}
+			if n := s.storageManager.GetTrackedFilesCount(); n != 0 {
+				t.Errorf("%d files tracked, want none", n)
+			}
+		})
+	}
+}
+
+func TestDownloadAllowedMIMETypes(t *testing.T) {
+	executable := append([]byte("\x7fELF\x02\x01\x01"), make([]byte, 1024)...)
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		wantErr     error
+	}{
+		{"mp4", "video/mp4", testMedia, nil},
+		{"executable", "application/x-executable", executable, ErrDisallowedContentType},
+		{"executable declared as video", "video/mp4", executable, ErrDisallowedContentType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, dir := newTestDownloadService(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Header().Set("Content-Disposition", `attachment; filename="Test video.mp4"`)
+				w.Write(tt.body)
+			})
+
+			req := &model.DownloadRequest{URL: "https://www.youtube.com/watch?v=abc123", FormatID: "18"}
+			resp, err := s.DownloadTracked(req, "client", 0, nil, nil, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DownloadTracked = %+v, %v; want %v", resp, err, tt.wantErr)
+			}
+			if tt.wantErr == nil {
+				return
+			}
+			entries, _ := os.ReadDir(dir)
+			for _, entry := range entries {
+				t.Errorf("file %s left behind", entry.Name())
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"sync/atomic"
+
+	"videodownload/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// DownloadSwitch is the runtime kill switch for new downloads
+// Info lookups and serving already-downloaded files are not affected
+type DownloadSwitch struct {
+	disabled atomic.Bool
+}
+
+// NewDownloadSwitch creates a kill switch in the given state
+func NewDownloadSwitch(disabled bool) *DownloadSwitch {
+	ds := &DownloadSwitch{}
+	ds.disabled.Store(disabled)
+	if disabled {
+		logger.Logger.Warn("Downloads are disabled at startup")
+	}
+	return ds
+}
+
+// IsDisabled reports whether new downloads are currently refused
+func (ds *DownloadSwitch) IsDisabled() bool {
+	return ds.disabled.Load()
+}
+
+// SetDisabled flips the switch, logging when the state changes
+// source identifies who toggled it (e.g. "sighup", "admin")
+func (ds *DownloadSwitch) SetDisabled(disabled bool, source string) {
+	if ds.disabled.Swap(disabled) == disabled {
+		return
+	}
+	logger.Logger.Warn("Download kill switch toggled",
+		zap.Bool("downloads_disabled", disabled),
+		zap.String("source", source))
+}
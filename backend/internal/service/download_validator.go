@@ -0,0 +1,346 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"videodownload/internal/model"
+	"videodownload/pkg/validator"
+
+	"go.uber.org/zap"
+)
+
+// DownloadValidator runs the checks every download entry point applies before queuing a download
+// REST and gRPC share it, so a request refused on one transport is refused on the other
+type DownloadValidator struct {
+	cfg          *model.Config
+	videoService *VideoService
+}
+
+// NewDownloadValidator creates a new download validator
+func NewDownloadValidator(vs *VideoService, cfg *model.Config) *DownloadValidator {
+	return &DownloadValidator{
+		cfg:          cfg,
+		videoService: vs,
+	}
+}
+
+// Validate runs the per-item checks of a download request
+// Returns nil when the request may proceed, otherwise the error to report
+func (v *DownloadValidator) Validate(ctx context.Context, log *zap.Logger, req *model.DownloadRequest, profile *model.LimitProfile) *model.ErrorResponse {
+	// Clients may echo the display label from /api/video/info
+	req.Quality = QualityCategory(req.Quality, v.cfg.QualityCategories.Labels)
+
+	// A video token stands for the URL it was issued for; the allowlist is still checked below
+	if req.VideoToken != "" {
+		tokenURL, err := v.videoService.ResolveVideoToken(req.VideoToken)
+		if err != nil {
+			log.Warn("Invalid video token", zap.Error(err))
+			return rejectRequest(http.StatusBadRequest, "invalid_video_token", "Video token is invalid")
+		}
+		req.URL = tokenURL
+	}
+	if req.URL == "" {
+		return rejectRequest(http.StatusBadRequest, "invalid_url", "Video URL or video token is required")
+	}
+
+	// Short share links are replaced by their final URL, so the worker gets what was checked
+	resolvedURL, err := v.videoService.ResolveShortURL(ctx, req.URL)
+	if err != nil {
+		log.Warn("Failed to resolve short URL", zap.String("url", req.URL), zap.Error(err))
+		return rejectRequest(http.StatusBadRequest, "unresolvable_url", "URL redirects could not be followed")
+	}
+	req.URL = resolvedURL
+
+	// Validate URL
+	_, reason := validator.CheckURL(req.URL, v.cfg.Security.DownloadAllowedDomains, v.cfg.Security.RequireHTTPSTarget)
+	if reason == validator.URLInsecure {
+		log.Warn("Insecure target URL rejected", zap.String("url", req.URL))
+		return rejectRequest(http.StatusBadRequest, "insecure_url", "Only https URLs are allowed")
+	}
+	if reason != "" {
+		log.Warn("Invalid URL domain", zap.String("url", req.URL))
+		return rejectRequest(http.StatusBadRequest, "invalid_domain", "URL domain is not allowed")
+	}
+
+	// Callbacks may only target allowlisted hosts, and only when they can be signed
+	if req.CallbackURL != "" {
+		if v.cfg.Callback.Secret == "" {
+			log.Warn("Callback requested but CALLBACK_SECRET is not set")
+			return rejectRequest(http.StatusBadRequest, "callback_disabled", "Callbacks are not enabled on this server")
+		}
+		if !validator.ValidateCallbackURL(req.CallbackURL, v.cfg.Callback.AllowedDomains) {
+			log.Warn("Callback URL not allowed", zap.String("callback_url", req.CallbackURL))
+			return rejectRequest(http.StatusBadRequest, "invalid_callback", "Callback URL host is not allowed")
+		}
+	}
+
+	if req.Filename != "" {
+		filename, ok := validator.ValidateFilenameOverride(req.Filename)
+		if !ok {
+			log.Warn("Invalid filename override", zap.String("filename", req.Filename))
+			return rejectRequest(http.StatusBadRequest, "invalid_filename", "Filename must be a plain, non-empty file name")
+		}
+		req.Filename = filename
+	}
+
+	if req.StartAt != nil {
+		if req.StartAt.Before(time.Now()) {
+			return rejectRequest(http.StatusBadRequest, "invalid_schedule", "start_at must be in the future")
+		}
+		maxAhead := time.Duration(v.cfg.Batch.MaxScheduleAheadSeconds) * time.Second
+		if time.Until(*req.StartAt) > maxAhead {
+			return rejectRequest(http.StatusBadRequest, "invalid_schedule", fmt.Sprintf("start_at may be at most %d seconds in the future", v.cfg.Batch.MaxScheduleAheadSeconds))
+		}
+	}
+
+	if rejection := v.resolveDefaultFormat(ctx, log, req); rejection != nil {
+		return rejection
+	}
+
+	// Validate format ID
+	if !validator.ValidateFormatID(req.FormatID) {
+		log.Warn("Invalid format ID", zap.String("format_id", req.FormatID))
+		return rejectRequest(http.StatusBadRequest, "invalid_format", "Invalid format ID")
+	}
+
+	if rejection := v.validateQualityBounds(ctx, log, req); rejection != nil {
+		return rejection
+	}
+
+	if rejection := v.validateEmbedOptions(log, req); rejection != nil {
+		return rejection
+	}
+	if rejection := v.validateTranscode(log, req); rejection != nil {
+		return rejection
+	}
+
+	// Fail early on formats the worker reported as unavailable in our region
+	if v.videoService.IsKnownGeoRestricted(req.URL, req.FormatID) {
+		log.Warn("Requested format is geo-restricted", zap.String("url", req.URL), zap.String("format_id", req.FormatID))
+		return rejectRequest(http.StatusUnavailableForLegalReasons, "geo_blocked", "The selected format is not available in this region")
+	}
+
+	if format, ok := v.videoService.GetKnownFormat(req.URL, req.FormatID); ok {
+		if reason := UnavailableReason(format); reason != "" {
+			log.Warn("Requested format can't be downloaded", zap.String("format_id", req.FormatID), zap.String("reason", reason))
+			return rejectRequest(http.StatusBadRequest, reason, unavailableMessages[reason])
+		}
+	}
+
+	if rejection := v.validateTrackSelection(log, req); rejection != nil {
+		return rejection
+	}
+
+	// Cross-check the client FileSize hint against recently fetched format info
+	if req.FileSize > 0 {
+		if knownSize, ok := v.videoService.GetKnownFormatSize(req.URL, req.FormatID); ok {
+			tolerance := knownSize * int64(v.cfg.Security.FileSizeTolerancePercent) / 100
+			diff := req.FileSize - knownSize
+			if diff < 0 {
+				diff = -diff
+			}
+			if diff > tolerance {
+				log.Warn("Client file size does not match known format size",
+					zap.Int64("client_size", req.FileSize),
+					zap.Int64("known_size", knownSize),
+					zap.String("format_id", req.FormatID))
+				return rejectRequest(http.StatusBadRequest, "size_mismatch", "Reported file size does not match the selected format")
+			}
+		}
+	}
+
+	// ✅ Validate file size BEFORE starting download
+	// This prevents worker from processing oversized files
+	if req.FileSize > 0 {
+		maxSizeBytes := int64(profile.MaxVideoSizeMB) * 1024 * 1024
+		if req.FileSize > maxSizeBytes {
+			maxSizeMB := profile.MaxVideoSizeMB
+			fileSizeMB := req.FileSize / (1024 * 1024)
+			log.Warn("File size exceeds limit",
+				zap.Int64("file_size", req.FileSize),
+				zap.Int64("max_size", maxSizeBytes))
+			return rejectRequest(http.StatusRequestEntityTooLarge, "file_too_large", fmt.Sprintf("File size exceeds maximum limit of %dMB. Requested size: %dMB.", maxSizeMB, fileSizeMB))
+		}
+		log.Debug("File size validation passed",
+			zap.Int64("file_size", req.FileSize),
+			zap.Int64("max_size", maxSizeBytes))
+	}
+
+	return nil
+}
+
+// validateQualityBounds enforces MIN_QUALITY / MAX_QUALITY on the category of the requested format
+// The category comes from the video's info rather than the client's quality field, so a
+// mislabeled request can't slip past the bounds; formats of unknown category are refused
+func (v *DownloadValidator) validateQualityBounds(ctx context.Context, log *zap.Logger, req *model.DownloadRequest) *model.ErrorResponse {
+	qc := &v.cfg.QualityCategories
+	if !HasQualityBounds(qc) {
+		return nil
+	}
+
+	quality, err := v.videoService.FormatQuality(ctx, req.URL, req.FormatID)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return rejectRequest(http.StatusGatewayTimeout, "request_timeout", "Request took too long and was cancelled")
+	}
+	if err != nil {
+		log.Warn("Failed to look up format quality", zap.String("url", req.URL), zap.Error(err))
+		return rejectRequest(http.StatusBadGateway, "fetch_failed", "Failed to fetch video information")
+	}
+
+	switch CheckQualityBounds(quality, qc) {
+	case QualityTooLow:
+		log.Warn("Quality below minimum", zap.String("quality", quality), zap.String("min_quality", qc.MinQuality))
+		return rejectRequest(http.StatusBadRequest, QualityTooLow, fmt.Sprintf("Quality %s is below the minimum allowed quality %s", quality, qc.MinQuality))
+	case QualityTooHigh:
+		log.Warn("Quality above maximum", zap.String("quality", quality), zap.String("max_quality", qc.MaxQuality))
+		return rejectRequest(http.StatusBadRequest, QualityTooHigh, fmt.Sprintf("Quality %s is above the maximum allowed quality %s", quality, qc.MaxQuality))
+	case QualityUnknown:
+		log.Warn("Quality of format unknown", zap.String("format_id", req.FormatID))
+		return rejectRequest(http.StatusBadRequest, QualityUnknown, fmt.Sprintf("The quality of format %s is unknown, so it can't be checked against the allowed qualities", req.FormatID))
+	}
+	return nil
+}
+
+// resolveDefaultFormat fills in the format of a request sent without format_id
+// Uses the request's quality, or DEFAULT_QUALITY when none is given; does nothing unless DEFAULT_QUALITY is set
+func (v *DownloadValidator) resolveDefaultFormat(ctx context.Context, log *zap.Logger, req *model.DownloadRequest) *model.ErrorResponse {
+	if req.FormatID != "" || v.cfg.QualityCategories.DefaultQuality == "" {
+		return nil
+	}
+
+	quality := req.Quality
+	if quality == "" {
+		quality = v.cfg.QualityCategories.DefaultQuality
+	}
+
+	format, err := v.videoService.ResolveFormatForQuality(ctx, req.URL, quality)
+	if errors.Is(err, ErrNoFormatForQuality) {
+		return rejectRequest(http.StatusBadRequest, "quality_unavailable", fmt.Sprintf("No %s format is available for this video", quality))
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return rejectRequest(http.StatusGatewayTimeout, "request_timeout", "Request took too long and was cancelled")
+	}
+	if err != nil {
+		log.Warn("Failed to resolve default format", zap.String("url", req.URL), zap.Error(err))
+		return rejectRequest(http.StatusBadGateway, "fetch_failed", "Failed to fetch video information")
+	}
+
+	log.Info("Resolved format from quality",
+		zap.String("quality", quality),
+		zap.String("format_id", format.FormatID))
+	req.FormatID = format.FormatID
+	req.Quality = format.Quality
+	if req.FileSize == 0 {
+		req.FileSize = format.FileSize
+	}
+	return nil
+}
+
+// unavailableMessages are the messages of the UnavailableReason codes
+var unavailableMessages = map[string]string{
+	"drm_protected":        "The selected format is DRM protected",
+	"live_stream":          "Live streams can't be downloaded while they are on air",
+	"unsupported_protocol": "The selected format uses a streaming protocol that is not supported",
+}
+
+// validateTrackSelection rejects video-only or audio-only formats when configured to,
+// suggesting a format with both tracks; raw_track opts into the single track
+func (v *DownloadValidator) validateTrackSelection(log *zap.Logger, req *model.DownloadRequest) *model.ErrorResponse {
+	if req.RawTrack {
+		return nil
+	}
+
+	format, ok := v.videoService.GetKnownFormat(req.URL, req.FormatID)
+	if !ok {
+		return nil
+	}
+
+	hasVideo, hasAudio := HasVideo(format), HasAudio(format)
+	var message, wantedQuality string
+	switch {
+	case v.cfg.QualityCategories.RejectVideoOnly && hasVideo && !hasAudio:
+		message = fmt.Sprintf("Format %s has no audio track", req.FormatID)
+		wantedQuality = format.Quality
+	case v.cfg.QualityCategories.RejectAudioOnly && hasAudio && !hasVideo && QualityRank(req.Quality) > 0:
+		message = fmt.Sprintf("Format %s has no video track but %s video was requested", req.FormatID, req.Quality)
+		wantedQuality = req.Quality
+	default:
+		return nil
+	}
+
+	if suggestion := v.videoService.SuggestMuxedFormat(req.URL, wantedQuality); suggestion != "" {
+		message += fmt.Sprintf(". Choose a format with audio and video such as %s", suggestion)
+	} else {
+		message += ". Choose a format with audio and video"
+	}
+	message += ", or set raw_track to download the single track"
+
+	log.Warn("Rejected single-track format", zap.String("format_id", req.FormatID), zap.String("quality", req.Quality))
+	return rejectRequest(http.StatusBadRequest, "incomplete_format", message)
+}
+
+// validateEmbedOptions checks that embedded metadata is only requested for containers that support it
+// When the format's container is not known yet the worker performs the same check
+func (v *DownloadValidator) validateEmbedOptions(log *zap.Logger, req *model.DownloadRequest) *model.ErrorResponse {
+	if req.EmbedThumbnail && !req.EmbedMetadata {
+		return rejectRequest(http.StatusBadRequest, "metadata_unsupported", "embed_thumbnail requires embed_metadata")
+	}
+	if !req.EmbedMetadata {
+		return nil
+	}
+
+	ext, ok := v.videoService.GetKnownFormatExtension(req.URL, req.FormatID)
+	if !ok {
+		return nil
+	}
+
+	if !SupportsEmbeddedMetadata(ext) {
+		log.Warn("Metadata embedding not supported for container", zap.String("ext", ext))
+		return rejectRequest(http.StatusBadRequest, "metadata_unsupported", fmt.Sprintf("Embedding metadata is not supported for .%s files", ext))
+	}
+	if req.EmbedThumbnail && !SupportsCoverArt(ext) {
+		log.Warn("Cover art embedding not supported for container", zap.String("ext", ext))
+		return rejectRequest(http.StatusBadRequest, "metadata_unsupported", fmt.Sprintf("Embedding a thumbnail is not supported for .%s files", ext))
+	}
+	return nil
+}
+
+// validateTranscode checks a transcode spec against TRANSCODE_* limits
+// Duration and size are only checked when the video's info was fetched recently;
+// the worker enforces the duration limit again after extraction
+func (v *DownloadValidator) validateTranscode(log *zap.Logger, req *model.DownloadRequest) *model.ErrorResponse {
+	spec := req.Transcode
+	if spec == nil {
+		return nil
+	}
+	cfg := &v.cfg.Transcode
+	if !cfg.Enabled {
+		return rejectRequest(http.StatusBadRequest, "transcode_disabled", "Transcoding is not enabled on this server")
+	}
+
+	if message := TranscodeSpecRejection(req, cfg); message != "" {
+		log.Warn("Unsupported transcode spec", zap.String("video_codec", spec.VideoCodec), zap.String("reason", message))
+		return rejectRequest(http.StatusBadRequest, "transcode_unsupported", message)
+	}
+
+	if duration, ok := v.videoService.GetKnownDuration(req.URL); ok && cfg.MaxDuration > 0 && duration > cfg.MaxDuration {
+		return rejectRequest(http.StatusBadRequest, "transcode_too_long", fmt.Sprintf("Videos longer than %d seconds can't be transcoded", cfg.MaxDuration))
+	}
+	if size, ok := v.videoService.GetKnownFormatSize(req.URL, req.FormatID); ok && cfg.MaxSourceSizeMB > 0 && size > int64(cfg.MaxSourceSizeMB)*1024*1024 {
+		return rejectRequest(http.StatusBadRequest, "transcode_too_large", fmt.Sprintf("Formats larger than %dMB can't be transcoded", cfg.MaxSourceSizeMB))
+	}
+	return nil
+}
+
+// rejectRequest builds an ErrorResponse for a refused request
+func rejectRequest(status int, code string, message string) *model.ErrorResponse {
+	return &model.ErrorResponse{
+		Error:   code,
+		Message: message,
+		Code:    status,
+	}
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"videodownload/internal/model"
+
+	"go.uber.org/zap"
+)
+
+func TestValidateEmbedOptions(t *testing.T) {
+	s := newTestVideoService(t, &model.Config{}, []model.FormatOption{
+		{FormatID: "18", Extension: "mp4"},
+		{FormatID: "251", Extension: "webm"},
+		{FormatID: "17", Extension: "3gp"},
+	})
+	v := NewDownloadValidator(s, &model.Config{})
+
+	tests := []struct {
+		name           string
+		formatID       string
+		embedMetadata  bool
+		embedThumbnail bool
+		wantRejected   bool
+	}{
+		{"mp4 with cover art", "18", true, true, false},
+		{"webm metadata", "251", true, false, false},
+		{"webm cover art", "251", true, true, true},
+		{"3gp metadata", "17", true, false, true},
+		{"3gp without embedding", "17", false, false, false},
+		{"cover art without metadata", "18", false, true, true},
+		{"unknown format is left to the worker", "999", true, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &model.DownloadRequest{
+				URL:            testVideoURL,
+				FormatID:       tt.formatID,
+				EmbedMetadata:  tt.embedMetadata,
+				EmbedThumbnail: tt.embedThumbnail,
+			}
+			rejection := v.validateEmbedOptions(zap.NewNop(), req)
+			if (rejection != nil) != tt.wantRejected {
+				t.Fatalf("rejection = %+v, want rejected = %v", rejection, tt.wantRejected)
+			}
+			if rejection != nil && rejection.Error != "metadata_unsupported" {
+				t.Errorf("error = %q, want metadata_unsupported", rejection.Error)
+			}
+		})
+	}
+}
+
+func TestValidateTrackSelection(t *testing.T) {
+	formats := []model.FormatOption{
+		{FormatID: "22", VideoCodec: "avc1.64001F", AudioCodec: "mp4a.40.2", Quality: "HD"},
+		{FormatID: "18", VideoCodec: "avc1.42001E", AudioCodec: "mp4a.40.2", Quality: "SD"},
+		{FormatID: "136", VideoCodec: "avc1.4d401f", AudioCodec: "none", Quality: "HD"},
+		{FormatID: "140", VideoCodec: "none", AudioCodec: "mp4a.40.2", Quality: "Audio"},
+	}
+
+	tests := []struct {
+		name            string
+		rejectVideoOnly bool
+		rejectAudioOnly bool
+		formatID        string
+		quality         string
+		rawTrack        bool
+		wantSuggestion  string // "" = not rejected
+	}{
+		{"video-only rejected with a muxed suggestion", true, false, "136", "", false, "22"},
+		{"video-only allowed with raw_track", true, false, "136", "", true, ""},
+		{"video-only allowed when not configured", false, true, "136", "", false, ""},
+		{"audio-only rejected for a video quality", false, true, "140", "SD", false, "18"},
+		{"audio-only allowed for the audio quality", false, true, "140", "Audio", false, ""},
+		{"audio-only allowed without a quality", false, true, "140", "", false, ""},
+		{"audio-only allowed with raw_track", false, true, "140", "SD", true, ""},
+		{"audio-only allowed when not configured", true, false, "140", "SD", false, ""},
+		{"muxed format passes", true, true, "22", "HD", false, ""},
+		{"unknown format is left to the worker", true, true, "999", "HD", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &model.Config{}
+			cfg.QualityCategories.RejectVideoOnly = tt.rejectVideoOnly
+			cfg.QualityCategories.RejectAudioOnly = tt.rejectAudioOnly
+			v := NewDownloadValidator(newTestVideoService(t, cfg, formats), cfg)
+
+			req := &model.DownloadRequest{URL: testVideoURL, FormatID: tt.formatID, Quality: tt.quality, RawTrack: tt.rawTrack}
+			rejection := v.validateTrackSelection(zap.NewNop(), req)
+			if tt.wantSuggestion == "" {
+				if rejection != nil {
+					t.Fatalf("rejected: %+v", rejection)
+				}
+				return
+			}
+			if rejection == nil || rejection.Error != "incomplete_format" || rejection.Code != http.StatusBadRequest {
+				t.Fatalf("rejection = %+v, want 400 incomplete_format", rejection)
+			}
+			for _, want := range []string{"such as " + tt.wantSuggestion, "raw_track"} {
+				if !strings.Contains(rejection.Message, want) {
+					t.Errorf("message %q does not mention %q", rejection.Message, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateTranscode(t *testing.T) {
+	cfg := &model.Config{Transcode: model.TranscodeConfig{
+		Enabled:         true,
+		Codecs:          []string{"h264", "vp9"},
+		MaxHeight:       1080,
+		MaxBitrateKbps:  4000,
+		MaxDuration:     600,
+		MaxSourceSizeMB: 1,
+	}}
+	s := newTestVideoService(t, cfg, nil)
+	longVideoURL := "https://www.youtube.com/watch?v=long"
+	s.rememberInfo(testVideoURL, &model.VideoInfo{URL: testVideoURL, Duration: 60, Formats: []model.FormatOption{
+		{FormatID: "18", Extension: "mp4", FileSize: 512 * 1024},
+		{FormatID: "22", Extension: "mp4", FileSize: 2 * 1024 * 1024},
+	}})
+	s.rememberInfo(longVideoURL, &model.VideoInfo{URL: longVideoURL, Duration: 900})
+	v := NewDownloadValidator(s, cfg)
+
+	tests := []struct {
+		name           string
+		disabled       bool
+		url            string
+		formatID       string
+		spec           model.TranscodeSpec
+		embedThumbnail bool
+		wantError      string // "" = accepted
+	}{
+		{"allowed spec", false, testVideoURL, "18", model.TranscodeSpec{VideoCodec: "h264", MaxHeight: 720, VideoBitrateKbps: 2000}, false, ""},
+		{"codec is case-insensitive", false, testVideoURL, "18", model.TranscodeSpec{VideoCodec: " VP9 ", MaxHeight: 480}, false, ""},
+		{"transcoding disabled", true, testVideoURL, "18", model.TranscodeSpec{VideoCodec: "h264", MaxHeight: 720}, false, "transcode_disabled"},
+		{"codec not allowed", false, testVideoURL, "18", model.TranscodeSpec{VideoCodec: "h265", MaxHeight: 720}, false, "transcode_unsupported"},
+		{"unknown codec", false, testVideoURL, "18", model.TranscodeSpec{VideoCodec: "prores", MaxHeight: 720}, false, "transcode_unsupported"},
+		{"height missing", false, testVideoURL, "18", model.TranscodeSpec{VideoCodec: "h264"}, false, "transcode_unsupported"},
+		{"height over the limit", false, testVideoURL, "18", model.TranscodeSpec{VideoCodec: "h264", MaxHeight: 2160}, false, "transcode_unsupported"},
+		{"bitrate over the limit", false, testVideoURL, "18", model.TranscodeSpec{VideoCodec: "h264", MaxHeight: 720, VideoBitrateKbps: 9000}, false, "transcode_unsupported"},
+		{"combined with cover art", false, testVideoURL, "18", model.TranscodeSpec{VideoCodec: "h264", MaxHeight: 720}, true, "transcode_unsupported"},
+		{"video too long", false, longVideoURL, "18", model.TranscodeSpec{VideoCodec: "h264", MaxHeight: 720}, false, "transcode_too_long"},
+		{"source too large", false, testVideoURL, "22", model.TranscodeSpec{VideoCodec: "h264", MaxHeight: 720}, false, "transcode_too_large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.Transcode.Enabled = !tt.disabled
+			spec := tt.spec
+			req := &model.DownloadRequest{URL: tt.url, FormatID: tt.formatID, Transcode: &spec, EmbedMetadata: tt.embedThumbnail, EmbedThumbnail: tt.embedThumbnail}
+			rejection := v.validateTranscode(zap.NewNop(), req)
+			if tt.wantError == "" {
+				if rejection != nil {
+					t.Errorf("rejection = %+v, want accepted", rejection)
+				}
+				return
+			}
+			if rejection == nil || rejection.Error != tt.wantError || rejection.Code != http.StatusBadRequest {
+				t.Errorf("rejection = %+v, want 400 %s", rejection, tt.wantError)
+			}
+		})
+	}
+}
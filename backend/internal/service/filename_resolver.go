@@ -0,0 +1,128 @@
+package service
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// defaultDownloadBase is the base name used when the worker gives no filename
+const defaultDownloadBase = "video_download"
+
+// Filename sources reported by resolveFilename
+const (
+	filenameFromDispositionExt = "content_disposition_filename*"
+	filenameFromDisposition    = "content_disposition_filename"
+	filenameFromFormat         = "format"
+	filenameFromDefault        = "default"
+)
+
+// resolveFilename picks the name of a file streamed back by the worker
+// Precedence: Content-Disposition filename* (RFC 5987), Content-Disposition filename,
+// a name derived from the format's extension, then video_download.mp4
+// Returns the filename and the source it came from
+func resolveFilename(contentDisposition string, formatExt string) (string, string) {
+	if filename, source := filenameFromContentDisposition(contentDisposition); filename != "" {
+		return filename, source
+	}
+
+	if ext := strings.Trim(formatExt, ". "); ext != "" && !strings.ContainsAny(ext, `/\`) {
+		return defaultDownloadBase + "." + ext, filenameFromFormat
+	}
+
+	return defaultDownloadBase + ".mp4", filenameFromDefault
+}
+
+// filenameFromContentDisposition extracts a safe base name from a Content-Disposition header
+func filenameFromContentDisposition(cd string) (string, string) {
+	if cd == "" {
+		return "", ""
+	}
+
+	_, params, err := mime.ParseMediaType(cd)
+	if err != nil {
+		// Malformed header: fall back to a plain filename= value
+		parts := strings.SplitN(cd, "filename=", 2)
+		if len(parts) < 2 {
+			return "", ""
+		}
+		value := strings.TrimSpace(strings.SplitN(parts[1], ";", 2)[0])
+		if filename := baseName(strings.Trim(value, `"`)); filename != "" {
+			return filename, filenameFromDisposition
+		}
+		return "", ""
+	}
+
+	// mime.ParseMediaType decodes a valid filename* into "filename", dropping the raw key,
+	// and keeps the plain filename when filename* is missing or undecodable
+	source := filenameFromDisposition
+	if hasExtendedFilename(cd) {
+		source = filenameFromDispositionExt
+	}
+
+	filename := baseName(params["filename"])
+	if filename == "" {
+		return "", ""
+	}
+	return filename, source
+}
+
+// hasExtendedFilename reports whether a Content-Disposition header has a decodable filename*
+// Mirrors mime.ParseMediaType, which only accepts the UTF-8 and US-ASCII charsets
+func hasExtendedFilename(cd string) bool {
+	for _, param := range strings.Split(cd, ";") {
+		key, value, found := strings.Cut(param, "=")
+		if !found || !strings.EqualFold(strings.TrimSpace(key), "filename*") {
+			continue
+		}
+		parts := strings.SplitN(strings.Trim(strings.TrimSpace(value), `"`), "'", 3)
+		if len(parts) != 3 {
+			return false
+		}
+		if charset := strings.ToLower(parts[0]); charset != "utf-8" && charset != "us-ascii" {
+			return false
+		}
+		decoded, err := url.PathUnescape(parts[2])
+		return err == nil && decoded != ""
+	}
+	return false
+}
+
+// baseName strips any directory components, rejecting names that are only a path
+func baseName(name string) string {
+	name = strings.TrimSpace(name)
+	// Workers may send Windows-style paths as well
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = filepath.Base(name)
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		return ""
+	}
+	return name
+}
+
+// isJSONResponse reports whether a worker response carries a JSON body
+func isJSONResponse(header http.Header) bool {
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// looksLikeErrorPage reports whether a downloaded body is an HTML or text page rather than media
+// Sniffed HTML/XML is always rejected; plain text only when it was also declared as plain text or HTML,
+// so text outputs such as text/vtt subtitles are kept
+func looksLikeErrorPage(contentType string, data []byte) bool {
+	sniffed := http.DetectContentType(data)
+	if strings.HasPrefix(sniffed, "text/html") || strings.HasPrefix(sniffed, "text/xml") {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	declaredPage := mediaType == "text/plain" || mediaType == "text/html" || mediaType == "application/xhtml+xml"
+	return declaredPage && strings.HasPrefix(sniffed, "text/")
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"videodownload/internal/model"
+)
+
+func TestResolveFilename(t *testing.T) {
+	tests := []struct {
+		name        string
+		disposition string
+		formatExt   string
+		want        string
+		wantSource  string
+	}{
+		{"filename* wins over filename", `attachment; filename="fallback.mp4"; filename*=UTF-8''Caf%C3%A9.mp4`, "webm", "Café.mp4", filenameFromDispositionExt},
+		{"plain filename", `attachment; filename="Test video.mp4"`, "webm", "Test video.mp4", filenameFromDisposition},
+		{"undecodable filename* keeps filename", `attachment; filename="plain.mp4"; filename*=bogus`, "", "plain.mp4", filenameFromDisposition},
+		{"malformed header", `attachment; filename=broken name.mp4; =x`, "", "broken name.mp4", filenameFromDisposition},
+		{"directories are stripped", `attachment; filename="../../etc/passwd"`, "", "passwd", filenameFromDisposition},
+		{"windows directories are stripped", `attachment; filename="C:\videos\clip.mp4"`, "", "clip.mp4", filenameFromDisposition},
+		{"path-only name falls through to format", `attachment; filename=".."`, "webm", "video_download.webm", filenameFromFormat},
+		{"no filename parameter", `attachment`, ".m4a", "video_download.m4a", filenameFromFormat},
+		{"no header", "", "mkv", "video_download.mkv", filenameFromFormat},
+		{"unsafe extension is ignored", "", "../mp4", "video_download.mp4", filenameFromDefault},
+		{"nothing known", "", "", "video_download.mp4", filenameFromDefault},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, source := resolveFilename(tt.disposition, tt.formatExt)
+			if got != tt.want || source != tt.wantSource {
+				t.Errorf("resolveFilename(%q, %q) = %q from %s, want %q from %s",
+					tt.disposition, tt.formatExt, got, source, tt.want, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestIsJSONResponse(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"application/json", true},
+		{"application/json; charset=utf-8", true},
+		{"Application/JSON", true},
+		{"video/mp4", false},
+		{"application/octet-stream", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		header := http.Header{"Content-Type": {tt.contentType}}
+		if got := isJSONResponse(header); got != tt.want {
+			t.Errorf("isJSONResponse(%q) = %v, want %v", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+// A body that happens to start with "{" is file data unless the worker declared JSON
+func TestDownloadJSONBodyVsFileData(t *testing.T) {
+	body := append([]byte(`{"status":"error"}`), testMedia...)
+	tests := []struct {
+		name        string
+		contentType string
+		wantErr     bool
+	}{
+		{"declared JSON is a worker message", "application/json", true},
+		{"media starting with a brace is stored", "video/mp4", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestDownloadService(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Header().Set("Content-Disposition", `attachment; filename="Test video.mp4"`)
+				w.Write(body)
+			})
+
+			req := &model.DownloadRequest{URL: "https://www.youtube.com/watch?v=abc123", FormatID: "18"}
+			resp, err := s.DownloadTracked(req, "client", 0, nil, nil, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("JSON worker response was stored as a file")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DownloadTracked: %v", err)
+			}
+			file, err := s.GetDownloadFile(resp.ID)
+			if err != nil {
+				t.Fatalf("GetDownloadFile: %v", err)
+			}
+			if info, err := os.Stat(file.FilePath); err != nil || info.Size() != int64(len(body)) {
+				t.Errorf("stored file = %v, %v; want %d bytes", info, err, len(body))
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"videodownload/internal/model"
+	"videodownload/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// formatProbe is the media URL of one format, with the headers the worker would send
+type formatProbe struct {
+	index   int
+	url     string
+	headers map[string]string
+}
+
+// VerifyFormats probes the media URL of every format and returns a copy of formats with
+// Reachable set
+// At most VERIFY_FORMATS_WORKERS probes run at once, each bounded by VERIFY_FORMATS_TIMEOUT.
+// Formats whose URL the worker didn't report are left without a Reachable flag.
+// Returns formats unchanged when verification is disabled
+func (s *VideoService) VerifyFormats(ctx context.Context, formats []model.FormatOption, metadata *model.VideoMetadata) []model.FormatOption {
+	if s.verifyClient == nil || metadata == nil {
+		return formats
+	}
+
+	rawByID := make(map[string]map[string]interface{}, len(metadata.Formats))
+	for _, rawFmt := range metadata.Formats {
+		if formatID, ok := rawFmt["format_id"].(string); ok {
+			rawByID[formatID] = rawFmt
+		}
+	}
+
+	probes := make(chan formatProbe, len(formats))
+	for i, format := range formats {
+		rawFmt := rawByID[format.FormatID]
+		mediaURL, _ := rawFmt["url"].(string)
+		if mediaURL == "" {
+			continue
+		}
+		headers := map[string]string{}
+		if rawHeaders, ok := rawFmt["http_headers"].(map[string]interface{}); ok {
+			for key, value := range rawHeaders {
+				if v, ok := value.(string); ok {
+					headers[key] = v
+				}
+			}
+		}
+		probes <- formatProbe{index: i, url: mediaURL, headers: headers}
+	}
+	close(probes)
+
+	verified := make([]model.FormatOption, len(formats))
+	copy(verified, formats)
+
+	workers := s.cfg.Python.VerifyFormatsWorkers
+	if workers > len(probes) {
+		workers = len(probes)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for probe := range probes {
+				reachable := s.probeFormatURL(ctx, probe)
+				// Each probe writes its own index, so no lock is needed
+				verified[probe.index].Reachable = &reachable
+			}
+		}()
+	}
+	wg.Wait()
+
+	unreachable := 0
+	for _, format := range verified {
+		if format.Reachable != nil && !*format.Reachable {
+			unreachable++
+		}
+	}
+	logger.Logger.Info("Formats verified", zap.Int("formats", len(formats)), zap.Int("unreachable", unreachable))
+	return verified
+}
+
+// probeFormatURL reports whether a format's media URL answers with a non-error status
+// HEAD is tried first; servers that don't allow it are asked for the first byte instead
+func (s *VideoService) probeFormatURL(ctx context.Context, probe formatProbe) bool {
+	status, err := s.probeRequest(ctx, http.MethodHead, probe)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented) {
+		status, err = s.probeRequest(ctx, http.MethodGet, probe)
+	}
+	if err != nil {
+		logger.Logger.Debug("Format probe failed", zap.Error(err))
+		return false
+	}
+	return status < http.StatusBadRequest
+}
+
+func (s *VideoService) probeRequest(ctx context.Context, method string, probe formatProbe) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, probe.url, nil)
+	if err != nil {
+		return 0, err
+	}
+	for key, value := range probe.headers {
+		req.Header.Set(key, value)
+	}
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+
+	resp, err := s.verifyClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
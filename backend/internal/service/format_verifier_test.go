@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"videodownload/internal/model"
+)
+
+func TestVerifyFormats(t *testing.T) {
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer live.Close()
+	gone := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer gone.Close()
+	// Refuses HEAD, so the probe falls back to a one-byte GET that needs the worker's headers
+	noHead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.Header.Get("Range") != "bytes=0-0" || r.Header.Get("Referer") != "https://www.youtube.com/":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusPartialContent)
+		}
+	}))
+	defer noHead.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer slow.Close()
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed.Close()
+
+	metadata := &model.VideoMetadata{Formats: []map[string]interface{}{
+		{"format_id": "18", "url": live.URL + "/18"},
+		{"format_id": "22", "url": gone.URL + "/22"},
+		{"format_id": "137", "url": noHead.URL + "/137", "http_headers": map[string]interface{}{"Referer": "https://www.youtube.com/"}},
+		{"format_id": "140", "url": closed.URL + "/140"},
+		{"format_id": "251", "url": slow.URL + "/251"},
+		{"format_id": "999"},
+	}}
+	var formats []model.FormatOption
+	for _, rawFmt := range metadata.Formats {
+		formats = append(formats, model.FormatOption{FormatID: rawFmt["format_id"].(string)})
+	}
+
+	cfg := &model.Config{}
+	cfg.Python.VerifyFormatsWorkers = 2
+	cfg.Python.VerifyFormatsTimeout = 1
+	s := NewVideoService("127.0.0.1", 0, 1, cfg)
+	// The stubs listen on loopback, which the public client refuses
+	s.verifyClient = &http.Client{Timeout: 200 * time.Millisecond}
+
+	verified := s.VerifyFormats(context.Background(), formats, metadata)
+
+	want := map[string]*bool{"18": boolPtr(true), "22": boolPtr(false), "137": boolPtr(true), "140": boolPtr(false), "251": boolPtr(false), "999": nil}
+	for _, format := range verified {
+		got, wantReachable := format.Reachable, want[format.FormatID]
+		if (got == nil) != (wantReachable == nil) || (got != nil && *got != *wantReachable) {
+			t.Errorf("format %s reachable = %v, want %v", format.FormatID, fmtReachable(got), fmtReachable(wantReachable))
+		}
+	}
+	for _, format := range formats {
+		if format.Reachable != nil {
+			t.Errorf("input format %s was annotated, want a copy", format.FormatID)
+		}
+	}
+}
+
+func TestVerifyFormatsRefusesPrivateAddresses(t *testing.T) {
+	var hits int
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hits++ }))
+	defer local.Close()
+
+	cfg := &model.Config{}
+	cfg.Python.VerifyFormatsWorkers = 1
+	cfg.Python.VerifyFormatsTimeout = 1
+	s := NewVideoService("127.0.0.1", 0, 1, cfg)
+
+	metadata := &model.VideoMetadata{Formats: []map[string]interface{}{{"format_id": "18", "url": local.URL}}}
+	verified := s.VerifyFormats(context.Background(), []model.FormatOption{{FormatID: "18"}}, metadata)
+	if verified[0].Reachable == nil || *verified[0].Reachable {
+		t.Errorf("loopback format reachable = %v, want false", fmtReachable(verified[0].Reachable))
+	}
+	if hits != 0 {
+		t.Errorf("loopback server got %d probes, want none", hits)
+	}
+}
+
+func TestVerifyFormatsDisabled(t *testing.T) {
+	s := NewVideoService("127.0.0.1", 0, 1, &model.Config{})
+	metadata := &model.VideoMetadata{Formats: []map[string]interface{}{{"format_id": "18", "url": "https://example.com/18"}}}
+
+	verified := s.VerifyFormats(context.Background(), []model.FormatOption{{FormatID: "18"}}, metadata)
+	if verified[0].Reachable != nil {
+		t.Errorf("reachable = %v, want unset with VERIFY_FORMATS_WORKERS=0", *verified[0].Reachable)
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// fmtReachable renders a Reachable flag for test messages
+func fmtReachable(reachable *bool) string {
+	if reachable == nil {
+		return "unset"
+	}
+	if *reachable {
+		return "true"
+	}
+	return "false"
+}
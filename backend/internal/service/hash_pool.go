@@ -0,0 +1,59 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+
+	"videodownload/internal/storage"
+	"videodownload/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// HashPool computes checksums of saved files in the background
+// At most HASH_WORKERS files are hashed at a time; the rest wait their turn
+type HashPool struct {
+	storageManager *storage.Manager
+	slots          chan struct{}
+}
+
+// NewHashPool creates a pool hashing at most workers files concurrently
+func NewHashPool(sm *storage.Manager, workers int) *HashPool {
+	return &HashPool{
+		storageManager: sm,
+		slots:          make(chan struct{}, workers),
+	}
+}
+
+// Submit schedules the file of download id for hashing and returns immediately
+// The checksum is stored on the tracked file once computed
+func (p *HashPool) Submit(id string, path string) {
+	go func() {
+		p.slots <- struct{}{}
+		defer func() { <-p.slots }()
+
+		checksum, err := hashFile(path)
+		if err != nil {
+			logger.Logger.Warn("Failed to hash file", zap.String("download_id", id), zap.String("path", path), zap.Error(err))
+			return
+		}
+		p.storageManager.SetChecksum(id, checksum)
+	}()
+}
+
+// hashFile returns the hex-encoded SHA-256 of a file
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// IntervalLimiter enforces a minimum gap between consecutive actions of the same client
+type IntervalLimiter struct {
+	interval  time.Duration
+	lastStart map[string]time.Time
+	lastPrune time.Time
+	mu        sync.Mutex
+}
+
+// NewIntervalLimiter creates a limiter allowing one action per client every interval
+func NewIntervalLimiter(interval time.Duration) *IntervalLimiter {
+	return &IntervalLimiter{
+		interval:  interval,
+		lastStart: make(map[string]time.Time),
+	}
+}
+
+// Allow records an action for clientKey if the interval has passed since its previous one
+// Otherwise it returns false and how long the client has to wait
+func (l *IntervalLimiter) Allow(clientKey string) (bool, time.Duration) {
+	if l.interval <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.pruneLocked(now)
+
+	if last, exists := l.lastStart[clientKey]; exists {
+		if wait := l.interval - now.Sub(last); wait > 0 {
+			return false, wait
+		}
+	}
+	l.lastStart[clientKey] = now
+	return true, 0
+}
+
+// pruneLocked drops clients whose last action is older than the interval, at most once per interval
+func (l *IntervalLimiter) pruneLocked(now time.Time) {
+	if now.Sub(l.lastPrune) < l.interval {
+		return
+	}
+	l.lastPrune = now
+
+	for clientKey, last := range l.lastStart {
+		if now.Sub(last) >= l.interval {
+			delete(l.lastStart, clientKey)
+		}
+	}
+}
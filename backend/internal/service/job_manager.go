@@ -0,0 +1,457 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"videodownload/internal/model"
+	"videodownload/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// downloadJob tracks a single async download
+type downloadJob struct {
+	clientKey     string
+	maxConcurrent int   // Download slots of the client's profile, 0 = unlimited
+	reserved      int64 // Quota MB reserved at enqueue, given back once the real size is charged
+	state         model.DownloadJob
+	startedAt     time.Time     // When the download left the queue
+	received      int64         // Bytes fetched so far while running
+	total         int64         // Expected size, 0 when unknown
+	speed         speedMeter    // Transfer rate while running
+	cancel        chan struct{} // Closed to cancel the job while it is scheduled
+	changed       chan struct{} // Closed and replaced whenever the job's state changes
+}
+
+// recentDurationSamples is how many finished downloads the start estimate averages over
+const recentDurationSamples = 20
+
+// JobManager runs async downloads in the background and keeps their state for polling
+type JobManager struct {
+	downloadService *DownloadService
+	quotaService    *QuotaService
+	cfg             *model.Config
+	jobs            map[string]*downloadJob
+	durations       []time.Duration // Run times of the latest downloads, oldest first
+	mu              sync.RWMutex
+}
+
+// NewJobManager creates a new job manager
+func NewJobManager(ds *DownloadService, qs *QuotaService, cfg *model.Config) *JobManager {
+	return &JobManager{
+		downloadService: ds,
+		quotaService:    qs,
+		cfg:             cfg,
+		jobs:            make(map[string]*downloadJob),
+	}
+}
+
+// Start queues a download for clientKey and returns its job right away
+// A request with a future start_at is scheduled and only joins the queue at that time.
+// Returns the error to report when the client already has MAX_QUEUED_JOBS unfinished jobs,
+// as many queued or running jobs as its profile's MaxConcurrent (0 = unlimited), or the
+// download doesn't fit its quota
+func (jm *JobManager) Start(req model.DownloadRequest, clientKey string, profile *model.LimitProfile) (*model.DownloadJob, *model.ErrorResponse) {
+	job, rejection := jm.enqueue(&req, clientKey, profile, true)
+	if rejection != nil {
+		return nil, rejection
+	}
+
+	if req.StartAt != nil && req.StartAt.After(time.Now()) {
+		jm.mu.Lock()
+		job.state.Status = model.JobScheduled
+		job.state.ScheduledAt = req.StartAt
+		jm.mu.Unlock()
+		logger.Logger.Info("Async download scheduled", zap.String("job_id", job.state.JobID), zap.Time("start_at", *req.StartAt))
+	} else {
+		logger.Logger.Info("Async download queued", zap.String("job_id", job.state.JobID), zap.String("url", req.URL))
+	}
+
+	go jm.run(job, req, profile.MaxConcurrent)
+	return jm.snapshot(job), nil
+}
+
+// enqueue registers a queued job for clientKey without running it
+// Quota for the expected size, or the profile's max video size when unknown, is reserved here
+// and settled by finish. checkQueue applies MAX_QUEUED_JOBS and, unless the job is scheduled,
+// the profile's MaxConcurrent; batch items are bounded by BATCH_MAX_ITEMS instead and wait
+// for a free download slot, but still count against both
+func (jm *JobManager) enqueue(req *model.DownloadRequest, clientKey string, profile *model.LimitProfile, checkQueue bool) (*downloadJob, *model.ErrorResponse) {
+	jm.expireJobs()
+
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	if maxQueued := jm.cfg.Batch.MaxQueuedJobs; checkQueue && maxQueued > 0 && jm.unfinishedJobsLocked(clientKey) >= maxQueued {
+		logger.Logger.Warn("Queued download limit reached", zap.String("client", clientKey), zap.Int("max_queued", maxQueued))
+		return nil, &model.ErrorResponse{
+			Error:   "queue_full",
+			Message: fmt.Sprintf("Too many unfinished downloads: at most %d may be queued or running at a time", maxQueued),
+			Code:    http.StatusTooManyRequests,
+		}
+	}
+
+	// A scheduled job waits for a download slot once its start time comes
+	scheduled := req.StartAt != nil && req.StartAt.After(time.Now())
+	if maxConcurrent := profile.MaxConcurrent; checkQueue && !scheduled && maxConcurrent > 0 && jm.activeJobsLocked(clientKey) >= maxConcurrent {
+		logger.Logger.Warn("Concurrent download limit reached", zap.String("client", clientKey), zap.String("profile", profile.Name), zap.Int("max_concurrent", maxConcurrent))
+		return nil, &model.ErrorResponse{
+			Error:   "concurrency_limit",
+			Message: fmt.Sprintf("Too many concurrent downloads: the %s tier allows %d at a time", profile.Name, maxConcurrent),
+			Code:    http.StatusTooManyRequests,
+		}
+	}
+
+	var reserved int64
+	if jm.cfg.Quota.Enabled {
+		reserved = bytesToQuotaMB(req.FileSize)
+		if req.FileSize <= 0 {
+			reserved = int64(profile.MaxVideoSizeMB)
+		}
+		if !jm.quotaService.Reserve(clientKey, reserved, profile.DailyLimitMB) {
+			logger.Logger.Warn("Quota insufficient for download", zap.String("client", clientKey), zap.Int64("reserved_mb", reserved))
+			return nil, &model.ErrorResponse{
+				Error:   "quota_insufficient",
+				Message: fmt.Sprintf("Daily download quota can't cover this download (%dMB). Please try again after quota reset.", reserved),
+				Code:    http.StatusPaymentRequired,
+			}
+		}
+	}
+
+	job := &downloadJob{
+		clientKey:     clientKey,
+		maxConcurrent: profile.MaxConcurrent,
+		reserved:      reserved,
+		changed:       make(chan struct{}),
+		cancel:        make(chan struct{}),
+		state: model.DownloadJob{
+			JobID:     newJobID(),
+			Status:    model.JobQueued,
+			CreatedAt: time.Now(),
+		},
+	}
+	jm.jobs[job.state.JobID] = job
+	return job, nil
+}
+
+// newJobID returns a random, unguessable job ID
+func newJobID() string {
+	return "job-" + randomID()
+}
+
+// activeJobsLocked counts the queued and running jobs of clientKey; jm.mu must be held
+func (jm *JobManager) activeJobsLocked(clientKey string) int {
+	count := 0
+	for _, job := range jm.jobs {
+		if job.clientKey == clientKey && job.state.FinishedAt == nil && job.state.Status != model.JobScheduled {
+			count++
+		}
+	}
+	return count
+}
+
+// unfinishedJobsLocked counts the scheduled, queued and running jobs of clientKey; jm.mu must be held
+func (jm *JobManager) unfinishedJobsLocked(clientKey string) int {
+	count := 0
+	for _, job := range jm.jobs {
+		if job.clientKey == clientKey && job.state.FinishedAt == nil {
+			count++
+		}
+	}
+	return count
+}
+
+// run downloads the job's file and records the outcome
+// A scheduled job first waits for its start time; when cancelled meanwhile, the canceller records the outcome
+func (jm *JobManager) run(job *downloadJob, req model.DownloadRequest, maxConcurrent int) {
+	if req.StartAt != nil && !jm.waitForStart(job, *req.StartAt) {
+		return
+	}
+
+	resp, err := jm.downloadService.DownloadTracked(&req, job.clientKey, maxConcurrent,
+		func() { jm.markRunning(job) }, jm.progressFunc(job), jm.phaseFunc(job))
+	jm.finish(job, resp, err)
+}
+
+// waitForStart holds a scheduled job until its start time, then moves it to the queue
+// Returns false when the job was cancelled first
+func (jm *JobManager) waitForStart(job *downloadJob, startAt time.Time) bool {
+	timer := time.NewTimer(time.Until(startAt))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		jm.mu.Lock()
+		defer jm.mu.Unlock()
+
+		// A cancel may have won the race against the timer
+		if isClosed(job.cancel) {
+			return false
+		}
+		job.state.Status = model.JobQueued
+		notifyLocked(job)
+		return true
+	case <-job.cancel:
+		return false
+	}
+}
+
+// CancelJob cancels a scheduled job of clientKey and returns its state
+// found is false for unknown jobs and jobs of other clients; cancelled is false once the
+// job has left the schedule
+func (jm *JobManager) CancelJob(jobID string, clientKey string) (job *model.DownloadJob, found bool, cancelled bool) {
+	jm.mu.Lock()
+	tracked, exists := jm.jobs[jobID]
+	if !exists || tracked.clientKey != clientKey {
+		jm.mu.Unlock()
+		return nil, false, false
+	}
+	cancelled = jm.cancelScheduledLocked(tracked)
+	jm.mu.Unlock()
+
+	if cancelled {
+		jm.finish(tracked, nil, ErrDownloadCancelled)
+		logger.Logger.Info("Scheduled download cancelled", zap.String("job_id", jobID))
+	}
+	return jm.snapshot(tracked), true, cancelled
+}
+
+// CancelScheduled cancels every scheduled job of clientKey and returns how many there were
+// Queued and running jobs are left to DownloadService.CancelAll
+func (jm *JobManager) CancelScheduled(clientKey string) int {
+	jm.mu.Lock()
+	var cancelled []*downloadJob
+	for _, job := range jm.jobs {
+		if job.clientKey == clientKey && jm.cancelScheduledLocked(job) {
+			cancelled = append(cancelled, job)
+		}
+	}
+	jm.mu.Unlock()
+
+	for _, job := range cancelled {
+		jm.finish(job, nil, ErrDownloadCancelled)
+	}
+	return len(cancelled)
+}
+
+// cancelScheduledLocked stops the timer of a scheduled job, reporting whether it was scheduled
+// The caller records the outcome with finish; jm.mu must be held
+func (jm *JobManager) cancelScheduledLocked(job *downloadJob) bool {
+	if job.state.Status != model.JobScheduled || isClosed(job.cancel) {
+		return false
+	}
+	close(job.cancel)
+	return true
+}
+
+// isClosed reports whether ch has been closed
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// markRunning records that the job's download left the queue
+func (jm *JobManager) markRunning(job *downloadJob) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	job.state.Status = model.JobRunning
+	job.startedAt = time.Now()
+	notifyLocked(job)
+}
+
+// progressFunc returns a ProgressFunc recording the bytes the job's download fetched
+func (jm *JobManager) progressFunc(job *downloadJob) ProgressFunc {
+	return func(received, total int64) {
+		jm.mu.Lock()
+		defer jm.mu.Unlock()
+
+		job.received = received
+		job.total = total
+		job.speed.observe(received, time.Now())
+		notifyLocked(job)
+	}
+}
+
+// phaseFunc returns a PhaseFunc moving the running job to the phase its download reached
+func (jm *JobManager) phaseFunc(job *downloadJob) PhaseFunc {
+	return func(phase string) {
+		jm.mu.Lock()
+		defer jm.mu.Unlock()
+
+		if job.state.Status == model.JobRunning {
+			job.state.Status = phase
+			notifyLocked(job)
+		}
+	}
+}
+
+// finish settles the job's quota reservation and records the outcome of its download
+func (jm *JobManager) finish(job *downloadJob, resp *model.DownloadResponse, err error) {
+	// The reservation is replaced by the stored file's real size; a coalesced duplicate was
+	// already charged by the first request
+	jm.quotaService.Release(job.clientKey, job.reserved)
+	if err == nil && jm.cfg.Quota.Enabled && !resp.Duplicate {
+		if fileSizeBytes, sizeErr := jm.downloadService.GetFileSize(resp.ID); sizeErr == nil && fileSizeBytes > 0 {
+			jm.quotaService.AddUsageBytes(job.clientKey, fileSizeBytes)
+		}
+	}
+
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	defer notifyLocked(job)
+
+	finishedAt := time.Now()
+	job.state.FinishedAt = &finishedAt
+	if err != nil {
+		logger.Logger.Warn("Async download failed", zap.String("job_id", job.state.JobID), zap.Error(err))
+		job.state.Status = model.JobFailed
+		job.state.Error = downloadErrorResponse(err)
+		return
+	}
+	job.state.Status = model.JobDone
+	job.state.Download = resp
+	if !job.startedAt.IsZero() && !resp.Duplicate {
+		jm.recordDurationLocked(finishedAt.Sub(job.startedAt))
+	}
+}
+
+// recordDurationLocked adds a finished download's run time to the recent history; jm.mu must be held
+func (jm *JobManager) recordDurationLocked(d time.Duration) {
+	jm.durations = append(jm.durations, d)
+	if len(jm.durations) > recentDurationSamples {
+		jm.durations = jm.durations[len(jm.durations)-recentDurationSamples:]
+	}
+}
+
+// estimateStartLocked returns roughly when a queued job will get a download slot
+// Each running download of the client is assumed to take the recent average run time, and the
+// client's queued jobs take the slot freed first in creation order. ok is false without any
+// history or with unlimited slots; jm.mu must be held
+func (jm *JobManager) estimateStartLocked(job *downloadJob, now time.Time) (start time.Time, ok bool) {
+	if len(jm.durations) == 0 || job.maxConcurrent <= 0 {
+		return time.Time{}, false
+	}
+	var total time.Duration
+	for _, d := range jm.durations {
+		total += d
+	}
+	average := total / time.Duration(len(jm.durations))
+
+	var freeAt []time.Time
+	ahead := 0
+	for _, other := range jm.jobs {
+		if other.clientKey != job.clientKey || other.state.FinishedAt != nil {
+			continue
+		}
+		switch {
+		case other.state.Status == model.JobRunning || other.state.Status == model.JobTranscoding:
+			done := other.startedAt.Add(average)
+			if done.Before(now) {
+				done = now
+			}
+			freeAt = append(freeAt, done)
+		case other.state.Status == model.JobQueued && other.state.CreatedAt.Before(job.state.CreatedAt):
+			ahead++
+		}
+	}
+	for len(freeAt) < job.maxConcurrent {
+		freeAt = append(freeAt, now)
+	}
+
+	for i := 0; ; i++ {
+		sort.Slice(freeAt, func(a, b int) bool { return freeAt[a].Before(freeAt[b]) })
+		if i == ahead {
+			return freeAt[0], true
+		}
+		freeAt[0] = freeAt[0].Add(average)
+	}
+}
+
+// GetJob returns the current state of a job owned by clientKey
+func (jm *JobManager) GetJob(jobID string, clientKey string) (*model.DownloadJob, bool) {
+	jm.expireJobs()
+
+	jm.mu.RLock()
+	job, exists := jm.jobs[jobID]
+	jm.mu.RUnlock()
+
+	if !exists || job.clientKey != clientKey {
+		return nil, false
+	}
+	return jm.snapshot(job), true
+}
+
+// WatchJob is like GetJob but also returns a channel closed on the job's next state change
+func (jm *JobManager) WatchJob(jobID string, clientKey string) (*model.DownloadJob, <-chan struct{}, bool) {
+	jm.mu.RLock()
+	job, exists := jm.jobs[jobID]
+	var changed <-chan struct{}
+	if exists {
+		changed = job.changed
+	}
+	jm.mu.RUnlock()
+
+	if !exists || job.clientKey != clientKey {
+		return nil, nil, false
+	}
+	return jm.snapshot(job), changed, true
+}
+
+// snapshot copies a job's current state, deriving its percent complete or, while queued, its estimated start
+func (jm *JobManager) snapshot(job *downloadJob) *model.DownloadJob {
+	jm.mu.RLock()
+	defer jm.mu.RUnlock()
+
+	state := job.state
+	if state.Status == model.JobQueued {
+		if start, ok := jm.estimateStartLocked(job, time.Now()); ok {
+			state.EstimatedStart = &start
+		}
+	}
+	if state.Status == model.JobRunning {
+		state.Received = job.received
+		state.TotalBytes = job.total
+		state.SpeedBps = job.speed.speed()
+		if eta, ok := job.speed.eta(job.received, job.total); ok {
+			state.ETASeconds = &eta
+		}
+	}
+	if state.Status == model.JobRunning && job.total > 0 {
+		percent := float64(job.received) * 100 / float64(job.total)
+		if percent > 100 {
+			percent = 100
+		}
+		state.Progress = &percent
+	} else if state.Status == model.JobRunning {
+		state.Indeterminate = true
+	}
+	return &state
+}
+
+// notifyLocked wakes everyone watching the job; jm.mu must be held
+func notifyLocked(job *downloadJob) {
+	close(job.changed)
+	job.changed = make(chan struct{})
+}
+
+// expireJobs drops finished job records older than JOB_TTL_SECONDS
+func (jm *JobManager) expireJobs() {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	ttl := time.Duration(jm.cfg.Batch.JobTTLSeconds) * time.Second
+	for id, job := range jm.jobs {
+		if job.state.FinishedAt != nil && time.Since(*job.state.FinishedAt) > ttl {
+			delete(jm.jobs, id)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"videodownload/internal/model"
+)
+
+// newTestJobManager returns a JobManager whose worker holds every download until release is closed
+func newTestJobManager(t *testing.T, maxQueued int, dailyLimitMB int64) (*JobManager, chan struct{}) {
+	t.Helper()
+	release := make(chan struct{})
+	ds, _ := newTestDownloadService(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Header().Set("Content-Disposition", `attachment; filename="Test video.mp4"`)
+		w.Write(testMedia)
+	})
+
+	cfg := ds.cfg
+	cfg.Batch.MaxQueuedJobs = maxQueued
+	cfg.Quota.Enabled = dailyLimitMB > 0
+	cfg.Quota.DailyLimitMB = dailyLimitMB
+	qs := NewQuotaService(&cfg.Quota, nil)
+	t.Cleanup(qs.Stop)
+	return NewJobManager(ds, qs, cfg), release
+}
+
+// startJob queues a download of a distinct video so duplicate detection doesn't coalesce jobs
+func startJob(jm *JobManager, clientKey string, n int, fileSize int64, profile *model.LimitProfile) (*model.DownloadJob, *model.ErrorResponse) {
+	req := model.DownloadRequest{
+		URL:      fmt.Sprintf("https://www.youtube.com/watch?v=job%d", n),
+		FormatID: "18",
+		FileSize: fileSize,
+	}
+	return jm.Start(req, clientKey, profile)
+}
+
+// waitForJobs waits until every job has finished, so downloads don't outlive the test
+func waitForJobs(t *testing.T, jm *JobManager, clientKey string, jobIDs []string) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for _, id := range jobIDs {
+		for {
+			job, ok := jm.GetJob(id, clientKey)
+			if !ok {
+				t.Fatalf("job %s not found", id)
+			}
+			if job.FinishedAt != nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("job %s did not finish", id)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func TestJobManagerQueueLimit(t *testing.T) {
+	jm, release := newTestJobManager(t, 2, 0)
+	profile := &model.LimitProfile{}
+
+	started := map[string][]string{}
+	tests := []struct {
+		name      string
+		clientKey string
+		wantError string
+	}{
+		{"first job", "client-a", ""},
+		{"second job", "client-a", ""},
+		{"over the cap", "client-a", "queue_full"},
+		{"other client has its own cap", "client-b", ""},
+	}
+	for i, tt := range tests {
+		job, rejection := startJob(jm, tt.clientKey, i, 0, profile)
+		switch {
+		case tt.wantError == "" && rejection != nil:
+			t.Fatalf("%s: rejected with %s", tt.name, rejection.Error)
+		case tt.wantError != "" && (rejection == nil || rejection.Error != tt.wantError):
+			t.Fatalf("%s: rejection = %+v, want %s", tt.name, rejection, tt.wantError)
+		case tt.wantError == "queue_full" && rejection.Code != http.StatusTooManyRequests:
+			t.Errorf("%s: status = %d, want %d", tt.name, rejection.Code, http.StatusTooManyRequests)
+		}
+		if job != nil {
+			started[tt.clientKey] = append(started[tt.clientKey], job.JobID)
+		}
+	}
+
+	close(release)
+	for clientKey, ids := range started {
+		waitForJobs(t, jm, clientKey, ids)
+	}
+
+	// Finished jobs no longer count against the cap
+	job, rejection := startJob(jm, "client-a", len(tests), 0, profile)
+	if rejection != nil {
+		t.Fatalf("job after the queue drained rejected with %s", rejection.Error)
+	}
+	waitForJobs(t, jm, "client-a", []string{job.JobID})
+}
+
+func TestJobManagerJobIDs(t *testing.T) {
+	jm, release := newTestJobManager(t, 0, 0)
+	profile := &model.LimitProfile{}
+
+	seen := map[string]bool{}
+	var ids []string
+	for i := 0; i < 5; i++ {
+		job, rejection := startJob(jm, "client-a", i, 0, profile)
+		if rejection != nil {
+			t.Fatalf("Start rejected with %s", rejection.Error)
+		}
+		if !strings.HasPrefix(job.JobID, "job-") || len(job.JobID) != len("job-")+32 {
+			t.Errorf("job ID %q, want job- and 32 hex digits", job.JobID)
+		}
+		if seen[job.JobID] {
+			t.Errorf("job ID %s handed out twice", job.JobID)
+		}
+		seen[job.JobID] = true
+		ids = append(ids, job.JobID)
+	}
+
+	tests := []struct {
+		name      string
+		clientKey string
+		wantFound bool
+	}{
+		{"owner", "client-a", true},
+		{"other client", "client-b", false},
+		{"no client", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := jm.GetJob(ids[0], tt.clientKey); ok != tt.wantFound {
+				t.Errorf("GetJob found = %v, want %v", ok, tt.wantFound)
+			}
+		})
+	}
+	if _, ok := jm.GetJob("job-unknown", "client-a"); ok {
+		t.Error("GetJob found an unknown job")
+	}
+
+	close(release)
+	waitForJobs(t, jm, "client-a", ids)
+}
+
+func TestJobManagerQuotaReservation(t *testing.T) {
+	jm, release := newTestJobManager(t, 0, 100)
+	profile := &model.LimitProfile{DailyLimitMB: 100, MaxVideoSizeMB: 60}
+
+	var ids []string
+	tests := []struct {
+		name       string
+		fileSize   int64
+		wantError  string
+		wantUsedMB int64 // Reserved usage after the job is queued
+	}{
+		{"unknown size reserves the max video size", 0, "", 60},
+		{"second unknown size doesn't fit", 0, "quota_insufficient", 60},
+		{"known size reserves the rounded up size", 10*1024*1024 + 1, "", 71},
+		{"known size over the remaining quota", 30 * 1024 * 1024, "quota_insufficient", 71},
+	}
+	for i, tt := range tests {
+		job, rejection := startJob(jm, "client-a", i, tt.fileSize, profile)
+		switch {
+		case tt.wantError == "" && rejection != nil:
+			t.Fatalf("%s: rejected with %s", tt.name, rejection.Error)
+		case tt.wantError != "" && (rejection == nil || rejection.Error != tt.wantError):
+			t.Fatalf("%s: rejection = %+v, want %s", tt.name, rejection, tt.wantError)
+		case tt.wantError != "" && rejection.Code != http.StatusPaymentRequired:
+			t.Errorf("%s: status = %d, want %d", tt.name, rejection.Code, http.StatusPaymentRequired)
+		}
+		if job != nil {
+			ids = append(ids, job.JobID)
+		}
+		if got := usedMB(jm.quotaService, "client-a"); got != tt.wantUsedMB {
+			t.Errorf("%s: used = %dMB, want %dMB", tt.name, got, tt.wantUsedMB)
+		}
+	}
+
+	close(release)
+	waitForJobs(t, jm, "client-a", ids)
+
+	// The reservations are replaced by the stored files' real sizes
+	wantMB := int64(len(ids)) * bytesToQuotaMB(int64(len(testMedia)))
+	if got := usedMB(jm.quotaService, "client-a"); got != wantMB {
+		t.Errorf("used after the downloads = %dMB, want %dMB", got, wantMB)
+	}
+}
+
+func TestJobManagerEstimatedStart(t *testing.T) {
+	now := time.Now()
+	jm := NewJobManager(nil, nil, &model.Config{})
+	addJob := func(id, clientKey, status string, created, started time.Time) {
+		jm.jobs[id] = &downloadJob{
+			clientKey:     clientKey,
+			maxConcurrent: 2,
+			startedAt:     started,
+			changed:       make(chan struct{}),
+			state:         model.DownloadJob{JobID: id, Status: status, CreatedAt: created},
+		}
+	}
+	// Two running downloads 10s in fill client-a's slots; the average run time will be 30s
+	addJob("running-1", "client-a", model.JobRunning, now.Add(-time.Minute), now.Add(-10*time.Second))
+	addJob("running-2", "client-a", model.JobRunning, now.Add(-time.Minute), now.Add(-10*time.Second))
+	addJob("queued-1", "client-a", model.JobQueued, now.Add(-3*time.Second), time.Time{})
+	addJob("queued-2", "client-a", model.JobQueued, now.Add(-2*time.Second), time.Time{})
+	addJob("queued-3", "client-a", model.JobQueued, now.Add(-time.Second), time.Time{})
+	addJob("other-client", "client-b", model.JobQueued, now.Add(-time.Minute), time.Time{})
+
+	estimate := func(id string) *time.Time {
+		return jm.snapshot(jm.jobs[id]).EstimatedStart
+	}
+
+	t.Run("cold start", func(t *testing.T) {
+		if got := estimate("queued-1"); got != nil {
+			t.Errorf("estimated_start = %v without history, want none", got)
+		}
+	})
+
+	jm.mu.Lock()
+	for _, d := range []time.Duration{20 * time.Second, 30 * time.Second, 40 * time.Second} {
+		jm.recordDurationLocked(d)
+	}
+	jm.mu.Unlock()
+
+	tests := []struct {
+		jobID     string
+		wantAfter time.Duration // From now; negative = no estimate
+	}{
+		{"queued-1", 20 * time.Second},
+		{"queued-2", 20 * time.Second},
+		{"queued-3", 50 * time.Second},
+		{"other-client", 0},
+		{"running-1", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.jobID, func(t *testing.T) {
+			got := estimate(tt.jobID)
+			switch {
+			case tt.wantAfter < 0:
+				if got != nil {
+					t.Errorf("estimated_start = %v for a running job, want none", got)
+				}
+			case got == nil:
+				t.Fatal("estimated_start missing")
+			default:
+				if diff := got.Sub(now.Add(tt.wantAfter)); diff < 0 || diff > time.Second {
+					t.Errorf("estimated_start = now+%v, want now+%v", got.Sub(now), tt.wantAfter)
+				}
+			}
+		})
+	}
+}
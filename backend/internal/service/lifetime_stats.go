@@ -0,0 +1,147 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"videodownload/internal/model"
+	"videodownload/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// LifetimeStats counts downloaded and served bytes across restarts
+// Counters are updated atomically and persisted to STATS_FILE periodically and on Stop
+type LifetimeStats struct {
+	cfg             *model.StatsConfig
+	downloads       atomic.Int64
+	downloadedBytes atomic.Int64
+	servedBytes     atomic.Int64
+	since           time.Time
+	saveMu          sync.Mutex // Serializes writes of the stats file
+	quitChan        chan bool
+}
+
+// NewLifetimeStats creates the lifetime counters, resuming from STATS_FILE when it exists
+func NewLifetimeStats(cfg *model.StatsConfig) *LifetimeStats {
+	stats := &LifetimeStats{
+		cfg:      cfg,
+		since:    time.Now(),
+		quitChan: make(chan bool),
+	}
+	stats.load()
+
+	if cfg.File != "" && cfg.PersistInterval > 0 {
+		go stats.persistRoutine()
+	}
+
+	return stats
+}
+
+// AddDownload records a completed download of size bytes
+func (ls *LifetimeStats) AddDownload(size int64) {
+	ls.downloads.Add(1)
+	ls.downloadedBytes.Add(size)
+}
+
+// AddServed records bytes sent to a client
+func (ls *LifetimeStats) AddServed(size int64) {
+	if size > 0 {
+		ls.servedBytes.Add(size)
+	}
+}
+
+// Snapshot returns the current counters
+func (ls *LifetimeStats) Snapshot() model.LifetimeStats {
+	return model.LifetimeStats{
+		Downloads:       ls.downloads.Load(),
+		DownloadedBytes: ls.downloadedBytes.Load(),
+		ServedBytes:     ls.servedBytes.Load(),
+		Since:           ls.since,
+	}
+}
+
+// load restores the counters from the stats file
+func (ls *LifetimeStats) load() {
+	if ls.cfg.File == "" {
+		return
+	}
+
+	data, err := os.ReadFile(ls.cfg.File)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Logger.Warn("Failed to read stats file", zap.String("path", ls.cfg.File), zap.Error(err))
+		}
+		return
+	}
+
+	var saved model.LifetimeStats
+	if err := json.Unmarshal(data, &saved); err != nil {
+		logger.Logger.Warn("Ignoring malformed stats file", zap.String("path", ls.cfg.File), zap.Error(err))
+		return
+	}
+
+	ls.downloads.Store(saved.Downloads)
+	ls.downloadedBytes.Store(saved.DownloadedBytes)
+	ls.servedBytes.Store(saved.ServedBytes)
+	if !saved.Since.IsZero() {
+		ls.since = saved.Since
+	}
+	logger.Logger.Info("Lifetime stats restored",
+		zap.Int64("downloads", saved.Downloads),
+		zap.Int64("downloaded_bytes", saved.DownloadedBytes),
+		zap.Int64("served_bytes", saved.ServedBytes))
+}
+
+// save writes the counters to the stats file, replacing it atomically
+func (ls *LifetimeStats) save() {
+	if ls.cfg.File == "" {
+		return
+	}
+
+	ls.saveMu.Lock()
+	defer ls.saveMu.Unlock()
+
+	data, _ := json.Marshal(ls.Snapshot())
+	if err := os.MkdirAll(filepath.Dir(ls.cfg.File), 0755); err != nil {
+		logger.Logger.Error("Failed to create stats directory", zap.String("path", ls.cfg.File), zap.Error(err))
+		return
+	}
+
+	tmpPath := ls.cfg.File + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		logger.Logger.Error("Failed to write stats file", zap.String("path", tmpPath), zap.Error(err))
+		return
+	}
+	if err := os.Rename(tmpPath, ls.cfg.File); err != nil {
+		os.Remove(tmpPath)
+		logger.Logger.Error("Failed to replace stats file", zap.String("path", ls.cfg.File), zap.Error(err))
+	}
+}
+
+// persistRoutine periodically saves the counters
+func (ls *LifetimeStats) persistRoutine() {
+	ticker := time.NewTicker(time.Duration(ls.cfg.PersistInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ls.quitChan:
+			return
+		case <-ticker.C:
+			ls.save()
+		}
+	}
+}
+
+// Stop stops the persist routine and saves the final counters
+func (ls *LifetimeStats) Stop() {
+	if ls.cfg.File != "" && ls.cfg.PersistInterval > 0 {
+		ls.quitChan <- true
+	}
+	ls.save()
+}
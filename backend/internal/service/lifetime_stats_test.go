@@ -0,0 +1,61 @@
+package service
+
+import (
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"videodownload/internal/model"
+)
+
+func TestLifetimeStatsPersistAcrossRestart(t *testing.T) {
+	cfg := &model.StatsConfig{File: filepath.Join(t.TempDir(), "stats", "stats.json")}
+	stats := NewLifetimeStats(cfg)
+
+	// Concurrent updates must not lose increments
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stats.AddDownload(100)
+			stats.AddServed(10)
+			stats.AddServed(0)
+		}()
+	}
+	wg.Wait()
+	before := stats.Snapshot()
+	want := model.LifetimeStats{Downloads: 50, DownloadedBytes: 5000, ServedBytes: 500, Since: before.Since}
+	if before != want {
+		t.Fatalf("counters = %+v, want %+v", before, want)
+	}
+	stats.Stop()
+
+	restarted := NewLifetimeStats(cfg)
+	after := restarted.Snapshot()
+	if after.Downloads != before.Downloads || after.DownloadedBytes != before.DownloadedBytes ||
+		after.ServedBytes != before.ServedBytes || !after.Since.Equal(before.Since) {
+		t.Errorf("after restart counters = %+v, want %+v", after, before)
+	}
+
+	restarted.AddDownload(1)
+	if got := restarted.Snapshot().Downloads; got != 51 {
+		t.Errorf("downloads after restart and one more = %d, want 51", got)
+	}
+}
+
+func TestDownloadCountsLifetimeBytes(t *testing.T) {
+	s, _ := newTestDownloadService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Write(testMedia)
+	})
+
+	req := &model.DownloadRequest{URL: "https://www.youtube.com/watch?v=abc123", FormatID: "18"}
+	if _, err := s.DownloadTracked(req, "client", 0, nil, nil, nil); err != nil {
+		t.Fatalf("DownloadTracked: %v", err)
+	}
+	if got := s.stats.Snapshot(); got.Downloads != 1 || got.DownloadedBytes != int64(len(testMedia)) {
+		t.Errorf("lifetime counters = %+v, want 1 download of %d bytes", got, len(testMedia))
+	}
+}
@@ -0,0 +1,16 @@
+package service
+
+import (
+	"os"
+	"testing"
+
+	"videodownload/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// TestMain silences logging once, since background goroutines of earlier tests may still log
+func TestMain(m *testing.M) {
+	logger.Logger = zap.NewNop()
+	os.Exit(m.Run())
+}
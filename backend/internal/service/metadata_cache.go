@@ -0,0 +1,91 @@
+package service
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"videodownload/internal/model"
+)
+
+// metadataCache keeps recently fetched worker metadata by normalized URL
+// Entries expire after the TTL; past maxEntries the least recently used entry is evicted
+type metadataCache struct {
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List // Front is the most recently used
+	mu         sync.Mutex
+}
+
+// cachedMetadata is one entry of the metadata cache
+type cachedMetadata struct {
+	key       string
+	metadata  *model.VideoMetadata
+	expiresAt time.Time
+}
+
+func newMetadataCache(ttl time.Duration, maxEntries int) *metadataCache {
+	return &metadataCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// get returns the unexpired metadata cached under key
+func (mc *metadataCache) get(key string) (*model.VideoMetadata, bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	element, ok := mc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*cachedMetadata)
+	if time.Now().After(entry.expiresAt) {
+		mc.removeLocked(element)
+		return nil, false
+	}
+	mc.lru.MoveToFront(element)
+	return entry.metadata, true
+}
+
+// put caches metadata under key, evicting the least recently used entry when full
+func (mc *metadataCache) put(key string, metadata *model.VideoMetadata) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	expiresAt := time.Now().Add(mc.ttl)
+	if element, ok := mc.entries[key]; ok {
+		entry := element.Value.(*cachedMetadata)
+		entry.metadata = metadata
+		entry.expiresAt = expiresAt
+		mc.lru.MoveToFront(element)
+		return
+	}
+
+	mc.entries[key] = mc.lru.PushFront(&cachedMetadata{key: key, metadata: metadata, expiresAt: expiresAt})
+	for mc.maxEntries > 0 && mc.lru.Len() > mc.maxEntries {
+		mc.removeLocked(mc.lru.Back())
+	}
+}
+
+// invalidate drops the entry cached under key
+func (mc *metadataCache) invalidate(key string) bool {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	element, ok := mc.entries[key]
+	if ok {
+		mc.removeLocked(element)
+	}
+	return ok
+}
+
+// removeLocked drops an entry; mc.mu must be held
+func (mc *metadataCache) removeLocked(element *list.Element) {
+	mc.lru.Remove(element)
+	delete(mc.entries, element.Value.(*cachedMetadata).key)
+}
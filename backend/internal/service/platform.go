@@ -0,0 +1,69 @@
+package service
+
+import (
+	"net/url"
+	"strings"
+)
+
+// platformPrefixes map lowercased yt-dlp extractor key prefixes to platforms
+// e.g. "YoutubeTab" and "YoutubeShorts" both belong to youtube
+var platformPrefixes = []struct {
+	prefix   string
+	platform string
+}{
+	{"youtube", "youtube"},
+	{"tiktok", "tiktok"},
+	{"vimeo", "vimeo"},
+	{"facebook", "facebook"},
+	{"instagram", "instagram"},
+	{"twitter", "twitter"},
+}
+
+// platformDomains map hosts whose name differs from the platform
+var platformDomains = map[string]string{
+	"youtu.be": "youtube",
+	"fb.watch": "facebook",
+	"x.com":    "twitter",
+}
+
+// DetectPlatform returns the normalized platform of a video
+// The worker's extractor key wins; otherwise the platform is derived from the URL's domain
+func DetectPlatform(extractorKey string, videoURL string) string {
+	if platform := platformFromExtractor(extractorKey); platform != "" {
+		return platform
+	}
+	return platformFromURL(videoURL)
+}
+
+// platformFromExtractor maps a yt-dlp extractor key to a platform, or "" when unknown
+func platformFromExtractor(extractorKey string) string {
+	key := strings.ToLower(strings.TrimSpace(extractorKey))
+	if key == "" || key == "generic" {
+		return ""
+	}
+	for _, entry := range platformPrefixes {
+		if strings.HasPrefix(key, entry.prefix) {
+			return entry.platform
+		}
+	}
+	return key
+}
+
+// platformFromURL derives a platform from the URL's domain, e.g. m.facebook.com -> facebook
+func platformFromURL(videoURL string) string {
+	u, err := url.Parse(strings.TrimSpace(videoURL))
+	if err != nil {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if platform, ok := platformDomains[host]; ok {
+		return platform
+	}
+
+	// The registered domain's name, e.g. "vimeo" for player.vimeo.com
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return host
+	}
+	return labels[len(labels)-2]
+}
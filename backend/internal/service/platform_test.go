@@ -0,0 +1,30 @@
+package service
+
+import "testing"
+
+func TestDetectPlatform(t *testing.T) {
+	tests := []struct {
+		name         string
+		extractorKey string
+		url          string
+		want         string
+	}{
+		{"youtube", "Youtube", "https://www.youtube.com/watch?v=abc123", "youtube"},
+		{"youtube tab", "YoutubeTab", "https://www.youtube.com/playlist?list=PL1", "youtube"},
+		{"tiktok", "TikTok", "https://www.tiktok.com/@user/video/1", "tiktok"},
+		{"extractor wins over domain", "Vimeo", "https://example.com/embed/1", "vimeo"},
+		{"unmapped extractor is lowercased", "Dailymotion", "https://www.dailymotion.com/video/x1", "dailymotion"},
+		{"generic falls back to domain", "Generic", "https://player.vimeo.com/video/1", "vimeo"},
+		{"missing falls back to domain", "", "https://m.facebook.com/watch?v=1", "facebook"},
+		{"short domain", "", "https://youtu.be/abc123", "youtube"},
+		{"renamed domain", "", "https://x.com/user/status/1", "twitter"},
+		{"nothing known", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectPlatform(tt.extractorKey, tt.url); got != tt.want {
+				t.Errorf("DetectPlatform(%q, %q) = %q, want %q", tt.extractorKey, tt.url, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+
+	"videodownload/internal/model"
+	"videodownload/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// isPlaylist reports whether the worker returned a playlist rather than a single video
+func isPlaylist(metadata *model.VideoMetadata) bool {
+	return len(metadata.Entries) > 0 || metadata.PlaylistCount > 0
+}
+
+// GetPlaylistInfo fetches the videos of a playlist URL
+// Fails with ErrNotPlaylist when the URL points to a single video
+func (s *VideoService) GetPlaylistInfo(ctx context.Context, playlistURL string, verbose bool) (*model.PlaylistInfo, error) {
+	metadata, err := s.fetchMetadata(ctx, playlistURL)
+	if err != nil {
+		return nil, err
+	}
+	if !isPlaylist(metadata) {
+		return nil, ErrNotPlaylist
+	}
+	return s.PlaylistFromMetadata(playlistURL, metadata, verbose), nil
+}
+
+// PlaylistFromMetadata converts playlist metadata into a PlaylistInfo of at most
+// MAX_PLAYLIST_ENTRIES videos
+// Entries are remembered like single videos, so downloads of them can be cross-checked
+func (s *VideoService) PlaylistFromMetadata(playlistURL string, metadata *model.VideoMetadata, verbose bool) *model.PlaylistInfo {
+	entries := metadata.Entries
+	if maxEntries := s.cfg.Python.MaxPlaylistEntries; maxEntries > 0 && len(entries) > maxEntries {
+		entries = entries[:maxEntries]
+	}
+
+	entryCount := metadata.PlaylistCount
+	if entryCount < len(metadata.Entries) {
+		entryCount = len(metadata.Entries)
+	}
+
+	playlist := &model.PlaylistInfo{
+		Type:       "playlist",
+		URL:        playlistURL,
+		Title:      metadata.Title,
+		Uploader:   metadata.Uploader,
+		Extractor:  metadata.ExtractorKey,
+		Platform:   DetectPlatform(metadata.ExtractorKey, playlistURL),
+		EntryCount: entryCount,
+		Truncated:  entryCount > len(entries),
+		Entries:    make([]model.VideoInfo, 0, len(entries)),
+	}
+	if playlist.Extractor == "" {
+		playlist.Extractor = metadata.Extractor
+	}
+
+	for _, entry := range entries {
+		videoInfo := s.parseMetadata(entry, verbose)
+		if entry.URL != "" {
+			s.rememberInfo(entry.URL, videoInfo)
+		}
+		playlist.Entries = append(playlist.Entries, *videoInfo)
+	}
+
+	logger.Logger.Info("Playlist info retrieved",
+		zap.String("title", playlist.Title),
+		zap.Int("entries", len(playlist.Entries)),
+		zap.Int("entry_count", playlist.EntryCount))
+	return playlist
+}
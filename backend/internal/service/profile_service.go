@@ -0,0 +1,112 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+
+	"videodownload/internal/model"
+	"videodownload/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// limitProfilesFile is the on-disk format of LIMIT_PROFILES_FILE
+type limitProfilesFile struct {
+	Profiles map[string]model.LimitProfile `json:"profiles"` // profile name -> limits
+	Keys     map[string]string             `json:"keys"`     // API key -> profile name
+}
+
+// ProfileService resolves API keys to limit profiles
+type ProfileService struct {
+	defaults model.LimitProfile
+	profiles map[string]*model.LimitProfile
+	keys     map[string]string
+}
+
+// NewProfileService creates a new profile service and loads the profiles file if configured
+func NewProfileService(cfg *model.Config) (*ProfileService, error) {
+	service := &ProfileService{
+		defaults: model.LimitProfile{
+			Name:              "default",
+			DailyLimitMB:      cfg.Quota.DailyLimitMB,
+			RequestsPerMinute: cfg.RateLimit.RequestsPerMinute,
+			MaxVideoSizeMB:    cfg.Storage.MaxVideoSizeMB,
+			MaxConcurrent:     cfg.ClientLimits.MaxConcurrent,
+		},
+		profiles: make(map[string]*model.LimitProfile),
+		keys:     make(map[string]string),
+	}
+
+	if cfg.ClientLimits.ProfilesFile == "" {
+		return service, nil
+	}
+
+	data, err := os.ReadFile(cfg.ClientLimits.ProfilesFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var file limitProfilesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	for name, profile := range file.Profiles {
+		profile := profile
+		profile.Name = name
+		service.applyDefaults(&profile)
+		service.profiles[name] = &profile
+	}
+
+	for key, name := range file.Keys {
+		if _, ok := service.profiles[name]; !ok {
+			logger.Logger.Warn("API key references unknown limit profile", zap.String("profile", name))
+			continue
+		}
+		service.keys[key] = name
+	}
+
+	logger.Logger.Info("Limit profiles loaded",
+		zap.Int("profiles", len(service.profiles)),
+		zap.Int("keys", len(service.keys)))
+
+	return service, nil
+}
+
+// applyDefaults fills unset profile limits from the global defaults
+func (ps *ProfileService) applyDefaults(profile *model.LimitProfile) {
+	if profile.DailyLimitMB <= 0 {
+		profile.DailyLimitMB = ps.defaults.DailyLimitMB
+	}
+	if profile.RequestsPerMinute <= 0 {
+		profile.RequestsPerMinute = ps.defaults.RequestsPerMinute
+	}
+	if profile.MaxVideoSizeMB <= 0 {
+		profile.MaxVideoSizeMB = ps.defaults.MaxVideoSizeMB
+	}
+	if profile.MaxConcurrent <= 0 {
+		profile.MaxConcurrent = ps.defaults.MaxConcurrent
+	}
+}
+
+// IsKnownKey reports whether the API key is mapped to a profile
+func (ps *ProfileService) IsKnownKey(apiKey string) bool {
+	_, ok := ps.keys[apiKey]
+	return apiKey != "" && ok
+}
+
+// GetProfile returns the limit profile for an API key
+// Unknown or absent keys get the default profile
+func (ps *ProfileService) GetProfile(apiKey string) *model.LimitProfile {
+	if name, ok := ps.keys[apiKey]; ok && apiKey != "" {
+		return ps.profiles[name]
+	}
+	defaults := ps.defaults
+	return &defaults
+}
+
+// DefaultProfile returns the global default limits
+func (ps *ProfileService) DefaultProfile() *model.LimitProfile {
+	defaults := ps.defaults
+	return &defaults
+}
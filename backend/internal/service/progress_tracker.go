@@ -0,0 +1,63 @@
+package service
+
+import (
+	"sync"
+)
+
+// ProgressTracker counts the open progress streams, globally and per client
+// Each stream holds a goroutine and a connection, so both counts are capped
+type ProgressTracker struct {
+	maxTotal     int // 0 = unlimited
+	maxPerClient int // 0 = unlimited
+	total        int
+	perClient    map[string]int
+	mu           sync.Mutex
+}
+
+// NewProgressTracker creates a tracker allowing maxTotal streams, at most maxPerClient per client
+func NewProgressTracker(maxTotal, maxPerClient int) *ProgressTracker {
+	return &ProgressTracker{
+		maxTotal:     maxTotal,
+		maxPerClient: maxPerClient,
+		perClient:    make(map[string]int),
+	}
+}
+
+// Subscribe takes a stream slot for clientKey
+// Returns false when the global or the client's cap is reached; otherwise the returned release
+// must be called once the stream ends. Calling it again has no effect
+func (t *ProgressTracker) Subscribe(clientKey string) (release func(), ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.maxTotal > 0 && t.total >= t.maxTotal {
+		return nil, false
+	}
+	if t.maxPerClient > 0 && t.perClient[clientKey] >= t.maxPerClient {
+		return nil, false
+	}
+	t.total++
+	t.perClient[clientKey]++
+
+	var once sync.Once
+	return func() { once.Do(func() { t.unsubscribe(clientKey) }) }, true
+}
+
+// unsubscribe frees a slot taken by Subscribe
+func (t *ProgressTracker) unsubscribe(clientKey string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.total--
+	t.perClient[clientKey]--
+	if t.perClient[clientKey] <= 0 {
+		delete(t.perClient, clientKey)
+	}
+}
+
+// ActiveSubscribers returns the number of open progress streams
+func (t *ProgressTracker) ActiveSubscribers() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.total
+}
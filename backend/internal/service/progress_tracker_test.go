@@ -0,0 +1,49 @@
+package service
+
+import "testing"
+
+func TestProgressTrackerCaps(t *testing.T) {
+	tracker := NewProgressTracker(3, 2)
+
+	releaseA1, ok := tracker.Subscribe("a")
+	if !ok {
+		t.Fatal("first stream of a refused")
+	}
+	if _, ok := tracker.Subscribe("a"); !ok {
+		t.Fatal("second stream of a refused")
+	}
+	if _, ok := tracker.Subscribe("a"); ok {
+		t.Fatal("third stream of a allowed past the per-client cap")
+	}
+	if _, ok := tracker.Subscribe("b"); !ok {
+		t.Fatal("first stream of b refused")
+	}
+	if _, ok := tracker.Subscribe("c"); ok {
+		t.Fatal("stream of c allowed past the global cap")
+	}
+	if got := tracker.ActiveSubscribers(); got != 3 {
+		t.Fatalf("ActiveSubscribers = %d, want 3", got)
+	}
+
+	// Releasing twice frees a single slot
+	releaseA1()
+	releaseA1()
+	if got := tracker.ActiveSubscribers(); got != 2 {
+		t.Fatalf("ActiveSubscribers after release = %d, want 2", got)
+	}
+	if _, ok := tracker.Subscribe("c"); !ok {
+		t.Fatal("released slot not reusable")
+	}
+	if _, ok := tracker.Subscribe("a"); ok {
+		t.Fatal("a got a slot while the global cap is reached")
+	}
+}
+
+func TestProgressTrackerUnlimited(t *testing.T) {
+	tracker := NewProgressTracker(0, 0)
+	for i := 0; i < 100; i++ {
+		if _, ok := tracker.Subscribe("a"); !ok {
+			t.Fatalf("stream %d refused without caps", i)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"math/rand"
+	"sort"
 	"sync"
 	"time"
 
@@ -12,30 +14,39 @@ import (
 
 // QuotaEntry tracks quota usage per IP
 type QuotaEntry struct {
-	IP         string
-	UsedMB     int64
-	ResetTime  time.Time
-	LastUpdate time.Time
+	IP         string    `json:"ip"`
+	UsedMB     int64     `json:"used_mb"`
+	ResetTime  time.Time `json:"reset_time"`
+	LastUpdate time.Time `json:"last_update"`
 }
 
 // QuotaService manages user download quotas
 type QuotaService struct {
-	cfg      *model.QuotaConfig
-	quotas   map[string]*QuotaEntry
-	mu       sync.RWMutex
-	quitChan chan bool
+	cfg         *model.QuotaConfig
+	quotas      map[string]*QuotaEntry
+	store       QuotaStore // nil keeps quotas in memory only
+	mu          sync.RWMutex
+	quitChan    chan bool
+	persistQuit chan bool
 }
 
 // NewQuotaService creates a new quota service
-func NewQuotaService(cfg *model.QuotaConfig) *QuotaService {
+// With a store, entries are restored from it and saved every QUOTA_PERSIST_INTERVAL and on Stop
+func NewQuotaService(cfg *model.QuotaConfig, store QuotaStore) *QuotaService {
 	service := &QuotaService{
-		cfg:      cfg,
-		quotas:   make(map[string]*QuotaEntry),
-		quitChan: make(chan bool),
+		cfg:         cfg,
+		quotas:      make(map[string]*QuotaEntry),
+		quitChan:    make(chan bool),
+		persistQuit: make(chan bool),
 	}
 
 	if cfg.Enabled {
+		service.store = store
+		service.load()
 		go service.resetRoutine()
+		if service.store != nil && cfg.PersistInterval > 0 {
+			go service.persistRoutine()
+		}
 	}
 
 	return service
@@ -43,8 +54,13 @@ func NewQuotaService(cfg *model.QuotaConfig) *QuotaService {
 
 // CheckQuota checks if IP has remaining quota
 func (qs *QuotaService) CheckQuota(ip string, requestedSizeMB int64) (bool, int64) {
+	return qs.CheckQuotaWithLimit(ip, requestedSizeMB, qs.cfg.DailyLimitMB)
+}
+
+// CheckQuotaWithLimit checks if a client has remaining quota under a per-client daily limit
+func (qs *QuotaService) CheckQuotaWithLimit(ip string, requestedSizeMB int64, dailyLimitMB int64) (bool, int64) {
 	if !qs.cfg.Enabled {
-		return true, qs.cfg.DailyLimitMB
+		return true, dailyLimitMB
 	}
 
 	qs.mu.RLock()
@@ -54,17 +70,14 @@ func (qs *QuotaService) CheckQuota(ip string, requestedSizeMB int64) (bool, int6
 	// Create new quota entry if not exists
 	if !exists {
 		qs.mu.Lock()
-		resetTime := qs.calculateResetTime()
-		qs.quotas[ip] = &QuotaEntry{
-			IP:         ip,
-			UsedMB:     0,
-			ResetTime:  resetTime,
-			LastUpdate: time.Now(),
-		}
-		entry = qs.quotas[ip]
+		entry = qs.insertEntryLocked(ip, 0)
 		qs.mu.Unlock()
 
-		logger.Logger.Info("New quota entry created", zap.String("ip", ip), zap.Time("reset_time", resetTime))
+		// Without room to track the client, the request is allowed untracked
+		if entry == nil {
+			return true, dailyLimitMB
+		}
+		logger.Logger.Info("New quota entry created", zap.String("ip", ip), zap.Time("reset_time", entry.ResetTime))
 	}
 
 	// Check if quota reset time has passed
@@ -80,9 +93,9 @@ func (qs *QuotaService) CheckQuota(ip string, requestedSizeMB int64) (bool, int6
 	}
 
 	// Check if quota is available
-	remaining := qs.cfg.DailyLimitMB - entry.UsedMB
+	remaining := dailyLimitMB - entry.UsedMB
 	if remaining <= 0 {
-		logger.Logger.Warn("Quota exhausted", zap.String("ip", ip), zap.Int64("limit_mb", qs.cfg.DailyLimitMB))
+		logger.Logger.Warn("Quota exhausted", zap.String("ip", ip), zap.Int64("limit_mb", dailyLimitMB))
 		return false, 0
 	}
 
@@ -105,14 +118,9 @@ func (qs *QuotaService) AddUsage(ip string, sizeMB int64) error {
 
 	entry, exists := qs.quotas[ip]
 	if !exists {
-		resetTime := qs.calculateResetTime()
-		qs.quotas[ip] = &QuotaEntry{
-			IP:         ip,
-			UsedMB:     sizeMB,
-			ResetTime:  resetTime,
-			LastUpdate: time.Now(),
+		if qs.insertEntryLocked(ip, sizeMB) != nil {
+			logger.Logger.Info("Quota usage added for new IP", zap.String("ip", ip), zap.Int64("used_mb", sizeMB))
 		}
-		logger.Logger.Info("Quota usage added for new IP", zap.String("ip", ip), zap.Int64("used_mb", sizeMB))
 		return nil
 	}
 
@@ -124,8 +132,116 @@ func (qs *QuotaService) AddUsage(ip string, sizeMB int64) error {
 	return nil
 }
 
+// Reserve charges sizeMB to a client's usage up front when it fits under dailyLimitMB
+// Returns false, charging nothing, when it doesn't; the reservation is given back with Release
+func (qs *QuotaService) Reserve(ip string, sizeMB int64, dailyLimitMB int64) bool {
+	if !qs.cfg.Enabled {
+		return true
+	}
+	// Creates the entry and applies a due reset
+	if allowed, _ := qs.CheckQuotaWithLimit(ip, sizeMB, dailyLimitMB); !allowed {
+		return false
+	}
+
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	entry, exists := qs.quotas[ip]
+	if !exists {
+		// Untracked clients were allowed by CheckQuotaWithLimit
+		return true
+	}
+	// Another request may have reserved since the check
+	if entry.UsedMB+sizeMB > dailyLimitMB {
+		return false
+	}
+	entry.UsedMB += sizeMB
+	entry.LastUpdate = time.Now()
+	return true
+}
+
+// Release gives back sizeMB of an earlier Reserve
+func (qs *QuotaService) Release(ip string, sizeMB int64) {
+	if !qs.cfg.Enabled {
+		return
+	}
+
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+
+	entry, exists := qs.quotas[ip]
+	if !exists {
+		return
+	}
+	// A reset since the reservation already cleared it
+	entry.UsedMB -= sizeMB
+	if entry.UsedMB < 0 {
+		entry.UsedMB = 0
+	}
+	entry.LastUpdate = time.Now()
+}
+
+// insertEntryLocked adds a quota entry for ip, keeping the map within QUOTA_MAX_ENTRIES
+// When full, the least recently updated idle entry is evicted; active entries are never evicted.
+// Returns the existing entry if another request created it first, or nil when nothing can be evicted.
+// Caller must hold qs.mu
+func (qs *QuotaService) insertEntryLocked(ip string, usedMB int64) *QuotaEntry {
+	if entry, exists := qs.quotas[ip]; exists {
+		return entry
+	}
+
+	now := time.Now()
+	if qs.cfg.MaxEntries > 0 && len(qs.quotas) >= qs.cfg.MaxEntries {
+		var oldestIdle *QuotaEntry
+		for _, entry := range qs.quotas {
+			idle := entry.UsedMB == 0 || now.After(entry.ResetTime)
+			if idle && (oldestIdle == nil || entry.LastUpdate.Before(oldestIdle.LastUpdate)) {
+				oldestIdle = entry
+			}
+		}
+		if oldestIdle == nil {
+			logger.Logger.Warn("Quota map full of active entries, client not tracked",
+				zap.String("ip", ip),
+				zap.Int("max_entries", qs.cfg.MaxEntries))
+			return nil
+		}
+		delete(qs.quotas, oldestIdle.IP)
+		logger.Logger.Debug("Idle quota entry evicted on insert", zap.String("evicted_ip", oldestIdle.IP))
+	}
+
+	entry := &QuotaEntry{
+		IP:         ip,
+		UsedMB:     usedMB,
+		ResetTime:  qs.calculateResetTime(),
+		LastUpdate: now,
+	}
+	qs.quotas[ip] = entry
+	return entry
+}
+
+// AddUsageBytes adds a download of sizeBytes to quota usage, rounded up to whole MB
+func (qs *QuotaService) AddUsageBytes(ip string, sizeBytes int64) error {
+	sizeMB := bytesToQuotaMB(sizeBytes)
+	logger.Logger.Debug("Quota usage added", zap.String("ip", ip), zap.Int64("size_mb", sizeMB))
+	return qs.AddUsage(ip, sizeMB)
+}
+
+// bytesToQuotaMB converts a size to the whole MB charged for it, rounding up
+func bytesToQuotaMB(sizeBytes int64) int64 {
+	sizeMB := sizeBytes / (1024 * 1024)
+	if sizeBytes%(1024*1024) > 0 {
+		sizeMB++
+	}
+	return sizeMB
+}
+
 // GetQuotaInfo returns current quota info for IP
 func (qs *QuotaService) GetQuotaInfo(ip string) map[string]interface{} {
+	return qs.GetQuotaInfoWithLimit(ip, qs.cfg.DailyLimitMB)
+}
+
+// GetQuotaInfoWithLimit returns current quota info for a client under a per-client daily limit
+func (qs *QuotaService) GetQuotaInfoWithLimit(ip string, dailyLimitMB int64) map[string]interface{} {
 	if !qs.cfg.Enabled {
 		return map[string]interface{}{
 			"enabled": false,
@@ -141,13 +257,13 @@ func (qs *QuotaService) GetQuotaInfo(ip string) map[string]interface{} {
 		return map[string]interface{}{
 			"enabled":      true,
 			"used_mb":      0,
-			"limit_mb":     qs.cfg.DailyLimitMB,
-			"remaining_mb": qs.cfg.DailyLimitMB,
+			"limit_mb":     dailyLimitMB,
+			"remaining_mb": dailyLimitMB,
 			"reset_time":   resetTime,
 		}
 	}
 
-	remaining := qs.cfg.DailyLimitMB - entry.UsedMB
+	remaining := dailyLimitMB - entry.UsedMB
 	if remaining < 0 {
 		remaining = 0
 	}
@@ -155,17 +271,25 @@ func (qs *QuotaService) GetQuotaInfo(ip string) map[string]interface{} {
 	return map[string]interface{}{
 		"enabled":      true,
 		"used_mb":      entry.UsedMB,
-		"limit_mb":     qs.cfg.DailyLimitMB,
+		"limit_mb":     dailyLimitMB,
 		"remaining_mb": remaining,
 		"reset_time":   entry.ResetTime,
 	}
 }
 
 // calculateResetTime calculates next reset time based on config
+// With jitter enabled each entry gets a random offset within the window centered on the reset time,
+// so clients are unblocked gradually instead of all at once
 func (qs *QuotaService) calculateResetTime() time.Time {
 	now := time.Now()
 	resetTime := time.Date(now.Year(), now.Month(), now.Day(), qs.cfg.ResetHour, qs.cfg.ResetMinute, 0, 0, now.Location())
 
+	if qs.cfg.ResetJitterSeconds > 0 {
+		window := time.Duration(qs.cfg.ResetJitterSeconds) * time.Second
+		offset := time.Duration(rand.Int63n(int64(window))) - window/2
+		resetTime = resetTime.Add(offset)
+	}
+
 	// If reset time has already passed today, set for tomorrow
 	if resetTime.Before(now) {
 		resetTime = resetTime.AddDate(0, 0, 1)
@@ -210,11 +334,125 @@ func (qs *QuotaService) checkAndResetQuotas() {
 	if resetCount > 0 {
 		logger.Logger.Info("Quota reset completed", zap.Int("entries_reset", resetCount))
 	}
+
+	qs.evictIdleEntries(now)
+}
+
+// evictIdleEntries removes entries without usage for longer than the idle TTL,
+// then evicts the oldest idle entries while the map exceeds its hard cap
+// Caller must hold qs.mu
+func (qs *QuotaService) evictIdleEntries(now time.Time) {
+	idleTTL := time.Duration(qs.cfg.IdleTTLSeconds) * time.Second
+	evicted := 0
+
+	var idle []*QuotaEntry
+	for ip, entry := range qs.quotas {
+		if entry.UsedMB != 0 {
+			continue
+		}
+		if idleTTL > 0 && now.Sub(entry.LastUpdate) > idleTTL {
+			delete(qs.quotas, ip)
+			evicted++
+			continue
+		}
+		idle = append(idle, entry)
+	}
+
+	if qs.cfg.MaxEntries > 0 && len(qs.quotas) > qs.cfg.MaxEntries {
+		sort.Slice(idle, func(i, j int) bool {
+			return idle[i].LastUpdate.Before(idle[j].LastUpdate)
+		})
+		for _, entry := range idle {
+			if len(qs.quotas) <= qs.cfg.MaxEntries {
+				break
+			}
+			delete(qs.quotas, entry.IP)
+			evicted++
+		}
+	}
+
+	if evicted > 0 {
+		logger.Logger.Info("Idle quota entries evicted", zap.Int("evicted", evicted), zap.Int("remaining", len(qs.quotas)))
+	}
 }
 
-// Stop stops the quota service
+// GetEntryCount returns the number of tracked quota entries
+func (qs *QuotaService) GetEntryCount() int {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
+	return len(qs.quotas)
+}
+
+// load restores the entries of the store, dropping those whose reset time has passed
+func (qs *QuotaService) load() {
+	if qs.store == nil {
+		return
+	}
+
+	entries, err := qs.store.Load()
+	if err != nil {
+		logger.Logger.Warn("Failed to load quota entries", zap.Error(err))
+		return
+	}
+
+	now := time.Now()
+	dropped := 0
+	for _, entry := range entries {
+		if entry == nil || entry.IP == "" || now.After(entry.ResetTime) {
+			dropped++
+			continue
+		}
+		if qs.cfg.MaxEntries > 0 && len(qs.quotas) >= qs.cfg.MaxEntries {
+			dropped++
+			continue
+		}
+		qs.quotas[entry.IP] = entry
+	}
+
+	logger.Logger.Info("Quota entries restored", zap.Int("entries", len(qs.quotas)), zap.Int("dropped", dropped))
+}
+
+// save writes a snapshot of the entries to the store
+func (qs *QuotaService) save() {
+	if qs.store == nil {
+		return
+	}
+
+	qs.mu.RLock()
+	entries := make([]*QuotaEntry, 0, len(qs.quotas))
+	for _, entry := range qs.quotas {
+		snapshot := *entry
+		entries = append(entries, &snapshot)
+	}
+	qs.mu.RUnlock()
+
+	if err := qs.store.Save(entries); err != nil {
+		logger.Logger.Error("Failed to save quota entries", zap.Error(err))
+	}
+}
+
+// persistRoutine periodically saves the entries to the store
+func (qs *QuotaService) persistRoutine() {
+	ticker := time.NewTicker(time.Duration(qs.cfg.PersistInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-qs.persistQuit:
+			return
+		case <-ticker.C:
+			qs.save()
+		}
+	}
+}
+
+// Stop stops the quota service and saves the entries to the store
 func (qs *QuotaService) Stop() {
 	if qs.cfg.Enabled {
 		qs.quitChan <- true
+		if qs.store != nil && qs.cfg.PersistInterval > 0 {
+			qs.persistQuit <- true
+		}
+		qs.save()
 	}
 }
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"videodownload/internal/model"
+)
+
+// newTestQuotaService returns an enabled quota service stopped when the test ends
+func newTestQuotaService(t *testing.T, dailyLimitMB int64, store QuotaStore) *QuotaService {
+	t.Helper()
+	qs := NewQuotaService(&model.QuotaConfig{
+		Enabled:        true,
+		DailyLimitMB:   dailyLimitMB,
+		IdleTTLSeconds: 3600,
+		MaxEntries:     100,
+	}, store)
+	t.Cleanup(qs.Stop)
+	return qs
+}
+
+// usedMB returns the quota a client has used so far
+func usedMB(qs *QuotaService, ip string) int64 {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
+	if entry, ok := qs.quotas[ip]; ok {
+		return entry.UsedMB
+	}
+	return 0
+}
+
+func TestQuotaReserveRelease(t *testing.T) {
+	tests := []struct {
+		name       string
+		usedMB     int64
+		reserveMB  int64
+		wantOK     bool
+		wantUsedMB int64 // Usage after the reservation
+	}{
+		{"fits", 10, 50, true, 60},
+		{"fills the quota exactly", 60, 40, true, 100},
+		{"exceeds the remaining quota", 80, 30, false, 80},
+		{"quota already exhausted", 100, 1, false, 100},
+		{"zero size while quota remains", 10, 0, true, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qs := newTestQuotaService(t, 100, nil)
+			qs.AddUsage("1.2.3.4", tt.usedMB)
+
+			if ok := qs.Reserve("1.2.3.4", tt.reserveMB, 100); ok != tt.wantOK {
+				t.Fatalf("Reserve = %v, want %v", ok, tt.wantOK)
+			}
+			if got := usedMB(qs, "1.2.3.4"); got != tt.wantUsedMB {
+				t.Errorf("used after Reserve = %dMB, want %dMB", got, tt.wantUsedMB)
+			}
+
+			if tt.wantOK {
+				qs.Release("1.2.3.4", tt.reserveMB)
+				if got := usedMB(qs, "1.2.3.4"); got != tt.usedMB {
+					t.Errorf("used after Release = %dMB, want %dMB", got, tt.usedMB)
+				}
+			}
+		})
+	}
+}
+
+func TestQuotaReleaseAfterReset(t *testing.T) {
+	qs := newTestQuotaService(t, 100, nil)
+	if !qs.Reserve("1.2.3.4", 40, 100) {
+		t.Fatal("Reserve rejected")
+	}
+
+	// A reset between reservation and release must not drive usage negative
+	qs.mu.Lock()
+	qs.quotas["1.2.3.4"].UsedMB = 0
+	qs.mu.Unlock()
+	qs.Release("1.2.3.4", 40)
+
+	if got := usedMB(qs, "1.2.3.4"); got != 0 {
+		t.Errorf("used = %dMB, want 0", got)
+	}
+}
+
+func TestBytesToQuotaMB(t *testing.T) {
+	tests := []struct {
+		bytes int64
+		want  int64
+	}{
+		{0, 0},
+		{1, 1},
+		{1024 * 1024, 1},
+		{1024*1024 + 1, 2},
+		{10 * 1024 * 1024, 10},
+	}
+	for _, tt := range tests {
+		if got := bytesToQuotaMB(tt.bytes); got != tt.want {
+			t.Errorf("bytesToQuotaMB(%d) = %d, want %d", tt.bytes, got, tt.want)
+		}
+	}
+}
+
+func TestFileQuotaStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota", "quota.json")
+	store := NewFileQuotaStore(path)
+
+	entries, err := store.Load()
+	if err != nil || entries != nil {
+		t.Fatalf("Load of a missing file = (%v, %v), want (nil, nil)", entries, err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	saved := []*QuotaEntry{
+		{IP: "1.2.3.4", UsedMB: 42, ResetTime: now.Add(time.Hour), LastUpdate: now},
+		{IP: "5.6.7.8", UsedMB: 7, ResetTime: now.Add(-time.Minute), LastUpdate: now.Add(-time.Hour)},
+	}
+	if err := store.Save(saved); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(loaded) != len(saved) {
+		t.Fatalf("loaded %d entries, want %d", len(loaded), len(saved))
+	}
+	for i, entry := range loaded {
+		if entry.IP != saved[i].IP || entry.UsedMB != saved[i].UsedMB || !entry.ResetTime.Equal(saved[i].ResetTime) {
+			t.Errorf("entry %d = %+v, want %+v", i, entry, saved[i])
+		}
+	}
+
+	// A restarted service resumes the saved usage, except where the reset time has passed
+	qs := NewQuotaService(&model.QuotaConfig{Enabled: true, DailyLimitMB: 100, IdleTTLSeconds: 3600, MaxEntries: 100}, store)
+	if got := usedMB(qs, "1.2.3.4"); got != 42 {
+		t.Errorf("restored usage = %dMB, want 42MB", got)
+	}
+	if isTracked(qs, "5.6.7.8") {
+		t.Error("entry past its reset time was restored")
+	}
+
+	// Stopping saves the usage added since
+	qs.AddUsage("1.2.3.4", 8)
+	qs.Stop()
+	restarted := newTestQuotaService(t, 100, store)
+	if got := usedMB(restarted, "1.2.3.4"); got != 50 {
+		t.Errorf("usage after a restart = %dMB, want 50MB", got)
+	}
+}
+
+// isTracked reports whether the quota service has an entry for ip
+func isTracked(qs *QuotaService, ip string) bool {
+	qs.mu.RLock()
+	defer qs.mu.RUnlock()
+	_, ok := qs.quotas[ip]
+	return ok
+}
+
+// setLastUpdate backdates the last update of ip's entry
+func setLastUpdate(qs *QuotaService, ip string, lastUpdate time.Time) {
+	qs.mu.Lock()
+	defer qs.mu.Unlock()
+	qs.quotas[ip].LastUpdate = lastUpdate
+}
+
+func TestQuotaEvictsIdleEntries(t *testing.T) {
+	qs := newTestQuotaService(t, 100, nil)
+	now := time.Now()
+
+	// Idle past the TTL, idle within the TTL, and active past the TTL
+	qs.CheckQuota("idle-old", 0)
+	qs.CheckQuota("idle-recent", 0)
+	qs.AddUsage("active-old", 10)
+	setLastUpdate(qs, "idle-old", now.Add(-2*time.Hour))
+	setLastUpdate(qs, "idle-recent", now.Add(-time.Minute))
+	setLastUpdate(qs, "active-old", now.Add(-2*time.Hour))
+
+	qs.checkAndResetQuotas()
+
+	for ip, want := range map[string]bool{"idle-old": false, "idle-recent": true, "active-old": true} {
+		if got := isTracked(qs, ip); got != want {
+			t.Errorf("%s tracked = %v, want %v", ip, got, want)
+		}
+	}
+	if got := qs.GetEntryCount(); got != 2 {
+		t.Errorf("entry count = %d, want 2", got)
+	}
+}
+
+func TestQuotaMaxEntries(t *testing.T) {
+	qs := newTestQuotaService(t, 100, nil)
+	qs.cfg.MaxEntries = 3
+	now := time.Now()
+
+	qs.CheckQuota("idle-older", 0)
+	qs.CheckQuota("idle-newer", 0)
+	qs.AddUsage("active", 10)
+	setLastUpdate(qs, "idle-older", now.Add(-2*time.Minute))
+	setLastUpdate(qs, "idle-newer", now.Add(-time.Minute))
+	setLastUpdate(qs, "active", now.Add(-time.Hour))
+
+	// A new client evicts the oldest idle entry, never the active one
+	qs.CheckQuota("new", 0)
+	for ip, want := range map[string]bool{"idle-older": false, "idle-newer": true, "active": true, "new": true} {
+		if got := isTracked(qs, ip); got != want {
+			t.Errorf("%s tracked = %v, want %v", ip, got, want)
+		}
+	}
+
+	// With only active entries left, new clients are allowed but not tracked
+	qs.AddUsage("idle-newer", 1)
+	qs.AddUsage("new", 1)
+	if allowed, _ := qs.CheckQuota("overflow", 0); !allowed {
+		t.Error("untracked client rejected")
+	}
+	if isTracked(qs, "overflow") || qs.GetEntryCount() != 3 {
+		t.Errorf("overflow tracked = %v with %d entries, want untracked with 3", isTracked(qs, "overflow"), qs.GetEntryCount())
+	}
+
+	// Usage past its reset time counts as idle, so that entry makes room
+	qs.mu.Lock()
+	qs.quotas["active"].ResetTime = now.Add(-time.Second)
+	qs.mu.Unlock()
+	qs.CheckQuota("after-reset", 0)
+	for ip, want := range map[string]bool{"active": false, "idle-newer": true, "new": true, "after-reset": true} {
+		if got := isTracked(qs, ip); got != want {
+			t.Errorf("after reset, %s tracked = %v, want %v", ip, got, want)
+		}
+	}
+}
+
+func TestQuotaResetJitter(t *testing.T) {
+	// A reset hour a few hours ahead keeps the whole jitter window on the same side of now
+	resetHour := (time.Now().Hour() + 3) % 24
+	exact := (&QuotaService{cfg: &model.QuotaConfig{ResetHour: resetHour}}).calculateResetTime()
+
+	tests := []struct {
+		name          string
+		jitterSeconds int
+		wantSpread    bool
+	}{
+		{"exact by default", 0, false},
+		{"spread with jitter", 3600, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qs := &QuotaService{cfg: &model.QuotaConfig{ResetHour: resetHour, ResetJitterSeconds: tt.jitterSeconds}}
+			window := time.Duration(tt.jitterSeconds) * time.Second
+
+			earliest, latest := exact, exact
+			distinct := make(map[time.Time]bool)
+			for i := 0; i < 200; i++ {
+				resetTime := qs.calculateResetTime()
+				if offset := resetTime.Sub(exact); offset < -window/2 || offset > window/2 {
+					t.Fatalf("reset time %v is %v from %v, outside the jitter window", resetTime, offset, exact)
+				}
+				distinct[resetTime] = true
+				if resetTime.Before(earliest) {
+					earliest = resetTime
+				}
+				if resetTime.After(latest) {
+					latest = resetTime
+				}
+			}
+
+			if !tt.wantSpread {
+				if len(distinct) != 1 {
+					t.Errorf("got %d distinct reset times without jitter, want 1", len(distinct))
+				}
+				return
+			}
+			if len(distinct) < 100 {
+				t.Errorf("got %d distinct reset times of 200, want them spread out", len(distinct))
+			}
+			// 200 uniform draws cover well over half of the window
+			if spread := latest.Sub(earliest); spread < window/2 {
+				t.Errorf("reset times span %v, want at least %v", spread, window/2)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// QuotaStore persists quota entries so usage survives restarts
+type QuotaStore interface {
+	// Load returns the saved entries, or none when nothing was saved yet
+	Load() ([]*QuotaEntry, error)
+	// Save replaces the saved entries
+	Save(entries []*QuotaEntry) error
+}
+
+// FileQuotaStore keeps quota entries in a JSON file
+type FileQuotaStore struct {
+	path string
+	mu   sync.Mutex // Serializes writes of the file
+}
+
+// NewFileQuotaStore creates a store backed by the JSON file at path
+func NewFileQuotaStore(path string) *FileQuotaStore {
+	return &FileQuotaStore{path: path}
+}
+
+// Load reads the entries from the file; a missing file yields no entries
+func (s *FileQuotaStore) Load() ([]*QuotaEntry, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*QuotaEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Save writes the entries to the file, replacing it atomically
+func (s *FileQuotaStore) Save(entries []*QuotaEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
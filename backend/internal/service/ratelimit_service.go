@@ -1,6 +1,7 @@
 package service
 
 import (
+	"sort"
 	"sync"
 	"time"
 
@@ -10,12 +11,12 @@ import (
 	"go.uber.org/zap"
 )
 
-// RateLimitEntry tracks request rate for an IP
+// RateLimitEntry is the token bucket of an IP
+// Tokens refill continuously at RequestsPerMinute/60 per second up to BurstSize; each request takes one
 type RateLimitEntry struct {
-	IP       string
-	Requests int
-	ResetAt  time.Time
-	Blocked  bool
+	IP         string
+	Tokens     float64
+	LastRefill time.Time
 }
 
 // RateLimitService manages rate limiting for DDoS protection
@@ -43,6 +44,12 @@ func NewRateLimitService(cfg *model.RateLimitConfig) *RateLimitService {
 
 // IsAllowed checks if an IP is allowed to make a request
 func (rls *RateLimitService) IsAllowed(ip string) bool {
+	return rls.IsAllowedWithLimit(ip, rls.cfg.RequestsPerMinute)
+}
+
+// IsAllowedWithLimit checks if a client is allowed to make a request under a per-client limit
+// The request takes a token from the client's bucket, which refills at requestsPerMinute
+func (rls *RateLimitService) IsAllowedWithLimit(ip string, requestsPerMinute int) bool {
 	if !rls.cfg.Enabled {
 		return true
 	}
@@ -51,100 +58,42 @@ func (rls *RateLimitService) IsAllowed(ip string) bool {
 	defer rls.mu.Unlock()
 
 	now := time.Now()
+	capacity := rls.capacity(requestsPerMinute)
 	entry, exists := rls.limits[ip]
 
-	// Create new entry if not exists
+	// New clients start with a full bucket
 	if !exists {
-		rls.limits[ip] = &RateLimitEntry{
-			IP:       ip,
-			Requests: 1,
-			ResetAt:  now.Add(time.Minute),
-			Blocked:  false,
-		}
+		entry = &RateLimitEntry{IP: ip, Tokens: capacity, LastRefill: now}
+		rls.limits[ip] = entry
 		logger.Logger.Debug("New rate limit entry created", zap.String("ip", ip))
-		return true
-	}
-
-	// Check if reset time has passed
-	if now.After(entry.ResetAt) {
-		entry.Requests = 1
-		entry.ResetAt = now.Add(time.Minute)
-		entry.Blocked = false
-		return true
+	} else {
+		entry.Tokens = refilledTokens(entry, now, requestsPerMinute, capacity)
+		entry.LastRefill = now
 	}
 
-	// Check if already blocked
-	if entry.Blocked {
-		logger.Logger.Warn("Request blocked - IP is rate limited", zap.String("ip", ip))
+	if entry.Tokens < 1 {
+		logger.Logger.Warn("Rate limit exceeded", zap.String("ip", ip), zap.Float64("tokens", entry.Tokens), zap.Int("limit", requestsPerMinute))
 		return false
 	}
+	entry.Tokens--
 
-	// Increment request counter
-	entry.Requests++
-
-	// Check if limit exceeded
-	if entry.Requests > rls.cfg.RequestsPerMinute {
-		entry.Blocked = true
-		logger.Logger.Warn("Rate limit exceeded", zap.String("ip", ip), zap.Int("requests", entry.Requests), zap.Int("limit", rls.cfg.RequestsPerMinute))
-		return false
-	}
-
-	logger.Logger.Debug("Request allowed", zap.String("ip", ip), zap.Int("requests", entry.Requests), zap.Int("limit", rls.cfg.RequestsPerMinute))
+	logger.Logger.Debug("Request allowed", zap.String("ip", ip), zap.Float64("tokens", entry.Tokens), zap.Int("limit", requestsPerMinute))
 	return true
 }
 
 // AllowBurst checks if an IP is allowed with burst capacity
+// The bucket capacity already is the burst allowance, so this is the same as IsAllowed
 func (rls *RateLimitService) AllowBurst(ip string) bool {
-	if !rls.cfg.Enabled {
-		return true
-	}
-
-	rls.mu.Lock()
-	defer rls.mu.Unlock()
-
-	now := time.Now()
-	entry, exists := rls.limits[ip]
-
-	// Create new entry if not exists
-	if !exists {
-		rls.limits[ip] = &RateLimitEntry{
-			IP:       ip,
-			Requests: 1,
-			ResetAt:  now.Add(time.Minute),
-			Blocked:  false,
-		}
-		return true
-	}
-
-	// Check if reset time has passed
-	if now.After(entry.ResetAt) {
-		entry.Requests = 1
-		entry.ResetAt = now.Add(time.Minute)
-		entry.Blocked = false
-		return true
-	}
-
-	// Check if blocked
-	if entry.Blocked {
-		return false
-	}
-
-	// Increment counter
-	entry.Requests++
-
-	// Use burst size as temporary limit
-	limit := rls.cfg.RequestsPerMinute + rls.cfg.BurstSize
-	if entry.Requests > limit {
-		entry.Blocked = true
-		logger.Logger.Warn("Burst limit exceeded, blocking IP", zap.String("ip", ip), zap.Int("requests", entry.Requests))
-		return false
-	}
-
-	return true
+	return rls.IsAllowed(ip)
 }
 
-// GetRemaining returns remaining requests for IP in current window
+// GetRemaining returns the requests an IP can make right now
 func (rls *RateLimitService) GetRemaining(ip string) int {
+	return rls.GetRemainingWithLimit(ip, rls.cfg.RequestsPerMinute)
+}
+
+// GetRemainingWithLimit returns the whole tokens left in a client's bucket under a per-client limit
+func (rls *RateLimitService) GetRemainingWithLimit(ip string, requestsPerMinute int) int {
 	if !rls.cfg.Enabled {
 		return -1 // Unlimited
 	}
@@ -152,22 +101,29 @@ func (rls *RateLimitService) GetRemaining(ip string) int {
 	rls.mu.RLock()
 	defer rls.mu.RUnlock()
 
+	capacity := rls.capacity(requestsPerMinute)
 	entry, exists := rls.limits[ip]
 	if !exists {
-		return rls.cfg.RequestsPerMinute
+		return int(capacity)
 	}
+	return int(refilledTokens(entry, time.Now(), requestsPerMinute, capacity))
+}
 
-	now := time.Now()
-	if now.After(entry.ResetAt) {
-		return rls.cfg.RequestsPerMinute
+// capacity returns the bucket size: BurstSize, or the per-minute limit when no burst size is set
+func (rls *RateLimitService) capacity(requestsPerMinute int) float64 {
+	if rls.cfg.BurstSize > 0 {
+		return float64(rls.cfg.BurstSize)
 	}
+	return float64(max(requestsPerMinute, 1))
+}
 
-	remaining := rls.cfg.RequestsPerMinute - entry.Requests
-	if remaining < 0 {
-		remaining = 0
+// refilledTokens returns the tokens of entry at now, without modifying it
+func refilledTokens(entry *RateLimitEntry, now time.Time, requestsPerMinute int, capacity float64) float64 {
+	elapsed := now.Sub(entry.LastRefill).Minutes()
+	if elapsed <= 0 {
+		return min(entry.Tokens, capacity)
 	}
-
-	return remaining
+	return min(entry.Tokens+elapsed*float64(requestsPerMinute), capacity)
 }
 
 // cleanupRoutine periodically cleans up old entries
@@ -195,18 +151,40 @@ func (rls *RateLimitService) cleanup() {
 	removed := 0
 
 	for ip, entry := range rls.limits {
-		// Remove entries with reset time older than 2 hours
-		if now.Sub(entry.ResetAt) > 2*time.Hour {
+		// Remove entries idle for more than 2 hours; their bucket has long been full again
+		if now.Sub(entry.LastRefill) > 2*time.Hour {
 			delete(rls.limits, ip)
 			removed++
 		}
 	}
 
+	// Enforce the hard cap by evicting the longest idle entries first
+	if rls.cfg.MaxEntries > 0 && len(rls.limits) > rls.cfg.MaxEntries {
+		entries := make([]*RateLimitEntry, 0, len(rls.limits))
+		for _, entry := range rls.limits {
+			entries = append(entries, entry)
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].LastRefill.Before(entries[j].LastRefill)
+		})
+		for _, entry := range entries[:len(entries)-rls.cfg.MaxEntries] {
+			delete(rls.limits, entry.IP)
+			removed++
+		}
+	}
+
 	if removed > 0 {
 		logger.Logger.Debug("Rate limit entries cleaned up", zap.Int("removed", removed), zap.Int("remaining", len(rls.limits)))
 	}
 }
 
+// GetEntryCount returns the number of tracked rate limit entries
+func (rls *RateLimitService) GetEntryCount() int {
+	rls.mu.RLock()
+	defer rls.mu.RUnlock()
+	return len(rls.limits)
+}
+
 // Reset resets rate limit for a specific IP (admin operation)
 func (rls *RateLimitService) Reset(ip string) {
 	if !rls.cfg.Enabled {
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"videodownload/internal/model"
+)
+
+// newTestRateLimitService returns an enabled rate limiter stopped when the test ends
+func newTestRateLimitService(t *testing.T, requestsPerMinute, burstSize int) *RateLimitService {
+	t.Helper()
+	rls := NewRateLimitService(&model.RateLimitConfig{
+		Enabled:           true,
+		RequestsPerMinute: requestsPerMinute,
+		BurstSize:         burstSize,
+		CleanupInterval:   60,
+	})
+	t.Cleanup(rls.Stop)
+	return rls
+}
+
+func TestRateLimitTokenBucket(t *testing.T) {
+	tests := []struct {
+		name        string
+		rpm         int
+		burst       int
+		idle        time.Duration // Time passed since the bucket was drained
+		wantAllowed int           // Requests allowed in a row after idle
+	}{
+		{"burst size is the capacity", 60, 5, 0, 0},
+		{"capacity defaults to the per-minute limit", 3, 0, 0, 0},
+		{"refills at the per-minute rate", 60, 5, 2 * time.Second, 2},
+		{"partial tokens don't allow a request", 60, 5, 500 * time.Millisecond, 0},
+		{"refill is capped at the capacity", 60, 5, time.Hour, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rls := newTestRateLimitService(t, tt.rpm, tt.burst)
+			capacity := tt.burst
+			if capacity == 0 {
+				capacity = tt.rpm
+			}
+
+			for i := 0; i < capacity; i++ {
+				if !rls.IsAllowed("1.2.3.4") {
+					t.Fatalf("request %d of a full bucket rejected", i+1)
+				}
+			}
+			if rls.IsAllowed("1.2.3.4") {
+				t.Fatal("request allowed after draining the bucket")
+			}
+			if !rls.IsAllowed("5.6.7.8") {
+				t.Fatal("other client rejected")
+			}
+
+			rls.mu.Lock()
+			rls.limits["1.2.3.4"].LastRefill = time.Now().Add(-tt.idle)
+			rls.mu.Unlock()
+
+			if got := rls.GetRemaining("1.2.3.4"); got != tt.wantAllowed {
+				t.Errorf("GetRemaining = %d, want %d", got, tt.wantAllowed)
+			}
+			allowed := 0
+			for rls.IsAllowed("1.2.3.4") {
+				allowed++
+			}
+			if allowed != tt.wantAllowed {
+				t.Errorf("allowed %d requests after %v, want %d", allowed, tt.idle, tt.wantAllowed)
+			}
+		})
+	}
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	rls := NewRateLimitService(&model.RateLimitConfig{RequestsPerMinute: 1, BurstSize: 1})
+	for i := 0; i < 3; i++ {
+		if !rls.IsAllowed("1.2.3.4") {
+			t.Fatalf("request %d rejected while rate limiting is disabled", i+1)
+		}
+	}
+	if got := rls.GetRemaining("1.2.3.4"); got != -1 {
+		t.Errorf("GetRemaining = %d, want -1", got)
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// retryBucket tracks the retry tokens left for a client
+type retryBucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+// RetryBudget limits how many worker retries a single client may trigger
+// Each client has a token bucket of size capacity refilled evenly over window
+type RetryBudget struct {
+	capacity  float64
+	window    time.Duration
+	buckets   map[string]*retryBucket
+	lastPrune time.Time
+	mu        sync.Mutex
+}
+
+// NewRetryBudget creates a retry budget of capacity retries per window
+func NewRetryBudget(capacity int, window time.Duration) *RetryBudget {
+	return &RetryBudget{
+		capacity: float64(capacity),
+		window:   window,
+		buckets:  make(map[string]*retryBucket),
+	}
+}
+
+// Allow consumes one retry token for clientKey, returning false when the budget is exhausted
+func (rb *RetryBudget) Allow(clientKey string) bool {
+	if rb.capacity <= 0 {
+		return false
+	}
+
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	now := time.Now()
+	rb.pruneLocked(now)
+
+	bucket, exists := rb.buckets[clientKey]
+	if !exists {
+		bucket = &retryBucket{tokens: rb.capacity, lastRefill: now}
+		rb.buckets[clientKey] = bucket
+	}
+
+	// Refill proportionally to the time elapsed since the last refill
+	if rb.window > 0 {
+		elapsed := now.Sub(bucket.lastRefill)
+		bucket.tokens += rb.capacity * float64(elapsed) / float64(rb.window)
+		if bucket.tokens > rb.capacity {
+			bucket.tokens = rb.capacity
+		}
+	}
+	bucket.lastRefill = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// pruneLocked drops buckets that have fully refilled, bounding memory
+// Runs at most once per window; the caller must hold rb.mu
+func (rb *RetryBudget) pruneLocked(now time.Time) {
+	if now.Sub(rb.lastPrune) < rb.window {
+		return
+	}
+	rb.lastPrune = now
+
+	for clientKey, bucket := range rb.buckets {
+		if now.Sub(bucket.lastRefill) > rb.window {
+			delete(rb.buckets, clientKey)
+		}
+	}
+}
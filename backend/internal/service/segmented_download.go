@@ -0,0 +1,286 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"videodownload/internal/model"
+	"videodownload/pkg/logger"
+	"videodownload/pkg/tracing"
+	"videodownload/pkg/validator"
+
+	"go.uber.org/zap"
+)
+
+// rangeSource is a media URL probed for byte-range support
+type rangeSource struct {
+	url     string
+	headers map[string]string
+	size    int64
+	etag    string // Validator every segment must match so all ranges come from the same object
+}
+
+// fetchSegmented downloads a large single-file format as parallel byte ranges
+// Returns false whenever the source can't be fetched in segments, so the caller falls back
+// to the single-stream worker download
+func (s *DownloadService) fetchSegmented(ctx context.Context, req *model.DownloadRequest) (*fetchedFile, bool) {
+	cfg := s.cfg.Segmented
+	// Embedding metadata and transcoding need the worker's postprocessors
+	if !cfg.Enabled || cfg.Segments < 2 || req.EmbedMetadata || req.Transcode != nil {
+		return nil, false
+	}
+
+	resolved, err := s.resolveSource(ctx, req)
+	if err != nil {
+		logger.Logger.Debug("Could not resolve direct source, using worker download", zap.Error(err))
+		return nil, false
+	}
+	if !resolved.Segmentable || resolved.URL == "" {
+		return nil, false
+	}
+
+	source, err := s.probeRanges(ctx, resolved)
+	if err != nil {
+		logger.Logger.Debug("Source does not support ranges, using worker download", zap.Error(err))
+		return nil, false
+	}
+
+	if source.size < int64(cfg.ThresholdMB)*1024*1024 {
+		return nil, false
+	}
+	// Oversized files are left to the regular path, which reports the limit error
+	if !s.storageManager.ValidateFileSize(source.size) {
+		return nil, false
+	}
+
+	data, err := s.fetchRanges(ctx, source)
+	if err != nil {
+		logger.Logger.Warn("Segmented download failed, falling back to worker download",
+			zap.String("url", req.URL),
+			zap.Error(err))
+		return nil, false
+	}
+
+	spooled, err := s.spool(bytes.NewReader(data))
+	if err != nil {
+		logger.Logger.Warn("Failed to spool segmented download, falling back to worker download", zap.Error(err))
+		return nil, false
+	}
+
+	filename := validator.SanitizeFilename(resolved.Title)
+	if req.Quality != "" && req.Quality != "Unknown" {
+		filename += "_" + req.Quality
+	}
+	filename += "." + resolved.Ext
+
+	logger.Logger.Info("Segmented download completed",
+		zap.String("filename", filename),
+		zap.Int64("size_bytes", source.size),
+		zap.Int("segments", cfg.Segments))
+
+	return &fetchedFile{filename: filename, spooledFile: spooled}, true
+}
+
+// resolveSource asks the worker for the direct media URL of the requested format
+func (s *DownloadService) resolveSource(ctx context.Context, req *model.DownloadRequest) (*model.PythonWorkerResolveResponse, error) {
+	bodyBytes, _ := json.Marshal(model.PythonWorkerResolveRequest{
+		Version:  model.PythonWorkerRequestVersion,
+		URL:      req.URL,
+		FormatID: req.FormatID,
+	})
+
+	release, err := s.downloadPool.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", s.pythonWorkerURL+"/api/resolve", bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, httpReq.Header)
+
+	resp, err := s.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("resolve failed with status %d", resp.StatusCode)
+	}
+
+	var resolved model.PythonWorkerResolveResponse
+	if err := json.NewDecoder(resp.Body).Decode(&resolved); err != nil {
+		return nil, err
+	}
+	return &resolved, nil
+}
+
+// probeRanges checks that the source honours range requests and learns its total size
+func (s *DownloadService) probeRanges(ctx context.Context, resolved *model.PythonWorkerResolveResponse) (*rangeSource, error) {
+	source := &rangeSource{url: resolved.URL, headers: resolved.HTTPHeaders}
+
+	resp, err := s.getRange(ctx, source, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("range request returned status %d", resp.StatusCode)
+	}
+
+	_, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return nil, err
+	}
+	if total <= 0 {
+		return nil, fmt.Errorf("source did not report its total size")
+	}
+
+	source.size = total
+	source.etag = resp.Header.Get("ETag")
+	return source, nil
+}
+
+// fetchRanges downloads the source in SEGMENTED_DOWNLOAD_SEGMENTS ranges, at most
+// SEGMENTED_DOWNLOAD_CONCURRENCY at a time, and assembles them in order
+// Every segment must cover exactly its range and match the probed ETag, so the assembled
+// bytes are a consistent copy whose SHA-256 is then recorded like any other download
+func (s *DownloadService) fetchRanges(ctx context.Context, source *rangeSource) ([]byte, error) {
+	segments := int64(s.cfg.Segmented.Segments)
+	if segments > source.size {
+		segments = source.size
+	}
+	segmentSize := (source.size + segments - 1) / segments
+
+	concurrency := s.cfg.Segmented.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	data := make([]byte, source.size)
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+
+	for start := int64(0); start < source.size; start += segmentSize {
+		end := start + segmentSize - 1
+		if end >= source.size {
+			end = source.size - 1
+		}
+
+		wg.Add(1)
+		go func(start, end int64) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if err := s.fetchRange(ctx, source, start, end, data[start:end+1]); err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(start, end)
+	}
+
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return data, nil
+}
+
+// fetchRange downloads bytes start..end (inclusive) of the source into buf
+func (s *DownloadService) fetchRange(ctx context.Context, source *rangeSource, start, end int64, buf []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	resp, err := s.getRange(ctx, source, start, end)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("segment %d-%d returned status %d", start, end, resp.StatusCode)
+	}
+	if source.etag != "" && resp.Header.Get("ETag") != source.etag {
+		return fmt.Errorf("segment %d-%d comes from a different version of the file", start, end)
+	}
+
+	gotStart, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return err
+	}
+	if gotStart != start || total != source.size {
+		return fmt.Errorf("segment %d-%d returned an unexpected range", start, end)
+	}
+
+	if _, err := io.ReadFull(resp.Body, buf); err != nil {
+		return fmt.Errorf("segment %d-%d incomplete: %w", start, end, err)
+	}
+	return nil
+}
+
+// getRange issues a GET for bytes start..end of the source
+func (s *DownloadService) getRange(ctx context.Context, source *rangeSource, start, end int64) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", source.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range source.headers {
+		httpReq.Header.Set(key, value)
+	}
+	httpReq.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	return s.httpClient.Do(httpReq)
+}
+
+// parseContentRange parses a "bytes start-end/total" Content-Range header
+func parseContentRange(header string) (int64, int64, error) {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", header)
+	}
+
+	rangePart, totalPart, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", header)
+	}
+	startPart, _, ok := strings.Cut(rangePart, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", header)
+	}
+
+	start, err := strconv.ParseInt(startPart, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", header)
+	}
+	// An unknown total ("*") is reported as -1
+	if totalPart == "*" {
+		return start, -1, nil
+	}
+	total, err := strconv.ParseInt(totalPart, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", header)
+	}
+	return start, total, nil
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"videodownload/internal/model"
+)
+
+// transcodeCodecs are the video codecs the worker knows how to encode
+var transcodeCodecs = map[string]bool{"h264": true, "h265": true, "vp9": true}
+
+// TranscodeSpecRejection normalizes req.Transcode and checks it against cfg
+// Returns a message describing the problem, or "" when the spec is acceptable
+func TranscodeSpecRejection(req *model.DownloadRequest, cfg *model.TranscodeConfig) string {
+	spec := req.Transcode
+	spec.VideoCodec = strings.ToLower(strings.TrimSpace(spec.VideoCodec))
+	if !transcodeCodecAllowed(spec.VideoCodec, cfg.Codecs) {
+		return fmt.Sprintf("Video codec %q is not supported for transcoding", spec.VideoCodec)
+	}
+	if spec.MaxHeight <= 0 || spec.MaxHeight > cfg.MaxHeight {
+		return fmt.Sprintf("max_height must be between 1 and %d", cfg.MaxHeight)
+	}
+	if spec.VideoBitrateKbps < 0 || spec.VideoBitrateKbps > cfg.MaxBitrateKbps {
+		return fmt.Sprintf("video_bitrate_kbps must be between 0 and %d", cfg.MaxBitrateKbps)
+	}
+	if req.EmbedThumbnail {
+		return "embed_thumbnail can't be combined with transcode"
+	}
+	return ""
+}
+
+// transcodeCodecAllowed reports whether codec is both supported by the worker and in allowed
+func transcodeCodecAllowed(codec string, allowed []string) bool {
+	if !transcodeCodecs[codec] {
+		return false
+	}
+	for _, entry := range allowed {
+		if strings.EqualFold(strings.TrimSpace(entry), codec) {
+			return true
+		}
+	}
+	return false
+}
+
+// transcodePhasePollInterval is how often the worker is asked whether a transcode has started
+var transcodePhasePollInterval = time.Second
+
+// watchTranscodePhase polls the worker's /api/task/<taskID> while it handles a transcoding download
+// report is called with model.JobTranscoding once the worker starts re-encoding. The returned
+// func stops polling and must be called once the worker has answered
+func (s *DownloadService) watchTranscodePhase(ctx context.Context, taskID string, report PhaseFunc) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(transcodePhasePollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if s.taskPhase(ctx, taskID) == model.JobTranscoding {
+				report(model.JobTranscoding)
+				return
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// taskPhase returns the phase the worker reports for a transcoding download, "" when unknown
+// Workers running several processes may not know the task; the job then stays running
+func (s *DownloadService) taskPhase(ctx context.Context, taskID string) string {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, s.pythonWorkerURL+"/api/task/"+url.PathEscape(taskID), nil)
+	if err != nil {
+		return ""
+	}
+	resp, err := s.httpClient.Do(httpReq)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+
+	var task model.PythonWorkerTaskPhase
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&task) != nil {
+		return ""
+	}
+	return task.Phase
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"videodownload/internal/model"
+)
+
+func TestTranscodeJobPhase(t *testing.T) {
+	interval := transcodePhasePollInterval
+	transcodePhasePollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { transcodePhasePollInterval = interval })
+
+	// The fake worker downloads until startTranscode is closed, then transcodes until release is
+	startTranscode := make(chan struct{})
+	release := make(chan struct{})
+	var mu sync.Mutex
+	phases := map[string]string{}
+	s, _ := newTestDownloadService(t, func(w http.ResponseWriter, r *http.Request) {
+		if taskID, ok := strings.CutPrefix(r.URL.Path, "/api/task/"); ok {
+			mu.Lock()
+			phase, known := phases[taskID]
+			mu.Unlock()
+			if !known {
+				http.NotFound(w, r)
+				return
+			}
+			json.NewEncoder(w).Encode(model.PythonWorkerTaskPhase{TaskID: taskID, Phase: phase})
+			return
+		}
+
+		var body model.PythonWorkerDownloadRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		taskID := body.Transcode.TaskID
+		setPhase := func(phase string) {
+			mu.Lock()
+			phases[taskID] = phase
+			mu.Unlock()
+		}
+		setPhase("downloading")
+		<-startTranscode
+		setPhase("transcoding")
+		<-release
+
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Header().Set("X-Transcoded", "true")
+		w.Write(testMedia)
+	})
+	qs := NewQuotaService(&s.cfg.Quota, nil)
+	t.Cleanup(qs.Stop)
+	jm := NewJobManager(s, qs, s.cfg)
+
+	req := model.DownloadRequest{
+		URL:       "https://www.youtube.com/watch?v=abc123",
+		FormatID:  "18",
+		Transcode: &model.TranscodeSpec{VideoCodec: "h264", MaxHeight: 720},
+	}
+	started, rejection := jm.Start(req, "client", &model.LimitProfile{})
+	if rejection != nil {
+		t.Fatalf("Start: %+v", rejection)
+	}
+
+	waitForStatus := func(want string) *model.DownloadJob {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			job, _ := jm.GetJob(started.JobID, "client")
+			if job.Status == want {
+				return job
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("job status = %s, want %s", job.Status, want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	waitForStatus(model.JobRunning)
+	// While the worker is still downloading, polling keeps the job running
+	time.Sleep(5 * transcodePhasePollInterval)
+	if job, _ := jm.GetJob(started.JobID, "client"); job.Status != model.JobRunning {
+		t.Errorf("status while downloading = %s, want running", job.Status)
+	}
+
+	close(startTranscode)
+	waitForStatus(model.JobTranscoding)
+
+	close(release)
+	done := waitForStatus(model.JobDone)
+	if done.Download == nil || !done.Download.Transcoded {
+		t.Errorf("finished job = %+v, want a transcoded download", done.Download)
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"videodownload/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// ErrRedirectUnresolved is returned when a short URL's redirects can't be followed safely
+var ErrRedirectUnresolved = errors.New("redirects could not be resolved")
+
+// errPrivateAddress is returned by the resolver's dialer for non-public addresses
+var errPrivateAddress = errors.New("refusing to connect to a non-public address")
+
+// newPublicClient builds an HTTP client for URLs taken from user input or extracted pages
+// Every connection, including each redirect hop, is checked to go to a public address,
+// so DNS answers pointing at internal hosts are refused at dial time
+func newPublicClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return errPrivateAddress
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:       nil,
+			DialContext: dialer.DialContext,
+		},
+	}
+}
+
+// newRedirectClient builds the HTTP client used to follow short URL redirects
+func newRedirectClient(timeout time.Duration) *http.Client {
+	client := newPublicClient(timeout)
+	// Hops are followed one at a time by ResolveShortURL
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return client
+}
+
+// isPublicIP reports whether ip is a globally routable unicast address
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// isShortURLHost reports whether host is one of REDIRECT_RESOLVE_HOSTS or a subdomain of one
+func isShortURLHost(host string, shortHosts []string) bool {
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	for _, shortHost := range shortHosts {
+		shortHost = strings.ToLower(strings.TrimSpace(shortHost))
+		if shortHost != "" && (host == shortHost || strings.HasSuffix(host, "."+shortHost)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveShortURL follows the redirects of a short or share URL (e.g. fb.watch) so the final
+// host can be checked against the allowlist
+// URLs whose host isn't in REDIRECT_RESOLVE_HOSTS, or any URL when RESOLVE_REDIRECTS is off,
+// are returned unchanged. At most REDIRECT_MAX_DEPTH hops are followed; each hop must be
+// http(s) on a public address
+func (s *VideoService) ResolveShortURL(ctx context.Context, videoURL string) (string, error) {
+	cfg := &s.cfg.Security
+	if s.redirectClient == nil {
+		return videoURL, nil
+	}
+	u, err := url.Parse(strings.TrimSpace(videoURL))
+	if err != nil || !isShortURLHost(u.Hostname(), cfg.RedirectResolveHosts) {
+		return videoURL, nil
+	}
+
+	current := u
+	for hop := 0; hop < cfg.RedirectMaxDepth; hop++ {
+		if current.Scheme != "http" && current.Scheme != "https" {
+			return "", fmt.Errorf("%w: redirect to unsupported scheme %q", ErrRedirectUnresolved, current.Scheme)
+		}
+
+		// GET rather than HEAD: some share links only redirect page loads
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, current.String(), nil)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrRedirectUnresolved, err)
+		}
+		resp, err := s.redirectClient.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrRedirectUnresolved, err)
+		}
+		resp.Body.Close()
+
+		location := resp.Header.Get("Location")
+		if resp.StatusCode < 300 || resp.StatusCode >= 400 || location == "" {
+			if current.String() != videoURL {
+				logger.Logger.Info("Short URL resolved",
+					zap.String("url", videoURL),
+					zap.String("resolved", current.String()),
+					zap.Int("hops", hop))
+			}
+			return current.String(), nil
+		}
+
+		next, err := current.Parse(location)
+		if err != nil {
+			return "", fmt.Errorf("%w: invalid redirect location", ErrRedirectUnresolved)
+		}
+		current = next
+	}
+
+	return "", fmt.Errorf("%w: more than %d redirects", ErrRedirectUnresolved, cfg.RedirectMaxDepth)
+}
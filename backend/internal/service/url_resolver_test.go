@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"videodownload/internal/model"
+)
+
+// newTestRedirectStub serves redirects by Host and points the resolver's client at it,
+// so short hosts like fb.watch reach the stub without DNS
+func newTestRedirectStub(t *testing.T, s *VideoService, redirects map[string]string) *atomic.Int32 {
+	t.Helper()
+	var hops atomic.Int32
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hops.Add(1)
+		if location, ok := redirects[r.Host+r.URL.Path]; ok {
+			http.Redirect(w, r, location, http.StatusFound)
+			return
+		}
+		w.Write([]byte("<html>video page</html>"))
+	}))
+	t.Cleanup(stub.Close)
+
+	client := newRedirectClient(s.redirectClient.Timeout)
+	client.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, stub.Listener.Addr().String())
+		},
+	}
+	s.redirectClient = client
+	return &hops
+}
+
+func newTestResolver(t *testing.T, maxDepth int) *VideoService {
+	t.Helper()
+	cfg := &model.Config{}
+	cfg.Security.ResolveRedirects = true
+	cfg.Security.RedirectResolveHosts = []string{"fb.watch", "t.co"}
+	cfg.Security.RedirectMaxDepth = maxDepth
+	cfg.Security.RedirectTimeout = 2
+	return NewVideoService("127.0.0.1", 0, 1, cfg)
+}
+
+func TestResolveShortURL(t *testing.T) {
+	redirects := map[string]string{
+		"fb.watch/abc":  "http://www.facebook.com/watch/?v=123",
+		"t.co/chain":    "http://fb.watch/abc",
+		"fb.watch/loop": "http://fb.watch/loop",
+		"fb.watch/ftp":  "ftp://files.example.com/video.mp4",
+	}
+
+	tests := []struct {
+		name     string
+		url      string
+		want     string
+		wantHops int32
+		wantErr  bool
+	}{
+		{"short link resolves to its final host", "http://fb.watch/abc", "http://www.facebook.com/watch/?v=123", 2, false},
+		{"hops are followed in turn", "http://t.co/chain", "http://www.facebook.com/watch/?v=123", 3, false},
+		{"non-redirecting short link is kept", "http://fb.watch/plain", "http://fb.watch/plain", 1, false},
+		{"other hosts are not resolved", "https://www.youtube.com/watch?v=abc123", "https://www.youtube.com/watch?v=abc123", 0, false},
+		{"redirect loop stops at the depth limit", "http://fb.watch/loop", "", 3, true},
+		{"non-http hop is refused", "http://fb.watch/ftp", "", 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestResolver(t, 3)
+			hops := newTestRedirectStub(t, s, redirects)
+
+			got, err := s.ResolveShortURL(context.Background(), tt.url)
+			if tt.wantErr {
+				if !errors.Is(err, ErrRedirectUnresolved) {
+					t.Errorf("ResolveShortURL(%s) = %q, %v, want ErrRedirectUnresolved", tt.url, got, err)
+				}
+			} else if err != nil || got != tt.want {
+				t.Errorf("ResolveShortURL(%s) = %q, %v, want %q", tt.url, got, err, tt.want)
+			}
+			if n := hops.Load(); n != tt.wantHops {
+				t.Errorf("stub got %d requests, want %d", n, tt.wantHops)
+			}
+		})
+	}
+}
+
+func TestResolveShortURLDisabled(t *testing.T) {
+	cfg := &model.Config{}
+	cfg.Security.RedirectResolveHosts = []string{"fb.watch"}
+	s := NewVideoService("127.0.0.1", 0, 1, cfg)
+
+	if got, err := s.ResolveShortURL(context.Background(), "http://fb.watch/abc"); err != nil || got != "http://fb.watch/abc" {
+		t.Errorf("ResolveShortURL = %q, %v, want the URL unchanged with RESOLVE_REDIRECTS off", got, err)
+	}
+}
+
+func TestResolveShortURLRefusesPrivateAddresses(t *testing.T) {
+	var hits atomic.Int32
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer stub.Close()
+
+	s := newTestResolver(t, 3)
+	s.cfg.Security.RedirectResolveHosts = []string{"127.0.0.1"}
+
+	_, err := s.ResolveShortURL(context.Background(), stub.URL+"/abc")
+	if !errors.Is(err, ErrRedirectUnresolved) || !strings.Contains(err.Error(), errPrivateAddress.Error()) {
+		t.Errorf("ResolveShortURL(loopback) error = %v, want the non-public address refused", err)
+	}
+	if n := hits.Load(); n != 0 {
+		t.Errorf("loopback stub got %d requests, want none", n)
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1::1", true},
+		{"127.0.0.1", false},
+		{"10.0.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"0.0.0.0", false},
+		{"::1", false},
+		{"fd00::1", false},
+	}
+	for _, tt := range tests {
+		if got := isPublicIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("isPublicIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// timestampParams are the query parameters platforms use for a start offset
+var timestampParams = []string{"t", "start", "time_continue"}
+
+// timestampPattern matches offsets like 90, 90s, 1m30s and 1h2m3s
+var timestampPattern = regexp.MustCompile(`^(?:(\d+)h)?(?:(\d+)m)?(?:(\d+)s?)?$`)
+
+// StripTimestamp removes a start offset (?t=, &start=, #t=) from a video URL
+// Returns the URL without it and the offset in seconds (0 when the URL has none)
+// URLs that fail to parse are returned unchanged
+func StripTimestamp(rawURL string) (string, int) {
+	rawURL = strings.TrimSpace(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL, 0
+	}
+
+	start := 0
+	query := u.Query()
+	changed := false
+	for _, param := range timestampParams {
+		if !query.Has(param) {
+			continue
+		}
+		if seconds, ok := parseTimestamp(query.Get(param)); ok {
+			if start == 0 {
+				start = seconds
+			}
+			query.Del(param)
+			changed = true
+		}
+	}
+	if changed {
+		u.RawQuery = query.Encode()
+	}
+
+	if fragment, found := strings.CutPrefix(u.Fragment, "t="); found {
+		if seconds, ok := parseTimestamp(fragment); ok {
+			if start == 0 {
+				start = seconds
+			}
+			u.Fragment = ""
+			u.RawFragment = ""
+			changed = true
+		}
+	}
+
+	if !changed {
+		return rawURL, 0
+	}
+	return u.String(), start
+}
+
+// parseTimestamp converts an offset like 90, 90s or 1h2m3s to seconds
+func parseTimestamp(value string) (int, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	match := timestampPattern.FindStringSubmatch(value)
+	if match == nil || value == "" {
+		return 0, false
+	}
+
+	seconds := 0
+	for i, unit := range []int{3600, 60, 1} {
+		if match[i+1] != "" {
+			n, err := strconv.Atoi(match[i+1])
+			if err != nil {
+				return 0, false
+			}
+			seconds += n * unit
+		}
+	}
+	return seconds, true
+}
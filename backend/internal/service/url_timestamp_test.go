@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"videodownload/internal/model"
+)
+
+func TestStripTimestamp(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		wantURL   string
+		wantStart int
+	}{
+		{"?t= seconds", "https://youtu.be/abc123?t=90", "https://youtu.be/abc123", 90},
+		{"&t= with units", "https://www.youtube.com/watch?v=abc123&t=1m30s", "https://www.youtube.com/watch?v=abc123", 90},
+		{"&start=", "https://www.youtube.com/watch?v=abc123&start=45", "https://www.youtube.com/watch?v=abc123", 45},
+		{"#t=", "https://www.youtube.com/watch?v=abc123#t=1h2m3s", "https://www.youtube.com/watch?v=abc123", 3723},
+		{"no timestamp", "https://www.youtube.com/watch?v=abc123", "https://www.youtube.com/watch?v=abc123", 0},
+		{"other fragment kept", "https://www.youtube.com/watch?v=abc123#comments", "https://www.youtube.com/watch?v=abc123#comments", 0},
+		{"unparsable offset kept", "https://www.youtube.com/watch?v=abc123&t=soon", "https://www.youtube.com/watch?v=abc123&t=soon", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotURL, gotStart := StripTimestamp(tt.url)
+			if gotURL != tt.wantURL || gotStart != tt.wantStart {
+				t.Errorf("StripTimestamp(%q) = %q, %d; want %q, %d", tt.url, gotURL, gotStart, tt.wantURL, tt.wantStart)
+			}
+		})
+	}
+}
+
+func TestDeterministicIDIgnoresTimestamp(t *testing.T) {
+	plain := &model.DownloadRequest{URL: "https://www.youtube.com/watch?v=abc123", FormatID: "18"}
+	offset := &model.DownloadRequest{URL: "https://www.youtube.com/watch?v=abc123&t=90s", FormatID: "18"}
+
+	if deterministicDownloadID(plain, true) != deterministicDownloadID(offset, true) {
+		t.Error("with STRIP_URL_TIMESTAMPS, URLs differing only by offset got different IDs")
+	}
+	if deterministicDownloadID(plain, false) == deterministicDownloadID(offset, false) {
+		t.Error("without STRIP_URL_TIMESTAMPS, URLs differing by offset share an ID")
+	}
+}
+
+func TestVideoInfoStartTime(t *testing.T) {
+	provider, calls := newTestInfoProvider(t, http.StatusOK, `{"title": "Clip", "formats": [{"format_id": "18", "ext": "mp4", "height": 360, "vcodec": "avc1", "acodec": "mp4a"}]}`)
+	providerURL, _ := url.Parse(provider.URL)
+	port, _ := strconv.Atoi(providerURL.Port())
+	cfg := &model.Config{}
+	cfg.Python.StripURLTimestamps = true
+	cfg.Python.MetadataCacheTTL = 300
+	cfg.Python.MetadataCacheMaxEntries = 10
+	s := NewVideoService(providerURL.Hostname(), port, 5, cfg)
+
+	for _, tt := range []struct {
+		url       string
+		wantStart int
+	}{
+		{testVideoURL + "&t=90s", 90},
+		{testVideoURL + "#t=2m", 120},
+	} {
+		info, err := s.GetVideoInfo(context.Background(), tt.url, false)
+		if err != nil {
+			t.Fatalf("GetVideoInfo(%s): %v", tt.url, err)
+		}
+		if info.StartTime != tt.wantStart {
+			t.Errorf("start time of %s = %d, want %d", tt.url, info.StartTime, tt.wantStart)
+		}
+	}
+	// Both offsets share the cached metadata of the stripped URL
+	if n := calls.Load(); n != 1 {
+		t.Errorf("offsets of one video made %d info calls, want 1", n)
+	}
+}
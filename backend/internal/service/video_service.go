@@ -2,49 +2,741 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"videodownload/internal/model"
 	"videodownload/pkg/logger"
+	"videodownload/pkg/tracing"
 
 	"go.uber.org/zap"
 )
 
+// knownInfo is a recently fetched VideoInfo used as an authoritative size source
+type knownInfo struct {
+	info      *model.VideoInfo
+	fetchedAt time.Time
+}
+
 // VideoService handles video metadata extraction
 type VideoService struct {
 	pythonWorkerURL string
+	infoProviders   []string // Base URLs tried in order; the primary worker comes first
 	httpClient      *http.Client
 	cfg             *model.Config
+	knownInfos      map[string]*knownInfo
+	inflight        map[string]*metadataCall // Metadata fetches in progress, shared by identical URLs
+	redirectClient  *http.Client             // Follows short URL redirects; nil unless RESOLVE_REDIRECTS is on
+	verifyClient    *http.Client             // Probes format URLs; nil unless VERIFY_FORMATS_WORKERS is set
+	metadataCache   *metadataCache           // Recently fetched metadata; nil when METADATA_CACHE_TTL is 0
+	videoTokens     *videoTokenCodec         // Seals URLs into the video tokens of /api/video/info
+	infoPool        *workerPool              // Bounds info calls to the workers; nil when WORKER_INFO_CONCURRENCY is 0
+	mu              sync.RWMutex
+}
+
+// metadataCall is a metadata fetch whose result is shared by every caller asking for the same URL
+type metadataCall struct {
+	done     chan struct{} // Closed once metadata and err are set
+	metadata *model.VideoMetadata
+	err      error
 }
 
 // NewVideoService creates a new video service
 func NewVideoService(host string, port int, timeout int, cfg *model.Config) *VideoService {
-	return &VideoService{
-		pythonWorkerURL: fmt.Sprintf("http://%s:%d", host, port),
+	pythonWorkerURL := fmt.Sprintf("http://%s:%d", host, port)
+	infoProviders := append([]string{pythonWorkerURL}, cfg.Python.InfoFallbackURLs...)
+
+	vs := &VideoService{
+		pythonWorkerURL: pythonWorkerURL,
+		infoProviders:   infoProviders,
 		httpClient: &http.Client{
 			Timeout: time.Duration(timeout) * time.Second,
 		},
-		cfg: cfg,
+		cfg:        cfg,
+		knownInfos: make(map[string]*knownInfo),
+		inflight:   make(map[string]*metadataCall),
+
+		videoTokens: newVideoTokenCodec(cfg.Security.VideoTokenSecret),
+		infoPool:    newWorkerPool(cfg.Python.InfoConcurrency),
+	}
+	if cfg.Security.ResolveRedirects {
+		vs.redirectClient = newRedirectClient(time.Duration(cfg.Security.RedirectTimeout) * time.Second)
+	}
+	if cfg.Python.MetadataCacheTTL > 0 {
+		vs.metadataCache = newMetadataCache(time.Duration(cfg.Python.MetadataCacheTTL)*time.Second, cfg.Python.MetadataCacheMaxEntries)
 	}
+	if cfg.Python.VerifyFormatsWorkers > 0 {
+		vs.verifyClient = newPublicClient(time.Duration(cfg.Python.VerifyFormatsTimeout) * time.Second)
+	}
+	return vs
 }
 
 // GetVideoInfo fetches video information from yt-dlp worker
-func (s *VideoService) GetVideoInfo(videoURL string) (*model.VideoInfo, error) {
-	endpoint := s.pythonWorkerURL + "/api/info"
+// When verbose is true, description, tags and engagement counters are included
+func (s *VideoService) GetVideoInfo(ctx context.Context, videoURL string, verbose bool) (*model.VideoInfo, error) {
+	videoInfo, _, err := s.GetVideoInfoWithRaw(ctx, videoURL, verbose)
+	return videoInfo, err
+}
+
+// GetVideoInfoWithRaw is like GetVideoInfo but also returns the worker's metadata as received
+// The raw metadata may be shared with concurrent callers and must not be modified
+// A playlist URL fails with ErrPlaylist; its metadata can be passed to PlaylistFromMetadata
+func (s *VideoService) GetVideoInfoWithRaw(ctx context.Context, videoURL string, verbose bool) (*model.VideoInfo, *model.VideoMetadata, error) {
+	metadata, err := s.fetchMetadata(ctx, videoURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	if isPlaylist(metadata) {
+		return nil, metadata, ErrPlaylist
+	}
+	// Without formats the info is only worth returning when at least the title was extracted
+	if len(metadata.Formats) == 0 && metadata.Title == "" {
+		return nil, metadata, ErrInfoExtractionFailed
+	}
+
+	videoInfo := s.parseMetadata(*metadata, verbose)
+	if videoInfo.Platform == "" {
+		// The worker reported neither an extractor nor the video's URL
+		videoInfo.Platform = DetectPlatform("", videoURL)
+	}
+	if s.cfg.Python.StripURLTimestamps {
+		_, videoInfo.StartTime = StripTimestamp(videoURL)
+	}
+	s.rememberInfo(videoURL, videoInfo)
+	logger.Logger.Info("Video info retrieved", zap.String("title", videoInfo.Title), zap.Int("formats", len(videoInfo.Formats)))
+	return videoInfo, metadata, nil
+}
+
+// PrefetchResult is the outcome of prefetching the info of one URL
+type PrefetchResult struct {
+	Info *model.VideoInfo
+	Err  error
+}
+
+// PrefetchInfo fetches the info of several URLs with at most workers fetches in flight
+// URLs still being fetched at the deadline are left out of the result, as are duplicates' repeats
+func (s *VideoService) PrefetchInfo(ctx context.Context, urls []string, workers int, deadline time.Time) map[string]*PrefetchResult {
+	type fetched struct {
+		url    string
+		result *PrefetchResult
+	}
+
+	pending := make(chan string, len(urls))
+	seen := make(map[string]bool)
+	for _, videoURL := range urls {
+		if !seen[videoURL] {
+			seen[videoURL] = true
+			pending <- videoURL
+		}
+	}
+	close(pending)
+
+	// Buffered so workers never block once the deadline has passed
+	done := make(chan fetched, len(seen))
+	if workers > len(seen) {
+		workers = len(seen)
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for videoURL := range pending {
+				if time.Now().After(deadline) {
+					continue
+				}
+				info, err := s.GetVideoInfo(ctx, videoURL, false)
+				done <- fetched{url: videoURL, result: &PrefetchResult{Info: info, Err: err}}
+			}
+		}()
+	}
+
+	results := make(map[string]*PrefetchResult)
+	timeout := time.After(time.Until(deadline))
+	for len(results) < len(seen) {
+		select {
+		case item := <-done:
+			results[item.url] = item.result
+		case <-timeout:
+			logger.Logger.Warn("Info prefetch deadline reached", zap.Int("fetched", len(results)), zap.Int("urls", len(seen)))
+			return results
+		}
+	}
+	return results
+}
+
+// infoKey normalizes a video URL for caching and deduplication
+// With STRIP_URL_TIMESTAMPS, start offsets are dropped so every offset shares one entry
+func (s *VideoService) infoKey(videoURL string) string {
+	if s.cfg.Python.StripURLTimestamps {
+		stripped, _ := StripTimestamp(videoURL)
+		return stripped
+	}
+	return strings.TrimSpace(videoURL)
+}
+
+// rememberInfo records a fetched VideoInfo for later format, platform and size lookups
+// When KNOWN_INFO_MAX_ENTRIES is reached, expired entries are dropped first, then the oldest one
+func (s *VideoService) rememberInfo(videoURL string, videoInfo *model.VideoInfo) {
+	if s.cfg.Python.KnownInfoTTL <= 0 || s.cfg.Python.KnownInfoMaxEntries <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	key := s.infoKey(videoURL)
+	if _, exists := s.knownInfos[key]; !exists && len(s.knownInfos) >= s.cfg.Python.KnownInfoMaxEntries {
+		ttl := time.Duration(s.cfg.Python.KnownInfoTTL) * time.Second
+		oldestKey := ""
+		for k, entry := range s.knownInfos {
+			if now.Sub(entry.fetchedAt) > ttl {
+				delete(s.knownInfos, k)
+				continue
+			}
+			if oldestKey == "" || entry.fetchedAt.Before(s.knownInfos[oldestKey].fetchedAt) {
+				oldestKey = k
+			}
+		}
+		if len(s.knownInfos) >= s.cfg.Python.KnownInfoMaxEntries {
+			delete(s.knownInfos, oldestKey)
+		}
+	}
+
+	s.knownInfos[key] = &knownInfo{
+		info:      videoInfo,
+		fetchedAt: now,
+	}
+}
+
+// lookupInfo returns the VideoInfo of a video fetched within KNOWN_INFO_TTL
+func (s *VideoService) lookupInfo(videoURL string) (*knownInfo, bool) {
+	s.mu.RLock()
+	entry, exists := s.knownInfos[s.infoKey(videoURL)]
+	s.mu.RUnlock()
+
+	if !exists || time.Since(entry.fetchedAt) > time.Duration(s.cfg.Python.KnownInfoTTL)*time.Second {
+		return nil, false
+	}
+	return entry, true
+}
+
+// GetKnownFormatSize returns the authoritative size of a format from a recently fetched VideoInfo
+// Returns false when no info younger than FILESIZE_HINT_MAX_AGE is known or the format size is unknown
+func (s *VideoService) GetKnownFormatSize(videoURL string, formatID string) (int64, bool) {
+	entry, ok := s.lookupInfo(videoURL)
+	if !ok || time.Since(entry.fetchedAt) > time.Duration(s.cfg.Security.FileSizeHintMaxAge)*time.Second {
+		return 0, false
+	}
+	for _, format := range entry.info.Formats {
+		if format.FormatID == formatID && format.FileSize > 0 {
+			return format.FileSize, true
+		}
+	}
+	return 0, false
+}
+
+// GetKnownFormatExtension returns the container extension of a format from a recently fetched VideoInfo
+func (s *VideoService) GetKnownFormatExtension(videoURL string, formatID string) (string, bool) {
+	format, ok := s.knownFormat(videoURL, formatID)
+	if !ok || format.Extension == "" {
+		return "", false
+	}
+	return format.Extension, true
+}
 
-	reqBody := map[string]string{"url": videoURL}
-	bodyBytes, _ := json.Marshal(reqBody)
+// IsKnownGeoRestricted reports whether a recently fetched VideoInfo marked the format as geo-restricted
+// Formats without fresh info are treated as available
+func (s *VideoService) IsKnownGeoRestricted(videoURL string, formatID string) bool {
+	format, ok := s.knownFormat(videoURL, formatID)
+	return ok && format.GeoRestricted
+}
+
+// FormatQuality returns the quality category of a video's format as the worker reports it
+// Recently fetched info is used when available, otherwise the info is fetched; "" means the
+// format is not among the video's formats of enabled categories
+func (s *VideoService) FormatQuality(ctx context.Context, videoURL string, formatID string) (string, error) {
+	if format, ok := s.knownFormat(videoURL, formatID); ok {
+		return format.Quality, nil
+	}
 
-	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(bodyBytes))
+	info, err := s.GetVideoInfo(ctx, videoURL, false)
+	if err != nil {
+		return "", err
+	}
+	for _, format := range info.Formats {
+		if format.FormatID == formatID {
+			return format.Quality, nil
+		}
+	}
+	return "", nil
+}
+
+// GetKnownFormat returns a format from a recently fetched VideoInfo
+func (s *VideoService) GetKnownFormat(videoURL string, formatID string) (model.FormatOption, bool) {
+	return s.knownFormat(videoURL, formatID)
+}
+
+// GetKnownDuration returns the duration in seconds of a recently fetched video
+func (s *VideoService) GetKnownDuration(videoURL string) (int, bool) {
+	entry, exists := s.lookupInfo(videoURL)
+	if !exists || entry.info.Duration <= 0 {
+		return 0, false
+	}
+	return entry.info.Duration, true
+}
+
+// GetPlatform returns the platform of a video, preferring a recently fetched VideoInfo over the URL's domain
+func (s *VideoService) GetPlatform(videoURL string) string {
+	if entry, exists := s.lookupInfo(videoURL); exists && entry.info.Platform != "" {
+		return entry.info.Platform
+	}
+	return DetectPlatform("", videoURL)
+}
+
+// SuggestMuxedFormat returns the ID of a recently fetched format with both audio and video
+// in the given quality category, or "" when none is known
+func (s *VideoService) SuggestMuxedFormat(videoURL string, quality string) string {
+	entry, exists := s.lookupInfo(videoURL)
+	if !exists {
+		return ""
+	}
+
+	for _, format := range entry.info.Formats {
+		if HasVideo(format) && HasAudio(format) && (quality == "" || format.Quality == quality) {
+			return format.FormatID
+		}
+	}
+	return ""
+}
+
+// HasVideo reports whether a format carries a video track
+func HasVideo(format model.FormatOption) bool {
+	return format.VideoCodec != "" && format.VideoCodec != "none"
+}
+
+// HasAudio reports whether a format carries an audio track
+func HasAudio(format model.FormatOption) bool {
+	return format.AudioCodec != "" && format.AudioCodec != "none"
+}
+
+// freeVideoCodecs and freeAudioCodecs are royalty-free codec prefixes as reported by yt-dlp
+var (
+	freeVideoCodecs = []string{"vp8", "vp9", "vp09", "av01", "av1", "theora"}
+	freeAudioCodecs = []string{"opus", "vorbis", "flac"}
+)
+
+// isMP4Format reports whether a format comes in an mp4 or m4a container
+func isMP4Format(format model.FormatOption) bool {
+	switch strings.ToLower(format.Extension) {
+	case "mp4", "m4a":
+		return true
+	}
+	return false
+}
+
+// IsFreeFormat reports whether a format uses only royalty-free codecs
+// When the codecs are unknown the container decides (webm, ogg, opus)
+func IsFreeFormat(format model.FormatOption) bool {
+	if !HasVideo(format) && !HasAudio(format) {
+		switch strings.ToLower(format.Extension) {
+		case "webm", "ogg", "oga", "opus":
+			return true
+		}
+		return false
+	}
+	if HasVideo(format) && !hasCodecPrefix(format.VideoCodec, freeVideoCodecs) {
+		return false
+	}
+	if HasAudio(format) && !hasCodecPrefix(format.AudioCodec, freeAudioCodecs) {
+		return false
+	}
+	return true
+}
+
+func hasCodecPrefix(codec string, prefixes []string) bool {
+	codec = strings.ToLower(codec)
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(codec, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Quality bound rejection codes returned by CheckQualityBounds
+const (
+	QualityTooLow  = "quality_too_low"
+	QualityTooHigh = "quality_too_high"
+	QualityUnknown = "quality_unknown"
+)
+
+// HasQualityBounds reports whether MIN_QUALITY or MAX_QUALITY is set
+func HasQualityBounds(qc *model.QualityCategoriesConfig) bool {
+	return qc.MinQuality != "" || qc.MaxQuality != ""
+}
+
+// CheckQualityBounds checks a format's quality category against MIN_QUALITY / MAX_QUALITY
+// Returns "" when allowed, otherwise QualityTooLow, QualityTooHigh, or QualityUnknown for a
+// category that can't be ranked while bounds are set. Audio is never bounded
+func CheckQualityBounds(quality string, qc *model.QualityCategoriesConfig) string {
+	if quality == "Audio" || !HasQualityBounds(qc) {
+		return ""
+	}
+	rank := QualityRank(quality)
+	if rank == 0 {
+		return QualityUnknown
+	}
+	if qc.MinQuality != "" && rank < QualityRank(qc.MinQuality) {
+		return QualityTooLow
+	}
+	if qc.MaxQuality != "" && rank > QualityRank(qc.MaxQuality) {
+		return QualityTooHigh
+	}
+	return ""
+}
+
+// supportedProtocols are the yt-dlp protocols the worker can download; manifest protocols are
+// matched by IsManifestProtocol
+var supportedProtocols = map[string]bool{
+	"": true, "http": true, "https": true,
+}
+
+// IsSupportedProtocol reports whether the worker can download a format of the yt-dlp protocol
+// Merged formats report their parts joined by "+", e.g. m3u8_native+https
+func IsSupportedProtocol(protocol string) bool {
+	for _, part := range strings.Split(protocol, "+") {
+		if !supportedProtocols[part] && !IsManifestProtocol(part) {
+			return false
+		}
+	}
+	return true
+}
+
+// UnavailableReason returns the error code of a format that can't be downloaded at all:
+// "drm_protected", "live_stream" or "unsupported_protocol", or "" when it can
+func UnavailableReason(format model.FormatOption) string {
+	switch {
+	case format.HasDRM:
+		return "drm_protected"
+	case format.IsLive:
+		return "live_stream"
+	case !IsSupportedProtocol(format.Protocol):
+		return "unsupported_protocol"
+	}
+	return ""
+}
+
+// DownloadRejection returns the error code StartDownload would reject the format with when
+// requested under the given quality category, or "" when it would be accepted
+// An empty quality stands for the format's own category, as when a request omits it
+func DownloadRejection(format model.FormatOption, quality string, qc *model.QualityCategoriesConfig, maxVideoSizeMB int) string {
+	if reason := CheckQualityBounds(format.Quality, qc); reason != "" {
+		return reason
+	}
+	if format.GeoRestricted {
+		return "geo_blocked"
+	}
+	if reason := UnavailableReason(format); reason != "" {
+		return reason
+	}
+
+	hasVideo, hasAudio := HasVideo(format), HasAudio(format)
+	if qc.RejectVideoOnly && hasVideo && !hasAudio {
+		return "incomplete_format"
+	}
+	if qc.RejectAudioOnly && hasAudio && !hasVideo && QualityRank(quality) > 0 {
+		return "incomplete_format"
+	}
+
+	if format.FileSize > int64(maxVideoSizeMB)*1024*1024 {
+		return "file_too_large"
+	}
+	return ""
+}
+
+// ErrNoFormatForQuality is returned when a video has no format in the requested quality category
+var ErrNoFormatForQuality = errors.New("no format available for the requested quality")
+
+// ErrPlaylist is returned when single-video info is requested for a playlist URL
+var ErrPlaylist = errors.New("url is a playlist")
+
+// ErrNotPlaylist is returned when playlist info is requested for a single video
+var ErrNotPlaylist = errors.New("url is not a playlist")
+
+// ErrInfoExtractionFailed is returned when the worker extracted neither formats nor a title
+var ErrInfoExtractionFailed = errors.New("no video information could be extracted")
+
+// ResolveFormatForQuality picks the best format of a quality category for a video
+// Video categories prefer formats with both tracks, Audio prefers audio-only ones;
+// next come royalty-free formats with PREFER_FREE_FORMATS, mp4/m4a without it;
+// remaining ties go to the higher bitrate, then the larger file
+func (s *VideoService) ResolveFormatForQuality(ctx context.Context, videoURL string, quality string) (model.FormatOption, error) {
+	var info *model.VideoInfo
+	if entry, exists := s.lookupInfo(videoURL); exists {
+		info = entry.info
+	} else {
+		fetched, err := s.GetVideoInfo(ctx, videoURL, false)
+		if err != nil {
+			return model.FormatOption{}, err
+		}
+		info = fetched
+	}
+	return s.BestFormatForQuality(info, quality)
+}
+
+// BestFormatForQuality picks the best format of a quality category from already fetched info,
+// by the same preferences as ResolveFormatForQuality
+func (s *VideoService) BestFormatForQuality(info *model.VideoInfo, quality string) (model.FormatOption, error) {
+	preferred := func(format model.FormatOption) bool {
+		if quality == "Audio" {
+			return !HasVideo(format)
+		}
+		return HasVideo(format) && HasAudio(format)
+	}
+
+	favored := isMP4Format
+	if s.cfg.QualityCategories.PreferFreeFormats {
+		favored = IsFreeFormat
+	}
+
+	var best *model.FormatOption
+	for i := range info.Formats {
+		format := &info.Formats[i]
+		if format.Quality != quality {
+			continue
+		}
+		if best == nil {
+			best = format
+			continue
+		}
+		if preferred(*format) != preferred(*best) {
+			if preferred(*format) {
+				best = format
+			}
+			continue
+		}
+		if favored(*format) != favored(*best) {
+			if favored(*format) {
+				best = format
+			}
+			continue
+		}
+		if format.Bitrate != best.Bitrate {
+			if format.Bitrate > best.Bitrate {
+				best = format
+			}
+			continue
+		}
+		if format.FileSize > best.FileSize {
+			best = format
+		}
+	}
+
+	if best == nil {
+		return model.FormatOption{}, ErrNoFormatForQuality
+	}
+	return *best, nil
+}
+
+// knownFormat looks up a format in a recently fetched VideoInfo
+func (s *VideoService) knownFormat(videoURL string, formatID string) (model.FormatOption, bool) {
+	entry, exists := s.lookupInfo(videoURL)
+	if !exists {
+		return model.FormatOption{}, false
+	}
+
+	for _, format := range entry.info.Formats {
+		if format.FormatID == formatID {
+			return format, true
+		}
+	}
+
+	return model.FormatOption{}, false
+}
+
+// GetManifest returns the HLS/DASH manifest URL of a manifest-based format
+func (s *VideoService) GetManifest(ctx context.Context, videoURL string, formatID string) (*model.ManifestResponse, error) {
+	metadata, err := s.fetchMetadata(ctx, videoURL)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rawFmt := range metadata.Formats {
+		if id, _ := rawFmt["format_id"].(string); id != formatID {
+			continue
+		}
+
+		protocol, _ := rawFmt["protocol"].(string)
+		if !IsManifestProtocol(protocol) {
+			return nil, fmt.Errorf("format %s is not manifest-based", formatID)
+		}
+
+		manifestURL, _ := rawFmt["manifest_url"].(string)
+		if manifestURL == "" {
+			return nil, fmt.Errorf("worker did not provide a manifest URL for format %s", formatID)
+		}
+
+		return &model.ManifestResponse{
+			FormatID:    formatID,
+			Protocol:    protocol,
+			ManifestURL: manifestURL,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("format %s not found", formatID)
+}
+
+// IsManifestProtocol reports whether a yt-dlp protocol is HLS or DASH based
+func IsManifestProtocol(protocol string) bool {
+	return strings.HasPrefix(protocol, "m3u8") || strings.Contains(protocol, "dash")
+}
+
+// metadataContainers are the containers that can carry embedded title/artist/date tags
+var metadataContainers = map[string]bool{
+	"mp4": true, "m4a": true, "mov": true, "mkv": true, "mka": true,
+	"webm": true, "mp3": true, "ogg": true, "opus": true, "flac": true,
+}
+
+// coverArtContainers are the containers that can carry an embedded thumbnail as cover art
+var coverArtContainers = map[string]bool{
+	"mp4": true, "m4a": true, "mov": true, "mkv": true, "mka": true,
+	"mp3": true, "ogg": true, "opus": true, "flac": true,
+}
+
+// SupportsEmbeddedMetadata reports whether metadata tags can be embedded in the container
+func SupportsEmbeddedMetadata(ext string) bool {
+	return metadataContainers[strings.ToLower(ext)]
+}
+
+// SupportsCoverArt reports whether a thumbnail can be embedded as cover art in the container
+func SupportsCoverArt(ext string) bool {
+	return coverArtContainers[strings.ToLower(ext)]
+}
+
+// fetchMetadata requests raw video metadata, joining a fetch already in progress for the same URL
+// The shared fetch is not cancelled by any one caller; each caller stops waiting when its ctx is done
+// Complete results are cached for METADATA_CACHE_TTL
+func (s *VideoService) fetchMetadata(ctx context.Context, videoURL string) (*model.VideoMetadata, error) {
+	key := s.infoKey(videoURL)
+
+	if s.metadataCache != nil {
+		if metadata, ok := s.metadataCache.get(key); ok {
+			logger.Logger.Info("Video info served from cache", zap.String("url", videoURL), zap.Bool("cache_hit", true))
+			return metadata, nil
+		}
+		logger.Logger.Debug("Video info not cached", zap.String("url", videoURL), zap.Bool("cache_hit", false))
+	}
+
+	s.mu.Lock()
+	call, joined := s.inflight[key]
+	if !joined {
+		call = &metadataCall{done: make(chan struct{})}
+		s.inflight[key] = call
+	}
+	s.mu.Unlock()
+
+	if joined {
+		logger.Logger.Debug("Joining in-flight info fetch", zap.String("url", videoURL))
+	} else {
+		go func() {
+			call.metadata, call.err = s.fetchMetadataFromProviders(context.WithoutCancel(ctx), key)
+			if call.err == nil && s.metadataCache != nil && isCacheable(call.metadata) {
+				s.metadataCache.put(key, call.metadata)
+			}
+
+			s.mu.Lock()
+			delete(s.inflight, key)
+			s.mu.Unlock()
+			close(call.done)
+		}()
+	}
+
+	select {
+	case <-call.done:
+		return call.metadata, call.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("failed to fetch video info: %w", ctx.Err())
+	}
+}
+
+// isCacheable reports whether metadata is complete enough to be served again from the cache
+// Partial results may stem from a transient worker problem, so they are always refetched
+func isCacheable(metadata *model.VideoMetadata) bool {
+	if len(metadata.Warnings) > 0 {
+		return false
+	}
+	return len(metadata.Formats) > 0 || isPlaylist(metadata)
+}
+
+// InvalidateInfo drops the cached metadata and known formats of a URL so the next request refetches them
+func (s *VideoService) InvalidateInfo(videoURL string) {
+	s.mu.Lock()
+	delete(s.knownInfos, s.infoKey(videoURL))
+	s.mu.Unlock()
+
+	if s.metadataCache != nil && s.metadataCache.invalidate(s.infoKey(videoURL)) {
+		logger.Logger.Info("Video info cache entry invalidated", zap.String("url", videoURL))
+	}
+}
+
+// fetchMetadataFromProviders requests raw video metadata, trying each info provider in order
+func (s *VideoService) fetchMetadataFromProviders(ctx context.Context, videoURL string) (*model.VideoMetadata, error) {
+	release, err := s.infoPool.acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch video info: %w", err)
+	}
+	defer release()
+
+	var lastErr error
+	for i, provider := range s.infoProviders {
+		metadata, err := s.fetchMetadataFrom(ctx, provider, videoURL)
+		if err == nil {
+			if i > 0 {
+				logger.Logger.Info("Video info served by fallback provider",
+					zap.String("provider", provider),
+					zap.String("url", videoURL))
+			}
+			return metadata, nil
+		}
+
+		lastErr = err
+		if i < len(s.infoProviders)-1 {
+			logger.Logger.Warn("Info provider failed, trying next",
+				zap.String("provider", provider),
+				zap.Error(err))
+		}
+	}
+	return nil, lastErr
+}
+
+// fetchMetadataFrom requests raw video metadata from a single info provider
+func (s *VideoService) fetchMetadataFrom(ctx context.Context, provider string, videoURL string) (_ *model.VideoMetadata, err error) {
+	endpoint := provider + "/api/info"
+
+	ctx, span := tracing.StartClient(ctx, "worker.info")
+	span.SetAttribute("worker.endpoint", endpoint)
+	defer func() { span.End(err) }()
+
+	bodyBytes, _ := json.Marshal(model.PythonWorkerInfoRequest{
+		Version:    model.PythonWorkerRequestVersion,
+		URL:        videoURL,
+		MaxEntries: s.cfg.Python.MaxPlaylistEntries,
+	})
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(bodyBytes))
 	if err != nil {
 		logger.Logger.Error("Failed to create request", zap.Error(err))
 		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, req.Header)
 
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
@@ -64,13 +756,11 @@ func (s *VideoService) GetVideoInfo(videoURL string) (*model.VideoInfo, error) {
 		return nil, err
 	}
 
-	videoInfo := s.parseMetadata(metadata)
-	logger.Logger.Info("Video info retrieved", zap.String("title", videoInfo.Title), zap.Int("formats", len(videoInfo.Formats)))
-	return videoInfo, nil
+	return &metadata, nil
 }
 
 // parseMetadata converts raw metadata to VideoInfo
-func (s *VideoService) parseMetadata(metadata model.VideoMetadata) *model.VideoInfo {
+func (s *VideoService) parseMetadata(metadata model.VideoMetadata, verbose bool) *model.VideoInfo {
 	formats := []model.FormatOption{}
 
 	// Build set of enabled categories for quick lookup
@@ -82,17 +772,131 @@ func (s *VideoService) parseMetadata(metadata model.VideoMetadata) *model.VideoI
 	for _, fmt := range metadata.Formats {
 		format := s.parseFormat(fmt)
 		if format != nil && enabledCategoriesMap[format.Quality] {
+			format.IsLive = metadata.IsLive
 			formats = append(formats, *format)
 		}
 	}
 
-	return &model.VideoInfo{
+	videoInfo := &model.VideoInfo{
 		URL:          metadata.URL,
 		Title:        metadata.Title,
 		Duration:     int(metadata.Duration),
 		ThumbnailURL: metadata.Thumbnail,
+		Thumbnails:   parseThumbnails(metadata.Thumbnails),
 		Uploader:     metadata.Uploader,
+		Extractor:    metadata.ExtractorKey,
 		Formats:      formats,
+		IsLive:       metadata.IsLive,
+	}
+	if videoInfo.Extractor == "" {
+		videoInfo.Extractor = metadata.Extractor
+	}
+	videoInfo.Platform = DetectPlatform(videoInfo.Extractor, metadata.URL)
+
+	if len(metadata.Warnings) > 0 || len(metadata.Formats) == 0 {
+		videoInfo.Partial = true
+		videoInfo.Warnings = metadata.Warnings
+		if len(videoInfo.Warnings) == 0 {
+			videoInfo.Warnings = []string{"format extraction failed"}
+		}
+	}
+
+	// Keep thumbnail_url populated for older clients when only the list is reported
+	if videoInfo.ThumbnailURL == "" && len(videoInfo.Thumbnails) > 0 {
+		videoInfo.ThumbnailURL = videoInfo.Thumbnails[len(videoInfo.Thumbnails)-1].URL
+	}
+
+	if verbose {
+		applyVerboseMetadata(videoInfo, metadata)
+	}
+
+	return videoInfo
+}
+
+// parseThumbnails drops thumbnails without a URL and sorts the rest by resolution, smallest first
+// Thumbnails of equal resolution (including unknown dimensions) are ordered by preference
+func parseThumbnails(raw []model.Thumbnail) []model.Thumbnail {
+	thumbnails := []model.Thumbnail{}
+	for _, thumbnail := range raw {
+		if thumbnail.URL != "" {
+			thumbnails = append(thumbnails, thumbnail)
+		}
+	}
+
+	sort.SliceStable(thumbnails, func(i, j int) bool {
+		areaI := thumbnails[i].Width * thumbnails[i].Height
+		areaJ := thumbnails[j].Width * thumbnails[j].Height
+		if areaI != areaJ {
+			return areaI < areaJ
+		}
+		return thumbnails[i].Preference < thumbnails[j].Preference
+	})
+	return thumbnails
+}
+
+// LimitFormatsPerQuality keeps at most limit formats of each quality category
+// The best formats win: higher fps, then higher bitrate, then larger file, then format ID
+// as a deterministic tie-break. Kept formats stay in their original order
+func LimitFormatsPerQuality(formats []model.FormatOption, limit int) []model.FormatOption {
+	if limit <= 0 {
+		return formats
+	}
+
+	ranked := make([]int, len(formats))
+	for i := range ranked {
+		ranked[i] = i
+	}
+	sort.SliceStable(ranked, func(a, b int) bool {
+		fa, fb := formats[ranked[a]], formats[ranked[b]]
+		if fa.Fps != fb.Fps {
+			return fa.Fps > fb.Fps
+		}
+		if fa.Bitrate != fb.Bitrate {
+			return fa.Bitrate > fb.Bitrate
+		}
+		if fa.FileSize != fb.FileSize {
+			return fa.FileSize > fb.FileSize
+		}
+		return fa.FormatID < fb.FormatID
+	})
+
+	keep := make([]bool, len(formats))
+	perQuality := make(map[string]int)
+	for _, i := range ranked {
+		if perQuality[formats[i].Quality] < limit {
+			perQuality[formats[i].Quality]++
+			keep[i] = true
+		}
+	}
+
+	limited := make([]model.FormatOption, 0, len(formats))
+	for i, format := range formats {
+		if keep[i] {
+			limited = append(limited, format)
+		}
+	}
+	return limited
+}
+
+// applyVerboseMetadata copies optional descriptive fields into VideoInfo
+// Missing fields from the worker are left empty and omitted from JSON
+func applyVerboseMetadata(videoInfo *model.VideoInfo, metadata model.VideoMetadata) {
+	videoInfo.Description = metadata.Description
+	videoInfo.UploadDate = metadata.UploadDate
+
+	for _, tag := range metadata.Tags {
+		if tag != "" {
+			videoInfo.Tags = append(videoInfo.Tags, tag)
+		}
+	}
+
+	if metadata.ViewCount != nil {
+		viewCount := int64(*metadata.ViewCount)
+		videoInfo.ViewCount = &viewCount
+	}
+	if metadata.LikeCount != nil {
+		likeCount := int64(*metadata.LikeCount)
+		videoInfo.LikeCount = &likeCount
 	}
 }
 
@@ -129,9 +933,23 @@ func (s *VideoService) parseFormat(rawFmt map[string]interface{}) *model.FormatO
 	if v, ok := rawFmt["fps"].(float64); ok {
 		format.Fps = int(v)
 	}
+	if v, ok := rawFmt["tbr"].(float64); ok {
+		format.Bitrate = v
+	}
+	if v, ok := rawFmt["protocol"].(string); ok {
+		format.Protocol = v
+	}
+	// Formats are assumed available unless the worker reports otherwise
+	if v, ok := rawFmt["geo_restricted"].(bool); ok {
+		format.GeoRestricted = v
+	}
+	if v, ok := rawFmt["has_drm"].(bool); ok {
+		format.HasDRM = v
+	}
 
 	format.Quality = s.determineQuality(format)
 	format.OfficialName = s.buildOfficialName(format)
+	format.AspectRatio, format.Orientation = aspectRatio(format.Resolution)
 
 	return format
 }
@@ -173,6 +991,23 @@ func (s *VideoService) determineQuality(format *model.FormatOption) string {
 	}
 }
 
+// QualityRank returns the position of a video quality category from lowest (FD) to highest (FHD)
+// Audio and unknown categories are not ranked and return 0
+func QualityRank(quality string) int {
+	switch quality {
+	case "FD":
+		return 1
+	case "SD":
+		return 2
+	case "HD":
+		return 3
+	case "FHD":
+		return 4
+	default:
+		return 0
+	}
+}
+
 // normalizeByHeight determines quality category by extracting height from resolution
 func normalizeByHeight(resolution string) string {
 	// Try to extract height from resolution string like "640x360"
@@ -220,10 +1055,109 @@ func parseResolutionHeight(parts []string) int {
 	return height
 }
 
-// buildOfficialName builds a readable format name
+// commonAspectRatios are snapped to when a resolution is within 2% of them,
+// so e.g. 854x480 reports 16:9 instead of 427:240
+var commonAspectRatios = [][2]int{
+	{16, 9}, {9, 16}, {4, 3}, {3, 4}, {1, 1}, {21, 9}, {3, 2}, {2, 3}, {5, 4}, {4, 5},
+}
+
+// aspectRatio derives the aspect ratio and orientation of a "WIDTHxHEIGHT" resolution
+// Returns empty strings when either dimension is missing or zero
+func aspectRatio(resolution string) (string, string) {
+	parts := parseResolution(resolution)
+	if len(parts) < 2 {
+		return "", ""
+	}
+
+	var width int
+	fmt.Sscanf(parts[0], "%d", &width)
+	height := parseResolutionHeight(parts)
+	if width <= 0 || height <= 0 {
+		return "", ""
+	}
+
+	orientation := "square"
+	if width > height {
+		orientation = "landscape"
+	} else if height > width {
+		orientation = "portrait"
+	}
+
+	ratio := float64(width) / float64(height)
+	for _, common := range commonAspectRatios {
+		target := float64(common[0]) / float64(common[1])
+		if diff := ratio/target - 1; diff > -0.02 && diff < 0.02 {
+			return fmt.Sprintf("%d:%d", common[0], common[1]), orientation
+		}
+	}
+
+	divisor := gcd(width, height)
+	return fmt.Sprintf("%d:%d", width/divisor, height/divisor), orientation
+}
+
+// gcd returns the greatest common divisor of two positive integers
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// QualityLabel returns the display label of a quality category, or the category itself when unlabeled
+func QualityLabel(quality string, labels map[string]string) string {
+	if label, ok := labels[quality]; ok {
+		return label
+	}
+	return quality
+}
+
+// QualityCategory maps a display label back to its quality category
+// Categories and unknown values are returned unchanged
+func QualityCategory(quality string, labels map[string]string) string {
+	for category, label := range labels {
+		if strings.EqualFold(quality, label) {
+			return category
+		}
+	}
+	return quality
+}
+
+// LabelFormats returns a copy of formats with the quality field replaced by its display label
+// Filtering and grouping must run on the unlabeled formats
+func LabelFormats(formats []model.FormatOption, labels map[string]string) []model.FormatOption {
+	if len(labels) == 0 {
+		return formats
+	}
+
+	labeled := make([]model.FormatOption, len(formats))
+	for i, format := range formats {
+		format.Quality = QualityLabel(format.Quality, labels)
+		labeled[i] = format
+	}
+	return labeled
+}
+
+// maxOfficialNameLength bounds the length of OfficialName in characters
+const maxOfficialNameLength = 80
+
+// buildOfficialName builds a readable format name using the configured quality label
 func (s *VideoService) buildOfficialName(format *model.FormatOption) string {
+	label := QualityLabel(format.Quality, s.cfg.QualityCategories.Labels)
+
+	var name string
 	if format.Quality == "Audio" {
-		return fmt.Sprintf("%s - %s", format.Quality, format.AudioCodec)
+		name = fmt.Sprintf("%s - %s", label, format.AudioCodec)
+	} else {
+		name = fmt.Sprintf("%s (%s) - %s + %s", label, format.Resolution, format.VideoCodec, format.AudioCodec)
+	}
+	return truncateRunes(name, maxOfficialNameLength)
+}
+
+// truncateRunes shortens s to at most maxLen runes, marking the cut with an ellipsis
+func truncateRunes(s string, maxLen int) string {
+	runes := []rune(s)
+	if len(runes) <= maxLen {
+		return s
 	}
-	return fmt.Sprintf("%s (%s) - %s + %s", format.Quality, format.Resolution, format.VideoCodec, format.AudioCodec)
+	return string(runes[:maxLen-1]) + "…"
 }
@@ -0,0 +1,564 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"videodownload/internal/model"
+)
+
+const testVideoURL = "https://www.youtube.com/watch?v=abc123"
+
+// newTestVideoService returns a VideoService that already knows info for testVideoURL
+func newTestVideoService(t *testing.T, cfg *model.Config, formats []model.FormatOption) *VideoService {
+	t.Helper()
+	cfg.Security.FileSizeHintMaxAge = 300
+	cfg.Python.KnownInfoTTL = 300
+	cfg.Python.KnownInfoMaxEntries = 10
+	s := NewVideoService("127.0.0.1", 0, 1, cfg)
+	s.rememberInfo(testVideoURL, &model.VideoInfo{URL: testVideoURL, Formats: formats})
+	return s
+}
+
+func TestResolveFormatForQualityPreferFreeFormats(t *testing.T) {
+	formats := []model.FormatOption{
+		{FormatID: "22", Extension: "mp4", VideoCodec: "avc1.64001F", AudioCodec: "mp4a.40.2", Quality: "HD", Bitrate: 1500},
+		{FormatID: "43", Extension: "webm", VideoCodec: "vp9", AudioCodec: "opus", Quality: "HD", Bitrate: 2000},
+		{FormatID: "136", Extension: "mp4", VideoCodec: "avc1.4d401f", AudioCodec: "none", Quality: "HD", Bitrate: 3000},
+		{FormatID: "140", Extension: "m4a", VideoCodec: "none", AudioCodec: "mp4a.40.2", Quality: "Audio", Bitrate: 128},
+		{FormatID: "251", Extension: "webm", VideoCodec: "none", AudioCodec: "opus", Quality: "Audio", Bitrate: 160},
+	}
+
+	tests := []struct {
+		name       string
+		preferFree bool
+		quality    string
+		wantFormat string
+	}{
+		{"video defaults to mp4", false, "HD", "22"},
+		{"video prefers free formats with the flag", true, "HD", "43"},
+		{"audio defaults to m4a", false, "Audio", "140"},
+		{"audio prefers free formats with the flag", true, "Audio", "251"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &model.Config{}
+			cfg.QualityCategories.PreferFreeFormats = tt.preferFree
+			s := newTestVideoService(t, cfg, formats)
+
+			format, err := s.ResolveFormatForQuality(context.Background(), testVideoURL, tt.quality)
+			if err != nil {
+				t.Fatalf("ResolveFormatForQuality: %v", err)
+			}
+			if format.FormatID != tt.wantFormat {
+				t.Errorf("format = %s, want %s", format.FormatID, tt.wantFormat)
+			}
+		})
+	}
+}
+
+func TestResolveFormatForQualityNoFormat(t *testing.T) {
+	s := newTestVideoService(t, &model.Config{}, []model.FormatOption{
+		{FormatID: "18", Extension: "mp4", VideoCodec: "avc1", AudioCodec: "mp4a", Quality: "FD"},
+	})
+
+	if _, err := s.ResolveFormatForQuality(context.Background(), testVideoURL, "FHD"); !errors.Is(err, ErrNoFormatForQuality) {
+		t.Fatalf("err = %v, want ErrNoFormatForQuality", err)
+	}
+}
+
+// mixedFormatsMetadata is worker metadata with a format for each rejection reason
+const mixedFormatsMetadata = `{
+	"id": "abc123",
+	"title": "Mixed formats",
+	"url": "https://www.youtube.com/watch?v=abc123",
+	"formats": [
+		{"format_id": "18", "ext": "mp4", "resolution": "640x360", "vcodec": "avc1", "acodec": "mp4a", "filesize": 1048576, "protocol": "https"},
+		{"format_id": "hls-720", "ext": "mp4", "resolution": "1280x720", "vcodec": "avc1", "acodec": "mp4a", "filesize": 2097152, "protocol": "m3u8_native"},
+		{"format_id": "drm-720", "ext": "mp4", "resolution": "1280x720", "vcodec": "avc1", "acodec": "mp4a", "filesize": 2097152, "protocol": "https", "has_drm": true},
+		{"format_id": "rtmp-720", "ext": "flv", "resolution": "1280x720", "vcodec": "avc1", "acodec": "mp4a", "filesize": 2097152, "protocol": "rtmp"},
+		{"format_id": "geo-360", "ext": "mp4", "resolution": "640x360", "vcodec": "avc1", "acodec": "mp4a", "filesize": 1048576, "protocol": "https", "geo_restricted": true},
+		{"format_id": "137", "ext": "mp4", "resolution": "1920x1080", "vcodec": "avc1", "acodec": "none", "filesize": 4194304, "protocol": "https"},
+		{"format_id": "140", "ext": "m4a", "resolution": "audio only", "vcodec": "none", "acodec": "mp4a", "filesize": 524288, "protocol": "https"},
+		{"format_id": "huge", "ext": "mp4", "resolution": "640x360", "vcodec": "avc1", "acodec": "mp4a", "filesize": 209715200, "protocol": "https"}
+	]
+}`
+
+func TestDownloadRejectionMixedFormats(t *testing.T) {
+	var metadata model.VideoMetadata
+	if err := json.Unmarshal([]byte(mixedFormatsMetadata), &metadata); err != nil {
+		t.Fatal(err)
+	}
+	live := metadata
+	live.IsLive = true
+
+	tests := []struct {
+		name     string
+		metadata model.VideoMetadata
+		quality  string
+		want     map[string]string
+	}{
+		{"own category", metadata, "", map[string]string{
+			"18": "", "hls-720": "", "drm-720": "drm_protected", "rtmp-720": "unsupported_protocol",
+			"geo-360": "geo_blocked", "137": "incomplete_format", "140": "", "huge": "file_too_large",
+		}},
+		{"requested as video", metadata, "HD", map[string]string{
+			"18": "", "hls-720": "", "137": "incomplete_format", "140": "incomplete_format",
+		}},
+		{"live stream", live, "", map[string]string{
+			"18": "live_stream", "hls-720": "live_stream", "140": "live_stream",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &model.Config{}
+			cfg.QualityCategories.Enabled = []string{"FHD", "HD", "SD", "FD", "Audio"}
+			cfg.QualityCategories.RejectVideoOnly = true
+			cfg.QualityCategories.RejectAudioOnly = true
+			s := newTestVideoService(t, cfg, nil)
+
+			formats := map[string]model.FormatOption{}
+			for _, format := range s.parseMetadata(tt.metadata, false).Formats {
+				formats[format.FormatID] = format
+			}
+			for formatID, want := range tt.want {
+				format, ok := formats[formatID]
+				if !ok {
+					t.Fatalf("format %s missing from parsed info", formatID)
+				}
+				if got := DownloadRejection(format, tt.quality, &cfg.QualityCategories, 100); got != want {
+					t.Errorf("format %s: rejection = %q, want %q", formatID, got, want)
+				}
+			}
+		})
+	}
+}
+
+// fullMetadata is worker metadata carrying every descriptive field
+const fullMetadata = `{
+	"id": "abc123",
+	"title": "Full metadata",
+	"url": "https://www.youtube.com/watch?v=abc123",
+	"description": "A video about things",
+	"tags": ["music", "", "live"],
+	"view_count": 123456,
+	"like_count": 789,
+	"upload_date": "20240131",
+	"formats": [{"format_id": "18", "ext": "mp4", "resolution": "640x360", "vcodec": "avc1", "acodec": "mp4a"}]
+}`
+
+func TestParseMetadataVerbose(t *testing.T) {
+	viewCount, likeCount := int64(123456), int64(789)
+
+	tests := []struct {
+		name     string
+		metadata string
+		verbose  bool
+		want     model.VideoInfo
+	}{
+		{"verbose", fullMetadata, true, model.VideoInfo{
+			Description: "A video about things",
+			Tags:        []string{"music", "live"},
+			ViewCount:   &viewCount,
+			LikeCount:   &likeCount,
+			UploadDate:  "20240131",
+		}},
+		{"lean by default", fullMetadata, false, model.VideoInfo{}},
+		{"missing fields", `{"id": "abc123", "title": "Bare", "formats": []}`, true, model.VideoInfo{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var metadata model.VideoMetadata
+			if err := json.Unmarshal([]byte(tt.metadata), &metadata); err != nil {
+				t.Fatal(err)
+			}
+			s := newTestVideoService(t, &model.Config{}, nil)
+
+			info := s.parseMetadata(metadata, tt.verbose)
+			if info.Description != tt.want.Description || info.UploadDate != tt.want.UploadDate {
+				t.Errorf("description, upload date = %q, %q; want %q, %q", info.Description, info.UploadDate, tt.want.Description, tt.want.UploadDate)
+			}
+			if fmt.Sprint(info.Tags) != fmt.Sprint(tt.want.Tags) {
+				t.Errorf("tags = %q, want %q", info.Tags, tt.want.Tags)
+			}
+			if !equalCount(info.ViewCount, tt.want.ViewCount) || !equalCount(info.LikeCount, tt.want.LikeCount) {
+				t.Errorf("view, like count = %v, %v; want %v, %v", info.ViewCount, info.LikeCount, tt.want.ViewCount, tt.want.LikeCount)
+			}
+
+			body, _ := json.Marshal(info)
+			if !tt.verbose && strings.Contains(string(body), "view_count") {
+				t.Errorf("lean response contains verbose fields: %s", body)
+			}
+		})
+	}
+}
+
+// equalCount compares optional counters
+func equalCount(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func TestCheckQualityBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		quality string
+		min     string
+		max     string
+		want    string
+	}{
+		{"no bounds", "FD", "", "", ""},
+		{"within bounds", "HD", "SD", "FHD", ""},
+		{"at the minimum", "SD", "SD", "", ""},
+		{"at the maximum", "FHD", "", "FHD", ""},
+		{"below the minimum", "FD", "SD", "", QualityTooLow},
+		{"above the maximum", "FHD", "", "HD", QualityTooHigh},
+		{"audio is never bounded", "Audio", "HD", "FHD", ""},
+		{"unknown category with bounds", "", "SD", "", QualityUnknown},
+		{"unknown category without bounds", "", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qc := &model.QualityCategoriesConfig{MinQuality: tt.min, MaxQuality: tt.max}
+			if got := CheckQualityBounds(tt.quality, qc); got != tt.want {
+				t.Errorf("CheckQualityBounds(%q) = %q, want %q", tt.quality, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildOfficialNameIsBounded(t *testing.T) {
+	s := newTestVideoService(t, &model.Config{}, nil)
+	format := &model.FormatOption{
+		Quality:    "FHD",
+		Resolution: strings.Repeat("1920x1080", 100),
+		VideoCodec: strings.Repeat("avc1.640028", 100),
+		AudioCodec: "mp4a.40.2",
+	}
+	name := s.buildOfficialName(format)
+	if n := utf8.RuneCountInString(name); n > maxOfficialNameLength {
+		t.Errorf("official name has %d characters, want at most %d", n, maxOfficialNameLength)
+	}
+	if !strings.HasPrefix(name, "FHD (1920x1080") {
+		t.Errorf("official name = %q, want it to start with the label and resolution", name)
+	}
+}
+
+// newTestInfoProvider starts a stub info provider answering with status and body, counting its calls
+func newTestInfoProvider(t *testing.T, status int, body string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	calls := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, calls
+}
+
+func TestInfoProviderFallback(t *testing.T) {
+	tests := []struct {
+		name          string
+		primaryStatus int
+		withFallback  bool
+		wantErr       bool
+		wantFallbacks int32
+		wantTitle     string
+	}{
+		{"first fails, second succeeds", http.StatusInternalServerError, true, false, 1, "Full metadata"},
+		{"first succeeds, second is not asked", http.StatusOK, true, false, 0, "Full metadata"},
+		{"single provider failing", http.StatusInternalServerError, false, true, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary, primaryCalls := newTestInfoProvider(t, tt.primaryStatus, fullMetadata)
+			fallback, fallbackCalls := newTestInfoProvider(t, http.StatusOK, fullMetadata)
+			primaryURL, _ := url.Parse(primary.URL)
+			port, _ := strconv.Atoi(primaryURL.Port())
+
+			cfg := &model.Config{}
+			if tt.withFallback {
+				cfg.Python.InfoFallbackURLs = []string{fallback.URL}
+			}
+			s := NewVideoService(primaryURL.Hostname(), port, 5, cfg)
+
+			info, err := s.GetVideoInfo(context.Background(), testVideoURL, false)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("GetVideoInfo succeeded, want an error")
+				}
+			} else if err != nil || info.Title != tt.wantTitle {
+				t.Fatalf("GetVideoInfo = %+v, %v; want title %q", info, err, tt.wantTitle)
+			}
+			if primaryCalls.Load() != 1 || fallbackCalls.Load() != tt.wantFallbacks {
+				t.Errorf("provider calls = %d, %d; want 1, %d", primaryCalls.Load(), fallbackCalls.Load(), tt.wantFallbacks)
+			}
+		})
+	}
+}
+
+func TestLimitFormatsPerQuality(t *testing.T) {
+	formats := []model.FormatOption{
+		{FormatID: "137", Quality: "FHD", Fps: 30, Bitrate: 4000},
+		{FormatID: "299", Quality: "FHD", Fps: 60, Bitrate: 6000},
+		{FormatID: "248", Quality: "FHD", Fps: 30, Bitrate: 5000},
+		{FormatID: "136", Quality: "HD", Fps: 30, Bitrate: 2500, FileSize: 100},
+		{FormatID: "22", Quality: "HD", Fps: 30, Bitrate: 2500, FileSize: 200},
+		{FormatID: "247", Quality: "HD", Fps: 30, Bitrate: 2500, FileSize: 200},
+		{FormatID: "18", Quality: "SD", Fps: 30, Bitrate: 500},
+		{FormatID: "140", Quality: "Audio", Bitrate: 128},
+		{FormatID: "251", Quality: "Audio", Bitrate: 160},
+	}
+
+	tests := []struct {
+		name  string
+		limit int
+		want  []string
+	}{
+		{"no limit", 0, []string{"137", "299", "248", "136", "22", "247", "18", "140", "251"}},
+		// fps first, then bitrate, then file size, then format ID; original order is kept
+		{"best per group", 1, []string{"299", "22", "18", "251"}},
+		{"best two per group", 2, []string{"299", "248", "22", "247", "18", "140", "251"}},
+		{"limit above group sizes", 5, []string{"137", "299", "248", "136", "22", "247", "18", "140", "251"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limited := LimitFormatsPerQuality(formats, tt.limit)
+			var got []string
+			perQuality := make(map[string]int)
+			for _, format := range limited {
+				got = append(got, format.FormatID)
+				perQuality[format.Quality]++
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("kept %v, want %v", got, tt.want)
+			}
+			for quality, count := range perQuality {
+				if tt.limit > 0 && count > tt.limit {
+					t.Errorf("%s kept %d formats, want at most %d", quality, count, tt.limit)
+				}
+			}
+		})
+	}
+
+	// The result doesn't depend on the input order
+	reversed := make([]model.FormatOption, len(formats))
+	for i, format := range formats {
+		reversed[len(formats)-1-i] = format
+	}
+	var got []string
+	for _, format := range LimitFormatsPerQuality(reversed, 1) {
+		got = append(got, format.FormatID)
+	}
+	if strings.Join(got, ",") != "251,18,22,299" {
+		t.Errorf("reversed input kept %v, want [251 18 22 299]", got)
+	}
+}
+
+func TestAspectRatio(t *testing.T) {
+	tests := []struct {
+		resolution      string
+		wantRatio       string
+		wantOrientation string
+	}{
+		{"1920x1080", "16:9", "landscape"},
+		{"854x480", "16:9", "landscape"}, // Snapped from 427:240
+		{"1080x1920", "9:16", "portrait"},
+		{"720x1280", "9:16", "portrait"},
+		{"1080x1080", "1:1", "square"},
+		{"640x480", "4:3", "landscape"},
+		{"1000x300", "10:3", "landscape"}, // Not a common ratio: reduced
+		{"audio only", "", ""},
+		{"0x1080", "", ""},
+		{"1920x0", "", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.resolution, func(t *testing.T) {
+			ratio, orientation := aspectRatio(tt.resolution)
+			if ratio != tt.wantRatio || orientation != tt.wantOrientation {
+				t.Errorf("aspectRatio(%q) = %q, %q; want %q, %q", tt.resolution, ratio, orientation, tt.wantRatio, tt.wantOrientation)
+			}
+		})
+	}
+
+	// parseFormat fills the fields, which are omitted from JSON without dimensions
+	s := NewVideoService("127.0.0.1", 0, 1, &model.Config{})
+	vertical := s.parseFormat(map[string]interface{}{"format_id": "137", "ext": "mp4", "resolution": "1080x1920"})
+	if vertical.AspectRatio != "9:16" || vertical.Orientation != "portrait" {
+		t.Errorf("parseFormat = %q, %q; want 9:16 portrait", vertical.AspectRatio, vertical.Orientation)
+	}
+	audio := s.parseFormat(map[string]interface{}{"format_id": "140", "ext": "m4a", "resolution": "audio only"})
+	data, _ := json.Marshal(audio)
+	if strings.Contains(string(data), "aspect_ratio") || strings.Contains(string(data), "orientation") {
+		t.Errorf("audio format JSON %s has aspect fields", data)
+	}
+}
+
+func TestParseThumbnails(t *testing.T) {
+	const thumbnails = `[
+		{"url": "https://i.ytimg.com/vi/abc123/maxresdefault.jpg", "width": 1280, "height": 720, "preference": 0},
+		{"url": "https://i.ytimg.com/vi/abc123/default.jpg", "width": 120, "height": 90, "preference": -10},
+		{"url": "", "width": 1920, "height": 1080},
+		{"url": "https://i.ytimg.com/vi/abc123/hqdefault.jpg", "width": 480, "height": 360, "preference": -5},
+		{"url": "https://i.ytimg.com/vi/abc123/unknown-b.webp", "preference": 2},
+		{"url": "https://i.ytimg.com/vi/abc123/unknown-a.webp", "preference": 1}
+	]`
+
+	tests := []struct {
+		name          string
+		thumbnail     string
+		wantThumbnail string
+	}{
+		{"worker's thumbnail is kept", `"https://i.ytimg.com/vi/abc123/hqdefault.jpg"`, "https://i.ytimg.com/vi/abc123/hqdefault.jpg"},
+		{"largest is used without one", `""`, "https://i.ytimg.com/vi/abc123/maxresdefault.jpg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var metadata model.VideoMetadata
+			raw := `{"id": "abc123", "title": "Thumbs", "formats": [], "thumbnail": ` + tt.thumbnail + `, "thumbnails": ` + thumbnails + `}`
+			if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+				t.Fatal(err)
+			}
+			info := newTestVideoService(t, &model.Config{}, nil).parseMetadata(metadata, false)
+
+			// Smallest first; missing dimensions sort first, by preference; entries without a URL are dropped
+			want := []string{"unknown-a.webp", "unknown-b.webp", "default.jpg", "hqdefault.jpg", "maxresdefault.jpg"}
+			var got []string
+			for _, thumbnail := range info.Thumbnails {
+				got = append(got, thumbnail.URL[strings.LastIndex(thumbnail.URL, "/")+1:])
+			}
+			if strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("thumbnails = %v, want %v", got, want)
+			}
+			if info.ThumbnailURL != tt.wantThumbnail {
+				t.Errorf("thumbnail_url = %q, want %q", info.ThumbnailURL, tt.wantThumbnail)
+			}
+		})
+	}
+}
+
+func TestVideoInfoPlatform(t *testing.T) {
+	tests := []struct {
+		name          string
+		metadata      string
+		wantExtractor string
+		wantPlatform  string
+	}{
+		{"reported by the worker", `{"title": "Clip", "extractor": "youtube:tab", "extractor_key": "YoutubeTab", "formats": []}`, "YoutubeTab", "youtube"},
+		{"extractor name only", `{"title": "Clip", "extractor": "TikTok", "formats": []}`, "TikTok", "tiktok"},
+		{"derived from the requested URL", `{"title": "Clip", "formats": []}`, "", "youtube"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, _ := newTestInfoProvider(t, http.StatusOK, tt.metadata)
+			providerURL, _ := url.Parse(provider.URL)
+			port, _ := strconv.Atoi(providerURL.Port())
+			s := NewVideoService(providerURL.Hostname(), port, 5, &model.Config{})
+
+			info, err := s.GetVideoInfo(context.Background(), testVideoURL, false)
+			if err != nil {
+				t.Fatalf("GetVideoInfo: %v", err)
+			}
+			if info.Extractor != tt.wantExtractor || info.Platform != tt.wantPlatform {
+				t.Errorf("extractor, platform = %q, %q; want %q, %q", info.Extractor, info.Platform, tt.wantExtractor, tt.wantPlatform)
+			}
+		})
+	}
+}
+
+func TestKnownInfoCache(t *testing.T) {
+	cfg := &model.Config{}
+	cfg.Python.KnownInfoTTL = 60
+	cfg.Python.KnownInfoMaxEntries = 2
+	s := NewVideoService("127.0.0.1", 0, 1, cfg)
+	urls := []string{
+		"https://www.youtube.com/watch?v=first",
+		"https://www.youtube.com/watch?v=second",
+		"https://www.youtube.com/watch?v=third",
+	}
+	for _, videoURL := range urls {
+		s.rememberInfo(videoURL, &model.VideoInfo{URL: videoURL, Duration: 60})
+		time.Sleep(time.Millisecond)
+	}
+
+	// The full cache made room for the third video by evicting the oldest
+	for i, wantKnown := range []bool{false, true, true} {
+		if _, known := s.GetKnownDuration(urls[i]); known != wantKnown {
+			t.Errorf("%s known = %v, want %v", urls[i], known, wantKnown)
+		}
+	}
+
+	// Expired entries are no longer used and make room before fresh ones are evicted
+	s.mu.Lock()
+	s.knownInfos[s.infoKey(urls[1])].fetchedAt = time.Now().Add(-2 * time.Minute)
+	s.mu.Unlock()
+	if _, known := s.GetKnownDuration(urls[1]); known {
+		t.Errorf("%s is still known past KNOWN_INFO_TTL", urls[1])
+	}
+	s.rememberInfo(urls[0], &model.VideoInfo{URL: urls[0], Duration: 60})
+	for i, wantKnown := range []bool{true, false, true} {
+		if _, known := s.GetKnownDuration(urls[i]); known != wantKnown {
+			t.Errorf("after refill, %s known = %v, want %v", urls[i], known, wantKnown)
+		}
+	}
+
+	// A zero TTL keeps nothing
+	cfg.Python.KnownInfoTTL = 0
+	disabled := NewVideoService("127.0.0.1", 0, 1, cfg)
+	disabled.rememberInfo(urls[0], &model.VideoInfo{URL: urls[0], Duration: 60})
+	if len(disabled.knownInfos) != 0 {
+		t.Errorf("KNOWN_INFO_TTL=0 kept %d entries", len(disabled.knownInfos))
+	}
+}
+
+func TestPartialVideoInfo(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantErr      error
+		wantPartial  bool
+		wantWarnings []string
+	}{
+		{"missing formats", `{"id": "abc123", "title": "No formats"}`, nil, true, []string{"format extraction failed"}},
+		{"worker warnings", `{"id": "abc123", "title": "No formats", "warnings": ["Requested format is not available"]}`, nil, true, []string{"Requested format is not available"}},
+		{"nothing extracted", `{"id": "abc123"}`, ErrInfoExtractionFailed, false, nil},
+		{"full metadata", fullMetadata, nil, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, _ := newTestInfoProvider(t, http.StatusOK, tt.body)
+			providerURL, _ := url.Parse(provider.URL)
+			port, _ := strconv.Atoi(providerURL.Port())
+			s := NewVideoService(providerURL.Hostname(), port, 5, &model.Config{})
+
+			info, err := s.GetVideoInfo(context.Background(), testVideoURL, false)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("GetVideoInfo = %+v, %v; want %v", info, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetVideoInfo: %v", err)
+			}
+			if info.Partial != tt.wantPartial || fmt.Sprint(info.Warnings) != fmt.Sprint(tt.wantWarnings) {
+				t.Errorf("partial = %v warnings = %q, want %v %q", info.Partial, info.Warnings, tt.wantPartial, tt.wantWarnings)
+			}
+		})
+	}
+}
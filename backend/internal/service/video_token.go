@@ -0,0 +1,94 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+
+	"videodownload/pkg/logger"
+)
+
+// ErrInvalidVideoToken is returned for a video token that is malformed, tampered with or signed
+// with another secret
+var ErrInvalidVideoToken = errors.New("invalid video token")
+
+// videoTokenCodec seals normalized video URLs into opaque tokens
+// Tokens are AES-GCM encrypted, so they neither reveal the URL nor survive modification. The
+// nonce is derived from the URL, which makes the token of a URL stable
+type videoTokenCodec struct {
+	aead     cipher.AEAD
+	nonceMAC []byte
+}
+
+// newVideoTokenCodec derives the token keys from secret
+// Without a secret a random one is used, so tokens only stay valid until the next restart
+func newVideoTokenCodec(secret string) *videoTokenCodec {
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, 32)
+		rand.Read(key)
+		logger.Logger.Warn("VIDEO_TOKEN_SECRET is not set, video tokens won't survive a restart")
+	}
+
+	encKey := sha256.Sum256(append([]byte("video-token-enc\n"), key...))
+	macKey := sha256.Sum256(append([]byte("video-token-nonce\n"), key...))
+	block, _ := aes.NewCipher(encKey[:])
+	aead, _ := cipher.NewGCM(block)
+	return &videoTokenCodec{aead: aead, nonceMAC: macKey[:]}
+}
+
+// seal returns the token of a video URL
+func (c *videoTokenCodec) seal(videoURL string) string {
+	plaintext := []byte(normalizeTokenURL(videoURL))
+
+	mac := hmac.New(sha256.New, c.nonceMAC)
+	mac.Write(plaintext)
+	nonce := mac.Sum(nil)[:c.aead.NonceSize()]
+
+	sealed := c.aead.Seal(nonce, nonce, plaintext, nil)
+	return base64.RawURLEncoding.EncodeToString(sealed)
+}
+
+// open returns the video URL sealed in a token
+func (c *videoTokenCodec) open(token string) (string, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", ErrInvalidVideoToken
+	}
+
+	nonceSize := c.aead.NonceSize()
+	plaintext, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", ErrInvalidVideoToken
+	}
+	return string(plaintext), nil
+}
+
+// normalizeTokenURL case-normalizes the scheme and host of a URL so spellings of the same
+// video share a token
+func normalizeTokenURL(videoURL string) string {
+	normalized := strings.TrimSpace(videoURL)
+	if u, err := url.Parse(normalized); err == nil && u.Host != "" {
+		u.Scheme = strings.ToLower(u.Scheme)
+		u.Host = strings.ToLower(u.Host)
+		normalized = u.String()
+	}
+	return normalized
+}
+
+// VideoToken returns the opaque token that stands for videoURL in download requests
+func (s *VideoService) VideoToken(videoURL string) string {
+	return s.videoTokens.seal(videoURL)
+}
+
+// ResolveVideoToken returns the URL a video token stands for
+// The URL still has to pass the allowlist checks, which may have changed since it was issued
+func (s *VideoService) ResolveVideoToken(token string) (string, error) {
+	return s.videoTokens.open(token)
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestVideoTokenRoundTrip(t *testing.T) {
+	codec := newVideoTokenCodec("secret")
+
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"plain URL", "https://www.youtube.com/watch?v=abc123", "https://www.youtube.com/watch?v=abc123"},
+		{"scheme and host are lowercased", "HTTPS://WWW.YouTube.com/watch?v=abc123", "https://www.youtube.com/watch?v=abc123"},
+		{"path and query keep their case", "https://vimeo.com/Channel/123?Key=V", "https://vimeo.com/Channel/123?Key=V"},
+		{"surrounding spaces are trimmed", "  https://vimeo.com/123  ", "https://vimeo.com/123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := codec.seal(tt.url)
+			if strings.Contains(token, "youtube") || strings.Contains(token, "vimeo") {
+				t.Errorf("token %q reveals the URL", token)
+			}
+			got, err := codec.open(token)
+			if err != nil || got != tt.want {
+				t.Errorf("open = (%q, %v), want (%q, nil)", got, err, tt.want)
+			}
+			// Spellings of the same video share a token, so it can key cached formats
+			if again := codec.seal(tt.want); again != token {
+				t.Errorf("token of the normalized URL = %q, want %q", again, token)
+			}
+		})
+	}
+
+	if codec.seal("https://vimeo.com/123") == codec.seal("https://vimeo.com/456") {
+		t.Error("different videos share a token")
+	}
+
+	// The same secret keeps tokens valid across restarts
+	token := codec.seal("https://vimeo.com/123")
+	if got, err := newVideoTokenCodec("secret").open(token); err != nil || got != "https://vimeo.com/123" {
+		t.Errorf("open after a restart = (%q, %v), want the URL", got, err)
+	}
+}
+
+func TestVideoTokenTamper(t *testing.T) {
+	codec := newVideoTokenCodec("secret")
+	token := codec.seal("https://www.youtube.com/watch?v=abc123")
+	sealed, _ := base64.RawURLEncoding.DecodeString(token)
+
+	// flip returns the token with one bit of byte i flipped
+	flip := func(i int) string {
+		tampered := append([]byte(nil), sealed...)
+		tampered[i] ^= 1
+		return base64.RawURLEncoding.EncodeToString(tampered)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"nonce changed", flip(0)},
+		{"ciphertext changed", flip(codec.aead.NonceSize())},
+		{"tag changed", flip(len(sealed) - 1)},
+		{"truncated", base64.RawURLEncoding.EncodeToString(sealed[:len(sealed)-1])},
+		{"shorter than a nonce", base64.RawURLEncoding.EncodeToString(sealed[:4])},
+		{"not base64", token + "!"},
+		{"plain URL", "https://www.youtube.com/watch?v=abc123"},
+		{"empty", ""},
+		{"other secret", newVideoTokenCodec("other").seal("https://www.youtube.com/watch?v=abc123")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := codec.open(tt.token); !errors.Is(err, ErrInvalidVideoToken) {
+				t.Errorf("open = (%q, %v), want ErrInvalidVideoToken", got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+)
+
+// workerPool bounds the worker calls of one kind that run at once
+// Info and download calls use separate pools so a burst of downloads can't starve info requests
+type workerPool struct {
+	slots chan struct{}
+}
+
+// newWorkerPool creates a pool allowing size concurrent calls
+// Returns nil for a size of 0 or less, which means unlimited
+func newWorkerPool(size int) *workerPool {
+	if size <= 0 {
+		return nil
+	}
+	return &workerPool{slots: make(chan struct{}, size)}
+}
+
+// acquire blocks until a slot is free and returns the func releasing it
+// Fails with ctx's error when ctx is done first. A nil pool never blocks
+func (p *workerPool) acquire(ctx context.Context) (func(), error) {
+	if p == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case p.slots <- struct{}{}:
+		return func() { <-p.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
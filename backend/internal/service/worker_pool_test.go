@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool(t *testing.T) {
+	pool := newWorkerPool(2)
+	first, err := pool.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pool.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A full pool makes callers wait until their context gives up
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := pool.acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("acquire on a full pool = %v, want DeadlineExceeded", err)
+	}
+
+	// Releasing a slot lets a waiting caller through
+	acquired := make(chan error, 1)
+	go func() {
+		_, err := pool.acquire(context.Background())
+		acquired <- err
+	}()
+	first()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Errorf("acquire after a release = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("waiting caller not let through after a release")
+	}
+}
+
+func TestWorkerPoolUnlimited(t *testing.T) {
+	pool := newWorkerPool(0)
+	if pool != nil {
+		t.Fatalf("newWorkerPool(0) = %+v, want nil", pool)
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := pool.acquire(context.Background()); err != nil {
+			t.Fatalf("acquire %d on an unlimited pool = %v", i, err)
+		}
+	}
+}
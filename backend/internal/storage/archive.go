@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"videodownload/internal/model"
+)
+
+// WriteZip streams the files into a zip archive written to w
+// Entries are ordered by filename then ID, so the same files always produce the same archive layout.
+// Media is stored as-is since it is already compressed; text files are deflated
+func WriteZip(w io.Writer, files []*model.DownloadedFile) error {
+	sorted := make([]*model.DownloadedFile, len(files))
+	copy(sorted, files)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Filename != sorted[j].Filename {
+			return sorted[i].Filename < sorted[j].Filename
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	archive := zip.NewWriter(w)
+	usedNames := make(map[string]bool)
+
+	for _, file := range sorted {
+		method := zip.Store
+		if IsCompressible(file.Filename) {
+			method = zip.Deflate
+		}
+
+		header := &zip.FileHeader{
+			Name:     uniqueEntryName(file.Filename, usedNames),
+			Method:   method,
+			Modified: file.CreatedAt,
+		}
+		entry, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		if err := copyFileTo(entry, file.FilePath); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// uniqueEntryName returns name, or "name (n).ext" when an earlier entry already uses it
+func uniqueEntryName(name string, usedNames map[string]bool) string {
+	candidate := name
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for n := 2; usedNames[candidate]; n++ {
+		candidate = fmt.Sprintf("%s (%d)%s", base, n, ext)
+	}
+	usedNames[candidate] = true
+	return candidate
+}
+
+// copyFileTo copies the file at path into w
+func copyFileTo(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
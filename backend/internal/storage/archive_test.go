@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"videodownload/internal/model"
+)
+
+func TestWriteZip(t *testing.T) {
+	dir := t.TempDir()
+	created := time.Date(2026, 3, 14, 15, 9, 26, 0, time.UTC)
+	var files []*model.DownloadedFile
+	for i, f := range []struct{ id, name string }{
+		{"3", "video.mp4"},
+		{"1", "video.en.vtt"},
+		{"4", "audio.m4a"},
+		{"2", "video.mp4"},
+	} {
+		path := filepath.Join(dir, f.id+"_"+f.name)
+		if err := os.WriteFile(path, []byte("contents of "+f.id), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, &model.DownloadedFile{
+			ID:        f.id,
+			Filename:  f.name,
+			FilePath:  path,
+			CreatedAt: created.Add(time.Duration(i) * time.Minute),
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := WriteZip(&buf, files); err != nil {
+		t.Fatalf("WriteZip: %v", err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("read zip: %v", err)
+	}
+
+	// Sorted by filename then ID; a repeated name gets a numbered suffix
+	want := []struct {
+		name     string
+		id       string
+		method   uint16
+		modified time.Time
+	}{
+		{"audio.m4a", "4", zip.Store, created.Add(2 * time.Minute)},
+		{"video.en.vtt", "1", zip.Deflate, created.Add(time.Minute)},
+		{"video.mp4", "2", zip.Store, created.Add(3 * time.Minute)},
+		{"video (2).mp4", "3", zip.Store, created},
+	}
+	if len(archive.File) != len(want) {
+		t.Fatalf("zip has %d entries, want %d", len(archive.File), len(want))
+	}
+	for i, entry := range archive.File {
+		w := want[i]
+		if entry.Name != w.name || entry.Method != w.method {
+			t.Errorf("entry %d = %s (method %d), want %s (method %d)", i, entry.Name, entry.Method, w.name, w.method)
+		}
+		if !entry.Modified.Equal(w.modified) {
+			t.Errorf("entry %s modified %v, want %v", entry.Name, entry.Modified, w.modified)
+		}
+		r, err := entry.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(r)
+		r.Close()
+		if string(data) != "contents of "+w.id {
+			t.Errorf("entry %s = %q, want the file of download %s", entry.Name, data, w.id)
+		}
+	}
+
+	// The same files in another order produce the same archive
+	var again bytes.Buffer
+	reversed := []*model.DownloadedFile{files[3], files[2], files[1], files[0]}
+	if err := WriteZip(&again, reversed); err != nil {
+		t.Fatalf("WriteZip: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Error("archive differs when the files are passed in another order")
+	}
+}
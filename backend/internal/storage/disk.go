@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"videodownload/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// Free disk levels of the download directory
+const (
+	DiskOK       = "ok"
+	DiskLow      = "low"      // Below WARN_FREE_DISK_PERCENT; downloads are still accepted
+	DiskCritical = "critical" // Below MIN_FREE_DISK_PERCENT; new downloads are rejected
+)
+
+// statFreeDisk reads the free share of a filesystem; replaced in tests to simulate disk levels
+var statFreeDisk = freeDiskPercent
+
+// CheckDisk returns the free disk level of the download directory and its free percentage
+// A filesystem that can't be inspected is reported as ok with a negative percentage,
+// so a failing statfs never blocks downloads
+func (m *Manager) CheckDisk() (string, float64) {
+	if m.cfg.MinFreeDiskPercent <= 0 && m.cfg.WarnFreeDiskPercent <= 0 {
+		return DiskOK, -1
+	}
+
+	freePercent, err := statFreeDisk(m.cfg.DownloadDir)
+	if err != nil {
+		logger.Logger.Warn("Failed to check free disk space", zap.String("dir", m.cfg.DownloadDir), zap.Error(err))
+		return DiskOK, -1
+	}
+	return diskLevel(freePercent, m.cfg.MinFreeDiskPercent, m.cfg.WarnFreeDiskPercent), freePercent
+}
+
+// diskLevel classifies a free percentage against the configured thresholds (0 = threshold off)
+func diskLevel(freePercent float64, minPercent int, warnPercent int) string {
+	if minPercent > 0 && freePercent < float64(minPercent) {
+		return DiskCritical
+	}
+	if warnPercent > 0 && freePercent < float64(warnPercent) {
+		return DiskLow
+	}
+	return DiskOK
+}
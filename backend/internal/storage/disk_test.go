@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"videodownload/internal/model"
+)
+
+func TestCheckDisk(t *testing.T) {
+	tests := []struct {
+		name        string
+		minPercent  int
+		warnPercent int
+		free        float64
+		statErr     error
+		wantLevel   string
+		wantPercent float64
+	}{
+		{"plenty free", 10, 20, 50, nil, DiskOK, 50},
+		{"at the warning threshold", 10, 20, 20, nil, DiskOK, 20},
+		{"below the warning threshold", 10, 20, 15, nil, DiskLow, 15},
+		{"at the minimum", 10, 20, 10, nil, DiskLow, 10},
+		{"below the minimum", 10, 20, 5, nil, DiskCritical, 5},
+		{"only a minimum set", 10, 0, 15, nil, DiskOK, 15},
+		{"only a warning set", 0, 20, 5, nil, DiskLow, 5},
+		{"thresholds off", 0, 0, 1, nil, DiskOK, -1},
+		{"statfs failing never blocks", 10, 20, 0, errors.New("statfs failed"), DiskOK, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, func(cfg *model.StorageConfig) {
+				cfg.MinFreeDiskPercent = tt.minPercent
+				cfg.WarnFreeDiskPercent = tt.warnPercent
+			})
+			statFreeDisk = func(dir string) (float64, error) {
+				if dir != m.cfg.DownloadDir {
+					t.Errorf("statfs of %s, want the download directory %s", dir, m.cfg.DownloadDir)
+				}
+				return tt.free, tt.statErr
+			}
+			t.Cleanup(func() { statFreeDisk = freeDiskPercent })
+
+			level, freePercent := m.CheckDisk()
+			if level != tt.wantLevel || freePercent != tt.wantPercent {
+				t.Errorf("CheckDisk = %s, %v; want %s, %v", level, freePercent, tt.wantLevel, tt.wantPercent)
+			}
+		})
+	}
+}
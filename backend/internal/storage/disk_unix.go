@@ -0,0 +1,17 @@
+//go:build !windows
+
+package storage
+
+import "syscall"
+
+// freeDiskPercent returns the share of dir's filesystem available to unprivileged users
+func freeDiskPercent(dir string) (float64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	if stat.Blocks == 0 {
+		return 100, nil
+	}
+	return float64(stat.Bavail) * 100 / float64(stat.Blocks), nil
+}
@@ -0,0 +1,8 @@
+package storage
+
+import "errors"
+
+// freeDiskPercent is not implemented on Windows; disk thresholds are ignored there
+func freeDiskPercent(dir string) (float64, error) {
+	return 0, errors.New("free disk space check is not supported on windows")
+}
@@ -0,0 +1,16 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"videodownload/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// TestMain silences logging once for every test of the package
+func TestMain(m *testing.M) {
+	logger.Logger = zap.NewNop()
+	os.Exit(m.Run())
+}
@@ -1,8 +1,12 @@
 package storage
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,17 +20,36 @@ import (
 type Manager struct {
 	cfg      *model.StorageConfig
 	files    map[string]*model.DownloadedFile
+	expired  map[string]*model.ExpiredFile // Cleaned-up downloads still inside the grace window
+	retries  map[string]*deleteRetry       // Expired files whose deletion failed, kept tracked for retry
 	mu       sync.RWMutex
 	quitChan chan bool
+	saveMu   sync.Mutex // Serializes writes of the tracking file
+
+	totalBytes    int64 // Combined size of tracked files
+	reservedBytes int64 // Space promised to downloads that are still being written
+}
+
+// maxRetryBackoffShift caps the deletion retry backoff at 1024 cleanup intervals
+const maxRetryBackoffShift = 10
+
+// deleteRetry tracks the failed deletion attempts of an expired file
+type deleteRetry struct {
+	attempts    int
+	nextAttempt time.Time
 }
 
-// NewManager creates a new storage manager
+// NewManager creates a new storage manager, resuming tracking from STORAGE_TRACKING_FILE when it exists
 func NewManager(cfg *model.StorageConfig) *Manager {
-	return &Manager{
+	m := &Manager{
 		cfg:      cfg,
 		files:    make(map[string]*model.DownloadedFile),
+		expired:  make(map[string]*model.ExpiredFile),
+		retries:  make(map[string]*deleteRetry),
 		quitChan: make(chan bool),
 	}
+	m.loadTracking()
+	return m
 }
 
 // Start starts the cleanup routine
@@ -34,8 +57,10 @@ func (m *Manager) Start() {
 	go m.cleanupRoutine()
 }
 
-// Stop stops the cleanup routine
+// Stop stops the cleanup routine and saves the tracked files
 func (m *Manager) Stop() {
+	defer m.saveTracking()
+
 	// Use a non-blocking send with recovery for cleanup
 	select {
 	case m.quitChan <- true:
@@ -55,8 +80,13 @@ func (m *Manager) SaveFile(id string, file *model.DownloadedFile) error {
 	file.ExpiresAt = time.Now().Add(time.Duration(m.cfg.FileTTLSeconds) * time.Second)
 
 	m.mu.Lock()
+	if previous, exists := m.files[id]; exists {
+		m.totalBytes -= previous.Size
+	}
 	m.files[id] = file
+	m.totalBytes += file.Size
 	m.mu.Unlock()
+	m.saveTracking()
 
 	logger.Logger.Info("File saved", zap.String("id", id), zap.String("filename", file.Filename))
 	return nil
@@ -99,17 +129,44 @@ func (m *Manager) cleanupExpiredFiles() {
 
 	for id, file := range m.files {
 		if now.After(file.ExpiresAt) {
+			retry := m.retries[id]
+			if retry != nil && now.Before(retry.nextAttempt) {
+				continue
+			}
+
 			// Try to remove the actual file
 			if err := os.Remove(file.FilePath); err != nil {
 				if !os.IsNotExist(err) {
-					// Log error only if file exists but can't be deleted
+					errorCount++
+					if retry == nil {
+						retry = &deleteRetry{}
+						m.retries[id] = retry
+					}
+					retry.attempts++
+
+					// Keep the entry tracked so later sweeps retry, backing off exponentially
+					if retry.attempts < m.cfg.CleanupMaxRetries {
+						backoff := time.Duration(m.cfg.CleanupInterval) * time.Second << min(retry.attempts-1, maxRetryBackoffShift)
+						retry.nextAttempt = now.Add(backoff)
+						if logger.Logger != nil {
+							logger.Logger.Warn("Failed to remove file, will retry",
+								zap.String("id", id),
+								zap.String("path", file.FilePath),
+								zap.Int("attempt", retry.attempts),
+								zap.Time("next_attempt", retry.nextAttempt),
+								zap.Error(err))
+						}
+						m.recordExpired(id, file)
+						continue
+					}
+
 					if logger.Logger != nil {
-						logger.Logger.Error("Failed to remove file",
+						logger.Logger.Error("Giving up removing file, manual cleanup required",
 							zap.String("id", id),
 							zap.String("path", file.FilePath),
+							zap.Int("attempts", retry.attempts),
 							zap.Error(err))
 					}
-					errorCount++
 				} else {
 					// File doesn't exist - that's okay, just remove from tracking
 					if logger.Logger != nil {
@@ -126,14 +183,28 @@ func (m *Manager) cleanupExpiredFiles() {
 				}
 				deletedCount++
 			}
-			// Remove from tracking map regardless of deletion success
+			// Compressed sidecars are regenerated on demand, so just drop them
+			os.Remove(file.FilePath + GzipSidecarSuffix)
+			m.removeEmptyParentDirs(file.FilePath)
+
+			// Stop tracking once deleted or out of retries
 			deletedIds = append(deletedIds, id)
+			m.recordExpired(id, file)
+		}
+	}
+
+	grace := time.Duration(m.cfg.ExpiredGraceSeconds) * time.Second
+	for id, expiredFile := range m.expired {
+		if now.Sub(expiredFile.ExpiredAt) > grace {
+			delete(m.expired, id)
 		}
 	}
 
 	// Delete from map
 	for _, id := range deletedIds {
+		m.totalBytes -= m.files[id].Size
 		delete(m.files, id)
+		delete(m.retries, id)
 	}
 
 	// Log summary if anything happened and logger is available
@@ -145,13 +216,55 @@ func (m *Manager) cleanupExpiredFiles() {
 	}
 }
 
+// recordExpired keeps a tombstone of an expired file for the grace window
+// Must be called with m.mu held
+func (m *Manager) recordExpired(id string, file *model.DownloadedFile) {
+	if m.cfg.ExpiredGraceSeconds > 0 && m.expired[id] == nil {
+		m.expired[id] = &model.ExpiredFile{ID: id, URL: file.URL, ClientKey: file.ClientKey, ExpiredAt: file.ExpiresAt}
+	}
+}
+
 // GetFile gets file info by ID
+// Expired files still waiting for a deletion retry are not returned
 func (m *Manager) GetFile(id string) *model.DownloadedFile {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
+	if m.retries[id] != nil {
+		return nil
+	}
 	return m.files[id]
 }
 
+// SetChecksum records the checksum of a tracked file computed after it was saved
+// The entry is replaced by an updated copy, so callers holding the old entry never see it change,
+// and the tracking file is rewritten so the checksum survives a restart
+func (m *Manager) SetChecksum(id string, checksum string) {
+	m.mu.Lock()
+	file, exists := m.files[id]
+	if !exists {
+		m.mu.Unlock()
+		return
+	}
+	updated := *file
+	updated.SHA256 = checksum
+	m.files[id] = &updated
+	m.mu.Unlock()
+	m.saveTracking()
+}
+
+// GetExpiredFile returns the metadata of a download that expired within the grace window
+// Returns nil for unknown IDs and for downloads that expired longer ago
+func (m *Manager) GetExpiredFile(id string) *model.ExpiredFile {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	expiredFile := m.expired[id]
+	if expiredFile == nil || time.Since(expiredFile.ExpiredAt) > time.Duration(m.cfg.ExpiredGraceSeconds)*time.Second {
+		return nil
+	}
+	return expiredFile
+}
+
 // ValidateFileSize checks if file size is within limits
 func (m *Manager) ValidateFileSize(sizeBytes int64) bool {
 	maxSizeBytes := int64(m.cfg.MaxVideoSizeMB) * 1024 * 1024
@@ -168,6 +281,55 @@ func (m *Manager) GetDownloadPath(filename string) string {
 	return filepath.Join(m.cfg.DownloadDir, filename)
 }
 
+// GetDownloadPathForID returns the path for a download's file, named <id>_<filename>
+// The ID prefix keeps downloads with the same name from overwriting each other. With the date
+// layout the file goes to YYYY/MM/DD/ (UTC); with sharding into a shard directory. Directories
+// are created on demand
+func (m *Manager) GetDownloadPathForID(id string, filename string) (string, error) {
+	filename = id + "_" + filepath.Base(filename)
+
+	var dir string
+	switch {
+	case m.cfg.DateDirs:
+		dir = filepath.Join(m.cfg.DownloadDir, time.Now().UTC().Format("2006/01/02"))
+	case m.cfg.ShardDirs:
+		dir = filepath.Join(m.cfg.DownloadDir, shardPrefix(id))
+	default:
+		return m.GetDownloadPath(filename), nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filename), nil
+}
+
+// shardPrefix returns the shard directory name for a download ID
+// A hash prefix spreads files evenly whatever the ID format
+func shardPrefix(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:1])
+}
+
+// removeEmptyParentDirs removes the shard or date directories of filePath that are now empty
+// Directories are removed bottom-up, stopping at the first non-empty one or the download directory
+func (m *Manager) removeEmptyParentDirs(filePath string) {
+	if !m.cfg.ShardDirs && !m.cfg.DateDirs {
+		return
+	}
+
+	root := filepath.Clean(m.cfg.DownloadDir)
+	for dir := filepath.Dir(filePath); filepath.Clean(dir) != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		// os.Remove only succeeds on empty directories
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		if logger.Logger != nil {
+			logger.Logger.Debug("Empty storage directory removed", zap.String("dir", dir))
+		}
+	}
+}
+
 // GetFileTTL returns the file time to live in seconds
 func (m *Manager) GetFileTTL() int {
 	return m.cfg.FileTTLSeconds
@@ -211,6 +373,141 @@ func (m *Manager) GetExpiredFilesCount() int {
 	return count
 }
 
+// GetTotalBytes returns the combined size of all tracked files
+func (m *Manager) GetTotalBytes() int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.totalBytes
+}
+
+// maxTotalBytes returns the MAX_TOTAL_STORED_MB cap in bytes, or 0 when there is none
+func (m *Manager) maxTotalBytes() int64 {
+	return int64(m.cfg.MaxTotalStoredMB) * 1024 * 1024
+}
+
+// HasRoomFor reports whether sizeBytes more would fit under MAX_TOTAL_STORED_MB
+// Only a hint; ReserveSpace makes the actual guarantee
+func (m *Manager) HasRoomFor(sizeBytes int64) bool {
+	maxBytes := m.maxTotalBytes()
+	if maxBytes <= 0 {
+		return true
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	// With eviction, tracked files make room; only in-flight reservations can't be reclaimed
+	if m.cfg.EvictOldestStored {
+		return m.reservedBytes+sizeBytes <= maxBytes
+	}
+	return m.totalBytes+m.reservedBytes+sizeBytes <= maxBytes
+}
+
+// ReserveSpace holds sizeBytes of the MAX_TOTAL_STORED_MB budget for a file about to be written
+// With STORAGE_EVICT_OLDEST the oldest tracked files are evicted to make room. Returns false when
+// it would not fit; otherwise the returned func must be called once the file is tracked with
+// SaveFile or abandoned
+func (m *Manager) ReserveSpace(sizeBytes int64) (func(), bool) {
+	maxBytes := m.maxTotalBytes()
+	if maxBytes <= 0 {
+		return func() {}, true
+	}
+
+	m.mu.Lock()
+	evicted := 0
+	if m.cfg.EvictOldestStored && m.reservedBytes+sizeBytes <= maxBytes {
+		evicted = m.evictOldest(m.totalBytes + m.reservedBytes + sizeBytes - maxBytes)
+	}
+	if m.totalBytes+m.reservedBytes+sizeBytes > maxBytes {
+		m.mu.Unlock()
+		if evicted > 0 {
+			m.saveTracking()
+		}
+		return nil, false
+	}
+	m.reservedBytes += sizeBytes
+	m.mu.Unlock()
+	if evicted > 0 {
+		m.saveTracking()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mu.Lock()
+			m.reservedBytes -= sizeBytes
+			m.mu.Unlock()
+		})
+	}, true
+}
+
+// evictOldest deletes tracked files, oldest first, until at least neededBytes are freed
+// Files waiting for a deletion retry are skipped. Returns the number of files evicted
+// Must be called with m.mu held
+func (m *Manager) evictOldest(neededBytes int64) int {
+	if neededBytes <= 0 {
+		return 0
+	}
+
+	candidates := make([]*model.DownloadedFile, 0, len(m.files))
+	for id, file := range m.files {
+		if m.retries[id] == nil {
+			candidates = append(candidates, file)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].CreatedAt.Before(candidates[j].CreatedAt)
+	})
+
+	now := time.Now()
+	var freed int64
+	evicted := 0
+	for _, file := range candidates {
+		if freed >= neededBytes {
+			break
+		}
+		if err := os.Remove(file.FilePath); err != nil && !os.IsNotExist(err) {
+			logger.Logger.Warn("Failed to evict file",
+				zap.String("id", file.ID),
+				zap.String("path", file.FilePath),
+				zap.Error(err))
+			continue
+		}
+		os.Remove(file.FilePath + GzipSidecarSuffix)
+		m.removeEmptyParentDirs(file.FilePath)
+
+		if m.cfg.ExpiredGraceSeconds > 0 {
+			m.expired[file.ID] = &model.ExpiredFile{ID: file.ID, URL: file.URL, ClientKey: file.ClientKey, ExpiredAt: now}
+		}
+		m.totalBytes -= file.Size
+		delete(m.files, file.ID)
+		freed += file.Size
+		evicted++
+
+		logger.Logger.Info("File evicted to stay under storage cap",
+			zap.String("id", file.ID),
+			zap.String("path", file.FilePath),
+			zap.Int64("size_bytes", file.Size),
+			zap.Time("created_at", file.CreatedAt))
+	}
+	return evicted
+}
+
+// GetOldestFileAge returns the age of the oldest tracked file, or 0 when none are tracked
+func (m *Manager) GetOldestFileAge() time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var oldest time.Time
+	for _, file := range m.files {
+		if oldest.IsZero() || file.CreatedAt.Before(oldest) {
+			oldest = file.CreatedAt
+		}
+	}
+	if oldest.IsZero() {
+		return 0
+	}
+	return time.Since(oldest)
+}
+
 // ManualCleanup manually triggers a cleanup run (useful for testing)
 func (m *Manager) ManualCleanup() {
 	m.cleanupExpiredFiles()
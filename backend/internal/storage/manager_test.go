@@ -0,0 +1,420 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"videodownload/internal/model"
+)
+
+const kb = 1024
+
+// newTestManager returns a Manager storing files in a temporary directory
+// configure, when set, adjusts the storage config before the manager is created
+func newTestManager(t *testing.T, configure func(cfg *model.StorageConfig)) *Manager {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := &model.StorageConfig{
+		DownloadDir:    filepath.Join(dir, "downloads"),
+		FileTTLSeconds: 3600,
+		TrackingFile:   filepath.Join(dir, "files.json"),
+	}
+	if configure != nil {
+		configure(cfg)
+	}
+	m := NewManager(cfg)
+	if err := m.EnsureDownloadDir(); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// expireAll marks every tracked file as expired
+func expireAll(m *Manager) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, file := range m.files {
+		file.ExpiresAt = time.Now().Add(-time.Second)
+	}
+}
+
+// storeFile writes a file of size bytes and tracks it, created age ago
+func storeFile(t *testing.T, m *Manager, id string, size int, age time.Duration) *model.DownloadedFile {
+	t.Helper()
+	path := filepath.Join(m.cfg.DownloadDir, id+"_video.mp4")
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file := &model.DownloadedFile{Filename: "video.mp4", FilePath: path, Size: int64(size)}
+	if err := m.SaveFile(id, file); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+
+	m.mu.Lock()
+	m.files[id].CreatedAt = time.Now().Add(-age)
+	m.mu.Unlock()
+	return file
+}
+
+func TestManagerShardDirs(t *testing.T) {
+	m := newTestManager(t, func(cfg *model.StorageConfig) { cfg.ShardDirs = true })
+
+	ids := []string{"1712345678000000001", "1712345678000000002", "a1b2c3d4e5f6"}
+	paths := make(map[string]string)
+	for _, id := range ids {
+		path, err := m.GetDownloadPathForID(id, "video.mp4")
+		if err != nil {
+			t.Fatalf("GetDownloadPathForID(%s): %v", id, err)
+		}
+		shard := shardPrefix(id)
+		if len(shard) != 2 {
+			t.Errorf("shard of %s = %q, want two hex digits", id, shard)
+		}
+		if want := filepath.Join(m.cfg.DownloadDir, shard, id+"_video.mp4"); path != want {
+			t.Errorf("path of %s = %s, want %s", id, path, want)
+		}
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatalf("shard directory not created: %v", err)
+		}
+		if err := m.SaveFile(id, &model.DownloadedFile{Filename: "video.mp4", FilePath: path, Size: 4}); err != nil {
+			t.Fatal(err)
+		}
+		paths[id] = path
+	}
+
+	// A restarted manager finds the files at their sharded paths
+	restored := NewManager(m.cfg)
+	for id, path := range paths {
+		if file := restored.GetFile(id); file == nil || file.FilePath != path {
+			t.Errorf("restored %s = %+v, want it at %s", id, file, path)
+		}
+	}
+
+	// Cleanup removes the files and the shard directories they leave empty
+	expireAll(m)
+	m.cleanupExpiredFiles()
+	for id, path := range paths {
+		if _, err := os.Stat(filepath.Dir(path)); !os.IsNotExist(err) {
+			t.Errorf("shard directory of %s still exists after cleanup: %v", id, err)
+		}
+	}
+	if _, err := os.Stat(m.cfg.DownloadDir); err != nil {
+		t.Errorf("download directory removed by cleanup: %v", err)
+	}
+}
+
+func TestManagerDateDirs(t *testing.T) {
+	m := newTestManager(t, func(cfg *model.StorageConfig) { cfg.DateDirs = true })
+
+	before := time.Now().UTC().Format("2006/01/02")
+	paths := make(map[string]string)
+	for _, id := range []string{"1712345678000000001", "1712345678000000002"} {
+		path, err := m.GetDownloadPathForID(id, "video.mp4")
+		if err != nil {
+			t.Fatalf("GetDownloadPathForID(%s): %v", id, err)
+		}
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatalf("date directory not created: %v", err)
+		}
+		if err := m.SaveFile(id, &model.DownloadedFile{Filename: "video.mp4", FilePath: path, Size: 4}); err != nil {
+			t.Fatal(err)
+		}
+		paths[id] = path
+	}
+	after := time.Now().UTC().Format("2006/01/02")
+
+	for id, path := range paths {
+		want := filepath.Join(m.cfg.DownloadDir, before, id+"_video.mp4")
+		if path != want && path != filepath.Join(m.cfg.DownloadDir, after, id+"_video.mp4") {
+			t.Errorf("path of %s = %s, want %s", id, path, want)
+		}
+		// The served name stays the original one
+		if file := m.GetFile(id); file == nil || file.Filename != "video.mp4" {
+			t.Errorf("file %s = %+v, want filename video.mp4", id, file)
+		}
+	}
+
+	// A restarted manager finds the files at their nested paths
+	restored := NewManager(m.cfg)
+	for id, path := range paths {
+		if file := restored.GetFile(id); file == nil || file.FilePath != path {
+			t.Errorf("restored %s = %+v, want it at %s", id, file, path)
+		}
+	}
+
+	// An unrelated file keeps the year directory, so pruning stops below it
+	dayDir := filepath.Dir(paths["1712345678000000001"])
+	yearDir := filepath.Dir(filepath.Dir(dayDir))
+	keep := filepath.Join(yearDir, "keep")
+	if err := os.WriteFile(keep, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	expireAll(m)
+	m.cleanupExpiredFiles()
+	if _, err := os.Stat(dayDir); !os.IsNotExist(err) {
+		t.Errorf("day directory still exists after cleanup: %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(dayDir)); !os.IsNotExist(err) {
+		t.Errorf("month directory still exists after cleanup: %v", err)
+	}
+	if _, err := os.Stat(keep); err != nil {
+		t.Errorf("non-empty year directory was pruned: %v", err)
+	}
+
+	// Once empty, the year directory goes too, but never the download directory
+	os.Remove(keep)
+	path, _ := m.GetDownloadPathForID("1712345678000000003", "video.mp4")
+	os.WriteFile(path, []byte("data"), 0644)
+	m.SaveFile("1712345678000000003", &model.DownloadedFile{Filename: "video.mp4", FilePath: path, Size: 4})
+	expireAll(m)
+	m.cleanupExpiredFiles()
+	entries, err := os.ReadDir(m.cfg.DownloadDir)
+	if err != nil {
+		t.Fatalf("download directory removed by cleanup: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("download directory holds %d entries after cleanup, want the date directories pruned", len(entries))
+	}
+}
+
+func TestManagerTracking(t *testing.T) {
+	m := newTestManager(t, nil)
+	storeFile(t, m, "a", 100, 0)
+	storeFile(t, m, "b", 200, 0)
+
+	if m.GetFile("a") == nil || m.GetFile("b") == nil {
+		t.Fatal("saved files not tracked")
+	}
+	if m.GetFile("missing") != nil {
+		t.Error("GetFile returned an untracked file")
+	}
+	if got := m.GetTotalBytes(); got != 300 {
+		t.Errorf("total = %d bytes, want 300", got)
+	}
+
+	// Saving an ID again replaces its size in the total
+	storeFile(t, m, "a", 50, 0)
+	if got := m.GetTotalBytes(); got != 250 {
+		t.Errorf("total after replacing a file = %d bytes, want 250", got)
+	}
+
+	// A restarted manager resumes from the tracking file, dropping entries whose file is gone
+	os.Remove(m.GetFile("b").FilePath)
+	restored := NewManager(m.cfg)
+	if restored.GetFile("a") == nil {
+		t.Error("file not restored from the tracking file")
+	}
+	if restored.GetFile("b") != nil {
+		t.Error("file missing on disk was restored")
+	}
+	if got := restored.GetTotalBytes(); got != 50 {
+		t.Errorf("restored total = %d bytes, want 50", got)
+	}
+}
+
+func TestManagerReserveSpace(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxMB       int
+		evictOldest bool
+		reserve     int64
+		wantOK      bool
+		wantEvicted []string
+	}{
+		{"no cap", 0, false, 10 * 1024 * kb, true, nil},
+		{"fits under the cap", 1, false, 100 * kb, true, nil},
+		{"over the cap is rejected", 1, false, 300 * kb, false, nil},
+		{"over the cap evicts the oldest", 1, true, 300 * kb, true, []string{"old"}},
+		{"evicts as many as needed", 1, true, 500 * kb, true, []string{"old", "middle"}},
+		{"larger than the cap evicts nothing", 1, true, 2 * 1024 * kb, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, func(cfg *model.StorageConfig) {
+				cfg.MaxTotalStoredMB = tt.maxMB
+				cfg.EvictOldestStored = tt.evictOldest
+			})
+			files := map[string]*model.DownloadedFile{
+				"old":    storeFile(t, m, "old", 300*kb, 3*time.Hour),
+				"middle": storeFile(t, m, "middle", 300*kb, 2*time.Hour),
+				"new":    storeFile(t, m, "new", 300*kb, time.Hour),
+			}
+
+			if got := m.HasRoomFor(tt.reserve); got != tt.wantOK {
+				t.Errorf("HasRoomFor = %v, want %v", got, tt.wantOK)
+			}
+			release, ok := m.ReserveSpace(tt.reserve)
+			if ok != tt.wantOK {
+				t.Fatalf("ReserveSpace = %v, want %v", ok, tt.wantOK)
+			}
+
+			evicted := map[string]bool{}
+			for _, id := range tt.wantEvicted {
+				evicted[id] = true
+			}
+			for id, file := range files {
+				tracked := m.GetFile(id) != nil
+				_, statErr := os.Stat(file.FilePath)
+				if tracked == evicted[id] || (statErr == nil) == evicted[id] {
+					t.Errorf("file %s tracked = %v, on disk = %v; want evicted = %v", id, tracked, statErr == nil, evicted[id])
+				}
+			}
+
+			if !ok || tt.maxMB == 0 {
+				return
+			}
+			// The reservation holds its share of the cap until released
+			if m.HasRoomFor(m.maxTotalBytes() - tt.reserve + 1) {
+				t.Error("HasRoomFor ignores the pending reservation")
+			}
+			release()
+			release()
+			m.mu.RLock()
+			reserved := m.reservedBytes
+			m.mu.RUnlock()
+			if reserved != 0 {
+				t.Errorf("reserved after releasing twice = %d bytes, want 0", reserved)
+			}
+		})
+	}
+}
+
+func TestCleanupRetriesFailedDeletion(t *testing.T) {
+	m := newTestManager(t, func(cfg *model.StorageConfig) {
+		cfg.CleanupInterval = 60
+		cfg.CleanupMaxRetries = 3
+	})
+
+	// A non-empty directory can't be removed, even by root, until it is emptied
+	path := filepath.Join(m.cfg.DownloadDir, "locked.mp4")
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	blocker := filepath.Join(path, "blocker")
+	if err := os.WriteFile(blocker, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m.SaveFile("locked", &model.DownloadedFile{Filename: "locked.mp4", FilePath: path, Size: 4})
+	expireAll(m)
+
+	m.cleanupExpiredFiles()
+	if _, tracked := m.files["locked"]; !tracked {
+		t.Fatal("file untracked after its deletion failed")
+	}
+	if m.GetFile("locked") != nil {
+		t.Error("GetFile returned a file waiting for a deletion retry")
+	}
+	retry := m.retries["locked"]
+	if retry == nil || retry.attempts != 1 || !retry.nextAttempt.After(time.Now()) {
+		t.Fatalf("retry = %+v, want 1 attempt with a backoff", retry)
+	}
+
+	// Before the backoff passes, a sweep leaves the file alone
+	os.Remove(blocker)
+	m.cleanupExpiredFiles()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("file removed during its backoff: %v", err)
+	}
+
+	retry.nextAttempt = time.Now().Add(-time.Second)
+	m.cleanupExpiredFiles()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("file still on disk after the retry: %v", err)
+	}
+	if _, tracked := m.files["locked"]; tracked || m.retries["locked"] != nil {
+		t.Error("file still tracked after the retry succeeded")
+	}
+	if total := m.GetTotalBytes(); total != 0 {
+		t.Errorf("total bytes = %d after the retry, want 0", total)
+	}
+}
+
+func TestCleanupGivesUpAfterMaxRetries(t *testing.T) {
+	m := newTestManager(t, func(cfg *model.StorageConfig) {
+		cfg.CleanupInterval = 60
+		cfg.CleanupMaxRetries = 2
+	})
+
+	path := filepath.Join(m.cfg.DownloadDir, "stuck.mp4")
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(path, "blocker"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m.SaveFile("stuck", &model.DownloadedFile{Filename: "stuck.mp4", FilePath: path, Size: 4})
+	expireAll(m)
+
+	m.cleanupExpiredFiles()
+	m.retries["stuck"].nextAttempt = time.Now().Add(-time.Second)
+	m.cleanupExpiredFiles()
+
+	if _, tracked := m.files["stuck"]; tracked {
+		t.Error("file still tracked after running out of retries")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("stuck file should be left for manual cleanup: %v", err)
+	}
+}
+
+func TestManagerEvictionSkipsPendingRetries(t *testing.T) {
+	m := newTestManager(t, func(cfg *model.StorageConfig) {
+		cfg.MaxTotalStoredMB = 1
+		cfg.EvictOldestStored = true
+	})
+	for i, age := range []time.Duration{3 * time.Hour, 2 * time.Hour, time.Hour} {
+		storeFile(t, m, fmt.Sprintf("f%d", i), 300*kb, age)
+	}
+
+	// The oldest file is waiting for a deletion retry, so the next oldest is evicted instead
+	m.mu.Lock()
+	m.retries["f0"] = &deleteRetry{}
+	m.mu.Unlock()
+
+	if _, ok := m.ReserveSpace(300 * kb); !ok {
+		t.Fatal("ReserveSpace rejected")
+	}
+	m.mu.RLock()
+	_, f0 := m.files["f0"]
+	_, f1 := m.files["f1"]
+	m.mu.RUnlock()
+	if !f0 || f1 {
+		t.Errorf("tracked after eviction: f0 = %v, f1 = %v; want f0 kept and f1 evicted", f0, f1)
+	}
+}
+
+func TestSetChecksumPersists(t *testing.T) {
+	m := newTestManager(t, nil)
+	path := m.GetDownloadPath("video.mp4")
+	if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SaveFile("abc", &model.DownloadedFile{Filename: "video.mp4", FilePath: path, Size: 4}); err != nil {
+		t.Fatal(err)
+	}
+	saved := m.GetFile("abc")
+
+	const checksum = "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7"
+	m.SetChecksum("abc", checksum)
+	m.SetChecksum("unknown", checksum)
+
+	if saved.SHA256 != "" {
+		t.Errorf("entry held before SetChecksum changed to %q", saved.SHA256)
+	}
+	if file := m.GetFile("abc"); file == nil || file.SHA256 != checksum {
+		t.Errorf("file = %+v, want checksum %s", file, checksum)
+	}
+
+	// A restarted manager reloads the checksum from the tracking file
+	restored := NewManager(m.cfg)
+	if file := restored.GetFile("abc"); file == nil || file.SHA256 != checksum {
+		t.Errorf("restored file = %+v, want checksum %s", file, checksum)
+	}
+	if file := restored.GetFile("unknown"); file != nil {
+		t.Errorf("restored unknown file = %+v, want none", file)
+	}
+}
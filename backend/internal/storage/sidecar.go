@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// GzipSidecarSuffix is appended to a stored file's path for its gzip-compressed copy
+const GzipSidecarSuffix = ".gz"
+
+// compressibleExtensions are text-based outputs worth pre-compressing
+// Media containers are already compressed and are always served as-is
+var compressibleExtensions = map[string]bool{
+	".srt": true, ".vtt": true, ".ass": true, ".ssa": true, ".ttml": true, ".lrc": true,
+	".json": true, ".txt": true, ".xml": true, ".csv": true,
+}
+
+// sidecarMu serializes sidecar generation so concurrent first requests compress once
+var sidecarMu sync.Mutex
+
+// IsCompressible reports whether a file is served with a gzip sidecar
+func IsCompressible(filename string) bool {
+	return compressibleExtensions[strings.ToLower(filepath.Ext(filename))]
+}
+
+// GzipSidecar returns the path of the gzip copy of filePath, generating it on first use
+// A sidecar older than its source is regenerated
+func GzipSidecar(filePath string) (string, error) {
+	sidecarPath := filePath + GzipSidecarSuffix
+
+	sidecarMu.Lock()
+	defer sidecarMu.Unlock()
+
+	source, err := os.Stat(filePath)
+	if err != nil {
+		return "", err
+	}
+	if sidecar, err := os.Stat(sidecarPath); err == nil && !sidecar.ModTime().Before(source.ModTime()) {
+		return sidecarPath, nil
+	}
+
+	in, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	// Compress into a temp file so readers never see a partial sidecar
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".gz-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	gz := gzip.NewWriter(tmp)
+	if _, err := io.Copy(gz, in); err != nil {
+		gz.Close()
+		tmp.Close()
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(tmp.Name(), sidecarPath); err != nil {
+		return "", err
+	}
+	return sidecarPath, nil
+}
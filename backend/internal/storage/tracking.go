@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"videodownload/internal/model"
+	"videodownload/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// loadTracking restores the tracked files from the tracking file
+// Entries whose file is gone are dropped; expired entries are dropped and their file deleted,
+// since no later sweep would know about it
+func (m *Manager) loadTracking() {
+	if m.cfg.TrackingFile == "" {
+		return
+	}
+
+	data, err := os.ReadFile(m.cfg.TrackingFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Logger.Warn("Failed to read tracking file", zap.String("path", m.cfg.TrackingFile), zap.Error(err))
+		}
+		return
+	}
+
+	var saved []*model.DownloadedFile
+	if err := json.Unmarshal(data, &saved); err != nil {
+		logger.Logger.Warn("Ignoring malformed tracking file", zap.String("path", m.cfg.TrackingFile), zap.Error(err))
+		return
+	}
+
+	now := time.Now()
+	dropped := 0
+	for _, file := range saved {
+		if file == nil || file.ID == "" {
+			continue
+		}
+		if _, err := os.Stat(file.FilePath); err != nil {
+			dropped++
+			continue
+		}
+		if now.After(file.ExpiresAt) {
+			os.Remove(file.FilePath)
+			os.Remove(file.FilePath + GzipSidecarSuffix)
+			m.removeEmptyParentDirs(file.FilePath)
+			dropped++
+			continue
+		}
+		m.files[file.ID] = file
+		m.totalBytes += file.Size
+	}
+
+	logger.Logger.Info("Tracked files restored",
+		zap.Int("files", len(m.files)),
+		zap.Int("dropped", dropped),
+		zap.Int64("total_bytes", m.totalBytes))
+}
+
+// saveTracking writes the tracked files to the tracking file, replacing it atomically
+func (m *Manager) saveTracking() {
+	if m.cfg.TrackingFile == "" {
+		return
+	}
+
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+
+	m.mu.RLock()
+	files := make([]*model.DownloadedFile, 0, len(m.files))
+	for _, file := range m.files {
+		files = append(files, file)
+	}
+	data, err := json.Marshal(files)
+	m.mu.RUnlock()
+	if err != nil {
+		logger.Logger.Error("Failed to encode tracked files", zap.Error(err))
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.cfg.TrackingFile), 0755); err != nil {
+		logger.Logger.Error("Failed to create tracking directory", zap.String("path", m.cfg.TrackingFile), zap.Error(err))
+		return
+	}
+
+	tmpPath := m.cfg.TrackingFile + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		logger.Logger.Error("Failed to write tracking file", zap.String("path", tmpPath), zap.Error(err))
+		return
+	}
+	if err := os.Rename(tmpPath, m.cfg.TrackingFile); err != nil {
+		os.Remove(tmpPath)
+		logger.Logger.Error("Failed to replace tracking file", zap.String("path", m.cfg.TrackingFile), zap.Error(err))
+	}
+}
@@ -12,10 +12,12 @@ import (
 
 	"videodownload/config"
 	"videodownload/internal/handler"
+	"videodownload/internal/rpcapi"
 	"videodownload/internal/service"
 	"videodownload/internal/storage"
 	"videodownload/pkg/logger"
 	"videodownload/pkg/middleware"
+	"videodownload/pkg/tracing"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -37,6 +39,9 @@ func main() {
 		zap.Int("port", cfg.Server.Port),
 	)
 
+	tracing.Init(&cfg.Tracing)
+	defer tracing.Shutdown()
+
 	// Initialize storage manager
 	storageManager := storage.NewManager(&cfg.Storage)
 	if err := storageManager.EnsureDownloadDir(); err != nil {
@@ -52,17 +57,38 @@ func main() {
 		cfg.Python.Timeout,
 		cfg,
 	)
+	lifetimeStats := service.NewLifetimeStats(&cfg.Stats)
+	defer lifetimeStats.Stop()
 	downloadService := service.NewDownloadService(
 		cfg.Python.Host,
 		cfg.Python.Port,
 		cfg.Python.Timeout,
 		storageManager,
+		videoService,
+		lifetimeStats,
+		cfg,
 	)
 
 	// Initialize quota service
-	quotaService := service.NewQuotaService(&cfg.Quota)
+	var quotaStore service.QuotaStore
+	if cfg.Quota.StorePath != "" {
+		quotaStore = service.NewFileQuotaStore(cfg.Quota.StorePath)
+	}
+	quotaService := service.NewQuotaService(&cfg.Quota, quotaStore)
 	defer quotaService.Stop()
 
+	// Initialize async download jobs
+	jobManager := service.NewJobManager(downloadService, quotaService, cfg)
+
+	// Initialize batch service; batch items run as jobs
+	batchService := service.NewBatchService(downloadService, jobManager, cfg)
+
+	// Download request checks shared by REST and RPC
+	downloadValidator := service.NewDownloadValidator(videoService, cfg)
+	if len(cfg.Callback.AllowedDomains) > 0 && cfg.Callback.Secret == "" {
+		logger.Logger.Warn("CALLBACK_ALLOWED_DOMAINS is set without CALLBACK_SECRET; requests with callback_url will be refused")
+	}
+
 	// Initialize rate limit service
 	rateLimitService := service.NewRateLimitService(&cfg.RateLimit)
 	defer rateLimitService.Stop()
@@ -70,54 +96,113 @@ func main() {
 	// Setup Gin router
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
+	// Only TRUSTED_PROXIES may set the client IP through X-Forwarded-For
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logger.Logger.Fatal("Invalid TRUSTED_PROXIES", zap.Error(err))
+	}
 
 	// Add middleware
-	router.Use(logger.GinLogger())
+	router.Use(logger.GinLogger(cfg.Logging.AccessLogExcludePaths))
+	router.Use(tracing.Middleware())
+
+	// Initialize per-API-key limit profiles
+	profileService, err := service.NewProfileService(cfg)
+	if err != nil {
+		logger.Logger.Fatal("Failed to load limit profiles", zap.Error(err))
+	}
+	router.Use(middleware.ClientProfileMiddleware(profileService, cfg.ClientLimits.APIKeyHeader))
+	router.Use(middleware.RouteTimeoutMiddleware(cfg.Server.RouteTimeouts))
 
 	// Add rate limiting middleware
 	if cfg.RateLimit.Enabled {
-		router.Use(middleware.RateLimitMiddleware(rateLimitService))
+		router.Use(middleware.RateLimitMiddleware(rateLimitService, cfg.RateLimit.ExemptPaths))
 		logger.Logger.Info("Rate limiting enabled", zap.Int("requests_per_minute", cfg.RateLimit.RequestsPerMinute))
 	}
 
-	// Add quota check middleware
+	// Quota is enforced only on the routes that start downloads
+	quotaCheck := func(c *gin.Context) { c.Next() }
 	if cfg.Quota.Enabled {
-		router.Use(middleware.QuotaCheckMiddleware(quotaService))
+		quotaCheck = middleware.QuotaCheckMiddleware(quotaService, cfg)
 		logger.Logger.Info("Quota limiting enabled", zap.Int64("daily_limit_mb", cfg.Quota.DailyLimitMB), zap.Int("reset_hour", cfg.Quota.ResetHour))
 	}
 
-	// Determine frontend path
-	frontendPath := "./frontend"
-	if _, err := os.Stat("../frontend"); err == nil {
-		frontendPath = "../frontend"
+	// Consecutive download starts of a client are spaced out independently of the rate limit
+	// Info extraction has its own per-client concurrency cap, separate from downloads
+	infoConcurrency := func(c *gin.Context) { c.Next() }
+	if cfg.ClientLimits.MaxConcurrentInfo > 0 {
+		infoConcurrency = middleware.InfoConcurrencyMiddleware(service.NewConcurrencyLimiter(), cfg.ClientLimits.MaxConcurrentInfo)
 	}
-	staticPath := filepath.Join(frontendPath, "static")
-	indexPath := filepath.Join(frontendPath, "index.html")
 
-	logger.Logger.Info("Frontend paths",
-		zap.String("static", staticPath),
-		zap.String("index", indexPath))
+	minInterval := func(c *gin.Context) { c.Next() }
+	if cfg.RateLimit.MinDownloadInterval > 0 {
+		minInterval = middleware.MinIntervalMiddleware(service.NewIntervalLimiter(time.Duration(cfg.RateLimit.MinDownloadInterval) * time.Second))
+		logger.Logger.Info("Minimum download interval enabled", zap.Int("seconds", cfg.RateLimit.MinDownloadInterval))
+	}
+
+	// JSON endpoints refuse other body types up front
+	requireJSON := middleware.RequireJSON()
 
 	// Public frontend
-	router.Static("/static", staticPath)
-	router.StaticFile("/", indexPath)
+	registerFrontend(router, cfg.Server.ServeFrontend)
 
 	// API handlers
-	videoHandler := handler.NewVideoHandler(videoService, cfg)
-	downloadHandler := handler.NewDownloadHandler(downloadService, cfg, quotaService, rateLimitService)
+	videoHandler := handler.NewVideoHandler(videoService, storageManager, cfg)
+	feedHandler := handler.NewFeedHandler(downloadService, cfg)
+	metricsHandler := handler.NewMetricsHandler(quotaService, rateLimitService, storageManager, lifetimeStats)
+	downloadSwitch := service.NewDownloadSwitch(cfg.Server.DownloadsDisabled)
+	adminHandler := handler.NewAdminHandler(service.NewCookieService(&cfg.Admin), downloadSwitch, downloadService, batchService, jobManager, lifetimeStats)
+	downloadHandler := handler.NewDownloadHandler(downloadService, videoService, batchService, jobManager, cfg, quotaService, rateLimitService, downloadSwitch, downloadValidator)
+
+	// Optional gRPC interface for service-to-service callers
+	if cfg.RPC.Enabled {
+		if cfg.ClientLimits.ProfilesFile == "" {
+			logger.Logger.Warn("RPC enabled without LIMIT_PROFILES_FILE; every call will be refused for lack of a known API key")
+		}
+		rpcServer := rpcapi.NewServer(cfg, videoService, downloadService, jobManager, quotaService, rateLimitService, profileService, downloadSwitch, downloadValidator)
+		if err := rpcServer.Start(); err != nil {
+			logger.Logger.Fatal("Failed to start RPC server", zap.Error(err))
+		}
+		defer rpcServer.Stop()
+	}
 
 	// Routes
 	api := router.Group("/api")
 	{
 		// Video info
-		api.GET("/video/info", videoHandler.GetVideoInfo)
+		api.GET("/video/info", infoConcurrency, videoHandler.GetVideoInfo)
+		api.GET("/video/manifest", infoConcurrency, videoHandler.GetManifest)
+		api.GET("/validate", videoHandler.ValidateURL)
 
 		// Downloads
-		api.POST("/download", downloadHandler.StartDownload)
+		api.POST("/download", requireJSON, quotaCheck, minInterval, downloadHandler.StartDownload)
+		api.POST("/download/batch", requireJSON, quotaCheck, minInterval, downloadHandler.StartBatchDownload)
+		api.POST("/download/playlist", requireJSON, quotaCheck, minInterval, downloadHandler.StartPlaylistDownload)
+		api.POST("/download/cancel-all", downloadHandler.CancelAllDownloads)
+		api.GET("/download/batch/:batchid", downloadHandler.GetBatchStatus)
+		api.GET("/download/batch/:batchid/zip", downloadHandler.GetBatchZip)
+		api.DELETE("/download/batch/:batchid", downloadHandler.CancelBatch)
+		api.GET("/download/status/:jobid", downloadHandler.GetJobStatus)
+		api.DELETE("/download/status/:jobid", downloadHandler.CancelJob)
+		api.GET("/download/progress/:id", downloadHandler.StreamJobProgress)
 		api.GET("/download/:id", downloadHandler.GetFile)
+		api.GET("/download/:id/checksum", downloadHandler.GetChecksum)
+		api.GET("/download/:id/info", downloadHandler.GetFileInfo)
+		api.GET("/downloads/feed", feedHandler.GetFeed)
+		api.GET("/downloads/export", feedHandler.ExportDownloads)
 
 		// Health check
 		api.GET("/health", videoHandler.HealthCheck)
+
+		// Metrics
+		api.GET("/metrics", metricsHandler.GetMetrics)
+
+		// Admin
+		admin := api.Group("/admin", middleware.AdminAuthMiddleware(cfg.Admin.APIKey, &cfg.Security))
+		admin.POST("/cookies/:profile", adminHandler.UploadCookies)
+		admin.GET("/downloads", adminHandler.GetDownloadSwitch)
+		admin.PUT("/downloads", requireJSON, adminHandler.SetDownloadSwitch)
+		admin.POST("/downloads/cancel-all", adminHandler.CancelAllForIP)
+		admin.GET("/stats", adminHandler.GetStats)
 	}
 
 	// Start server
@@ -137,6 +222,16 @@ func main() {
 		}
 	}()
 
+	// SIGHUP reloads runtime switches
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for range hupChan {
+			logger.Logger.Info("SIGHUP received, reloading runtime configuration")
+			downloadSwitch.SetDisabled(config.Reload().Server.DownloadsDisabled, "sighup")
+		}
+	}()
+
 	// Wait for interrupt signal
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -154,3 +249,27 @@ func main() {
 
 	logger.Logger.Info("Server stopped")
 }
+
+// registerFrontend serves the static frontend, preferring ../frontend when present
+// When disabled no route is registered and the filesystem isn't probed
+func registerFrontend(router *gin.Engine, enabled bool) {
+	if !enabled {
+		logger.Logger.Info("Frontend serving disabled")
+		return
+	}
+
+	// Determine frontend path
+	frontendPath := "./frontend"
+	if _, err := os.Stat("../frontend"); err == nil {
+		frontendPath = "../frontend"
+	}
+	staticPath := filepath.Join(frontendPath, "static")
+	indexPath := filepath.Join(frontendPath, "index.html")
+
+	logger.Logger.Info("Frontend paths",
+		zap.String("static", staticPath),
+		zap.String("index", indexPath))
+
+	router.Static("/static", staticPath)
+	router.StaticFile("/", indexPath)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"videodownload/config"
+	"videodownload/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestRegisterFrontend(t *testing.T) {
+	logger.Logger = zap.NewNop()
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name          string
+		serveFrontend string
+		wantRoutes    int
+	}{
+		{"enabled by default", "", 4}, // GET and HEAD of / and /static/*filepath
+		{"disabled", "false", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SERVE_FRONTEND", tt.serveFrontend)
+			router := gin.New()
+			registerFrontend(router, config.Load().Server.ServeFrontend)
+
+			if routes := router.Routes(); len(routes) != tt.wantRoutes {
+				t.Errorf("registered %d routes (%v), want %d", len(routes), routes, tt.wantRoutes)
+			}
+			if tt.wantRoutes > 0 {
+				return
+			}
+			for _, path := range []string{"/", "/static/app.js"} {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+				if w.Code != http.StatusNotFound {
+					t.Errorf("GET %s = %d, want 404", path, w.Code)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package i18n
+
+import (
+	"strings"
+)
+
+// DefaultLanguage is used when the caller does not accept any supported language
+const DefaultLanguage = "en"
+
+// catalog maps language -> error code -> localized message
+// Codes whose message carries request-specific details (sizes, limits) are not listed,
+// so the detailed English message is kept for them
+var catalog = map[string]map[string]string{
+	"en": {
+		"invalid_request":           "Invalid request format",
+		"invalid_url":               "Video URL is required",
+		"invalid_domain":            "URL domain is not allowed",
+		"invalid_format":            "Invalid format ID",
+		"invalid_id":                "File ID is required",
+		"not_found":                 "File not found or has expired",
+		"fetch_failed":              "Failed to fetch video information",
+		"quota_limit":               "Server is currently under maintenance. Please try again later.",
+		"quota_exhausted":           "Daily download quota exhausted. Please try again after quota reset.",
+		"rate_limit_exceeded":       "Too many requests. Please try again later.",
+		"manifest_disabled":         "Manifest passthrough is not enabled on this server",
+		"manifest_unavailable":      "No streaming manifest is available for this format",
+		"manifest_host_not_allowed": "Manifest host is not allowed",
+		"size_mismatch":             "Reported file size does not match the selected format",
+		"geo_blocked":               "The selected format is not available in this region",
+		"malformed_url":             "URL is malformed",
+		"unsupported_scheme":        "Only http and https URLs are supported",
+		"insecure_url":              "Only https URLs are allowed",
+		"unauthorized":              "Admin credentials required",
+		"invalid_profile":           "Invalid cookie profile name",
+		"invalid_callback":          "Callback URL host is not allowed",
+		"invalid_filename":          "Filename must be a plain, non-empty file name",
+		"downloads_disabled":        "Downloads are temporarily disabled. Please try again later.",
+		"download_timeout":          "Download took too long and was cancelled",
+		"download_cancelled":        "Download was cancelled",
+		"unsupported_media_type":    "Content-Type must be application/json",
+		"expired":                   "File has expired; download the video again",
+		"format_unavailable":        "The selected format is not available for this video",
+		"worker_bad_response":       "The worker returned an unexpected response instead of the video",
+		"request_timeout":           "Request took too long and was cancelled",
+		"storage_full":              "Server storage is full. Please try again later.",
+		"unresolvable_url":          "URL redirects could not be followed",
+		"invalid_video_token":       "Video token is invalid",
+		"download_pending":          "Download is not finished yet",
+		"progress_stream_limit":     "Too many open progress streams; close one and retry",
+		"job_started":               "Only scheduled jobs can be cancelled",
+		"quality_required":          "A quality is required to download a playlist",
+		"not_playlist":              "URL is not a playlist",
+		"playlist_failed":           "No video of the playlist could be downloaded",
+	},
+	"id": {
+		"invalid_request":           "Format permintaan tidak valid",
+		"invalid_url":               "URL video wajib diisi",
+		"invalid_domain":            "Domain URL tidak diizinkan",
+		"invalid_format":            "Format ID tidak valid",
+		"invalid_id":                "File ID wajib diisi",
+		"not_found":                 "File tidak ditemukan atau sudah kedaluwarsa",
+		"fetch_failed":              "Gagal mengambil informasi video",
+		"quota_limit":               "Server sedang dalam pemeliharaan. Silakan coba lagi nanti.",
+		"quota_exhausted":           "Kuota unduhan harian sudah habis. Silakan coba lagi setelah kuota direset.",
+		"rate_limit_exceeded":       "Terlalu banyak permintaan. Silakan coba lagi nanti.",
+		"manifest_disabled":         "Manifest passthrough tidak diaktifkan di server ini",
+		"manifest_unavailable":      "Manifest streaming tidak tersedia untuk format ini",
+		"manifest_host_not_allowed": "Host manifest tidak diizinkan",
+		"size_mismatch":             "Ukuran file yang dilaporkan tidak sesuai dengan format yang dipilih",
+		"geo_blocked":               "Format yang dipilih tidak tersedia di wilayah ini",
+		"malformed_url":             "Format URL tidak valid",
+		"unsupported_scheme":        "Hanya URL http dan https yang didukung",
+		"insecure_url":              "Hanya URL https yang diizinkan",
+		"unauthorized":              "Diperlukan kredensial admin",
+		"invalid_profile":           "Nama profil cookie tidak valid",
+		"invalid_callback":          "Host callback URL tidak diizinkan",
+		"invalid_filename":          "Nama file harus berupa nama file biasa dan tidak kosong",
+		"downloads_disabled":        "Download sedang dinonaktifkan sementara. Silakan coba lagi nanti.",
+		"download_timeout":          "Unduhan terlalu lama dan dibatalkan",
+		"download_cancelled":        "Unduhan dibatalkan",
+		"unsupported_media_type":    "Content-Type harus application/json",
+		"expired":                   "File sudah kedaluwarsa; silakan unduh ulang videonya",
+		"format_unavailable":        "Format yang dipilih tidak tersedia untuk video ini",
+		"worker_bad_response":       "Worker mengembalikan respons yang tidak terduga, bukan video",
+		"request_timeout":           "Permintaan terlalu lama dan dibatalkan",
+		"storage_full":              "Penyimpanan server penuh. Silakan coba lagi nanti.",
+		"unresolvable_url":          "Redirect URL tidak dapat diikuti",
+		"invalid_video_token":       "Video token tidak valid",
+		"download_pending":          "Download belum selesai",
+		"progress_stream_limit":     "Terlalu banyak stream progres yang terbuka; tutup salah satu lalu coba lagi",
+		"job_started":               "Hanya job terjadwal yang bisa dibatalkan",
+		"quality_required":          "Kualitas wajib diisi untuk download playlist",
+		"not_playlist":              "URL bukan playlist",
+		"playlist_failed":           "Tidak ada video dari playlist yang berhasil diunduh",
+	},
+}
+
+// Localize returns the message for code in the best language from an Accept-Language header
+// Falls back to the given message when the code has no catalog entry for that language
+func Localize(acceptLanguage string, code string, fallback string) string {
+	lang := ParseAcceptLanguage(acceptLanguage)
+	if lang == DefaultLanguage {
+		return fallback
+	}
+	if message, ok := catalog[lang][code]; ok {
+		return message
+	}
+	return fallback
+}
+
+// ParseAcceptLanguage returns the first supported language from an Accept-Language header
+// Entries are considered in the order given; entries with q=0 are skipped
+func ParseAcceptLanguage(header string) string {
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" {
+			continue
+		}
+
+		rejected := false
+		for _, param := range fields[1:] {
+			param = strings.ReplaceAll(strings.TrimSpace(param), " ", "")
+			if param == "q=0" || param == "q=0.0" || param == "q=0.00" || param == "q=0.000" {
+				rejected = true
+			}
+		}
+		if rejected {
+			continue
+		}
+
+		// Match on the primary subtag (e.g. "id-ID" -> "id")
+		if i := strings.Index(tag, "-"); i > 0 {
+			tag = tag[:i]
+		}
+		if _, ok := catalog[tag]; ok {
+			return tag
+		}
+	}
+	return DefaultLanguage
+}
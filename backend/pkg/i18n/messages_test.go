@@ -0,0 +1,40 @@
+package i18n
+
+import "testing"
+
+func TestLocalize(t *testing.T) {
+	const fallback = "File not found or has expired"
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		code           string
+		want           string
+	}{
+		{"no header", "", "not_found", fallback},
+		{"english", "en-US,en;q=0.9", "not_found", fallback},
+		{"indonesian", "id", "not_found", "File tidak ditemukan atau sudah kedaluwarsa"},
+		{"indonesian region subtag", "id-ID,en;q=0.5", "not_found", "File tidak ditemukan atau sudah kedaluwarsa"},
+		{"first supported language wins", "fr-FR, id;q=0.8, en;q=0.5", "not_found", "File tidak ditemukan atau sudah kedaluwarsa"},
+		{"q=0 refuses a language", "id;q=0, en", "not_found", fallback},
+		{"unsupported language", "fr-FR", "not_found", fallback},
+		{"code without a translation keeps the detailed message", "id", "file_too_large", fallback},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Localize(tt.acceptLanguage, tt.code, fallback); got != tt.want {
+				t.Errorf("Localize(%q, %q) = %q, want %q", tt.acceptLanguage, tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCatalogIsComplete(t *testing.T) {
+	for lang, messages := range catalog {
+		for code := range catalog[DefaultLanguage] {
+			if messages[code] == "" {
+				t.Errorf("catalog %q has no message for %q", lang, code)
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+const (
+	requestLoggerContextKey = "request_logger"
+	maxRequestIDLength      = 64
+)
+
+// attachRequestLogger stores a child logger carrying the request's correlation fields in the context
+// A well-formed incoming request ID is reused so logs can be joined with upstream proxies
+func attachRequestLogger(c *gin.Context) *zap.Logger {
+	requestID := c.GetHeader(RequestIDHeader)
+	if !validRequestID(requestID) {
+		requestID = newRequestID()
+	}
+	c.Header(RequestIDHeader, requestID)
+
+	requestLogger := Logger.With(
+		zap.String("request_id", requestID),
+		zap.String("method", c.Request.Method),
+		zap.String("path", c.Request.URL.Path),
+	)
+	c.Set(requestLoggerContextKey, requestLogger)
+	return requestLogger
+}
+
+// FromContext returns the request-scoped logger, or the global logger outside a request
+func FromContext(c *gin.Context) *zap.Logger {
+	if c != nil {
+		if value, exists := c.Get(requestLoggerContextKey); exists {
+			if requestLogger, ok := value.(*zap.Logger); ok {
+				return requestLogger
+			}
+		}
+	}
+	return Logger
+}
+
+// With adds fields to the request-scoped logger for the rest of the request
+func With(c *gin.Context, fields ...zap.Field) {
+	if _, exists := c.Get(requestLoggerContextKey); exists {
+		c.Set(requestLoggerContextKey, FromContext(c).With(fields...))
+	}
+}
+
+// validRequestID accepts short IDs made of letters, digits, '-' and '_'
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		isAlnum := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+		if !isAlnum && r != '-' && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID generates a random 16-byte hex request ID
+func newRequestID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
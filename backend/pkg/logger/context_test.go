@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestLoggerCorrelation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zapcore.DebugLevel)
+	previous := Logger
+	Logger = zap.New(core)
+	t.Cleanup(func() { Logger = previous })
+
+	router := gin.New()
+	router.Use(GinLogger(nil))
+	router.Use(func(c *gin.Context) { With(c, zap.String("client", "client-a")) })
+	router.GET("/api/video/info", func(c *gin.Context) {
+		FromContext(c).Info("Handler log")
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name          string
+		requestID     string
+		wantRequestID bool // The incoming ID is reused
+	}{
+		{"incoming ID reused", "req-123_abc", true},
+		{"missing ID generated", "", false},
+		{"malformed ID replaced", "bad id\nwith newline", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.TakeAll()
+			req := httptest.NewRequest(http.MethodGet, "/api/video/info?url=x", nil)
+			if tt.requestID != "" {
+				req.Header.Set(RequestIDHeader, tt.requestID)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			requestID := w.Header().Get(RequestIDHeader)
+			if !validRequestID(requestID) || (requestID == tt.requestID) != tt.wantRequestID {
+				t.Fatalf("response %s = %q, want reused: %v", RequestIDHeader, requestID, tt.wantRequestID)
+			}
+
+			// Both the handler's log and the access log carry the same correlation fields
+			entries := logs.TakeAll()
+			if len(entries) != 2 {
+				t.Fatalf("got %d log entries, want 2", len(entries))
+			}
+			for _, entry := range entries {
+				fields := entry.ContextMap()
+				want := map[string]string{"request_id": requestID, "method": "GET", "path": "/api/video/info", "client": "client-a"}
+				for key, value := range want {
+					if fields[key] != value {
+						t.Errorf("%q entry has %s = %v, want %q", entry.Message, key, fields[key], value)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFromContextOutsideRequest(t *testing.T) {
+	if FromContext(nil) != Logger {
+		t.Error("FromContext(nil) is not the global logger")
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if FromContext(c) != Logger {
+		t.Error("FromContext without GinLogger is not the global logger")
+	}
+	// With is a no-op without a request logger
+	With(c, zap.String("client", "x"))
+	if FromContext(c) != Logger {
+		t.Error("With attached a logger outside GinLogger")
+	}
+}
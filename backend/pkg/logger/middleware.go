@@ -2,6 +2,7 @@ package logger
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -9,9 +10,20 @@ import (
 )
 
 // GinLogger returns a middleware for logging HTTP requests
-func GinLogger() gin.HandlerFunc {
+// It also attaches the request-scoped logger returned by FromContext
+// Requests to excludePaths (e.g. health probes) are logged at DEBUG only
+func GinLogger(excludePaths []string) gin.HandlerFunc {
+	excluded := make(map[string]bool)
+	for _, path := range excludePaths {
+		path = strings.TrimSpace(path)
+		if path != "" {
+			excluded[path] = true
+		}
+	}
+
 	return func(c *gin.Context) {
 		startTime := time.Now()
+		attachRequestLogger(c)
 
 		// Process request
 		c.Next()
@@ -20,9 +32,15 @@ func GinLogger() gin.HandlerFunc {
 		duration := time.Since(startTime)
 		statusCode := c.Writer.Status()
 
-		Logger.Info("HTTP Request",
-			zap.String("method", c.Request.Method),
-			zap.String("path", c.Request.RequestURI),
+		// Fields added during the request (e.g. client) are picked up here
+		requestLogger := FromContext(c)
+		logFunc := requestLogger.Info
+		if excluded[c.Request.URL.Path] {
+			logFunc = requestLogger.Debug
+		}
+
+		logFunc("HTTP Request",
+			zap.String("uri", c.Request.RequestURI),
 			zap.String("ip", c.ClientIP()),
 			zap.Int("status", statusCode),
 			zap.Duration("duration", duration),
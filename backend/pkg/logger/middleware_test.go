@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestGinLoggerExcludePaths(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zapcore.DebugLevel)
+	previous := Logger
+	Logger = zap.New(core)
+	t.Cleanup(func() { Logger = previous })
+
+	router := gin.New()
+	router.Use(GinLogger([]string{"/api/health", " /api/health/live "}))
+	for _, path := range []string{"/api/health", "/api/health/live", "/api/video/info"} {
+		router.GET(path, func(c *gin.Context) { c.Status(http.StatusOK) })
+	}
+
+	tests := []struct {
+		path string
+		want zapcore.Level
+	}{
+		{"/api/health", zapcore.DebugLevel},
+		{"/api/health/live", zapcore.DebugLevel},
+		{"/api/health?probe=1", zapcore.DebugLevel},
+		{"/api/video/info", zapcore.InfoLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			logs.TakeAll()
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			entries := logs.FilterMessage("HTTP Request").TakeAll()
+			if len(entries) != 1 {
+				t.Fatalf("got %d access log entries, want 1", len(entries))
+			}
+			if entries[0].Level != tt.want {
+				t.Errorf("access log level = %s, want %s", entries[0].Level, tt.want)
+			}
+		})
+	}
+}
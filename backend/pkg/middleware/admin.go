@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"videodownload/internal/model"
+	"videodownload/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AdminKeyHeader carries the admin API key
+const AdminKeyHeader = "X-Admin-Key"
+
+// AdminAuthMiddleware restricts a route to callers presenting the admin API key
+// Admin routes answer 404 when no admin key is configured, so they are not discoverable
+func AdminAuthMiddleware(adminKey string, security *model.SecurityConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if adminKey == "" {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		if !IsAdminRequest(c, adminKey) {
+			logger.FromContext(c).Warn("Rejected admin request", zap.String("ip", c.ClientIP()), zap.String("path", c.Request.URL.Path))
+			ErrorJitter(security)
+			abortWithError(c, http.StatusUnauthorized, "unauthorized", "Admin credentials required")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// IsAdminRequest reports whether the request presents the admin API key
+// Always false when no admin key is configured
+func IsAdminRequest(c *gin.Context, adminKey string) bool {
+	provided := c.GetHeader(AdminKeyHeader)
+	return adminKey != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(adminKey)) == 1
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"videodownload/internal/model"
+	"videodownload/internal/service"
+	"videodownload/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	clientKeyContextKey    = "client_key"
+	limitProfileContextKey = "limit_profile"
+)
+
+// ClientProfileMiddleware identifies the client and attaches its limit profile
+// Clients with a known API key are tracked by key, everyone else by IP
+func ClientProfileMiddleware(profileService *service.ProfileService, apiKeyHeader string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey := c.GetHeader(apiKeyHeader)
+
+		clientKey := c.ClientIP()
+		if profileService.IsKnownKey(apiKey) {
+			clientKey = "key:" + apiKey
+		}
+
+		profile := profileService.GetProfile(apiKey)
+		c.Set(clientKeyContextKey, clientKey)
+		c.Set(limitProfileContextKey, profile)
+
+		// The client key may embed the API key, so logs carry the IP and profile name instead
+		logger.With(c, zap.String("client", c.ClientIP()), zap.String("profile", profile.Name))
+
+		c.Next()
+	}
+}
+
+// GetClientKey returns the identity used for per-client limits
+// Falls back to the client IP when ClientProfileMiddleware is not installed
+func GetClientKey(c *gin.Context) string {
+	if clientKey := c.GetString(clientKeyContextKey); clientKey != "" {
+		return clientKey
+	}
+	return c.ClientIP()
+}
+
+// GetLimitProfile returns the caller's limit profile, or nil when none is attached
+func GetLimitProfile(c *gin.Context) *model.LimitProfile {
+	if value, exists := c.Get(limitProfileContextKey); exists {
+		if profile, ok := value.(*model.LimitProfile); ok {
+			return profile
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"videodownload/config"
+	"videodownload/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testProfiles maps two API keys to tiers with different limits
+const testProfiles = `{
+	"profiles": {
+		"free": {"daily_limit_mb": 100, "requests_per_minute": 2, "max_concurrent": 1},
+		"paid": {"daily_limit_mb": 5000, "requests_per_minute": 5, "max_concurrent": 4}
+	},
+	"keys": {"free-key": "free", "paid-key": "paid"}
+}`
+
+func TestClientProfilesPerKey(t *testing.T) {
+	profilesFile := filepath.Join(t.TempDir(), "profiles.json")
+	if err := os.WriteFile(profilesFile, []byte(testProfiles), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LIMIT_PROFILES_FILE", profilesFile)
+	t.Setenv("QUOTA_ENABLED", "true")
+	t.Setenv("QUOTA_DAILY_LIMIT_MB", "1000")
+	t.Setenv("MAX_VIDEO_SIZE_MB", "50")
+	t.Setenv("RATELIMIT_REQUESTS_PER_MINUTE", "3")
+	t.Setenv("RATELIMIT_BURST_SIZE", "0")
+	cfg := config.Load()
+
+	profileService, err := service.NewProfileService(cfg)
+	if err != nil {
+		t.Fatalf("NewProfileService: %v", err)
+	}
+	rateLimitService := service.NewRateLimitService(&cfg.RateLimit)
+	defer rateLimitService.Stop()
+	quotaService := service.NewQuotaService(&cfg.Quota, nil)
+	defer quotaService.Stop()
+
+	router := gin.New()
+	router.Use(ClientProfileMiddleware(profileService, cfg.ClientLimits.APIKeyHeader))
+	router.Use(RateLimitMiddleware(rateLimitService, nil))
+	router.GET("/api/download", QuotaCheckMiddleware(quotaService, cfg), func(c *gin.Context) {
+		c.String(http.StatusOK, GetLimitProfile(c).Name+" "+GetClientKey(c))
+	})
+
+	tests := []struct {
+		name        string
+		apiKey      string
+		wantProfile string
+		wantClient  string
+		wantAllowed int
+		wantQuotaMB string
+	}{
+		{"free key", "free-key", "free", "key:free-key", 2, "100"},
+		{"paid key", "paid-key", "paid", "key:paid-key", 5, "5000"},
+		{"unknown key falls back to defaults", "stolen-key", "default", "192.0.2.1", 3, "1000"},
+		{"no key falls back to defaults", "", "default", "192.0.2.1", 0, "1000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed := 0
+			for i := 0; i < 10; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/api/download", nil)
+				req.RemoteAddr = "192.0.2.1:1234"
+				if tt.apiKey != "" {
+					req.Header.Set(cfg.ClientLimits.APIKeyHeader, tt.apiKey)
+				}
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				if w.Code != http.StatusOK {
+					continue
+				}
+				allowed++
+				if want := tt.wantProfile + " " + tt.wantClient; w.Body.String() != want {
+					t.Errorf("profile and client = %q, want %q", w.Body.String(), want)
+				}
+				if got := w.Header().Get("X-Quota-Limit-MB"); got != tt.wantQuotaMB {
+					t.Errorf("X-Quota-Limit-MB = %q, want %q", got, tt.wantQuotaMB)
+				}
+			}
+			// Keyless requests share the IP bucket the unknown key already drained
+			if allowed != tt.wantAllowed {
+				t.Errorf("allowed %d of 10 requests, want %d", allowed, tt.wantAllowed)
+			}
+		})
+	}
+}
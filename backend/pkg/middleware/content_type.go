@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+
+	"videodownload/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RequireJSON rejects requests whose Content-Type is not application/json with 415
+// Handlers still parse the body with ShouldBindJSON
+func RequireJSON() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// ContentType strips parameters such as charset
+		if c.ContentType() != "application/json" {
+			logger.FromContext(c).Warn("Rejected request with unsupported Content-Type",
+				zap.String("content_type", c.GetHeader("Content-Type")))
+			abortWithError(c, http.StatusUnsupportedMediaType, "unsupported_media_type", "Content-Type must be application/json")
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"videodownload/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireJSON(t *testing.T) {
+	router := gin.New()
+	router.POST("/api/download", RequireJSON(), func(c *gin.Context) {
+		var req model.DownloadRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.Status(http.StatusAccepted)
+	})
+
+	tests := []struct {
+		name        string
+		contentType string
+		wantStatus  int
+	}{
+		{"json", "application/json", http.StatusAccepted},
+		{"json with charset", "application/json; charset=utf-8", http.StatusAccepted},
+		{"missing", "", http.StatusUnsupportedMediaType},
+		{"form", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"text", "text/plain", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"url": "https://www.youtube.com/watch?v=abc123", "format_id": "18"}`
+			req := httptest.NewRequest(http.MethodPost, "/api/download", strings.NewReader(body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d %s, want %d", w.Code, w.Body, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusUnsupportedMediaType {
+				var resp model.ErrorResponse
+				json.Unmarshal(w.Body.Bytes(), &resp)
+				if resp.Error != "unsupported_media_type" || resp.Message == "" {
+					t.Errorf("body = %s, want unsupported_media_type with a message", w.Body)
+				}
+			}
+		})
+	}
+}
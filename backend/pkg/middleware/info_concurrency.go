@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"videodownload/internal/service"
+	"videodownload/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// InfoConcurrencyMiddleware caps how many info extractions a client may have in flight
+// Counted separately from downloads, so one client can't occupy all worker info capacity
+func InfoConcurrencyMiddleware(limiter *service.ConcurrencyLimiter, limit int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientKey := GetClientKey(c)
+		if !limiter.TryAcquire(clientKey, limit) {
+			logger.FromContext(c).Warn("Concurrent info request limit reached", zap.Int("max_concurrent_info", limit))
+			abortWithError(c, http.StatusTooManyRequests, "info_concurrency_limit",
+				fmt.Sprintf("Too many info requests in progress: at most %d at a time", limit))
+			return
+		}
+		defer limiter.Release(clientKey)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"videodownload/internal/model"
+	"videodownload/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestInfoConcurrency(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	router := gin.New()
+	router.GET("/api/video/info", InfoConcurrencyMiddleware(service.NewConcurrencyLimiter(), 2), func(c *gin.Context) {
+		// Held requests stand in for slow extractions
+		if c.Query("hold") == "true" {
+			entered <- struct{}{}
+			<-release
+		}
+		c.Status(http.StatusOK)
+	})
+	info := func(remoteAddr, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/video/info"+query, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Saturate the first client's two slots
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if w := info("192.0.2.1:1234", "?hold=true"); w.Code != http.StatusOK {
+				t.Errorf("held request = %d, want 200", w.Code)
+			}
+		}()
+		<-entered
+	}
+
+	w := info("192.0.2.1:1234", "")
+	var body model.ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusTooManyRequests || body.Error != "info_concurrency_limit" {
+		t.Errorf("request over the cap = %d %s, want 429 info_concurrency_limit", w.Code, w.Body)
+	}
+
+	// Another client has its own slots
+	if w := info("192.0.2.2:1234", ""); w.Code != http.StatusOK {
+		t.Errorf("other client's request = %d, want 200", w.Code)
+	}
+
+	// Finished requests free their slots
+	close(release)
+	wg.Wait()
+	if w := info("192.0.2.1:1234", ""); w.Code != http.StatusOK {
+		t.Errorf("request after the held ones finished = %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+
+	"videodownload/internal/service"
+	"videodownload/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// MinIntervalMiddleware spaces out download starts of the same client by MIN_DOWNLOAD_INTERVAL
+// Unlike the per-minute rate limit, it only looks at the time since the client's previous start
+func MinIntervalMiddleware(limiter *service.IntervalLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, wait := limiter.Allow(GetClientKey(c))
+		if !allowed {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			logger.FromContext(c).Warn("Download started too soon after the previous one", zap.Duration("wait", wait))
+			c.Header("Retry-After", fmt.Sprintf("%d", retryAfter))
+			abortWithError(c, http.StatusTooManyRequests, "download_too_soon",
+				fmt.Sprintf("Please wait %d seconds before starting another download", retryAfter))
+			return
+		}
+		c.Next()
+	}
+}
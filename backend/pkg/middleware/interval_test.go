@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"videodownload/internal/model"
+	"videodownload/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMinDownloadInterval(t *testing.T) {
+	router := gin.New()
+	router.POST("/api/download", MinIntervalMiddleware(service.NewIntervalLimiter(200*time.Millisecond)), func(c *gin.Context) {
+		c.Status(http.StatusAccepted)
+	})
+	start := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/download", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := start("192.0.2.1:1234"); w.Code != http.StatusAccepted {
+		t.Fatalf("first start = %d, want 202", w.Code)
+	}
+
+	// A back-to-back start is refused with the time left to wait
+	w := start("192.0.2.1:1234")
+	var body model.ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusTooManyRequests || body.Error != "download_too_soon" {
+		t.Fatalf("back-to-back start = %d %s, want 429 download_too_soon", w.Code, w.Body)
+	}
+	if retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || retryAfter != 1 {
+		t.Errorf("Retry-After = %q, want 1", w.Header().Get("Retry-After"))
+	}
+
+	// Other clients are not held back
+	if w := start("192.0.2.2:1234"); w.Code != http.StatusAccepted {
+		t.Errorf("other client's start = %d, want 202", w.Code)
+	}
+
+	// Once the interval has passed the client may start again
+	time.Sleep(250 * time.Millisecond)
+	if w := start("192.0.2.1:1234"); w.Code != http.StatusAccepted {
+		t.Errorf("start after the interval = %d, want 202", w.Code)
+	}
+}
+
+func TestMinDownloadIntervalDisabled(t *testing.T) {
+	limiter := service.NewIntervalLimiter(0)
+	for i := 0; i < 5; i++ {
+		if allowed, _ := limiter.Allow("192.0.2.1"); !allowed {
+			t.Fatalf("start %d refused with the interval disabled", i+1)
+		}
+	}
+}
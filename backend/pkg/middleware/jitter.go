@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"math/rand"
+	"time"
+
+	"videodownload/internal/model"
+)
+
+// ErrorJitter sleeps for a random duration between ERROR_JITTER_MIN_MS and ERROR_JITTER_MAX_MS
+// Applied only on not-found and auth failure paths so response timing doesn't reveal
+// whether an ID once existed or how close a credential was
+func ErrorJitter(cfg *model.SecurityConfig) {
+	if cfg.ErrorJitterMaxMs <= 0 {
+		return
+	}
+
+	minMs := cfg.ErrorJitterMinMs
+	if minMs < 0 || minMs > cfg.ErrorJitterMaxMs {
+		minMs = 0
+	}
+
+	delay := minMs + rand.Intn(cfg.ErrorJitterMaxMs-minMs+1)
+	time.Sleep(time.Duration(delay) * time.Millisecond)
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"videodownload/internal/model"
+)
+
+func TestErrorJitter(t *testing.T) {
+	tests := []struct {
+		name    string
+		minMs   int
+		maxMs   int
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{"disabled by default", 0, 0, 0, 20 * time.Millisecond},
+		{"within the range", 40, 60, 40 * time.Millisecond, 60 * time.Millisecond},
+		{"min above max starts at zero", 80, 30, 0, 30 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &model.SecurityConfig{ErrorJitterMinMs: tt.minMs, ErrorJitterMaxMs: tt.maxMs}
+			for i := 0; i < 5; i++ {
+				start := time.Now()
+				ErrorJitter(cfg)
+				// Allow for scheduling delays past the upper bound
+				if elapsed := time.Since(start); elapsed < tt.wantMin || elapsed > tt.wantMax+20*time.Millisecond {
+					t.Errorf("delay = %v, want between %v and %v", elapsed, tt.wantMin, tt.wantMax)
+				}
+			}
+		})
+	}
+}
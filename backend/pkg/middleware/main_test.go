@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"os"
+	"testing"
+
+	"videodownload/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// TestMain silences logging once for every test of the package
+func TestMain(m *testing.M) {
+	logger.Logger = zap.NewNop()
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
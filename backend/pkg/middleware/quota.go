@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"videodownload/internal/model"
+	"videodownload/internal/service"
+	"videodownload/pkg/i18n"
+	"videodownload/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// QuotaCheckMiddleware enforces the daily download quota on the routes it is attached to
+// This is the single place a request's quota is decided; handlers only record usage afterwards
+func QuotaCheckMiddleware(quotaService *service.QuotaService, cfg *model.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// If daily quota is less than max file size, users can't download files successfully
+		if cfg.Quota.DailyLimitMB < int64(cfg.Storage.MaxVideoSizeMB) {
+			logger.FromContext(c).Error("Server configuration error: daily quota limit is less than max video size",
+				zap.Int64("daily_limit_mb", cfg.Quota.DailyLimitMB),
+				zap.Int64("max_video_size_mb", int64(cfg.Storage.MaxVideoSizeMB)))
+			abortWithError(c, http.StatusServiceUnavailable, "quota_limit", "Server is currently under maintenance. Please try again later.")
+			return
+		}
+
+		ip := GetClientKey(c)
+		limit := cfg.Quota.DailyLimitMB
+		if profile := GetLimitProfile(c); profile != nil {
+			limit = profile.DailyLimitMB
+		}
+
+		// File size is unknown yet, so only reject clients whose quota is completely exhausted
+		allowed, remainingMB := quotaService.CheckQuotaWithLimit(ip, 0, limit)
+		SetQuotaHeaders(c, quotaService.GetQuotaInfoWithLimit(ip, limit))
+
+		if !allowed && remainingMB == 0 {
+			logger.FromContext(c).Warn("Quota exhausted", zap.String("ip", ip))
+			abortWithError(c, http.StatusPaymentRequired, "quota_exhausted", "Daily download quota exhausted. Please try again after quota reset.")
+			return
+		}
+
+		logger.FromContext(c).Debug("Quota check passed", zap.String("ip", ip), zap.Int64("remaining_mb", remainingMB))
+		c.Next()
+	}
+}
+
+// SetQuotaHeaders reports a client's quota state in X-Quota-* response headers
+func SetQuotaHeaders(c *gin.Context, quotaInfo map[string]interface{}) {
+	if enabled, _ := quotaInfo["enabled"].(bool); !enabled {
+		return
+	}
+
+	c.Header("X-Quota-Limit-MB", fmt.Sprintf("%v", quotaInfo["limit_mb"]))
+	c.Header("X-Quota-Remaining-MB", fmt.Sprintf("%v", quotaInfo["remaining_mb"]))
+	if resetTime, ok := quotaInfo["reset_time"].(time.Time); ok {
+		c.Header("X-Quota-Reset", fmt.Sprintf("%d", resetTime.Unix()))
+	}
+}
+
+// abortWithError writes a localized error response and stops the handler chain
+func abortWithError(c *gin.Context, status int, code string, message string) {
+	c.AbortWithStatusJSON(status, model.ErrorResponse{
+		Error:   code,
+		Message: i18n.Localize(c.GetHeader("Accept-Language"), code, message),
+		Code:    status,
+	})
+}
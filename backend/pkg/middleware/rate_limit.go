@@ -3,8 +3,10 @@ package middleware
 import (
 	"fmt"
 	"net/http"
+	"strings"
 
 	"videodownload/internal/service"
+	"videodownload/pkg/i18n"
 	"videodownload/pkg/logger"
 
 	"github.com/gin-gonic/gin"
@@ -12,16 +14,35 @@ import (
 )
 
 // RateLimitMiddleware creates a middleware for rate limiting
-func RateLimitMiddleware(rateLimitService *service.RateLimitService) gin.HandlerFunc {
+// Requests to exemptPaths (health probes, static assets) are neither limited nor counted
+func RateLimitMiddleware(rateLimitService *service.RateLimitService, exemptPaths []string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
+		if isExemptPath(c.Request.URL.Path, exemptPaths) {
+			c.Next()
+			return
+		}
+
+		ip := GetClientKey(c)
+
+		// Use the caller's profile limit when one is attached
+		limit := 0
+		if profile := GetLimitProfile(c); profile != nil {
+			limit = profile.RequestsPerMinute
+		}
+
+		allowed := false
+		if limit > 0 {
+			allowed = rateLimitService.IsAllowedWithLimit(ip, limit)
+		} else {
+			allowed = rateLimitService.IsAllowed(ip)
+		}
 
 		// Check rate limit
-		if !rateLimitService.IsAllowed(ip) {
-			logger.Logger.Warn("Rate limit exceeded", zap.String("ip", ip))
+		if !allowed {
+			logger.FromContext(c).Warn("Rate limit exceeded", zap.String("ip", ip))
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error":   "rate_limit_exceeded",
-				"message": "Too many requests. Please try again later.",
+				"message": i18n.Localize(c.GetHeader("Accept-Language"), "rate_limit_exceeded", "Too many requests. Please try again later."),
 				"code":    http.StatusTooManyRequests,
 			})
 			c.Abort()
@@ -30,6 +51,9 @@ func RateLimitMiddleware(rateLimitService *service.RateLimitService) gin.Handler
 
 		// Set remaining requests header
 		remaining := rateLimitService.GetRemaining(ip)
+		if limit > 0 {
+			remaining = rateLimitService.GetRemainingWithLimit(ip, limit)
+		}
 		if remaining >= 0 {
 			c.Header("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
 		}
@@ -38,20 +62,16 @@ func RateLimitMiddleware(rateLimitService *service.RateLimitService) gin.Handler
 	}
 }
 
-// QuotaCheckMiddleware creates a middleware to check download quota
-func QuotaCheckMiddleware(quotaService *service.QuotaService) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Only check quota on download endpoints
-		if c.Request.URL.Path == "/api/download" && c.Request.Method == "POST" {
-			ip := c.ClientIP()
-
-			// Check quota info for logging
-			quotaInfo := quotaService.GetQuotaInfo(ip)
-			c.Set("quota_info", quotaInfo)
-
-			logger.Logger.Debug("Quota check", zap.String("ip", ip), zap.Any("quota_info", quotaInfo))
+// isExemptPath reports whether path is in exemptPaths
+// Entries ending in "/" (other than "/" itself) match every path below them
+func isExemptPath(path string, exemptPaths []string) bool {
+	for _, exempt := range exemptPaths {
+		if path == exempt {
+			return true
+		}
+		if exempt != "/" && strings.HasSuffix(exempt, "/") && strings.HasPrefix(path, exempt) {
+			return true
 		}
-
-		c.Next()
 	}
+	return false
 }
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"videodownload/config"
+	"videodownload/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimitExemptPaths(t *testing.T) {
+	t.Setenv("RATELIMIT_REQUESTS_PER_MINUTE", "2")
+	t.Setenv("RATELIMIT_BURST_SIZE", "0")
+	cfg := config.Load()
+	rateLimitService := service.NewRateLimitService(&cfg.RateLimit)
+	defer rateLimitService.Stop()
+
+	router := gin.New()
+	router.Use(RateLimitMiddleware(rateLimitService, cfg.RateLimit.ExemptPaths))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/api/health", ok)
+	router.GET("/static/app.js", ok)
+	router.POST("/api/download", ok)
+	request := func(method, path string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w.Code
+	}
+
+	// Probes and assets well past the limit are never throttled and use up nothing
+	for i := 0; i < 10; i++ {
+		if code := request(http.MethodGet, "/api/health"); code != http.StatusOK {
+			t.Fatalf("health check %d = %d, want 200", i+1, code)
+		}
+		if code := request(http.MethodGet, "/static/app.js"); code != http.StatusOK {
+			t.Fatalf("static asset %d = %d, want 200", i+1, code)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		wantStatus int
+	}{
+		{"first download", http.StatusOK},
+		{"second download", http.StatusOK},
+		{"third download is limited", http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		if code := request(http.MethodPost, "/api/download"); code != tt.wantStatus {
+			t.Errorf("%s = %d, want %d", tt.name, code, tt.wantStatus)
+		}
+	}
+	if code := request(http.MethodGet, "/api/health"); code != http.StatusOK {
+		t.Errorf("health check of a limited client = %d, want 200", code)
+	}
+}
@@ -0,0 +1,199 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"videodownload/internal/model"
+	"videodownload/pkg/i18n"
+	"videodownload/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// routeWriteSlack is the time left after a route timeout to write the 504
+const routeWriteSlack = 5 * time.Second
+
+// RouteTimeoutMiddleware bounds each request's context by the timeout configured for its route
+// timeouts maps route patterns (e.g. /api/video/info) to seconds; unlisted routes and 0 mean no limit
+// The handler runs against the deadline context while its response is buffered; at the deadline
+// the client gets a 504 right away, even from a handler that ignores the context.
+// Buffering rules out streaming, so file and progress routes must not be listed
+// The connection's write deadline is moved to match, so a route timeout longer than
+// SERVER_TIMEOUT takes effect instead of the server's WriteTimeout cutting the response off
+func RouteTimeoutMiddleware(timeouts map[string]int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		seconds := timeouts[c.FullPath()]
+		if seconds <= 0 {
+			c.Next()
+			return
+		}
+
+		timeout := time.Duration(seconds) * time.Second
+		if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(timeout + routeWriteSlack)); err != nil {
+			logger.FromContext(c).Debug("Could not set route write deadline", zap.Error(err))
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		acceptLanguage := c.GetHeader("Accept-Language")
+
+		original := c.Writer
+		buffered := &bufferedWriter{ResponseWriter: original, header: original.Header().Clone()}
+		c.Writer = buffered
+
+		finished := make(chan any, 1)
+		go func() {
+			defer func() { finished <- recover() }()
+			c.Next()
+		}()
+
+		var panicked any
+		select {
+		case panicked = <-finished:
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				buffered.timeOut()
+				logger.FromContext(c).Warn("Request exceeded route timeout", zap.Int("timeout_seconds", seconds))
+				writeTimeoutResponse(original, acceptLanguage)
+			}
+			// The handler still holds the gin.Context, which is reused once this middleware returns
+			panicked = <-finished
+		}
+
+		c.Writer = original
+		if panicked != nil {
+			panic(panicked)
+		}
+		if buffered.timedOut {
+			c.Abort()
+			return
+		}
+		buffered.flushTo(original)
+	}
+}
+
+// writeTimeoutResponse sends the 504 of a request that exceeded its route timeout
+// Content-Length is set so the client has the whole response before the handler returns, and
+// the connection is closed since it stays busy until then
+func writeTimeoutResponse(w gin.ResponseWriter, acceptLanguage string) {
+	body, _ := json.Marshal(model.ErrorResponse{
+		Error:   "request_timeout",
+		Message: i18n.Localize(acceptLanguage, "request_timeout", "Request took too long and was cancelled"),
+		Code:    http.StatusGatewayTimeout,
+	})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("Connection", "close")
+	w.WriteHeader(http.StatusGatewayTimeout)
+	w.Write(body)
+	w.Flush()
+}
+
+// bufferedWriter holds a timed route's response until its handler returns
+// Once timed out, further writes are dropped since the 504 was already sent
+type bufferedWriter struct {
+	gin.ResponseWriter
+	header   http.Header
+	body     bytes.Buffer
+	status   int
+	written  bool
+	timedOut bool
+	mu       sync.Mutex
+}
+
+func (w *bufferedWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if code > 0 && !w.written {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.written = true
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.written = true
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *bufferedWriter) Status() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.written
+}
+
+// Flush does nothing; the response is sent once the handler returns
+func (w *bufferedWriter) Flush() {}
+
+// timeOut drops the buffered response and any later writes
+func (w *bufferedWriter) timeOut() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timedOut = true
+	w.body.Reset()
+}
+
+// flushTo sends the buffered response through w
+func (w *bufferedWriter) flushTo(dst gin.ResponseWriter) {
+	header := dst.Header()
+	for key := range header {
+		if _, ok := w.header[key]; !ok {
+			delete(header, key)
+		}
+	}
+	for key, values := range w.header {
+		header[key] = values
+	}
+
+	if w.status != 0 {
+		dst.WriteHeader(w.status)
+	}
+	if w.written {
+		dst.WriteHeaderNow()
+		dst.Write(w.body.Bytes())
+	}
+}
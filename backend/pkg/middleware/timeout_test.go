@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"videodownload/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRouteTimeout(t *testing.T) {
+	// Each handler takes 1.5s; the stubborn one ignores its context
+	release := make(chan struct{})
+	slow := func(c *gin.Context) {
+		select {
+		case <-time.After(1500 * time.Millisecond):
+			c.Header("X-Handler", "done")
+			c.JSON(http.StatusOK, gin.H{"ok": true})
+		case <-c.Request.Context().Done():
+		}
+	}
+	stubborn := func(c *gin.Context) {
+		<-release
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	}
+
+	router := gin.New()
+	router.Use(RouteTimeoutMiddleware(map[string]int{
+		"/api/validate":       1,
+		"/api/video/manifest": 1,
+		"/api/video/info":     3,
+	}))
+	router.GET("/api/validate", slow)
+	router.GET("/api/video/manifest", stubborn)
+	router.GET("/api/video/info", slow)
+	router.GET("/api/download/:id", slow)
+	server := httptest.NewServer(router)
+	defer server.Close()
+	defer close(release)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		maxElapsed time.Duration
+	}{
+		{"handler past its route timeout", "/api/validate", http.StatusGatewayTimeout, 1400 * time.Millisecond},
+		{"handler ignoring the context", "/api/video/manifest", http.StatusGatewayTimeout, 1400 * time.Millisecond},
+		{"handler within a longer route timeout", "/api/video/info", http.StatusOK, 2500 * time.Millisecond},
+		{"route without a timeout", "/api/download/abc", http.StatusOK, 2500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			resp, err := http.Get(server.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			elapsed := time.Since(start)
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d %s, want %d", resp.StatusCode, body, tt.wantStatus)
+			}
+			if elapsed > tt.maxElapsed {
+				t.Errorf("response took %v, want at most %v", elapsed, tt.maxElapsed)
+			}
+			if tt.wantStatus == http.StatusGatewayTimeout {
+				var errResp model.ErrorResponse
+				json.Unmarshal(body, &errResp)
+				if errResp.Error != "request_timeout" {
+					t.Errorf("body = %s, want request_timeout", body)
+				}
+				return
+			}
+			if resp.Header.Get("X-Handler") != "done" || string(body) != `{"ok":true}` {
+				t.Errorf("response = %v %s, want the handler's headers and body", resp.Header, body)
+			}
+		})
+	}
+}
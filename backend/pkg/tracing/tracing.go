@@ -0,0 +1,335 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"videodownload/internal/model"
+	"videodownload/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// TraceparentHeader carries the W3C trace context to and from other services
+const TraceparentHeader = "traceparent"
+
+const (
+	// Span kinds as numbered by OTLP
+	kindInternal = 1
+	kindServer   = 2
+	kindClient   = 3
+
+	exportBatchSize = 100
+	exportInterval  = 5 * time.Second
+	spanBufferSize  = 2048
+)
+
+// exporter is the active exporter; nil means tracing is a no-op
+var exporter *Exporter
+
+type spanContextKey struct{}
+
+// spanContext identifies a span within a trace
+type spanContext struct {
+	traceID [16]byte
+	spanID  [8]byte
+}
+
+// Span is a timed operation within a trace
+// All methods are safe to call on a nil Span, which is what Start returns when tracing is off
+type Span struct {
+	spanContext
+	parentID   [8]byte
+	name       string
+	kind       int
+	start      time.Time
+	attributes map[string]string
+	err        error
+}
+
+// Init starts exporting spans to the OTLP/HTTP collector in cfg
+// Without an endpoint nothing is recorded and no trace headers are sent
+func Init(cfg *model.TracingConfig) {
+	if cfg.Endpoint == "" {
+		return
+	}
+	exporter = newExporter(cfg)
+	go exporter.run()
+	logger.Logger.Info("Tracing enabled", zap.String("endpoint", exporter.endpoint))
+}
+
+// Shutdown flushes buffered spans and stops the exporter
+func Shutdown() {
+	if exporter != nil {
+		exporter.stop()
+		exporter = nil
+	}
+}
+
+// Start begins an internal span as a child of the span in ctx
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return start(ctx, name, kindInternal)
+}
+
+// StartClient begins a span for an outbound call; pass its context to Inject
+func StartClient(ctx context.Context, name string) (context.Context, *Span) {
+	return start(ctx, name, kindClient)
+}
+
+func start(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	if exporter == nil {
+		return ctx, nil
+	}
+
+	span := &Span{name: name, kind: kind, start: time.Now()}
+	if parent, ok := ctx.Value(spanContextKey{}).(spanContext); ok {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else {
+		rand.Read(span.traceID[:])
+	}
+	rand.Read(span.spanID[:])
+
+	return context.WithValue(ctx, spanContextKey{}, span.spanContext), span
+}
+
+// SetAttribute records a string attribute on the span
+func (s *Span) SetAttribute(key string, value string) {
+	if s == nil {
+		return
+	}
+	if s.attributes == nil {
+		s.attributes = make(map[string]string)
+	}
+	s.attributes[key] = value
+}
+
+// End finishes the span; a non-nil err marks it as failed
+func (s *Span) End(err error) {
+	if s == nil || exporter == nil {
+		return
+	}
+	s.err = err
+	exporter.record(s, time.Now())
+}
+
+// Inject adds the traceparent header of the span in ctx to an outbound request
+func Inject(ctx context.Context, header http.Header) {
+	if sc, ok := ctx.Value(spanContextKey{}).(spanContext); ok {
+		header.Set(TraceparentHeader, fmt.Sprintf("00-%x-%x-01", sc.traceID, sc.spanID))
+	}
+}
+
+// parseTraceparent reads a W3C traceparent header ("00-<trace id>-<span id>-<flags>")
+func parseTraceparent(value string) (spanContext, bool) {
+	var sc spanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.traceID[:], []byte(parts[1])); err != nil || sc.traceID == [16]byte{} {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.spanID[:], []byte(parts[2])); err != nil || sc.spanID == [8]byte{} {
+		return sc, false
+	}
+	return sc, true
+}
+
+// Middleware opens a server span per request, continuing the caller's trace when a
+// valid traceparent header is present
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if exporter == nil {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		if parent, ok := parseTraceparent(c.GetHeader(TraceparentHeader)); ok {
+			ctx = context.WithValue(ctx, spanContextKey{}, parent)
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		ctx, span := start(ctx, c.Request.Method+" "+route, kindServer)
+		span.SetAttribute("http.method", c.Request.Method)
+		span.SetAttribute("http.route", route)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttribute("http.status_code", strconv.Itoa(status))
+		var err error
+		if status >= http.StatusInternalServerError {
+			err = fmt.Errorf("HTTP %d", status)
+		}
+		span.End(err)
+	}
+}
+
+// Exporter batches finished spans and posts them to an OTLP/HTTP endpoint as JSON
+type Exporter struct {
+	endpoint    string
+	serviceName string
+	httpClient  *http.Client
+	spans       chan otlpSpan
+	quitChan    chan bool
+	done        chan struct{}
+}
+
+func newExporter(cfg *model.TracingConfig) *Exporter {
+	return &Exporter{
+		endpoint:    strings.TrimRight(cfg.Endpoint, "/") + "/v1/traces",
+		serviceName: cfg.ServiceName,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		spans:       make(chan otlpSpan, spanBufferSize),
+		quitChan:    make(chan bool),
+		done:        make(chan struct{}),
+	}
+}
+
+// record queues a finished span, dropping it when the buffer is full
+func (e *Exporter) record(s *Span, end time.Time) {
+	span := otlpSpan{
+		TraceID:           hex.EncodeToString(s.traceID[:]),
+		SpanID:            hex.EncodeToString(s.spanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+	}
+	if s.parentID != [8]byte{} {
+		span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+	for key, value := range s.attributes {
+		span.Attributes = append(span.Attributes, otlpAttribute{Key: key, Value: otlpValue{StringValue: value}})
+	}
+	if s.err != nil {
+		span.Status = &otlpStatus{Code: 2, Message: s.err.Error()}
+	}
+
+	select {
+	case e.spans <- span:
+	default:
+	}
+}
+
+// run exports spans in batches until stopped
+func (e *Exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	var batch []otlpSpan
+	for {
+		select {
+		case span := <-e.spans:
+			batch = append(batch, span)
+			if len(batch) >= exportBatchSize {
+				e.export(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				e.export(batch)
+				batch = nil
+			}
+		case <-e.quitChan:
+			for len(e.spans) > 0 {
+				batch = append(batch, <-e.spans)
+			}
+			if len(batch) > 0 {
+				e.export(batch)
+			}
+			return
+		}
+	}
+}
+
+func (e *Exporter) stop() {
+	e.quitChan <- true
+	<-e.done
+}
+
+// export posts one batch; failures are logged and the batch is dropped
+func (e *Exporter) export(batch []otlpSpan) {
+	body, _ := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttribute{{Key: "service.name", Value: otlpValue{StringValue: e.serviceName}}}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "videodownload"},
+			Spans: batch,
+		}},
+	}}})
+
+	resp, err := e.httpClient.Post(e.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logger.Logger.Warn("Failed to export spans", zap.Error(err), zap.Int("spans", len(batch)))
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		logger.Logger.Warn("Span export rejected", zap.Int("status", resp.StatusCode), zap.Int("spans", len(batch)))
+	}
+}
+
+// OTLP/HTTP JSON encoding of ExportTraceServiceRequest
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
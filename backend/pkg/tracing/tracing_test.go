@@ -0,0 +1,156 @@
+package tracing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"videodownload/internal/model"
+	"videodownload/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// TestMain silences logging once for every test of the package
+func TestMain(m *testing.M) {
+	logger.Logger = zap.NewNop()
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// newTestCollector starts an OTLP/HTTP endpoint and returns the spans it receives
+func newTestCollector(t *testing.T) (*httptest.Server, func() []otlpSpan) {
+	t.Helper()
+	var mu sync.Mutex
+	var spans []otlpSpan
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("export path = %q, want /v1/traces", r.URL.Path)
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode export: %v", err)
+		}
+		mu.Lock()
+		for _, resource := range req.ResourceSpans {
+			for _, scope := range resource.ScopeSpans {
+				spans = append(spans, scope.Spans...)
+			}
+		}
+		mu.Unlock()
+	}))
+	t.Cleanup(collector.Close)
+
+	return collector, func() []otlpSpan {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]otlpSpan(nil), spans...)
+	}
+}
+
+func TestSpansPropagateToOutboundRequests(t *testing.T) {
+	collector, exported := newTestCollector(t)
+	Init(&model.TracingConfig{Endpoint: collector.URL, ServiceName: "test"})
+	t.Cleanup(Shutdown)
+
+	var outbound string
+	worker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outbound = r.Header.Get(TraceparentHeader)
+	}))
+	defer worker.Close()
+
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/api/video/info", func(c *gin.Context) {
+		ctx, span := StartClient(c.Request.Context(), "worker.info")
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, worker.URL, nil)
+		Inject(ctx, req.Header)
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		span.End(err)
+		c.Status(http.StatusOK)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const callerSpanID = "00f067aa0ba902b7"
+	req := httptest.NewRequest(http.MethodGet, "/api/video/info", nil)
+	req.Header.Set(TraceparentHeader, "00-"+traceID+"-"+callerSpanID+"-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	Shutdown()
+	spans := exported()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2: %+v", len(spans), spans)
+	}
+	byName := make(map[string]otlpSpan)
+	for _, span := range spans {
+		byName[span.Name] = span
+	}
+	server, ok := byName["GET /api/video/info"]
+	if !ok {
+		t.Fatalf("no server span in %+v", spans)
+	}
+	client, ok := byName["worker.info"]
+	if !ok {
+		t.Fatalf("no client span in %+v", spans)
+	}
+
+	if server.Kind != kindServer || client.Kind != kindClient {
+		t.Errorf("kinds = %d, %d, want %d, %d", server.Kind, client.Kind, kindServer, kindClient)
+	}
+	if server.TraceID != traceID || client.TraceID != traceID {
+		t.Errorf("trace IDs = %s, %s, want the caller's %s", server.TraceID, client.TraceID, traceID)
+	}
+	if server.ParentSpanID != callerSpanID {
+		t.Errorf("server span parent = %s, want %s", server.ParentSpanID, callerSpanID)
+	}
+	if client.ParentSpanID != server.SpanID {
+		t.Errorf("client span parent = %s, want server span %s", client.ParentSpanID, server.SpanID)
+	}
+	if want := "00-" + traceID + "-" + client.SpanID + "-01"; outbound != want {
+		t.Errorf("outbound traceparent = %q, want %q", outbound, want)
+	}
+}
+
+func TestTracingDisabled(t *testing.T) {
+	ctx, span := StartClient(httptest.NewRequest(http.MethodGet, "/", nil).Context(), "worker.info")
+	if span != nil {
+		t.Errorf("span = %+v, want nil without an exporter", span)
+	}
+	span.SetAttribute("key", "value")
+	span.End(nil)
+
+	header := http.Header{}
+	Inject(ctx, header)
+	if value := header.Get(TraceparentHeader); value != "" {
+		t.Errorf("traceparent = %q, want none without an exporter", value)
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		value string
+		ok    bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if _, ok := parseTraceparent(tt.value); ok != tt.ok {
+				t.Errorf("parseTraceparent(%q) ok = %v, want %v", tt.value, ok, tt.ok)
+			}
+		})
+	}
+}
@@ -5,14 +5,37 @@ import (
 	"strings"
 )
 
+// URL rejection reasons returned by CheckURL
+const (
+	URLMalformed         = "malformed_url"
+	URLUnsupportedScheme = "unsupported_scheme"
+	URLDomainNotAllowed  = "invalid_domain"
+	URLInsecure          = "insecure_url" // Plain http while HTTPS is required
+)
+
 // ValidateURL validates if the URL is a valid video URL
 func ValidateURL(videoURL string, allowedDomains []string) bool {
-	u, err := url.Parse(videoURL)
-	if err != nil {
-		return false
+	_, reason := CheckURL(videoURL, allowedDomains, false)
+	return reason == ""
+}
+
+// CheckURL runs the URL checks without contacting the worker
+// Returns the normalized domain and an empty reason when the URL is allowed,
+// otherwise one of the URL* rejection reasons. With requireHTTPS, http URLs are rejected as URLInsecure
+func CheckURL(videoURL string, allowedDomains []string, requireHTTPS bool) (string, string) {
+	u, err := url.Parse(strings.TrimSpace(videoURL))
+	if err != nil || u.Host == "" {
+		return "", URLMalformed
 	}
 
-	host := u.Host
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", URLUnsupportedScheme
+	}
+	if requireHTTPS && u.Scheme != "https" {
+		return "", URLInsecure
+	}
+
+	host := u.Hostname()
 	if strings.HasPrefix(host, "www.") {
 		host = host[4:]
 	}
@@ -29,6 +52,42 @@ func ValidateURL(videoURL string, allowedDomains []string) bool {
 
 		// Check if host matches or contains domain
 		if host == cleanDomain || strings.HasSuffix(host, "."+cleanDomain) || strings.Contains(host, cleanDomain) {
+			return host, ""
+		}
+	}
+
+	return host, URLDomainNotAllowed
+}
+
+// ValidateCallbackURL checks a client-supplied callback URL against an allowlist
+// Matching is exact or by subdomain only, so look-alike hosts can't be used for SSRF
+func ValidateCallbackURL(callbackURL string, allowedDomains []string) bool {
+	return ValidateHostURL(callbackURL, allowedDomains)
+}
+
+// ValidateHostURL checks that an http(s) URL without credentials points to an allowed host
+// Unlike CheckURL, matching is exact or by subdomain only, so youtube.com.attacker.tld
+// does not pass for youtube.com
+func ValidateHostURL(rawURL string, allowedHosts []string) bool {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.User != nil {
+		return false
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return false
+	}
+
+	for _, domain := range allowedHosts {
+		cleanDomain := strings.ToLower(strings.TrimSpace(domain))
+		if len(cleanDomain) == 0 {
+			continue
+		}
+		if host == cleanDomain || strings.HasSuffix(host, "."+cleanDomain) {
 			return true
 		}
 	}
@@ -54,6 +113,40 @@ func SanitizeFilename(filename string) string {
 	return result
 }
 
+// ValidateFilenameOverride checks a client-chosen output filename and returns it sanitized
+// Empty names, names made only of dots and anything that looks like a path are rejected
+func ValidateFilenameOverride(filename string) (string, bool) {
+	filename = strings.TrimSpace(filename)
+	if filename == "" || strings.Trim(filename, ".") == "" {
+		return "", false
+	}
+	if strings.ContainsAny(filename, "/\\") {
+		return "", false
+	}
+	for _, r := range filename {
+		if r < 0x20 || r == 0x7f {
+			return "", false
+		}
+	}
+	return SanitizeFilename(filename), true
+}
+
+// ForceExtension replaces the extension of filename with ext (including the dot)
+// A name whose extension already matches, ignoring case, is returned unchanged
+func ForceExtension(filename string, ext string) string {
+	if ext == "" {
+		return filename
+	}
+	current := ""
+	if lastDot := strings.LastIndex(filename, "."); lastDot > 0 {
+		current = filename[lastDot:]
+	}
+	if strings.EqualFold(current, ext) {
+		return filename
+	}
+	return strings.TrimSuffix(filename, current) + ext
+}
+
 // TruncateFilename truncates filename to max length while preserving extension
 // Uses rune-level truncation to properly handle UTF-8 multi-byte characters
 func TruncateFilename(filename string, maxLen int) string {
@@ -87,3 +180,32 @@ func TruncateFilename(filename string, maxLen int) string {
 	baseName := string(runes[:availableLen])
 	return baseName + ext
 }
+
+// TruncateFilenameBytes truncates filename to at most maxBytes bytes while preserving extension
+// Cuts only at rune boundaries so UTF-8 characters are never split
+func TruncateFilenameBytes(filename string, maxBytes int) string {
+	if len(filename) <= maxBytes {
+		return filename
+	}
+
+	ext := ""
+	if lastDot := strings.LastIndex(filename, "."); lastDot != -1 && len(filename)-lastDot < maxBytes {
+		ext = filename[lastDot:]
+	}
+	base := filename[:len(filename)-len(ext)]
+
+	// Drop whole runes from the end of the base name until it fits
+	available := maxBytes - len(ext)
+	cut := 0
+	for i := range base {
+		if i > available {
+			break
+		}
+		cut = i
+	}
+	if len(base) <= available {
+		cut = len(base)
+	}
+
+	return base[:cut] + ext
+}
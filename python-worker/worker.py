@@ -12,7 +12,9 @@ import json
 from functools import wraps
 import traceback
 from datetime import datetime
+from urllib.parse import urlparse
 import subprocess
+import threading
 
 # Initialize Flask app
 app = Flask(__name__)
@@ -37,12 +39,38 @@ DOWNLOAD_DIR = os.getenv('DOWNLOAD_DIR', './downloads')
 MAX_VIDEO_SIZE_MB = int(os.getenv('MAX_VIDEO_SIZE_MB', 300))
 ALLOWED_DOMAINS = os.getenv('ALLOWED_DOMAINS', 'youtube.com,youtu.be,vimeo.com,facebook.com,m.facebook.com,fb.watch,tiktok.com,instagram.com,twitter.com,x.com').split(',')
 MAX_FILENAME_LENGTH = int(os.getenv('MAX_FILENAME_LENGTH', 200))
+COOKIES_DIR = os.getenv('COOKIES_DIR', './cookies')
+
+# Highest request body version this worker understands; newer fields are ignored
+REQUEST_VERSION = 1
+
+# Containers that can carry embedded metadata tags / a cover art thumbnail
+METADATA_CONTAINERS = {'mp4', 'm4a', 'mov', 'mkv', 'mka', 'webm', 'mp3', 'ogg', 'opus', 'flac'}
+COVER_ART_CONTAINERS = {'mp4', 'm4a', 'mov', 'mkv', 'mka', 'mp3', 'ogg', 'opus', 'flac'}
+
+# Encoders for each transcode video codec: (video encoder, audio encoder, container)
+TRANSCODE_ENCODERS = {
+    'h264': ('libx264', 'aac', 'mp4'),
+    'h265': ('libx265', 'aac', 'mp4'),
+    'vp9': ('libvpx-vp9', 'libopus', 'webm'),
+}
+
+# Phase of each download that asked for a transcode, keyed by the backend's task_id
+# Polled through /api/task/<task_id> so the backend can report the transcoding phase
+TASK_PHASES = {}
+TASK_PHASES_LOCK = threading.Lock()
 
 # Ensure download directory exists
 os.makedirs(DOWNLOAD_DIR, exist_ok=True)
 os.makedirs('./log', exist_ok=True)
 
 
+def check_request_version(data):
+    """Warn when the backend sends a newer request body than this worker understands"""
+    if data and int(data.get('version') or 1) > REQUEST_VERSION:
+        logger.warning(f"Request version {data.get('version')} is newer than supported version {REQUEST_VERSION}, unknown fields are ignored")
+
+
 def error_handler(f):
     """Decorator for handling errors in API endpoints"""
     @wraps(f)
@@ -92,6 +120,24 @@ def truncate_filename(filename, max_length):
     return base_name[:available_len] + ext
 
 
+def find_cookie_file(video_url):
+    """
+    Find the cookie jar for a URL uploaded via the backend admin API
+    Tries the host and each parent domain (m.facebook.com -> facebook.com), then 'default'
+    """
+    host = (urlparse(video_url).hostname or '').lower()
+    if host.startswith('www.'):
+        host = host[4:]
+    
+    parts = host.split('.')
+    candidates = ['.'.join(parts[i:]) for i in range(len(parts) - 1)] + ['default']
+    for profile in candidates:
+        path = os.path.join(COOKIES_DIR, f"{profile}.txt")
+        if os.path.isfile(path):
+            return path
+    return None
+
+
 def get_ydl_options(video_url):
     """Get yt-dlp options based on video source"""
     user_agent = 'Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36'
@@ -171,6 +217,11 @@ def get_ydl_options(video_url):
             'format_sort': ['res', 'fps']
         })
     
+    # Cookie jars are read on every request so uploads apply to new downloads immediately
+    cookie_file = find_cookie_file(video_url)
+    if cookie_file:
+        base_options['cookiefile'] = cookie_file
+    
     return base_options
 
 
@@ -184,11 +235,85 @@ def health_check():
     }), 200
 
 
+def collect_formats(info):
+    """Collect the downloadable formats of an extracted video"""
+    formats = []
+    if 'formats' in info:
+        for fmt in info['formats']:
+            # Skip storyboard and image formats
+            ext = fmt.get('ext', '')
+            if ext in ('mhtml', 'jpg', 'jpeg', 'png', 'gif', 'webp'):
+                continue
+            
+            # Get video/audio info
+            vcodec = fmt.get('vcodec', 'none')
+            acodec = fmt.get('acodec', 'none')
+            
+            # Skip if only has unknown codecs and no extension  
+            if not ext or (vcodec == 'none' and acodec == 'none'):
+                continue
+            
+            format_info = {
+                'format_id': fmt.get('format_id', ''),
+                'ext': ext,
+                'resolution': fmt.get('resolution', 'unknown'),
+                'vcodec': vcodec,
+                'acodec': acodec,
+                'filesize': fmt.get('filesize', 0),
+                'fps': fmt.get('fps', 0),
+                'tbr': fmt.get('tbr') or 0,
+                'format': fmt.get('format', ''),
+                'protocol': fmt.get('protocol', ''),
+                'manifest_url': fmt.get('manifest_url') or '',
+                'url': fmt.get('url') or '',
+                'http_headers': fmt.get('http_headers') or {},
+                'geo_restricted': bool(fmt.get('geo_restricted') or info.get('geo_restricted')),
+                # yt-dlp reports True, False or 'maybe'; anything but False can't be downloaded
+                'has_drm': bool(fmt.get('has_drm')),
+            }
+            formats.append(format_info)
+    return formats
+
+
+def build_info_response(info, video_url, formats, warnings):
+    """Build the /api/info response body of an extracted video"""
+    return {
+        'id': info.get('id', ''),
+        # Without formats an empty title tells the backend nothing usable was extracted
+        'title': info.get('title') or ('' if warnings else 'Unknown'),
+        'duration': info.get('duration', 0),
+        'thumbnail': info.get('thumbnail', ''),
+        'thumbnails': [
+            {
+                'url': thumb.get('url'),
+                'width': int(thumb.get('width') or 0),
+                'height': int(thumb.get('height') or 0),
+                'preference': int(thumb.get('preference') or 0),
+            }
+            for thumb in (info.get('thumbnails') or [])
+            if thumb.get('url')
+        ],
+        'uploader': info.get('uploader', 'Unknown'),
+        'extractor': info.get('extractor') or '',
+        'extractor_key': info.get('extractor_key') or '',
+        'url': video_url,
+        'formats': formats,
+        'is_live': bool(info.get('is_live')),
+        'description': info.get('description', ''),
+        'tags': info.get('tags') or [],
+        'view_count': info.get('view_count'),
+        'like_count': info.get('like_count'),
+        'upload_date': info.get('upload_date', ''),
+        'warnings': warnings,
+    }
+
+
 @app.route('/api/info', methods=['POST'])
 @error_handler
 def get_video_info():
     """Get video information from URL"""
     data = request.get_json()
+    check_request_version(data)
     
     if not data or 'url' not in data:
         return jsonify({
@@ -210,75 +335,87 @@ def get_video_info():
     
     logger.info(f"Fetching info for URL: {video_url}")
     
+    # Playlists are only extracted up to the backend's entry cap
+    max_entries = int(data.get('max_entries') or 0)
+    
     try:
+        # Problems that still leave basic metadata usable; reported back as warnings
+        warnings = []
+        
         ydl_opts = get_ydl_options(video_url)
-        with yt_dlp.YoutubeDL(ydl_opts) as ydl:
-            info = ydl.extract_info(video_url, download=False)
-            
-            # Process formats
-            formats = []
-            if 'formats' in info:
-                for fmt in info['formats']:
-                    # Skip storyboard and image formats
-                    ext = fmt.get('ext', '')
-                    if ext in ('mhtml', 'jpg', 'jpeg', 'png', 'gif', 'webp'):
-                        continue
-                    
-                    # Get video/audio info
-                    vcodec = fmt.get('vcodec', 'none')
-                    acodec = fmt.get('acodec', 'none')
-                    
-                    # Skip if only has unknown codecs and no extension  
-                    if not ext or (vcodec == 'none' and acodec == 'none'):
-                        continue
-                    
-                    format_info = {
-                        'format_id': fmt.get('format_id', ''),
-                        'ext': ext,
-                        'resolution': fmt.get('resolution', 'unknown'),
-                        'vcodec': vcodec,
-                        'acodec': acodec,
-                        'filesize': fmt.get('filesize', 0),
-                        'fps': fmt.get('fps', 0),
-                        'format': fmt.get('format', ''),
-                    }
-                    formats.append(format_info)
-            
-            # If no formats found, try to fetch them again with different options  
-            if not formats:
-                logger.warning(f"No formats found for {video_url}, retrying with different options...")
-                retry_opts = get_ydl_options(video_url)
-                retry_opts['skip_unavailable_fragments'] = False
-                with yt_dlp.YoutubeDL(retry_opts) as ydl:
-                    info = ydl.extract_info(video_url, download=False)
-                    if 'formats' in info:
-                        for fmt in info['formats']:
-                            if fmt.get('ext') and fmt.get('ext') not in ('mhtml', 'jpg', 'jpeg', 'png', 'gif', 'webp'):
-                                format_info = {
-                                    'format_id': fmt.get('format_id', ''),
-                                    'ext': fmt.get('ext', ''),
-                                    'resolution': fmt.get('resolution', 'unknown'),
-                                    'vcodec': fmt.get('vcodec', 'none'),
-                                    'acodec': fmt.get('acodec', 'none'),
-                                    'filesize': fmt.get('filesize', 0),
-                                    'fps': fmt.get('fps', 0),
-                                    'format': fmt.get('format', ''),
-                                }
-                                formats.append(format_info)
-            
-            response = {
-                'id': info.get('id', ''),
-                'title': info.get('title', 'Unknown'),
-                'duration': info.get('duration', 0),
-                'thumbnail': info.get('thumbnail', ''),
-                'uploader': info.get('uploader', 'Unknown'),
-                'url': video_url,
-                'formats': formats
-            }
-            
-            logger.info(f"Successfully fetched info. Formats: {len(formats)}")
+        if max_entries > 0:
+            ydl_opts['playlistend'] = max_entries
+        try:
+            with yt_dlp.YoutubeDL(ydl_opts) as ydl:
+                info = ydl.extract_info(video_url, download=False)
+        except yt_dlp.utils.DownloadError as e:
+            # Retry for metadata only; yt-dlp raises when no format can be listed
+            logger.warning(f"Extraction failed for {video_url}, retrying without formats: {str(e)}")
+            metadata_opts = get_ydl_options(video_url)
+            metadata_opts['ignore_no_formats_error'] = True
+            if max_entries > 0:
+                metadata_opts['playlistend'] = max_entries
+            with yt_dlp.YoutubeDL(metadata_opts) as ydl:
+                info = ydl.extract_info(video_url, download=False)
+            info['formats'] = []
+            warnings.append(f"format extraction failed: {str(e)}")
+        
+        # Playlist URLs list their videos as entries, each with its own formats
+        if info.get('_type') == 'playlist' or 'entries' in info:
+            entries = [entry for entry in (info.get('entries') or []) if entry]
+            if max_entries > 0:
+                entries = entries[:max_entries]
+            response = build_info_response(info, video_url, [], warnings)
+            response['entries'] = [
+                build_info_response(entry, entry.get('webpage_url') or entry.get('url') or '', collect_formats(entry), [])
+                for entry in entries
+            ]
+            response['playlist_count'] = info.get('playlist_count') or len(entries)
+            logger.info(f"Successfully fetched playlist info. Entries: {len(entries)}")
             return jsonify(response), 200
-            
+        
+        formats = collect_formats(info)
+        
+        # If no formats found, try to fetch them again with different options  
+        if not formats and not warnings:
+            logger.warning(f"No formats found for {video_url}, retrying with different options...")
+            retry_opts = get_ydl_options(video_url)
+            retry_opts['skip_unavailable_fragments'] = False
+            try:
+                with yt_dlp.YoutubeDL(retry_opts) as ydl:
+                    retry_info = ydl.extract_info(video_url, download=False)
+            except Exception as e:
+                retry_info = {}
+                warnings.append(f"format extraction failed: {str(e)}")
+            if retry_info:
+                info = retry_info
+                if 'formats' in info:
+                    for fmt in info['formats']:
+                        if fmt.get('ext') and fmt.get('ext') not in ('mhtml', 'jpg', 'jpeg', 'png', 'gif', 'webp'):
+                            format_info = {
+                                'format_id': fmt.get('format_id', ''),
+                                'ext': fmt.get('ext', ''),
+                                'resolution': fmt.get('resolution', 'unknown'),
+                                'vcodec': fmt.get('vcodec', 'none'),
+                                'acodec': fmt.get('acodec', 'none'),
+                                'filesize': fmt.get('filesize', 0),
+                                'fps': fmt.get('fps', 0),
+                                'tbr': fmt.get('tbr') or 0,
+                                'format': fmt.get('format', ''),
+                                'protocol': fmt.get('protocol', ''),
+                                'manifest_url': fmt.get('manifest_url') or '',
+                                'url': fmt.get('url') or '',
+                                'http_headers': fmt.get('http_headers') or {},
+                                'geo_restricted': bool(fmt.get('geo_restricted') or info.get('geo_restricted')),
+                                'has_drm': bool(fmt.get('has_drm')),
+                            }
+                            formats.append(format_info)
+        
+        response = build_info_response(info, video_url, formats, warnings)
+        
+        logger.info(f"Successfully fetched info. Formats: {len(formats)}, warnings: {len(warnings)}")
+        return jsonify(response), 200
+        
     except Exception as e:
         logger.error(f"Failed to fetch video info: {str(e)}")
         return jsonify({
@@ -288,6 +425,48 @@ def get_video_info():
         }), 400
 
 
+def set_task_phase(task_id, phase):
+    """Record the phase of a download's task; a phase of None forgets the task"""
+    if not task_id:
+        return
+    with TASK_PHASES_LOCK:
+        if phase is None:
+            TASK_PHASES.pop(task_id, None)
+        else:
+            TASK_PHASES[task_id] = phase
+
+
+def transcode_file(filepath, spec):
+    """Re-encode a downloaded file according to a transcode spec; returns the new path"""
+    video_encoder, audio_encoder, container = TRANSCODE_ENCODERS[spec['video_codec']]
+    base_path = filepath.rsplit('.', 1)[0]
+    output_path = f"{base_path}.transcoded.{container}"
+    
+    command = [
+        'ffmpeg', '-i', filepath,
+        '-map', '0:v:0', '-map', '0:a:0?',
+        '-vf', f"scale=-2:'min(ih,{int(spec['max_height'])})'",
+        '-c:v', video_encoder, '-c:a', audio_encoder,
+    ]
+    bitrate = int(spec.get('video_bitrate_kbps') or 0)
+    if bitrate > 0:
+        command += ['-b:v', f'{bitrate}k']
+    command += ['-y', output_path]
+    
+    logger.info(f"Transcoding to {spec['video_codec']} (max height {spec['max_height']}, bitrate {bitrate or 'default'})")
+    try:
+        subprocess.run(command, check=True, stdout=subprocess.DEVNULL, stderr=subprocess.DEVNULL)
+    except subprocess.CalledProcessError:
+        if os.path.exists(output_path):
+            os.remove(output_path)
+        raise
+    
+    os.remove(filepath)
+    final_path = f"{base_path}.{container}"
+    os.replace(output_path, final_path)
+    return final_path
+
+
 def get_format_with_audio(base_format_id, video_url):
     """
     Construct format string to ensure audio is included
@@ -328,11 +507,70 @@ def get_format_with_audio(base_format_id, video_url):
         return base_format_id
 
 
+@app.route('/api/resolve', methods=['POST'])
+@error_handler
+def resolve_format():
+    """Resolve the direct media URL of a format for segmented downloads"""
+    data = request.get_json()
+    check_request_version(data)
+    
+    if not data or 'url' not in data or 'format_id' not in data:
+        return jsonify({
+            'error': 'invalid_request',
+            'message': 'URL and format_id are required',
+            'code': 400
+        }), 400
+    
+    video_url = data['url']
+    format_id = data['format_id']
+    
+    if not validate_url(video_url):
+        return jsonify({
+            'error': 'invalid_domain',
+            'message': 'Domain is not allowed',
+            'code': 400
+        }), 400
+    
+    ydl_opts = get_ydl_options(video_url)
+    ydl_opts['skip_download'] = True
+    with yt_dlp.YoutubeDL(ydl_opts) as ydl:
+        info = ydl.extract_info(video_url, download=False)
+    
+    for fmt in info.get('formats') or []:
+        if fmt.get('format_id') != format_id:
+            continue
+        
+        # Only plain single files with both audio and video can be fetched as byte ranges;
+        # everything else needs merging or fragment handling in yt-dlp
+        protocol = fmt.get('protocol', '')
+        segmentable = (
+            protocol in ('http', 'https')
+            and fmt.get('acodec', 'none') != 'none'
+            and fmt.get('vcodec', 'none') != 'none'
+        )
+        
+        return jsonify({
+            'url': fmt.get('url', ''),
+            'http_headers': fmt.get('http_headers') or {},
+            'filesize': fmt.get('filesize') or fmt.get('filesize_approx') or 0,
+            'ext': fmt.get('ext', 'mp4'),
+            'title': info.get('title', 'video'),
+            'segmentable': segmentable,
+        }), 200
+    
+    return jsonify({
+        'error': 'invalid_format',
+        'message': f'Format {format_id} not found',
+        'code': 404
+    }), 404
+
+
 @app.route('/api/download', methods=['POST'])
 @error_handler
 def download_video():
     """Download video with specified format"""
     data = request.get_json()
+    check_request_version(data)
     
     if not data or 'url' not in data or 'format_id' not in data:
         return jsonify({
@@ -344,6 +582,18 @@ def download_video():
     video_url = data['url']
     format_id = data['format_id']
     quality = data.get('quality', 'Unknown')  # Get quality label from request
+    embed_metadata = bool(data.get('embed_metadata', False))
+    embed_thumbnail = embed_metadata and bool(data.get('embed_thumbnail', False))
+    transcode = data.get('transcode')
+    
+    task_id = transcode.get('task_id') if transcode else None
+    
+    if transcode and transcode.get('video_codec') not in TRANSCODE_ENCODERS:
+        return jsonify({
+            'error': 'transcode_unsupported',
+            'message': f"Video codec {transcode.get('video_codec')} is not supported for transcoding",
+            'code': 400
+        }), 400
     
     # Validate URL
     if not validate_url(video_url):
@@ -355,6 +605,7 @@ def download_video():
         }), 400
     
     logger.info(f"Starting download. URL: {video_url}, Format: {format_id}, Quality: {quality}")
+    set_task_phase(task_id, 'downloading')
     
     try:
         ydl_opts = get_ydl_options(video_url)
@@ -380,6 +631,20 @@ def download_video():
         except:
             pass
         
+        # Reject embedding for containers that cannot carry tags or cover art
+        if embed_metadata and target_ext.lower() not in METADATA_CONTAINERS:
+            return jsonify({
+                'error': 'metadata_unsupported',
+                'message': f'Embedding metadata is not supported for .{target_ext} files',
+                'code': 400
+            }), 400
+        if embed_thumbnail and target_ext.lower() not in COVER_ART_CONTAINERS:
+            return jsonify({
+                'error': 'metadata_unsupported',
+                'message': f'Embedding a thumbnail is not supported for .{target_ext} files',
+                'code': 400
+            }), 400
+        
         # Set quality suffix for filename
         quality_suffix = f"_{quality}" if quality and quality != 'Unknown' else ""
         
@@ -391,12 +656,26 @@ def download_video():
             'postprocessors': [],
         })
         
+        if embed_metadata:
+            ydl_opts['postprocessors'].append({'key': 'FFmpegMetadata', 'add_metadata': True})
+        if embed_thumbnail:
+            ydl_opts['writethumbnail'] = True
+            ydl_opts['postprocessors'].append({'key': 'EmbedThumbnail', 'already_have_thumbnail': False})
+        
         # Download the video
         logger.debug(f"Starting yt-dlp download with format: {format_spec}")
         with yt_dlp.YoutubeDL(ydl_opts) as ydl:
             try:
                 info = ydl.extract_info(video_url, download=True)
             except Exception as format_error:
+                # A format that no longer exists is reported so the backend can pick another
+                if 'requested format is not available' in str(format_error).lower():
+                    logger.warning(f"Format {format_spec} is no longer available: {str(format_error)}")
+                    return jsonify({
+                        'error': 'format_unavailable',
+                        'message': 'The selected format is not available for this video',
+                        'code': 410
+                    }), 410
                 # If format fails (common with Facebook), try best format
                 logger.warning(f"Format {format_spec} failed, retrying with best available format: {str(format_error)}")
                 ydl_opts['format'] = 'best'  # Fallback to best available
@@ -432,6 +711,33 @@ def download_video():
             except subprocess.CalledProcessError as e:
                 logger.warning(f"Conversion failed, keeping original: {str(e)}")
         
+        # Transcode after download; the duration limit is re-checked here because
+        # the backend only knows it when the video's info was fetched first
+        if transcode:
+            max_duration = int(transcode.get('max_duration') or 0)
+            duration = info.get('duration') or 0
+            if max_duration > 0 and duration > max_duration:
+                os.remove(filepath)
+                logger.warning(f"Video too long to transcode: {duration}s")
+                return jsonify({
+                    'error': 'transcode_too_long',
+                    'message': f'Videos longer than {max_duration} seconds can\'t be transcoded',
+                    'code': 400
+                }), 400
+            set_task_phase(task_id, 'transcoding')
+            try:
+                filepath = transcode_file(filepath, transcode)
+                filename = os.path.basename(filepath)
+            except subprocess.CalledProcessError as e:
+                if os.path.exists(filepath):
+                    os.remove(filepath)
+                logger.error(f"Transcode failed: {str(e)}")
+                return jsonify({
+                    'error': 'transcode_failed',
+                    'message': 'Transcoding the downloaded file failed',
+                    'code': 400
+                }), 400
+        
         # Now handle truncation and quality suffix
         # Pre-truncate to account for quality suffix
         if quality_suffix:
@@ -483,12 +789,19 @@ def download_video():
         logger.info(f"Download completed. File: {filepath}, Size: {file_size} bytes, Sending as: {download_filename}")
         
         # Send file to Golang backend with ONLY filename (no path)
-        return send_file(
+        response = send_file(
             filepath,
             as_attachment=True,
             download_name=download_filename,  # ONLY basename, no path!
             mimetype='application/octet-stream'
         )
+        if embed_metadata:
+            response.headers['X-Metadata-Embedded'] = 'true'
+        if embed_thumbnail:
+            response.headers['X-Thumbnail-Embedded'] = 'true'
+        if transcode:
+            response.headers['X-Transcoded'] = 'true'
+        return response
             
     except Exception as e:
         logger.error(f"Download failed: {str(e)}")
@@ -505,6 +818,22 @@ def download_video():
             'message': f"Download failed: {str(e)}",
             'code': 400
         }), 400
+    finally:
+        set_task_phase(task_id, None)
+
+
+@app.route('/api/task/<task_id>', methods=['GET'])
+def task_phase(task_id):
+    """Report whether a transcoding download is still downloading or already transcoding"""
+    with TASK_PHASES_LOCK:
+        phase = TASK_PHASES.get(task_id)
+    if phase is None:
+        return jsonify({
+            'error': 'not_found',
+            'message': 'Unknown task',
+            'code': 404
+        }), 404
+    return jsonify({'task_id': task_id, 'phase': phase})
 
 
 @app.errorhandler(413)